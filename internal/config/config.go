@@ -25,6 +25,39 @@ type Config struct {
 	JWT struct {
 		Secret string `mapstructure:"JWT_SECRET"`
 	} `mapstructure:",squash"`
+	Redis struct {
+		Addr     string `mapstructure:"REDIS_ADDR"`
+		Password string `mapstructure:"REDIS_PASSWORD"`
+	} `mapstructure:",squash"`
+	Egress struct {
+		Allowlist    string `mapstructure:"EGRESS_ALLOWLIST"`
+		Denylist     string `mapstructure:"EGRESS_DENYLIST"`
+		AllowPrivate bool   `mapstructure:"EGRESS_ALLOW_PRIVATE"`
+	} `mapstructure:",squash"`
+	Integrations struct {
+		SlackAPIURL     string `mapstructure:"SLACK_API_URL"`
+		TelegramAPIURL  string `mapstructure:"TELEGRAM_API_URL"`
+		HubSpotAPIURL   string `mapstructure:"HUBSPOT_API_URL"`
+		PipedriveAPIURL string `mapstructure:"PIPEDRIVE_API_URL"`
+		AmoCRMAPIURL    string `mapstructure:"AMOCRM_API_URL"`
+	} `mapstructure:",squash"`
+	Contacts struct {
+		DisposableDomainsFile string `mapstructure:"DISPOSABLE_DOMAINS_FILE"`
+	} `mapstructure:",squash"`
+	Tracking struct {
+		BaseURL string `mapstructure:"TRACKING_BASE_URL"`
+		Secret  string `mapstructure:"TRACKING_SECRET"`
+	} `mapstructure:",squash"`
+	SMTP struct {
+		Host          string `mapstructure:"SMTP_HOST"`
+		Port          int    `mapstructure:"SMTP_PORT"`
+		TLSMode       string `mapstructure:"SMTP_TLS_MODE"`
+		AuthMechanism string `mapstructure:"SMTP_AUTH_MECHANISM"`
+		Username      string `mapstructure:"SMTP_USERNAME"`
+		Password      string `mapstructure:"SMTP_PASSWORD"`
+		FromAddress   string `mapstructure:"SMTP_FROM_ADDRESS"`
+		FromName      string `mapstructure:"SMTP_FROM_NAME"`
+	} `mapstructure:",squash"`
 }
 
 func Load() (*Config, error) {
@@ -35,6 +68,27 @@ func Load() (*Config, error) {
 	viper.SetDefault("HTTP_WRITE_TIMEOUT", "30s")
 	viper.SetDefault("HTTP_IDLE_TIMEOUT", "120s")
 	viper.SetDefault("ADMIN_ENABLED", true)
+	viper.SetDefault("REDIS_ADDR", "")
+	viper.SetDefault("REDIS_PASSWORD", "")
+	viper.SetDefault("EGRESS_ALLOWLIST", "")
+	viper.SetDefault("EGRESS_DENYLIST", "")
+	viper.SetDefault("EGRESS_ALLOW_PRIVATE", false)
+	viper.SetDefault("SLACK_API_URL", "https://slack.com/api")
+	viper.SetDefault("TELEGRAM_API_URL", "https://api.telegram.org")
+	viper.SetDefault("HUBSPOT_API_URL", "")
+	viper.SetDefault("PIPEDRIVE_API_URL", "")
+	viper.SetDefault("AMOCRM_API_URL", "")
+	viper.SetDefault("DISPOSABLE_DOMAINS_FILE", "")
+	viper.SetDefault("TRACKING_BASE_URL", "")
+	viper.SetDefault("TRACKING_SECRET", "")
+	viper.SetDefault("SMTP_HOST", "")
+	viper.SetDefault("SMTP_PORT", 0)
+	viper.SetDefault("SMTP_TLS_MODE", "starttls")
+	viper.SetDefault("SMTP_AUTH_MECHANISM", "")
+	viper.SetDefault("SMTP_USERNAME", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+	viper.SetDefault("SMTP_FROM_ADDRESS", "")
+	viper.SetDefault("SMTP_FROM_NAME", "s4s")
 
 	// Load .env file if it exists
 	viper.SetConfigName(".env")
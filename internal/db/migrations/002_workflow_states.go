@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var WorkflowStates = []*gormigrate.Migration{
+	{
+		ID: "20261019_002_workflow_states",
+		Migrate: func(db *gorm.DB) error {
+			type WorkflowState struct {
+				ID         string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				WorkflowID string `gorm:"type:uuid;not null;uniqueIndex:idx_workflow_states_key"`
+				Key        string `gorm:"size:255;not null;uniqueIndex:idx_workflow_states_key"`
+				Value      string `gorm:"type:jsonb;not null"`
+				UpdatedAt  time.Time
+			}
+			return db.AutoMigrate(&WorkflowState{})
+		},
+		Rollback: func(db *gorm.DB) error {
+			return db.Migrator().DropTable("workflow_states")
+		},
+	},
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var LeadRuleSets = []*gormigrate.Migration{
+	{
+		ID: "20261019_003_lead_rule_sets",
+		Migrate: func(db *gorm.DB) error {
+			type LeadRuleSet struct {
+				ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				UserID      string `gorm:"type:uuid;not null;index"`
+				Name        string `gorm:"not null"`
+				Description string
+				Rules       string `gorm:"type:jsonb;not null"`
+				Grades      string `gorm:"type:jsonb;not null;default:'[]'"`
+				CreatedAt   time.Time
+				UpdatedAt   time.Time
+				DeletedAt   gorm.DeletedAt `gorm:"index"`
+			}
+			return db.AutoMigrate(&LeadRuleSet{})
+		},
+		Rollback: func(db *gorm.DB) error {
+			return db.Migrator().DropTable("lead_rule_sets")
+		},
+	},
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var Experiments = []*gormigrate.Migration{
+	{
+		ID: "20261019_004_experiments",
+		Migrate: func(db *gorm.DB) error {
+			type ExperimentAssignment struct {
+				ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				WorkflowID  string `gorm:"type:uuid;not null;uniqueIndex:idx_experiment_assignments_key"`
+				NodeID      string `gorm:"size:255;not null;uniqueIndex:idx_experiment_assignments_key"`
+				KeyHash     string `gorm:"size:64;not null;uniqueIndex:idx_experiment_assignments_key"`
+				Variant     string `gorm:"size:255;not null"`
+				ExecutionID string `gorm:"type:uuid"`
+				CreatedAt   time.Time
+			}
+			type ExperimentOutcome struct {
+				ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				WorkflowID  string `gorm:"type:uuid;not null;uniqueIndex:idx_experiment_outcomes_key"`
+				NodeID      string `gorm:"size:255;not null;uniqueIndex:idx_experiment_outcomes_key"`
+				KeyHash     string `gorm:"size:64;not null;uniqueIndex:idx_experiment_outcomes_key"`
+				Goal        string `gorm:"size:255;not null;uniqueIndex:idx_experiment_outcomes_key"`
+				Variant     string `gorm:"size:255;not null"`
+				ExecutionID string `gorm:"type:uuid"`
+				CreatedAt   time.Time
+			}
+			return db.AutoMigrate(&ExperimentAssignment{}, &ExperimentOutcome{})
+		},
+		Rollback: func(db *gorm.DB) error {
+			return db.Migrator().DropTable("experiment_outcomes", "experiment_assignments")
+		},
+	},
+}
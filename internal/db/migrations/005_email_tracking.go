@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var EmailTracking = []*gormigrate.Migration{
+	{
+		ID: "20261019_005_email_tracking",
+		Migrate: func(db *gorm.DB) error {
+			type TrackedEmail struct {
+				ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				UserID      string `gorm:"type:uuid;not null;index"`
+				WorkflowID  string `gorm:"type:uuid;index"`
+				ExecutionID string `gorm:"type:uuid;index"`
+				NodeID      string `gorm:"size:255"`
+				MessageID   string `gorm:"size:998"`
+				Recipients  string `gorm:"type:jsonb;not null;default:'[]'"`
+				Subject     string
+				CreatedAt   time.Time
+			}
+			type EmailEvent struct {
+				ID             string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				TrackedEmailID string `gorm:"type:uuid;not null;index"`
+				UserID         string `gorm:"type:uuid;not null;index"`
+				WorkflowID     string `gorm:"type:uuid"`
+				ExecutionID    string `gorm:"type:uuid;index"`
+				MessageID      string `gorm:"size:998"`
+				Type           string `gorm:"size:20;not null"`
+				URL            string
+				IP             string `gorm:"size:64"`
+				UserAgent      string
+				CreatedAt      time.Time
+			}
+			type Suppression struct {
+				ID        string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				UserID    string `gorm:"type:uuid;not null;uniqueIndex:idx_suppressions_email"`
+				Email     string `gorm:"size:320;not null;uniqueIndex:idx_suppressions_email"`
+				Reason    string `gorm:"size:50;not null"`
+				Source    string `gorm:"size:255"`
+				CreatedAt time.Time
+			}
+			return db.AutoMigrate(&TrackedEmail{}, &EmailEvent{}, &Suppression{})
+		},
+		Rollback: func(db *gorm.DB) error {
+			return db.Migrator().DropTable("suppressions", "email_events", "tracked_emails")
+		},
+	},
+}
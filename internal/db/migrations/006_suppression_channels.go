@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var SuppressionChannels = []*gormigrate.Migration{
+	{
+		ID: "20261019_006_suppression_channels",
+		Migrate: func(db *gorm.DB) error {
+			type Suppression struct {
+				ID        string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				UserID    string `gorm:"type:uuid;not null;uniqueIndex:idx_suppressions_address"`
+				Channel   string `gorm:"size:20;not null;default:'email';uniqueIndex:idx_suppressions_address"`
+				Address   string `gorm:"size:320;not null;uniqueIndex:idx_suppressions_address"`
+				Reason    string `gorm:"size:50;not null"`
+				Source    string `gorm:"size:255"`
+				Note      string
+				CreatedAt time.Time
+				UpdatedAt time.Time
+			}
+			m := db.Migrator()
+			if err := m.DropIndex(&Suppression{}, "idx_suppressions_email"); err != nil {
+				return err
+			}
+			if err := m.RenameColumn(&Suppression{}, "email", "address"); err != nil {
+				return err
+			}
+			return db.AutoMigrate(&Suppression{})
+		},
+		Rollback: func(db *gorm.DB) error {
+			type Suppression struct {
+				ID     string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				UserID string `gorm:"type:uuid;not null;uniqueIndex:idx_suppressions_email"`
+				Email  string `gorm:"size:320;not null;uniqueIndex:idx_suppressions_email"`
+			}
+			m := db.Migrator()
+			if err := db.Where("channel <> ?", "email").Delete(&Suppression{}).Error; err != nil {
+				return err
+			}
+			if err := m.DropIndex(&Suppression{}, "idx_suppressions_address"); err != nil {
+				return err
+			}
+			for _, column := range []string{"channel", "note", "updated_at"} {
+				if err := m.DropColumn(&Suppression{}, column); err != nil {
+					return err
+				}
+			}
+			if err := m.RenameColumn(&Suppression{}, "address", "email"); err != nil {
+				return err
+			}
+			return m.CreateIndex(&Suppression{}, "idx_suppressions_email")
+		},
+	},
+}
@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var Contacts = []*gormigrate.Migration{
+	{
+		ID: "20261019_007_contacts",
+		Migrate: func(db *gorm.DB) error {
+			type Contact struct {
+				ID        string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				UserID    string `gorm:"type:uuid;not null;index"`
+				Email     string `gorm:"size:320;not null;default:''"`
+				Phone     string `gorm:"size:32;not null;default:''"`
+				FirstName string `gorm:"size:255"`
+				LastName  string `gorm:"size:255"`
+				Company   string `gorm:"size:255"`
+				Title     string `gorm:"size:255"`
+				Owner     string `gorm:"size:320;index"`
+				Stage     string `gorm:"size:100;index"`
+				Source    string `gorm:"size:100"`
+				Tags      string `gorm:"type:jsonb;not null;default:'[]'"`
+				Fields    string `gorm:"type:jsonb;not null;default:'{}'"`
+				CreatedAt time.Time
+				UpdatedAt time.Time
+				DeletedAt gorm.DeletedAt `gorm:"index"`
+			}
+			if err := db.AutoMigrate(&Contact{}); err != nil {
+				return err
+			}
+			for _, statement := range []string{
+				// dedupe keys; empty values and deleted contacts do not count
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_email ON contacts (user_id, email) WHERE email <> '' AND deleted_at IS NULL`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_phone ON contacts (user_id, phone) WHERE phone <> '' AND deleted_at IS NULL`,
+				`CREATE INDEX IF NOT EXISTS idx_contacts_tags ON contacts USING GIN (tags)`,
+				// full-text search over names, company, email, phone and custom field values
+				`ALTER TABLE contacts ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+					to_tsvector('simple',
+						coalesce(first_name, '') || ' ' || coalesce(last_name, '') || ' ' ||
+						coalesce(company, '') || ' ' || coalesce(title, '') || ' ' ||
+						replace(replace(email, '@', ' '), '.', ' ') || ' ' || email || ' ' || phone || ' ' ||
+						jsonb_path_query_array(fields, 'strict $.*')::text)
+				) STORED`,
+				`CREATE INDEX IF NOT EXISTS idx_contacts_search ON contacts USING GIN (search)`,
+			} {
+				if err := db.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(db *gorm.DB) error {
+			return db.Migrator().DropTable("contacts")
+		},
+	},
+}
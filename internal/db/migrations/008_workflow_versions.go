@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var WorkflowVersions = []*gormigrate.Migration{
+	{
+		ID: "20261019_008_workflow_versions",
+		Migrate: func(db *gorm.DB) error {
+			type WorkflowVersion struct {
+				ID           string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+				WorkflowID   string `gorm:"type:uuid;not null;uniqueIndex:idx_workflow_versions_number"`
+				Version      int    `gorm:"not null;uniqueIndex:idx_workflow_versions_number"`
+				Name         string `gorm:"size:255;not null"`
+				JSON         string `gorm:"type:text;not null"`
+				MaxTimeout   int
+				RetryCount   int
+				RetryDelay   int
+				AuthorID     string `gorm:"type:uuid"`
+				Message      string `gorm:"type:text"`
+				RestoredFrom *int
+				CreatedAt    time.Time
+			}
+			type Workflow struct {
+				Version int `gorm:"default:1;not null"`
+			}
+			type Execution struct {
+				WorkflowVersion int
+			}
+			// workflows saved before this migration get their first version
+			// row from their current state on the next save
+			return db.AutoMigrate(&WorkflowVersion{}, &Workflow{}, &Execution{})
+		},
+		Rollback: func(db *gorm.DB) error {
+			type Execution struct{}
+			if err := db.Migrator().DropColumn(&Execution{}, "workflow_version"); err != nil {
+				return err
+			}
+			return db.Migrator().DropTable("workflow_versions")
+		},
+	},
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var WorkflowPublishing = []*gormigrate.Migration{
+	{
+		ID: "20261019_009_workflow_publishing",
+		Migrate: func(db *gorm.DB) error {
+			type Workflow struct {
+				PublishedJSON    string `gorm:"type:text"`
+				PublishedVersion int    `gorm:"default:0;not null"`
+				PublishedAt      *time.Time
+			}
+			if err := db.AutoMigrate(&Workflow{}); err != nil {
+				return err
+			}
+			if !db.Migrator().HasColumn(&Workflow{}, "json") {
+				return nil
+			}
+			// existing workflows keep running what they run today
+			return db.Exec(`UPDATE workflows SET published_json = json, published_version = version, published_at = updated_at WHERE published_version = 0`).Error
+		},
+		Rollback: func(db *gorm.DB) error {
+			type Workflow struct{}
+			for _, column := range []string{"published_json", "published_version", "published_at"} {
+				if err := db.Migrator().DropColumn(&Workflow{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
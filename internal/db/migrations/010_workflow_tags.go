@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var WorkflowTags = []*gormigrate.Migration{
+	{
+		ID: "20261019_010_workflow_tags",
+		Migrate: func(db *gorm.DB) error {
+			type Workflow struct {
+				Tags string `gorm:"type:jsonb;default:'[]'"`
+			}
+			return db.AutoMigrate(&Workflow{})
+		},
+		Rollback: func(db *gorm.DB) error {
+			// the column predates this migration in the initial schema
+			return nil
+		},
+	},
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var TemplateParameters = []*gormigrate.Migration{
+	{
+		ID: "20261019_011_template_parameters",
+		Migrate: func(db *gorm.DB) error {
+			type Template struct {
+				Description string `gorm:"type:text"`
+				Tags        string `gorm:"type:jsonb;default:'[]'"`
+				JSON        string `gorm:"type:text"`
+				Parameters  string `gorm:"type:jsonb;default:'[]'"`
+			}
+			if err := db.AutoMigrate(&Template{}); err != nil {
+				return err
+			}
+			// the initial schema kept the graph in config; templates are now
+			// stored with it in json, so the old required columns are relaxed
+			for _, column := range []string{"config", "version"} {
+				if !db.Migrator().HasColumn(&Template{}, column) {
+					continue
+				}
+				if err := db.Exec(`ALTER TABLE templates ALTER COLUMN ` + column + ` DROP NOT NULL`).Error; err != nil {
+					return err
+				}
+			}
+			if db.Migrator().HasColumn(&Template{}, "config") {
+				return db.Exec(`UPDATE templates SET json = config::text WHERE json IS NULL AND config IS NOT NULL`).Error
+			}
+			return nil
+		},
+		Rollback: func(db *gorm.DB) error {
+			type Template struct{}
+			for _, column := range []string{"json", "parameters"} {
+				if err := db.Migrator().DropColumn(&Template{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
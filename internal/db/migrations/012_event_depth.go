@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+var EventDepth = []*gormigrate.Migration{
+	{
+		ID: "20261019_012_event_depth",
+		Migrate: func(db *gorm.DB) error {
+			type TrackedEmail struct {
+				EventDepth int `gorm:"not null;default:0"`
+			}
+			return db.AutoMigrate(&TrackedEmail{})
+		},
+		Rollback: func(db *gorm.DB) error {
+			type TrackedEmail struct{}
+			return db.Migrator().DropColumn(&TrackedEmail{}, "event_depth")
+		},
+	},
+}
@@ -18,6 +18,17 @@ func RunMigrations(db *gorm.DB) error {
 	})
 
 	migrationsList := append([]*gormigrate.Migration{}, migrations.InitialSchema...)
+	migrationsList = append(migrationsList, migrations.WorkflowStates...)
+	migrationsList = append(migrationsList, migrations.LeadRuleSets...)
+	migrationsList = append(migrationsList, migrations.Experiments...)
+	migrationsList = append(migrationsList, migrations.EmailTracking...)
+	migrationsList = append(migrationsList, migrations.SuppressionChannels...)
+	migrationsList = append(migrationsList, migrations.Contacts...)
+	migrationsList = append(migrationsList, migrations.WorkflowVersions...)
+	migrationsList = append(migrationsList, migrations.WorkflowPublishing...)
+	migrationsList = append(migrationsList, migrations.WorkflowTags...)
+	migrationsList = append(migrationsList, migrations.TemplateParameters...)
+	migrationsList = append(migrationsList, migrations.EventDepth...)
 	//migrationsList = append(migrationsList, migrations.AdminTables)
 	m = gormigrate.New(db, gormigrate.DefaultOptions, migrationsList)
 
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"s4s-backend/internal/modules/workflow/dto"
+	"s4s-backend/internal/modules/workflow/services"
+)
+
+// maxBundleSize bounds the size of an import request
+const maxBundleSize = 10 << 20
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type BundleHandler struct {
+	bundleService *services.BundleService
+}
+
+func NewBundleHandler(bundleService *services.BundleService) *BundleHandler {
+	return &BundleHandler{bundleService: bundleService}
+}
+
+// ExportWorkflow downloads the workflow and the workflows it refers to as a
+// bundle; connections are replaced by placeholders
+func (h *BundleHandler) ExportWorkflow(c *gin.Context) {
+	bundle, err := h.bundleService.Export(c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		if errors.Is(err, services.ErrWorkflowNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Workflow not found", "code": 404})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "code": 500})
+		return
+	}
+
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(bundle.Workflows[0].Name, "-"), "-")
+	if name == "" {
+		name = "workflow"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.s4s.json"`, name))
+	c.JSON(http.StatusOK, bundle)
+}
+
+// ImportWorkflows creates the workflows of a bundle or an n8n export. Until
+// every placeholder is bound the response is 422 with the unbound
+// placeholders and the caller's connections that fit them.
+func (h *BundleHandler) ImportWorkflows(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBundleSize)
+
+	var req dto.ImportBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error(), "code": 400})
+		return
+	}
+
+	result, err := h.bundleService.Import(c.GetString("userID"), &req)
+	if err != nil {
+		var unbound *services.BindingsRequiredError
+		var invalid *services.ValidationError
+		switch {
+		case errors.As(err, &unbound):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Bind the placeholders to your connections", "code": 422, "placeholders": unbound.Unbound})
+		case errors.As(err, &invalid):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Bundle is invalid", "code": 422, "errors": invalid.Problems})
+		case errors.Is(err, services.ErrUnsupportedBundle), errors.Is(err, services.ErrNotN8nWorkflow):
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error(), "code": 400})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "code": 500})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"s4s-backend/internal/modules/workflow/services"
+)
+
+type ExperimentHandler struct {
+	experimentService *services.ExperimentService
+}
+
+func NewExperimentHandler(experimentService *services.ExperimentService) *ExperimentHandler {
+	return &ExperimentHandler{experimentService: experimentService}
+}
+
+func (h *ExperimentHandler) GetExperiments(c *gin.Context) {
+	userID := c.GetString("userID")
+	id := c.Param("id")
+
+	reports, err := h.experimentService.Report(id, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrWorkflowNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Workflow not found", "code": 404})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "code": 500})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reports})
+}
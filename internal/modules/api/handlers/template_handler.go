@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"s4s-backend/internal/modules/workflow/dto"
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/services"
+)
+
+type TemplateHandler struct {
+	templateService *services.TemplateService
+}
+
+func NewTemplateHandler(templateService *services.TemplateService) *TemplateHandler {
+	return &TemplateHandler{templateService: templateService}
+}
+
+// ListTemplates filters by ?category= and ?tags= (comma separated; a
+// template must carry all of them)
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+	var tags []string
+	for _, tag := range strings.Split(c.Query("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	templates, err := h.templateService.ListTemplates(c.Query("category"), tags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "code": 500})
+		return
+	}
+	if templates == nil {
+		templates = []models.Template{}
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+func (h *TemplateHandler) GetTemplate(c *gin.Context) {
+	template, err := h.templateService.GetTemplate(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Template not found", "code": 404})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// UseTemplate creates a workflow for the caller from the template with the
+// supplied parameter values
+func (h *TemplateHandler) UseTemplate(c *gin.Context) {
+	// templates without parameters need no body
+	var req dto.UseTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error(), "code": 400})
+		return
+	}
+
+	workflow, err := h.templateService.UseTemplate(c.Param("id"), c.GetString("userID"), &req)
+	if err != nil {
+		var invalid *services.ValidationError
+		switch {
+		case errors.Is(err, services.ErrTemplateNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"message": "Template not found", "code": 404})
+		case errors.As(err, &invalid):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Parameters are invalid", "code": 422, "errors": invalid.Problems})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "code": 500})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, workflow)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"s4s-backend/internal/modules/workflow/dto"
+	"s4s-backend/internal/modules/workflow/services"
+)
+
+type VersionHandler struct {
+	workflowService *services.WorkflowService
+}
+
+func NewVersionHandler(workflowService *services.WorkflowService) *VersionHandler {
+	return &VersionHandler{workflowService: workflowService}
+}
+
+func (h *VersionHandler) ListVersions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	versions, total, err := h.workflowService.ListVersions(c.Param("id"), c.GetString("userID"), page, limit)
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  versions,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (h *VersionHandler) GetVersion(c *gin.Context) {
+	number, ok := versionParam(c, c.Param("version"))
+	if !ok {
+		return
+	}
+
+	version, err := h.workflowService.GetVersion(c.Param("id"), c.GetString("userID"), number)
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, version)
+}
+
+// DiffVersion compares the version with the one given by "from", by default
+// the version before it
+func (h *VersionHandler) DiffVersion(c *gin.Context) {
+	number, ok := versionParam(c, c.Param("version"))
+	if !ok {
+		return
+	}
+	from := number - 1
+	if raw := c.Query("from"); raw != "" {
+		if from, ok = versionParam(c, raw); !ok {
+			return
+		}
+	}
+
+	diff, err := h.workflowService.DiffVersions(c.Param("id"), c.GetString("userID"), from, number)
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+func (h *VersionHandler) RestoreVersion(c *gin.Context) {
+	number, ok := versionParam(c, c.Param("version"))
+	if !ok {
+		return
+	}
+	var req dto.RestoreVersionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error(), "code": 400})
+			return
+		}
+	}
+
+	workflow, err := h.workflowService.RestoreVersion(c.Param("id"), c.GetString("userID"), number, req.Message)
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+func versionParam(c *gin.Context, raw string) (int, bool) {
+	number, err := strconv.Atoi(raw)
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid version", "code": 400})
+		return 0, false
+	}
+	return number, true
+}
+
+func versionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrWorkflowNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Workflow not found", "code": 404})
+	case errors.Is(err, services.ErrVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Version not found", "code": 404})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "code": 500})
+	}
+}
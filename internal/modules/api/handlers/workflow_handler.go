@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -117,8 +118,12 @@ func (h *WorkflowHandler) UpdateWorkflow(c *gin.Context) {
 		return
 	}
 
-	workflow, err := h.workflowService.UpdateWorkflow(id, &req)
+	workflow, err := h.workflowService.UpdateWorkflow(id, c.GetString("userID"), &req)
 	if err != nil {
+		if errors.Is(err, services.ErrWorkflowNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Workflow not found", "code": 404})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error(), "code": 400})
 		return
 	}
@@ -170,3 +175,23 @@ func (h *WorkflowHandler) RunWorkflow(c *gin.Context) {
 		"message":     "Workflow queued for execution",
 	})
 }
+
+// PublishWorkflow validates the draft and promotes it to the graph triggers
+// and production runs use
+func (h *WorkflowHandler) PublishWorkflow(c *gin.Context) {
+	workflow, err := h.workflowService.PublishWorkflow(c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		var invalid *services.ValidationError
+		switch {
+		case errors.Is(err, services.ErrWorkflowNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"message": "Workflow not found", "code": 404})
+		case errors.As(err, &invalid):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Workflow is invalid", "code": 422, "errors": invalid.Problems})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "code": 500})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
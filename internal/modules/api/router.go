@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 
 	"s4s-backend/internal/config"
@@ -14,8 +17,27 @@ import (
 	authHandlers "s4s-backend/internal/modules/auth/handlers"
 	authRepo "s4s-backend/internal/modules/auth/repository"
 	authServices "s4s-backend/internal/modules/auth/services"
+	connectionHandlers "s4s-backend/internal/modules/connection/handlers"
+	connectionRepo "s4s-backend/internal/modules/connection/repository"
+	connectionServices "s4s-backend/internal/modules/connection/services"
+	contactHandlers "s4s-backend/internal/modules/contacts/handlers"
+	contactRepo "s4s-backend/internal/modules/contacts/repository"
+	contactServices "s4s-backend/internal/modules/contacts/services"
+	scoringHandlers "s4s-backend/internal/modules/scoring/handlers"
+	scoringRepo "s4s-backend/internal/modules/scoring/repository"
+	scoringServices "s4s-backend/internal/modules/scoring/services"
+	suppressionHandlers "s4s-backend/internal/modules/suppression/handlers"
+	suppressionRepo "s4s-backend/internal/modules/suppression/repository"
+	suppressionServices "s4s-backend/internal/modules/suppression/services"
+	trackingHandlers "s4s-backend/internal/modules/tracking/handlers"
+	trackingRepo "s4s-backend/internal/modules/tracking/repository"
+	trackingServices "s4s-backend/internal/modules/tracking/services"
 	workflowRepo "s4s-backend/internal/modules/workflow/repository"
 	workflowServices "s4s-backend/internal/modules/workflow/services"
+	"s4s-backend/internal/modules/workflow/services/engine"
+	"s4s-backend/internal/pkg/emaildomains"
+	"s4s-backend/internal/pkg/mailer"
+	"s4s-backend/internal/pkg/tracking"
 )
 
 func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
@@ -23,6 +45,86 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	userRepository := authRepo.NewUserRepository(db)
 	workflowRepository := workflowRepo.NewWorkflowRepository(db)
 	executionRepository := workflowRepo.NewExecutionRepository(db)
+	workflowStateRepository := workflowRepo.NewWorkflowStateRepository(db)
+	experimentRepository := workflowRepo.NewExperimentRepository(db)
+	workflowVersionRepository := workflowRepo.NewWorkflowVersionRepository(db)
+	templateRepository := workflowRepo.NewTemplateRepository(db)
+	connectionRepository := connectionRepo.NewConnectionRepository(db)
+	ruleSetRepository := scoringRepo.NewRuleSetRepository(db)
+	trackingRepository := trackingRepo.NewTrackingRepository(db)
+	suppressionRepository := suppressionRepo.NewSuppressionRepository(db)
+	contactRepository := contactRepo.NewContactRepository(db)
+
+	// Initialize workflow engine
+	egressPolicy, err := engine.NewEgressPolicy(
+		engine.SplitEgressList(cfg.Egress.Allowlist),
+		engine.SplitEgressList(cfg.Egress.Denylist),
+		cfg.Egress.AllowPrivate,
+	)
+	if err != nil {
+		log.Fatalf("failed to configure egress policy: %v", err)
+	}
+	var platformSMTP *mailer.Config
+	if cfg.SMTP.Host != "" {
+		platformSMTP = &mailer.Config{
+			Host:          cfg.SMTP.Host,
+			Port:          cfg.SMTP.Port,
+			TLSMode:       cfg.SMTP.TLSMode,
+			AuthMechanism: cfg.SMTP.AuthMechanism,
+			Username:      cfg.SMTP.Username,
+			Password:      cfg.SMTP.Password,
+			FromAddress:   cfg.SMTP.FromAddress,
+			FromName:      cfg.SMTP.FromName,
+		}
+		if err := platformSMTP.Validate(); err != nil {
+			log.Fatalf("invalid platform smtp relay: %v", err)
+		}
+	}
+	var platformRedis *redis.Client
+	if cfg.Redis.Addr != "" {
+		platformRedis = redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+		})
+	}
+	if cfg.Contacts.DisposableDomainsFile != "" {
+		emaildomains.WatchDisposableFile(context.Background(), cfg.Contacts.DisposableDomainsFile, time.Minute)
+	}
+	connectionResolver := workflowServices.NewConnectionResolver(connectionRepository)
+	experimentService := workflowServices.NewExperimentService(experimentRepository, workflowRepository)
+	if cfg.Tracking.BaseURL != "" && len(cfg.Tracking.Secret) < tracking.MinSecretLength {
+		log.Fatalf("TRACKING_SECRET of at least %d characters is required when TRACKING_BASE_URL is set", tracking.MinSecretLength)
+	}
+	if cfg.Tracking.Secret != "" && cfg.Tracking.Secret == cfg.JWT.Secret {
+		log.Fatalf("TRACKING_SECRET must differ from JWT_SECRET")
+	}
+	trackingLinks := tracking.NewLinks(cfg.Tracking.BaseURL, cfg.Tracking.Secret)
+	var emailTracker engine.EmailTracker
+	if cfg.Tracking.BaseURL != "" {
+		emailTracker = workflowServices.NewEmailTracker(trackingLinks, trackingRepository)
+	}
+	// contact nodes and contact_event triggers depend on each other; the
+	// trigger service is set as the event dispatcher once it exists
+	contactService := contactServices.NewContactService(contactRepository)
+	executors := engine.NewExecutors(engine.Options{
+		Egress:         egressPolicy,
+		Connections:    connectionResolver,
+		RuleSets:       workflowServices.NewRuleSetResolver(ruleSetRepository),
+		State:          workflowServices.NewStateStore(workflowStateRepository),
+		Experiments:    experimentService,
+		EmailTracker:   emailTracker,
+		Suppressions:   workflowServices.NewSuppressionChecker(suppressionRepository),
+		Contacts:       workflowServices.NewContactStore(contactService),
+		PlatformSMTP:   platformSMTP,
+		PlatformRedis:  platformRedis,
+		SlackAPIURL:    cfg.Integrations.SlackAPIURL,
+		TelegramAPIURL: cfg.Integrations.TelegramAPIURL,
+		CRMBaseURLs: map[string]string{
+			"hubspot":   cfg.Integrations.HubSpotAPIURL,
+			"pipedrive": cfg.Integrations.PipedriveAPIURL,
+			"amocrm":    cfg.Integrations.AmoCRMAPIURL,
+		},
+	})
 
 	// Initialize services
 	authService := authServices.NewAuthService(
@@ -32,16 +134,42 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		cfg.JWT.Secret,
 	)
 	userService := authServices.NewUserService(userRepository)
+	connectionService := connectionServices.NewConnectionService(connectionRepository, egressPolicy.DialContext)
+	ruleSetService := scoringServices.NewRuleSetService(ruleSetRepository)
+	suppressionService := suppressionServices.NewSuppressionService(suppressionRepository)
 	workflowService := workflowServices.NewWorkflowService(
 		workflowRepository,
 		executionRepository,
+		workflowVersionRepository,
 		nil, // subscription service not needed for demo
+		executors,
 	)
+	triggerService := workflowServices.NewTriggerService(
+		workflowRepository,
+		workflowStateRepository,
+		workflowService,
+		connectionResolver,
+		egressPolicy,
+	)
+	triggerService.Start(context.Background())
+	contactService.SetEventDispatcher(triggerService)
+	bundleService := workflowServices.NewBundleService(workflowService, workflowRepository, connectionRepository, ruleSetRepository)
+	templateService := workflowServices.NewTemplateService(templateRepository, workflowService, connectionRepository, ruleSetRepository)
+	trackingService := trackingServices.NewTrackingService(trackingRepository, suppressionRepository, trackingLinks, triggerService)
 
 	// Initialize handlers
 	authHandler := authHandlers.NewAuthHandler(authService)
 	userHandler := handlers.NewUserHandler(userService)
 	workflowHandler := handlers.NewWorkflowHandler(workflowService)
+	experimentHandler := handlers.NewExperimentHandler(experimentService)
+	versionHandler := handlers.NewVersionHandler(workflowService)
+	bundleHandler := handlers.NewBundleHandler(bundleService)
+	templateHandler := handlers.NewTemplateHandler(templateService)
+	connectionHandler := connectionHandlers.NewConnectionHandler(connectionService)
+	ruleSetHandler := scoringHandlers.NewRuleSetHandler(ruleSetService)
+	trackingHandler := trackingHandlers.NewTrackingHandler(trackingService)
+	suppressionHandler := suppressionHandlers.NewSuppressionHandler(suppressionService)
+	contactHandler := contactHandlers.NewContactHandler(contactService)
 
 	// Apply global middleware
 	r.Use(
@@ -71,6 +199,15 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 			//auth.POST("/refresh", authHandler.RefreshToken)
 		}
 
+		// Email tracking links; public, authenticated by their signature
+		tracked := api.Group("/t")
+		{
+			tracked.GET("/o/:id", trackingHandler.Open)
+			tracked.GET("/c/:id", trackingHandler.Click)
+			tracked.GET("/u/:id", trackingHandler.UnsubscribeForm)
+			tracked.POST("/u/:id", trackingHandler.Unsubscribe)
+		}
+
 		// Protected routes
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
@@ -87,8 +224,69 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 			{
 				workflows.GET("", workflowHandler.ListWorkflows)
 				workflows.POST("", workflowHandler.CreateWorkflow)
+				workflows.POST("/import", bundleHandler.ImportWorkflows)
 				workflows.GET("/:id", workflowHandler.GetWorkflow)
+				workflows.PUT("/:id", workflowHandler.UpdateWorkflow)
+				workflows.POST("/:id/publish", workflowHandler.PublishWorkflow)
+				workflows.POST("/:id/test", workflowHandler.TestWorkflow)
 				workflows.POST("/:id/run", workflowHandler.RunWorkflow)
+				workflows.GET("/:id/export", bundleHandler.ExportWorkflow)
+				workflows.GET("/:id/experiments", experimentHandler.GetExperiments)
+				workflows.GET("/:id/versions", versionHandler.ListVersions)
+				workflows.GET("/:id/versions/:version", versionHandler.GetVersion)
+				workflows.GET("/:id/versions/:version/diff", versionHandler.DiffVersion)
+				workflows.POST("/:id/versions/:version/restore", versionHandler.RestoreVersion)
+			}
+
+			// Workflow template routes
+			templates := protected.Group("/templates")
+			{
+				templates.GET("", templateHandler.ListTemplates)
+				templates.GET("/:id", templateHandler.GetTemplate)
+				templates.POST("/:id/use", templateHandler.UseTemplate)
+			}
+
+			// Connection routes
+			connections := protected.Group("/connections")
+			{
+				connections.GET("", connectionHandler.ListConnections)
+				connections.POST("", connectionHandler.CreateConnection)
+				connections.GET("/:id", connectionHandler.GetConnection)
+				connections.PUT("/:id", connectionHandler.UpdateConnection)
+				connections.DELETE("/:id", connectionHandler.DeleteConnection)
+				connections.POST("/:id/test", connectionHandler.TestConnection)
+			}
+
+			// Lead scoring rule set routes
+			ruleSets := protected.Group("/rule-sets")
+			{
+				ruleSets.GET("", ruleSetHandler.ListRuleSets)
+				ruleSets.POST("", ruleSetHandler.CreateRuleSet)
+				ruleSets.GET("/:id", ruleSetHandler.GetRuleSet)
+				ruleSets.PUT("/:id", ruleSetHandler.UpdateRuleSet)
+				ruleSets.DELETE("/:id", ruleSetHandler.DeleteRuleSet)
+			}
+
+			// Suppression list routes
+			suppressions := protected.Group("/suppressions")
+			{
+				suppressions.GET("", suppressionHandler.ListSuppressions)
+				suppressions.POST("", suppressionHandler.CreateSuppression)
+				suppressions.POST("/import", suppressionHandler.ImportSuppressions)
+				suppressions.GET("/:id", suppressionHandler.GetSuppression)
+				suppressions.PUT("/:id", suppressionHandler.UpdateSuppression)
+				suppressions.DELETE("/:id", suppressionHandler.DeleteSuppression)
+			}
+
+			// Contacts store routes
+			contacts := protected.Group("/contacts")
+			{
+				contacts.GET("", contactHandler.ListContacts)
+				contacts.POST("", contactHandler.CreateContact)
+				contacts.POST("/upsert", contactHandler.UpsertContact)
+				contacts.GET("/:id", contactHandler.GetContact)
+				contacts.PUT("/:id", contactHandler.UpdateContact)
+				contacts.DELETE("/:id", contactHandler.DeleteContact)
 			}
 		}
 	}
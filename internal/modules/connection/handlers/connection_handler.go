@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"s4s-backend/internal/modules/connection/models"
@@ -42,7 +43,7 @@ func (h *ConnectionHandler) CreateConnection(c *gin.Context) {
 	}
 
 	if err := h.connectionService.CreateConnection(c.Request.Context(), conn); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(saveErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -109,7 +110,7 @@ func (h *ConnectionHandler) UpdateConnection(c *gin.Context) {
 	}
 
 	if err := h.connectionService.UpdateConnection(c.Request.Context(), conn); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(saveErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -139,6 +140,11 @@ func (h *ConnectionHandler) TestConnection(c *gin.Context) {
 	}
 
 	success, err := h.connectionService.TestConnection(c.Request.Context(), conn)
+	var testErr *services.TestError
+	if errors.As(err, &testErr) {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": testErr.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -147,6 +153,16 @@ func (h *ConnectionHandler) TestConnection(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": success})
 }
 
+// saveErrorStatus is 400 for credentials that fail validation and 500 for
+// anything else, such as the database being unavailable
+func saveErrorStatus(err error) int {
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 func (h *ConnectionHandler) HandleIncomingWebhook(c *gin.Context) {
 	provider := c.Param("provider")
 
@@ -2,6 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
 	"s4s-backend/internal/modules/connection/models"
 	connectionRepo "s4s-backend/internal/modules/connection/repository"
 )
@@ -15,15 +20,54 @@ type ConnectionService interface {
 	TestConnection(ctx context.Context, conn *models.Connection) (bool, error)
 }
 
+// ValidationError reports credentials that don't meet the service's
+// requirements; it is the caller's mistake, unlike a storage failure
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// TestError reports credentials the remote service rejected or couldn't be
+// reached with, as opposed to failing to record the test result
+type TestError struct {
+	Err error
+}
+
+func (e *TestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TestError) Unwrap() error {
+	return e.Err
+}
+
+// Dialer opens outbound connections when testing credentials
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
 type connectionService struct {
 	repo connectionRepo.ConnectionRepository
+	dial Dialer
 }
 
-func NewConnectionService(repo connectionRepo.ConnectionRepository) ConnectionService {
-	return &connectionService{repo: repo}
+func NewConnectionService(repo connectionRepo.ConnectionRepository, dial Dialer) ConnectionService {
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second}
+		dial = dialer.DialContext
+	}
+	return &connectionService{repo: repo, dial: dial}
 }
 
 func (s *connectionService) CreateConnection(ctx context.Context, conn *models.Connection) error {
+	if err := validateCredentials(conn); err != nil {
+		return err
+	}
 	return s.repo.Create(conn)
 }
 
@@ -36,6 +80,9 @@ func (s *connectionService) ListConnections(ctx context.Context, userID string)
 }
 
 func (s *connectionService) UpdateConnection(ctx context.Context, conn *models.Connection) error {
+	if err := validateCredentials(conn); err != nil {
+		return err
+	}
 	return s.repo.Update(conn)
 }
 
@@ -43,8 +90,44 @@ func (s *connectionService) DeleteConnection(ctx context.Context, id, userID str
 	return s.repo.Delete(id, userID)
 }
 
+// TestConnection verifies the credentials against the remote service and
+// records the outcome on the connection. Services without a tester pass.
+// Rejected credentials are reported as a TestError.
 func (s *connectionService) TestConnection(ctx context.Context, conn *models.Connection) (bool, error) {
-	// Implementation depends on the connection type
-	// This is a basic implementation that always returns true
+	tester, ok := testers[conn.ServiceName]
+	if !ok {
+		return true, nil
+	}
+
+	testErr := tester(ctx, conn.Credentials, s.dial)
+
+	now := time.Now()
+	status := "success"
+	if testErr != nil {
+		status = "failed"
+	}
+	conn.LastTestedAt = &now
+	conn.LastTestStatus = &status
+	if err := s.repo.Update(conn); err != nil {
+		return false, fmt.Errorf("failed to record test result: %w", err)
+	}
+
+	if testErr != nil {
+		return false, &TestError{Err: testErr}
+	}
 	return true, nil
 }
+
+func validateCredentials(conn *models.Connection) error {
+	if conn.Credentials == nil {
+		return &ValidationError{Err: errors.New("credentials are required")}
+	}
+	validate, ok := validators[conn.ServiceName]
+	if !ok {
+		return nil
+	}
+	if err := validate(conn.Credentials); err != nil {
+		return &ValidationError{Err: err}
+	}
+	return nil
+}
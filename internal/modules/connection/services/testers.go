@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"s4s-backend/internal/pkg/amqpconn"
+	"s4s-backend/internal/pkg/dbconn"
+	"s4s-backend/internal/pkg/imapconn"
+	"s4s-backend/internal/pkg/mailer"
+	"s4s-backend/internal/pkg/redisconn"
+	"s4s-backend/internal/pkg/utils"
+)
+
+// validators check the shape of credentials when a connection is saved
+var validators = map[string]func(creds map[string]interface{}) error{
+	"smtp":      validateSMTP,
+	"slack":     validateSlack,
+	"telegram":  validateTelegram,
+	"hubspot":   requireCredentials("accessToken"),
+	"pipedrive": requireCredentials("apiToken"),
+	"amocrm":    requireCredentials("accessToken", "subdomain"),
+	"database":  validateDatabase,
+	"amqp":      validateAMQP,
+	"redis":     validateRedis,
+	"imap":      validateIMAP,
+	"secret":    validateSecret,
+}
+
+// testers verify credentials against the remote service
+var testers = map[string]func(ctx context.Context, creds map[string]interface{}, dial Dialer) error{
+	"smtp":     testSMTP,
+	"database": testDatabase,
+	"amqp":     testAMQP,
+	"redis":    testRedis,
+	"imap":     testIMAP,
+}
+
+func validateSMTP(creds map[string]interface{}) error {
+	_, err := mailer.ConfigFromCredentials(creds)
+	return err
+}
+
+func testSMTP(ctx context.Context, creds map[string]interface{}, dial Dialer) error {
+	cfg, err := mailer.ConfigFromCredentials(creds)
+	if err != nil {
+		return err
+	}
+	cfg.Dial = mailer.DialFunc(dial)
+	return mailer.Verify(ctx, cfg)
+}
+
+func validateSlack(creds map[string]interface{}) error {
+	botToken, _ := creds["botToken"].(string)
+	webhookURL, _ := creds["webhookUrl"].(string)
+	if botToken == "" && webhookURL == "" {
+		return errors.New("slack connection requires botToken or webhookUrl")
+	}
+	if webhookURL != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.New("webhookUrl must be an https URL")
+		}
+	}
+	return nil
+}
+
+// requireCredentials returns a validator checking the given keys are non-empty strings
+func requireCredentials(keys ...string) func(creds map[string]interface{}) error {
+	return func(creds map[string]interface{}) error {
+		for _, key := range keys {
+			if value, _ := creds[key].(string); value == "" {
+				return fmt.Errorf("%s is required", key)
+			}
+		}
+		return nil
+	}
+}
+
+func validateTelegram(creds map[string]interface{}) error {
+	if botToken, _ := creds["botToken"].(string); botToken == "" {
+		return errors.New("telegram connection requires botToken")
+	}
+	return nil
+}
+
+func validateDatabase(creds map[string]interface{}) error {
+	_, err := dbconn.ConfigFromCredentials(creds)
+	return err
+}
+
+func testDatabase(ctx context.Context, creds map[string]interface{}, dial Dialer) error {
+	cfg, err := dbconn.ConfigFromCredentials(creds)
+	if err != nil {
+		return err
+	}
+	return dbconn.Ping(ctx, cfg, dbconn.DialFunc(dial))
+}
+
+func validateAMQP(creds map[string]interface{}) error {
+	_, err := amqpconn.ConfigFromCredentials(creds)
+	return err
+}
+
+func testAMQP(ctx context.Context, creds map[string]interface{}, dial Dialer) error {
+	cfg, err := amqpconn.ConfigFromCredentials(creds)
+	if err != nil {
+		return err
+	}
+	return amqpconn.Verify(ctx, cfg, amqpconn.DialFunc(dial))
+}
+
+func validateRedis(creds map[string]interface{}) error {
+	_, err := redisconn.ConfigFromCredentials(creds)
+	return err
+}
+
+func testRedis(ctx context.Context, creds map[string]interface{}, dial Dialer) error {
+	cfg, err := redisconn.ConfigFromCredentials(creds)
+	if err != nil {
+		return err
+	}
+	return redisconn.Ping(ctx, cfg, redisconn.DialFunc(dial))
+}
+
+func validateIMAP(creds map[string]interface{}) error {
+	_, err := imapconn.ConfigFromCredentials(creds)
+	return err
+}
+
+func testIMAP(ctx context.Context, creds map[string]interface{}, dial Dialer) error {
+	cfg, err := imapconn.ConfigFromCredentials(creds)
+	if err != nil {
+		return err
+	}
+	return imapconn.Verify(ctx, cfg, imapconn.DialFunc(dial))
+}
+
+func validateSecret(creds map[string]interface{}) error {
+	_, err := utils.SecretFromCredentials(creds)
+	return err
+}
@@ -0,0 +1,35 @@
+package dto
+
+// ContactInput carries the writable fields of a contact. Nil fields are left
+// unchanged on update.
+type ContactInput struct {
+	Email     *string                `json:"email"`
+	Phone     *string                `json:"phone"`
+	FirstName *string                `json:"firstName"`
+	LastName  *string                `json:"lastName"`
+	Company   *string                `json:"company"`
+	Title     *string                `json:"title"`
+	Owner     *string                `json:"owner"`
+	Stage     *string                `json:"stage"`
+	Source    *string                `json:"source"`
+	Tags      []string               `json:"tags"`
+	Fields    map[string]interface{} `json:"fields"`
+	// Country is the default region for phone numbers without an international prefix
+	Country string `json:"country"`
+}
+
+type CreateContactRequest struct {
+	ContactInput
+}
+
+type UpdateContactRequest struct {
+	ContactInput
+}
+
+// UpsertContactRequest updates the contact with the same email (else phone)
+// or creates a new one
+type UpsertContactRequest struct {
+	ContactInput
+	// TagsMode is "add" (default) to merge tags or "replace" to overwrite them
+	TagsMode string `json:"tagsMode"`
+}
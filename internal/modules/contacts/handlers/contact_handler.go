@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"s4s-backend/internal/modules/contacts/dto"
+	contactRepo "s4s-backend/internal/modules/contacts/repository"
+	"s4s-backend/internal/modules/contacts/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContactHandler struct {
+	contactService services.ContactService
+}
+
+func NewContactHandler(service services.ContactService) *ContactHandler {
+	return &ContactHandler{
+		contactService: service,
+	}
+}
+
+func (h *ContactHandler) CreateContact(c *gin.Context) {
+	var req dto.CreateContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Source == nil {
+		source := "api"
+		req.Source = &source
+	}
+
+	contact, err := h.contactService.CreateContact(c.Request.Context(), c.GetString("userID"), req.ContactInput)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, contact)
+}
+
+// UpsertContact updates the contact with the request's email (else phone) or
+// creates it; responds 201 when a contact was created
+func (h *ContactHandler) UpsertContact(c *gin.Context) {
+	var req dto.UpsertContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := services.UpsertOptions{ReplaceTags: strings.EqualFold(req.TagsMode, "replace")}
+	contact, created, err := h.contactService.UpsertContact(c.Request.Context(), c.GetString("userID"), req.ContactInput, opts)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, contact)
+}
+
+func (h *ContactHandler) GetContact(c *gin.Context) {
+	contact, err := h.contactService.GetContact(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, contact)
+}
+
+// ListContacts filters by full-text query (q), tag, stage and owner
+func (h *ContactHandler) ListContacts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	filter := contactRepo.Filter{
+		Search: c.Query("q"),
+		Tag:    c.Query("tag"),
+		Stage:  c.Query("stage"),
+		Owner:  c.Query("owner"),
+	}
+	contacts, total, err := h.contactService.ListContacts(c.Request.Context(), c.GetString("userID"), filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  contacts,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (h *ContactHandler) UpdateContact(c *gin.Context) {
+	var req dto.UpdateContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contact, err := h.contactService.UpdateContact(c.Request.Context(), c.Param("id"), c.GetString("userID"), req.ContactInput)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, contact)
+}
+
+func (h *ContactHandler) DeleteContact(c *gin.Context) {
+	if err := h.contactService.DeleteContact(c.Request.Context(), c.Param("id"), c.GetString("userID")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, contactRepo.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrDuplicateContact):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Contact is a person in a user's built-in contacts store. Email and phone
+// are unique per user, so imports and workflows update a contact instead of
+// creating a duplicate.
+type Contact struct {
+	ID        string         `gorm:"type:uuid;primary_key" json:"id"`
+	UserID    string         `gorm:"type:uuid;not null;index" json:"userId"`
+	Email     string         `gorm:"size:320;not null;default:''" json:"email"`
+	Phone     string         `gorm:"size:32;not null;default:''" json:"phone"`
+	FirstName string         `gorm:"size:255" json:"firstName"`
+	LastName  string         `gorm:"size:255" json:"lastName"`
+	Company   string         `gorm:"size:255" json:"company"`
+	Title     string         `gorm:"size:255" json:"title"`
+	Owner     string         `gorm:"size:320;index" json:"owner"`
+	Stage     string         `gorm:"size:100;index" json:"stage"`
+	Source    string         `gorm:"size:100" json:"source"`
+	Tags      Tags           `gorm:"type:jsonb;not null" json:"tags"`
+	Fields    Fields         `gorm:"type:jsonb;not null" json:"fields"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Tags are free-form labels such as "newsletter" or "vip"
+type Tags []string
+
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	return json.Marshal(t)
+}
+
+func (t *Tags) Scan(value interface{}) error {
+	return scanJSON(value, t)
+}
+
+// Fields are custom fields, e.g. {"industry": "retail", "employees": 40}
+type Fields map[string]interface{}
+
+func (f Fields) Value() (driver.Value, error) {
+	if f == nil {
+		return "{}", nil
+	}
+	return json.Marshal(f)
+}
+
+func (f *Fields) Scan(value interface{}) error {
+	return scanJSON(value, f)
+}
+
+func scanJSON(value interface{}, target interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, target)
+}
+
+func (c *Contact) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Contact) TableName() string {
+	return "contacts"
+}
+
+// Data returns the contact as workflow data, with snake_case keys
+func (c *Contact) Data() map[string]interface{} {
+	tags := make([]interface{}, len(c.Tags))
+	for i, tag := range c.Tags {
+		tags[i] = tag
+	}
+	fields := make(map[string]interface{}, len(c.Fields))
+	for k, v := range c.Fields {
+		fields[k] = v
+	}
+	return map[string]interface{}{
+		"id":         c.ID,
+		"email":      c.Email,
+		"phone":      c.Phone,
+		"first_name": c.FirstName,
+		"last_name":  c.LastName,
+		"company":    c.Company,
+		"title":      c.Title,
+		"owner":      c.Owner,
+		"stage":      c.Stage,
+		"source":     c.Source,
+		"tags":       tags,
+		"fields":     fields,
+		"created_at": c.CreatedAt.UTC().Format(time.RFC3339),
+		"updated_at": c.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"s4s-backend/internal/modules/contacts/models"
+)
+
+// ErrNotFound is returned for contacts that do not exist or belong to another user
+var ErrNotFound = errors.New("contact not found")
+
+// Filter narrows a contact listing; empty fields match everything
+type Filter struct {
+	// Search is a full-text query over names, company, email, phone and custom fields
+	Search string
+	Tag    string
+	Stage  string
+	Owner  string
+}
+
+type ContactRepository interface {
+	Create(contact *models.Contact) error
+	GetByID(id, userID string) (*models.Contact, error)
+	// FindByEmailOrPhone returns the contact with the email, else the one with
+	// the phone; empty values are not matched
+	FindByEmailOrPhone(userID, email, phone string) (*models.Contact, error)
+	FindByUserID(userID string, filter Filter, page, limit int) ([]*models.Contact, int64, error)
+	Update(contact *models.Contact) error
+	Delete(id, userID string) error
+}
+
+type contactRepository struct {
+	db *gorm.DB
+}
+
+func NewContactRepository(db *gorm.DB) ContactRepository {
+	return &contactRepository{db: db}
+}
+
+func (r *contactRepository) Create(contact *models.Contact) error {
+	return r.db.Create(contact).Error
+}
+
+func (r *contactRepository) GetByID(id, userID string) (*models.Contact, error) {
+	var contact models.Contact
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&contact).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &contact, nil
+}
+
+func (r *contactRepository) FindByEmailOrPhone(userID, email, phone string) (*models.Contact, error) {
+	for _, key := range []struct{ column, value string }{{"email", email}, {"phone", phone}} {
+		if key.value == "" {
+			continue
+		}
+		var contact models.Contact
+		err := r.db.Where("user_id = ? AND "+key.column+" = ?", userID, key.value).First(&contact).Error
+		if err == nil {
+			return &contact, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *contactRepository) FindByUserID(userID string, filter Filter, page, limit int) ([]*models.Contact, int64, error) {
+	var contacts []*models.Contact
+	var total int64
+
+	query := r.db.Model(&models.Contact{}).Where("user_id = ?", userID)
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		query = query.Where("search @@ websearch_to_tsquery('simple', ?) OR email ILIKE ?", search, "%"+search+"%")
+	}
+	if filter.Tag != "" {
+		tag, _ := json.Marshal([]string{filter.Tag})
+		query = query.Where("tags @> ?::jsonb", string(tag))
+	}
+	if filter.Stage != "" {
+		query = query.Where("stage = ?", filter.Stage)
+	}
+	if filter.Owner != "" {
+		query = query.Where("owner = ?", filter.Owner)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "updated_at DESC"
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		order = "ts_rank(search, websearch_to_tsquery('simple', ?)) DESC, updated_at DESC"
+		query = query.Order(gorm.Expr(order, search))
+	} else {
+		query = query.Order(order)
+	}
+	offset := (page - 1) * limit
+	err := query.Offset(offset).Limit(limit).Find(&contacts).Error
+	return contacts, total, err
+}
+
+func (r *contactRepository) Update(contact *models.Contact) error {
+	return r.db.Save(contact).Error
+}
+
+func (r *contactRepository) Delete(id, userID string) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Contact{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"s4s-backend/internal/modules/contacts/dto"
+	"s4s-backend/internal/modules/contacts/models"
+	contactRepo "s4s-backend/internal/modules/contacts/repository"
+	"s4s-backend/internal/pkg/phone"
+)
+
+// Contact event types passed to the EventDispatcher
+const (
+	EventCreated      = "created"
+	EventStageChanged = "stage_changed"
+)
+
+var (
+	// ErrDuplicateContact is returned when another contact already has the email or phone
+	ErrDuplicateContact = errors.New("a contact with this email or phone already exists")
+	// ErrContactKeyRequired is returned for contacts without an email or phone
+	ErrContactKeyRequired = errors.New("email or phone is required")
+)
+
+// EventDispatcher runs the workflows triggered by contact events
+type EventDispatcher interface {
+	DispatchContactEvent(ctx context.Context, userID string, event map[string]interface{})
+}
+
+// UpsertOptions tune ContactService.UpsertContact
+type UpsertOptions struct {
+	// ReplaceTags overwrites the contact's tags instead of adding to them
+	ReplaceTags bool
+	// SourceWorkflowID is the workflow making the change; its own
+	// contact_event triggers are not run for it
+	SourceWorkflowID string
+	// EventDepth is the number of events that led to the workflow run making
+	// the change; it is passed on with the event to stop trigger loops
+	EventDepth int
+}
+
+type ContactService interface {
+	CreateContact(ctx context.Context, userID string, input dto.ContactInput) (*models.Contact, error)
+	GetContact(ctx context.Context, id, userID string) (*models.Contact, error)
+	// FindContact returns the contact with the email, else the phone; both
+	// are normalized like stored values
+	FindContact(ctx context.Context, userID, email, phone, country string) (*models.Contact, error)
+	ListContacts(ctx context.Context, userID string, filter contactRepo.Filter, page, limit int) ([]*models.Contact, int64, error)
+	UpdateContact(ctx context.Context, id, userID string, input dto.ContactInput) (*models.Contact, error)
+	DeleteContact(ctx context.Context, id, userID string) error
+	// UpsertContact merges input into the contact with the same email, else
+	// the same phone, or creates one. Empty values do not clear existing
+	// ones, tags are added and custom fields are merged key by key.
+	UpsertContact(ctx context.Context, userID string, input dto.ContactInput, opts UpsertOptions) (contact *models.Contact, created bool, err error)
+	// SetEventDispatcher sets where contact events are sent; events are
+	// dropped until it is set
+	SetEventDispatcher(events EventDispatcher)
+}
+
+type contactService struct {
+	repo   contactRepo.ContactRepository
+	events EventDispatcher
+}
+
+func NewContactService(repo contactRepo.ContactRepository) ContactService {
+	return &contactService{repo: repo}
+}
+
+func (s *contactService) SetEventDispatcher(events EventDispatcher) {
+	s.events = events
+}
+
+func (s *contactService) CreateContact(ctx context.Context, userID string, input dto.ContactInput) (*models.Contact, error) {
+	contact := &models.Contact{UserID: userID, Tags: models.Tags{}, Fields: models.Fields{}}
+	if err := apply(contact, input, false, false); err != nil {
+		return nil, err
+	}
+	if err := s.checkDuplicate(contact); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(contact); err != nil {
+		return nil, err
+	}
+	s.dispatch(ctx, EventCreated, contact, "", UpsertOptions{})
+	return contact, nil
+}
+
+func (s *contactService) GetContact(ctx context.Context, id, userID string) (*models.Contact, error) {
+	return s.repo.GetByID(id, userID)
+}
+
+func (s *contactService) FindContact(ctx context.Context, userID, email, phone, country string) (*models.Contact, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	phone = normalizePhone(phone, country)
+	if email == "" && phone == "" {
+		return nil, ErrContactKeyRequired
+	}
+	return s.repo.FindByEmailOrPhone(userID, email, phone)
+}
+
+func (s *contactService) ListContacts(ctx context.Context, userID string, filter contactRepo.Filter, page, limit int) ([]*models.Contact, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	return s.repo.FindByUserID(userID, filter, page, limit)
+}
+
+func (s *contactService) UpdateContact(ctx context.Context, id, userID string, input dto.ContactInput) (*models.Contact, error) {
+	contact, err := s.repo.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	previousStage := contact.Stage
+	if err := apply(contact, input, false, true); err != nil {
+		return nil, err
+	}
+	if err := s.checkDuplicate(contact); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(contact); err != nil {
+		return nil, err
+	}
+	if contact.Stage != previousStage {
+		s.dispatch(ctx, EventStageChanged, contact, previousStage, UpsertOptions{})
+	}
+	return contact, nil
+}
+
+func (s *contactService) DeleteContact(ctx context.Context, id, userID string) error {
+	return s.repo.Delete(id, userID)
+}
+
+func (s *contactService) UpsertContact(ctx context.Context, userID string, input dto.ContactInput, opts UpsertOptions) (*models.Contact, bool, error) {
+	candidate := &models.Contact{UserID: userID, Tags: models.Tags{}, Fields: models.Fields{}}
+	if err := apply(candidate, input, false, false); err != nil {
+		return nil, false, err
+	}
+
+	existing, err := s.repo.FindByEmailOrPhone(userID, candidate.Email, candidate.Phone)
+	if errors.Is(err, contactRepo.ErrNotFound) {
+		if err := s.repo.Create(candidate); err == nil {
+			s.dispatch(ctx, EventCreated, candidate, "", opts)
+			return candidate, true, nil
+		} else if existing, err = s.repo.FindByEmailOrPhone(userID, candidate.Email, candidate.Phone); err != nil {
+			// a concurrent upsert may have created the contact in the meantime;
+			// otherwise report the original failure
+			return nil, false, err
+		}
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	previousStage := existing.Stage
+	if err := apply(existing, input, true, opts.ReplaceTags); err != nil {
+		return nil, false, err
+	}
+	if err := s.checkDuplicate(existing); err != nil {
+		return nil, false, err
+	}
+	if err := s.repo.Update(existing); err != nil {
+		return nil, false, err
+	}
+	if existing.Stage != previousStage {
+		s.dispatch(ctx, EventStageChanged, existing, previousStage, opts)
+	}
+	return existing, false, nil
+}
+
+// checkDuplicate reports whether another contact of the user has the
+// contact's email or phone
+func (s *contactService) checkDuplicate(contact *models.Contact) error {
+	lookups := []struct{ email, phone string }{{email: contact.Email}, {phone: contact.Phone}}
+	for _, lookup := range lookups {
+		if lookup.email == "" && lookup.phone == "" {
+			continue
+		}
+		other, err := s.repo.FindByEmailOrPhone(contact.UserID, lookup.email, lookup.phone)
+		if errors.Is(err, contactRepo.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if other.ID != contact.ID {
+			return ErrDuplicateContact
+		}
+	}
+	return nil
+}
+
+func (s *contactService) dispatch(ctx context.Context, eventType string, contact *models.Contact, previousStage string, opts UpsertOptions) {
+	if s.events == nil {
+		return
+	}
+	s.events.DispatchContactEvent(ctx, contact.UserID, map[string]interface{}{
+		"event":              eventType,
+		"contact":            contact.Data(),
+		"contact_id":         contact.ID,
+		"stage":              contact.Stage,
+		"previous_stage":     previousStage,
+		"source_workflow_id": opts.SourceWorkflowID,
+		"event_depth":        opts.EventDepth,
+		"occurred_at":        time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// apply copies input into contact and normalizes the email and phone. With
+// merge, empty values leave existing ones untouched and custom fields are
+// merged; otherwise fields present in input replace the contact's. Tags are
+// added to the existing ones unless replaceTags is set.
+func apply(contact *models.Contact, input dto.ContactInput, merge, replaceTags bool) error {
+	set := func(target *string, value *string) {
+		if value == nil {
+			return
+		}
+		trimmed := strings.TrimSpace(*value)
+		if merge && trimmed == "" {
+			return
+		}
+		*target = trimmed
+	}
+
+	if input.Email != nil {
+		email, err := normalizeEmail(*input.Email)
+		if err != nil {
+			return err
+		}
+		set(&contact.Email, &email)
+	}
+	if input.Phone != nil {
+		number := normalizePhone(*input.Phone, input.Country)
+		set(&contact.Phone, &number)
+	}
+	set(&contact.FirstName, input.FirstName)
+	set(&contact.LastName, input.LastName)
+	set(&contact.Company, input.Company)
+	set(&contact.Title, input.Title)
+	set(&contact.Owner, input.Owner)
+	set(&contact.Stage, input.Stage)
+	set(&contact.Source, input.Source)
+
+	if input.Tags != nil {
+		if replaceTags {
+			contact.Tags = models.Tags{}
+		}
+		contact.Tags = addTags(contact.Tags, input.Tags)
+	}
+	if input.Fields != nil {
+		if !merge {
+			contact.Fields = models.Fields{}
+		}
+		if contact.Fields == nil {
+			contact.Fields = models.Fields{}
+		}
+		for k, v := range input.Fields {
+			if k = strings.TrimSpace(k); k != "" {
+				contact.Fields[k] = v
+			}
+		}
+	}
+
+	if contact.Email == "" && contact.Phone == "" {
+		return ErrContactKeyRequired
+	}
+	return nil
+}
+
+// normalizeEmail lowercases and validates an email; an empty one is allowed
+func normalizeEmail(raw string) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(raw))
+	if email == "" {
+		return "", nil
+	}
+	parsed, err := mail.ParseAddress(email)
+	if err != nil || parsed.Address != email {
+		return "", fmt.Errorf("invalid email: %s", raw)
+	}
+	return email, nil
+}
+
+// normalizePhone converts a phone to E.164 when it can be parsed and keeps
+// it as entered otherwise, so it is still matched exactly on the next upsert
+func normalizePhone(raw, country string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if number, _, err := phone.Normalize(raw, country); err == nil {
+		return number
+	}
+	return raw
+}
+
+// addTags appends the tags that are not on the list yet
+func addTags(tags models.Tags, add []string) models.Tags {
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		seen[strings.ToLower(tag)] = true
+	}
+	for _, tag := range add {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
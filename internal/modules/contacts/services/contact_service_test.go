@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"s4s-backend/internal/modules/contacts/dto"
+	"s4s-backend/internal/modules/contacts/models"
+	contactRepo "s4s-backend/internal/modules/contacts/repository"
+)
+
+// memoryRepository keeps contacts in memory in the order they were created.
+// Like a database it hands out copies, so changes are only seen once saved.
+type memoryRepository struct {
+	contacts []*models.Contact
+}
+
+func copyContact(contact *models.Contact) *models.Contact {
+	copied := *contact
+	copied.Tags = slices.Clone(contact.Tags)
+	copied.Fields = maps.Clone(contact.Fields)
+	return &copied
+}
+
+func (r *memoryRepository) Create(contact *models.Contact) error {
+	contact.ID = fmt.Sprintf("contact-%d", len(r.contacts)+1)
+	r.contacts = append(r.contacts, copyContact(contact))
+	return nil
+}
+
+func (r *memoryRepository) GetByID(id, userID string) (*models.Contact, error) {
+	for _, contact := range r.contacts {
+		if contact.ID == id && contact.UserID == userID {
+			return copyContact(contact), nil
+		}
+	}
+	return nil, contactRepo.ErrNotFound
+}
+
+func (r *memoryRepository) FindByEmailOrPhone(userID, email, phone string) (*models.Contact, error) {
+	for _, match := range []func(*models.Contact) bool{
+		func(c *models.Contact) bool { return email != "" && c.Email == email },
+		func(c *models.Contact) bool { return phone != "" && c.Phone == phone },
+	} {
+		for _, contact := range r.contacts {
+			if contact.UserID == userID && match(contact) {
+				return copyContact(contact), nil
+			}
+		}
+	}
+	return nil, contactRepo.ErrNotFound
+}
+
+func (r *memoryRepository) FindByUserID(userID string, filter contactRepo.Filter, page, limit int) ([]*models.Contact, int64, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (r *memoryRepository) Update(contact *models.Contact) error {
+	for i, stored := range r.contacts {
+		if stored.ID == contact.ID {
+			r.contacts[i] = copyContact(contact)
+			return nil
+		}
+	}
+	return contactRepo.ErrNotFound
+}
+
+func (r *memoryRepository) Delete(id, userID string) error {
+	return errors.New("not implemented")
+}
+
+// recordingDispatcher records the contact events as "event stage depth source"
+type recordingDispatcher struct {
+	events []string
+}
+
+func (d *recordingDispatcher) DispatchContactEvent(ctx context.Context, userID string, event map[string]interface{}) {
+	d.events = append(d.events, fmt.Sprintf("%v %v %v %v", event["event"], event["stage"], event["event_depth"], event["source_workflow_id"]))
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestUpsertContact(t *testing.T) {
+	existing := func() []*models.Contact {
+		return []*models.Contact{
+			{ID: "anna", UserID: "user-1", Email: "anna@example.com", FirstName: "Anna", Stage: "lead", Tags: models.Tags{"vip"}, Fields: models.Fields{"size": 10.0}},
+			{ID: "boss", UserID: "user-1", Phone: "+4930123456", FirstName: "Boss", Stage: "lead", Tags: models.Tags{}, Fields: models.Fields{}},
+			{ID: "other-user", UserID: "user-2", Email: "carl@example.com", Tags: models.Tags{}, Fields: models.Fields{}},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		input       dto.ContactInput
+		opts        UpsertOptions
+		wantID      string
+		wantCreated bool
+		wantErr     error
+		wantEvents  []string
+		check       func(t *testing.T, contact *models.Contact)
+	}{
+		{
+			name:   "email matches case-insensitively",
+			input:  dto.ContactInput{Email: ptr(" ANNA@Example.com "), LastName: ptr("Smith"), FirstName: ptr(""), Tags: []string{"VIP", "new"}, Fields: map[string]interface{}{"plan": "pro"}},
+			wantID: "anna",
+			check: func(t *testing.T, c *models.Contact) {
+				if c.FirstName != "Anna" || c.LastName != "Smith" {
+					t.Errorf("name = %q %q, want empty values to keep the old one", c.FirstName, c.LastName)
+				}
+				if !slices.Equal(c.Tags, models.Tags{"vip", "new"}) {
+					t.Errorf("tags = %v", c.Tags)
+				}
+				if c.Fields["size"] != 10.0 || c.Fields["plan"] != "pro" {
+					t.Errorf("fields = %v, want them merged", c.Fields)
+				}
+			},
+		},
+		{
+			name:   "phone matches in any format",
+			input:  dto.ContactInput{Phone: ptr("030 123456"), Country: "DE", Stage: ptr("won")},
+			opts:   UpsertOptions{SourceWorkflowID: "wf-1", EventDepth: 2},
+			wantID: "boss", wantEvents: []string{"stage_changed won 2 wf-1"},
+		},
+		{
+			name:   "email wins over phone",
+			input:  dto.ContactInput{Email: ptr("anna@example.com"), Phone: ptr("+4930999999")},
+			wantID: "anna",
+		},
+		{
+			name:    "taking another contact's phone is a duplicate",
+			input:   dto.ContactInput{Email: ptr("anna@example.com"), Phone: ptr("+4930123456")},
+			wantErr: ErrDuplicateContact,
+		},
+		{
+			name:   "unchanged stage sends no event",
+			input:  dto.ContactInput{Email: ptr("anna@example.com"), Stage: ptr("lead")},
+			wantID: "anna",
+		},
+		{
+			name:   "new contact",
+			input:  dto.ContactInput{Email: ptr("dora@example.com"), Stage: ptr("lead")},
+			opts:   UpsertOptions{SourceWorkflowID: "wf-1", EventDepth: 1},
+			wantID: "contact-4", wantCreated: true, wantEvents: []string{"created lead 1 wf-1"},
+		},
+		{
+			name:   "other users' contacts are not matched",
+			input:  dto.ContactInput{Email: ptr("carl@example.com")},
+			wantID: "contact-4", wantCreated: true, wantEvents: []string{"created  0 "},
+		},
+		{
+			name:    "email or phone is required",
+			input:   dto.ContactInput{FirstName: ptr("Nobody")},
+			wantErr: ErrContactKeyRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryRepository{contacts: existing()}
+			events := &recordingDispatcher{}
+			service := NewContactService(repo)
+			service.SetEventDispatcher(events)
+
+			contact, created, err := service.UpsertContact(context.Background(), "user-1", tt.input, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpsertContact() = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if contact.ID != tt.wantID || created != tt.wantCreated {
+				t.Errorf("upserted %s (created %v), want %s (created %v)", contact.ID, created, tt.wantID, tt.wantCreated)
+			}
+			if !slices.Equal(events.events, tt.wantEvents) {
+				t.Errorf("events = %q, want %q", events.events, tt.wantEvents)
+			}
+			if tt.check != nil {
+				tt.check(t, contact)
+			}
+		})
+	}
+}
+
+func TestUpdateContactRejectsDuplicates(t *testing.T) {
+	repo := &memoryRepository{}
+	service := NewContactService(repo)
+	ctx := context.Background()
+	if _, err := service.CreateContact(ctx, "user-1", dto.ContactInput{Email: ptr("anna@example.com")}); err != nil {
+		t.Fatal(err)
+	}
+	boss, err := service.CreateContact(ctx, "user-1", dto.ContactInput{Email: ptr("boss@example.com"), Phone: ptr("+4930123456")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.CreateContact(ctx, "user-1", dto.ContactInput{Email: ptr("Anna@Example.com")}); !errors.Is(err, ErrDuplicateContact) {
+		t.Errorf("CreateContact() with a known email = %v, want ErrDuplicateContact", err)
+	}
+	if _, err := service.CreateContact(ctx, "user-1", dto.ContactInput{Phone: ptr("+49 30 123456")}); !errors.Is(err, ErrDuplicateContact) {
+		t.Errorf("CreateContact() with a known phone = %v, want ErrDuplicateContact", err)
+	}
+	if _, err := service.UpdateContact(ctx, boss.ID, "user-1", dto.ContactInput{Email: ptr("anna@example.com")}); !errors.Is(err, ErrDuplicateContact) {
+		t.Errorf("UpdateContact() to another contact's email = %v, want ErrDuplicateContact", err)
+	}
+	if _, err := service.UpdateContact(ctx, boss.ID, "user-1", dto.ContactInput{Email: ptr("boss@example.com"), FirstName: ptr("Boss")}); err != nil {
+		t.Errorf("UpdateContact() keeping its own email = %v", err)
+	}
+}
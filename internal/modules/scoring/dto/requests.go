@@ -0,0 +1,17 @@
+package dto
+
+import "s4s-backend/internal/pkg/scoring"
+
+type CreateRuleSetRequest struct {
+	Name        string          `json:"name" binding:"required"`
+	Description string          `json:"description"`
+	Rules       []scoring.Rule  `json:"rules" binding:"required"`
+	Grades      []scoring.Grade `json:"grades"`
+}
+
+type UpdateRuleSetRequest struct {
+	Name        string          `json:"name"`
+	Description *string         `json:"description"`
+	Rules       []scoring.Rule  `json:"rules"`
+	Grades      []scoring.Grade `json:"grades"`
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"s4s-backend/internal/modules/scoring/dto"
+	"s4s-backend/internal/modules/scoring/models"
+	"s4s-backend/internal/modules/scoring/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RuleSetHandler struct {
+	ruleSetService services.RuleSetService
+}
+
+func NewRuleSetHandler(service services.RuleSetService) *RuleSetHandler {
+	return &RuleSetHandler{
+		ruleSetService: service,
+	}
+}
+
+func (h *RuleSetHandler) CreateRuleSet(c *gin.Context) {
+	var req dto.CreateRuleSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ruleSet := &models.RuleSet{
+		UserID:      c.GetString("userID"),
+		Name:        req.Name,
+		Description: req.Description,
+		Rules:       req.Rules,
+		Grades:      req.Grades,
+	}
+
+	if err := h.ruleSetService.CreateRuleSet(c.Request.Context(), ruleSet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ruleSet)
+}
+
+func (h *RuleSetHandler) GetRuleSet(c *gin.Context) {
+	ruleSet, err := h.ruleSetService.GetRuleSet(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule set not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ruleSet)
+}
+
+func (h *RuleSetHandler) ListRuleSets(c *gin.Context) {
+	ruleSets, err := h.ruleSetService.ListRuleSets(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ruleSets)
+}
+
+func (h *RuleSetHandler) UpdateRuleSet(c *gin.Context) {
+	var req dto.UpdateRuleSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ruleSet, err := h.ruleSetService.GetRuleSet(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule set not found"})
+		return
+	}
+
+	if req.Name != "" {
+		ruleSet.Name = req.Name
+	}
+	if req.Description != nil {
+		ruleSet.Description = *req.Description
+	}
+	if req.Rules != nil {
+		ruleSet.Rules = req.Rules
+	}
+	if req.Grades != nil {
+		ruleSet.Grades = req.Grades
+	}
+
+	if err := h.ruleSetService.UpdateRuleSet(c.Request.Context(), ruleSet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ruleSet)
+}
+
+func (h *RuleSetHandler) DeleteRuleSet(c *gin.Context) {
+	if err := h.ruleSetService.DeleteRuleSet(c.Request.Context(), c.Param("id"), c.GetString("userID")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
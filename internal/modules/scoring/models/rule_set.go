@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"s4s-backend/internal/pkg/scoring"
+)
+
+// RuleSet is a user's reusable list of lead scoring rules
+type RuleSet struct {
+	ID          string         `gorm:"type:uuid;primary_key" json:"id"`
+	UserID      string         `gorm:"type:uuid;not null;index" json:"userId"`
+	Name        string         `gorm:"not null" json:"name"`
+	Description string         `json:"description"`
+	Rules       Rules          `gorm:"type:jsonb;not null" json:"rules"`
+	Grades      Grades         `gorm:"type:jsonb" json:"grades"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type Rules []scoring.Rule
+
+func (r Rules) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *Rules) Scan(value interface{}) error {
+	return scanJSON(value, r)
+}
+
+type Grades []scoring.Grade
+
+func (g Grades) Value() (driver.Value, error) {
+	if g == nil {
+		return "[]", nil
+	}
+	return json.Marshal(g)
+}
+
+func (g *Grades) Scan(value interface{}) error {
+	return scanJSON(value, g)
+}
+
+func scanJSON(value interface{}, target interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, target)
+}
+
+func (r *RuleSet) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (RuleSet) TableName() string {
+	return "lead_rule_sets"
+}
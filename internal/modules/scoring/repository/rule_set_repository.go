@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"s4s-backend/internal/modules/scoring/models"
+)
+
+type RuleSetRepository interface {
+	Create(ruleSet *models.RuleSet) error
+	GetByID(id, userID string) (*models.RuleSet, error)
+	FindByUserID(userID string) ([]*models.RuleSet, error)
+	Update(ruleSet *models.RuleSet) error
+	Delete(id, userID string) error
+}
+
+type ruleSetRepository struct {
+	db *gorm.DB
+}
+
+func NewRuleSetRepository(db *gorm.DB) RuleSetRepository {
+	return &ruleSetRepository{db: db}
+}
+
+func (r *ruleSetRepository) Create(ruleSet *models.RuleSet) error {
+	return r.db.Create(ruleSet).Error
+}
+
+func (r *ruleSetRepository) GetByID(id, userID string) (*models.RuleSet, error) {
+	var ruleSet models.RuleSet
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&ruleSet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("rule set not found")
+		}
+		return nil, err
+	}
+	return &ruleSet, nil
+}
+
+func (r *ruleSetRepository) FindByUserID(userID string) ([]*models.RuleSet, error) {
+	var ruleSets []*models.RuleSet
+	err := r.db.Where("user_id = ?", userID).Order("name").Find(&ruleSets).Error
+	if err != nil {
+		return nil, err
+	}
+	return ruleSets, nil
+}
+
+func (r *ruleSetRepository) Update(ruleSet *models.RuleSet) error {
+	return r.db.Save(ruleSet).Error
+}
+
+func (r *ruleSetRepository) Delete(id, userID string) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.RuleSet{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("rule set not found")
+	}
+	return nil
+}
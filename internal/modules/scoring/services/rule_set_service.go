@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+
+	"s4s-backend/internal/modules/scoring/models"
+	scoringRepo "s4s-backend/internal/modules/scoring/repository"
+	"s4s-backend/internal/pkg/scoring"
+)
+
+type RuleSetService interface {
+	CreateRuleSet(ctx context.Context, ruleSet *models.RuleSet) error
+	GetRuleSet(ctx context.Context, id, userID string) (*models.RuleSet, error)
+	ListRuleSets(ctx context.Context, userID string) ([]*models.RuleSet, error)
+	UpdateRuleSet(ctx context.Context, ruleSet *models.RuleSet) error
+	DeleteRuleSet(ctx context.Context, id, userID string) error
+}
+
+type ruleSetService struct {
+	repo scoringRepo.RuleSetRepository
+}
+
+func NewRuleSetService(repo scoringRepo.RuleSetRepository) RuleSetService {
+	return &ruleSetService{repo: repo}
+}
+
+func (s *ruleSetService) CreateRuleSet(ctx context.Context, ruleSet *models.RuleSet) error {
+	if _, err := scoring.Compile(ruleSet.Rules, ruleSet.Grades); err != nil {
+		return err
+	}
+	return s.repo.Create(ruleSet)
+}
+
+func (s *ruleSetService) GetRuleSet(ctx context.Context, id, userID string) (*models.RuleSet, error) {
+	return s.repo.GetByID(id, userID)
+}
+
+func (s *ruleSetService) ListRuleSets(ctx context.Context, userID string) ([]*models.RuleSet, error) {
+	return s.repo.FindByUserID(userID)
+}
+
+func (s *ruleSetService) UpdateRuleSet(ctx context.Context, ruleSet *models.RuleSet) error {
+	if _, err := scoring.Compile(ruleSet.Rules, ruleSet.Grades); err != nil {
+		return err
+	}
+	return s.repo.Update(ruleSet)
+}
+
+func (s *ruleSetService) DeleteRuleSet(ctx context.Context, id, userID string) error {
+	return s.repo.Delete(id, userID)
+}
@@ -0,0 +1,30 @@
+package dto
+
+type CreateSuppressionRequest struct {
+	Address string `json:"address" binding:"required"`
+	Channel string `json:"channel"`
+	Reason  string `json:"reason"`
+	Note    string `json:"note"`
+}
+
+type UpdateSuppressionRequest struct {
+	Reason string  `json:"reason"`
+	Note   *string `json:"note"`
+}
+
+// ImportResult reports a CSV import
+type ImportResult struct {
+	// Imported is the number of addresses added to the list
+	Imported int64 `json:"imported"`
+	// Duplicates were already on the list or repeated in the file
+	Duplicates int64 `json:"duplicates"`
+	// Invalid lists the rows that were skipped, up to the first 100
+	Invalid      []InvalidRow `json:"invalid"`
+	InvalidCount int          `json:"invalidCount"`
+}
+
+type InvalidRow struct {
+	Line    int    `json:"line"`
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
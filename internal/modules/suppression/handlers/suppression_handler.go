@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"s4s-backend/internal/modules/suppression/dto"
+	"s4s-backend/internal/modules/suppression/models"
+	"s4s-backend/internal/modules/suppression/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize bounds the size of an uploaded CSV file
+const maxImportSize = 10 << 20
+
+type SuppressionHandler struct {
+	suppressionService services.SuppressionService
+}
+
+func NewSuppressionHandler(service services.SuppressionService) *SuppressionHandler {
+	return &SuppressionHandler{
+		suppressionService: service,
+	}
+}
+
+func (h *SuppressionHandler) CreateSuppression(c *gin.Context) {
+	var req dto.CreateSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suppression := &models.Suppression{
+		UserID:  c.GetString("userID"),
+		Channel: strings.ToLower(req.Channel),
+		Address: req.Address,
+		Reason:  strings.ToLower(req.Reason),
+		Note:    req.Note,
+		Source:  "api",
+	}
+
+	if err := h.suppressionService.CreateSuppression(c.Request.Context(), suppression); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrAlreadySuppressed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, suppression)
+}
+
+func (h *SuppressionHandler) GetSuppression(c *gin.Context) {
+	suppression, err := h.suppressionService.GetSuppression(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suppression not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppression)
+}
+
+func (h *SuppressionHandler) ListSuppressions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	suppressions, total, err := h.suppressionService.ListSuppressions(
+		c.Request.Context(), c.GetString("userID"), c.Query("channel"), c.Query("q"), page, limit,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  suppressions,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (h *SuppressionHandler) UpdateSuppression(c *gin.Context) {
+	var req dto.UpdateSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suppression, err := h.suppressionService.GetSuppression(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suppression not found"})
+		return
+	}
+
+	if req.Reason != "" {
+		suppression.Reason = strings.ToLower(req.Reason)
+	}
+	if req.Note != nil {
+		suppression.Note = *req.Note
+	}
+
+	if err := h.suppressionService.UpdateSuppression(c.Request.Context(), suppression); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppression)
+}
+
+// DeleteSuppression removes an address from the list, e.g. after the
+// recipient opted back in
+func (h *SuppressionHandler) DeleteSuppression(c *gin.Context) {
+	if err := h.suppressionService.DeleteSuppression(c.Request.Context(), c.Param("id"), c.GetString("userID")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ImportSuppressions adds the addresses of a CSV file, uploaded as the
+// "file" field of a multipart form or sent as a text/csv body
+func (h *SuppressionHandler) ImportSuppressions(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var file io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		opened, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer opened.Close()
+		file = opened
+	}
+
+	result, err := h.suppressionService.Import(
+		c.Request.Context(), c.GetString("userID"), file,
+		strings.ToLower(c.Query("channel")), strings.ToLower(c.Query("reason")),
+	)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
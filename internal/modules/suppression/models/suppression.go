@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Suppression is an address a user's workflows must not message, e.g.
+// because the recipient unsubscribed. Address is an email for the email
+// channel, a chat ID or @username for telegram and a channel or user ID for slack.
+type Suppression struct {
+	ID        string    `gorm:"type:uuid;primary_key" json:"id"`
+	UserID    string    `gorm:"type:uuid;not null;uniqueIndex:idx_suppressions_address" json:"userId"`
+	Channel   string    `gorm:"size:20;not null;default:'email';uniqueIndex:idx_suppressions_address" json:"channel"`
+	Address   string    `gorm:"size:320;not null;uniqueIndex:idx_suppressions_address" json:"address"`
+	Reason    string    `gorm:"size:50;not null" json:"reason"`
+	Source    string    `gorm:"size:255" json:"source,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Channels
+const (
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+	ChannelSlack    = "slack"
+)
+
+// Channels lists the supported channels
+var Channels = map[string]bool{ChannelEmail: true, ChannelTelegram: true, ChannelSlack: true}
+
+// Suppression reasons
+const (
+	ReasonUnsubscribe = "unsubscribe"
+	ReasonManual      = "manual"
+	ReasonImport      = "import"
+	ReasonBounce      = "bounce"
+	ReasonComplaint   = "complaint"
+)
+
+// Reasons lists the supported reasons
+var Reasons = map[string]bool{
+	ReasonUnsubscribe: true, ReasonManual: true, ReasonImport: true, ReasonBounce: true, ReasonComplaint: true,
+}
+
+// NormalizeAddress returns the form addresses are stored and matched in:
+// emails and telegram usernames are case-insensitive
+func NormalizeAddress(channel, address string) string {
+	address = strings.TrimSpace(address)
+	if channel == ChannelEmail || channel == ChannelTelegram && strings.HasPrefix(address, "@") {
+		return strings.ToLower(address)
+	}
+	return address
+}
+
+func (s *Suppression) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Suppression) TableName() string {
+	return "suppressions"
+}
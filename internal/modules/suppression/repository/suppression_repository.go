@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"s4s-backend/internal/modules/suppression/models"
+)
+
+// importBatchSize bounds the rows inserted per statement by AddMany
+const importBatchSize = 500
+
+type SuppressionRepository interface {
+	// Add stores the suppression; an address already on the list keeps its entry
+	Add(suppression *models.Suppression) error
+	// AddMany stores suppressions like Add and returns how many were new
+	AddMany(suppressions []*models.Suppression) (int64, error)
+	GetByID(id, userID string) (*models.Suppression, error)
+	// FindByUserID lists the user's suppressions, optionally filtered by
+	// channel and an address substring
+	FindByUserID(userID, channel, search string, page, limit int) ([]*models.Suppression, int64, error)
+	Update(suppression *models.Suppression) error
+	Delete(id, userID string) error
+	// FindSuppressed returns the addresses among addresses that are on the
+	// user's list for channel
+	FindSuppressed(userID, channel string, addresses []string) ([]string, error)
+}
+
+type suppressionRepository struct {
+	db *gorm.DB
+}
+
+func NewSuppressionRepository(db *gorm.DB) SuppressionRepository {
+	return &suppressionRepository{db: db}
+}
+
+func (r *suppressionRepository) Add(suppression *models.Suppression) error {
+	if suppression.Channel == "" {
+		suppression.Channel = models.ChannelEmail
+	}
+	suppression.Address = models.NormalizeAddress(suppression.Channel, suppression.Address)
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(suppression).Error
+}
+
+func (r *suppressionRepository) AddMany(suppressions []*models.Suppression) (int64, error) {
+	if len(suppressions) == 0 {
+		return 0, nil
+	}
+	for _, suppression := range suppressions {
+		if suppression.Channel == "" {
+			suppression.Channel = models.ChannelEmail
+		}
+		suppression.Address = models.NormalizeAddress(suppression.Channel, suppression.Address)
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(suppressions, importBatchSize)
+	return result.RowsAffected, result.Error
+}
+
+func (r *suppressionRepository) GetByID(id, userID string) (*models.Suppression, error) {
+	var suppression models.Suppression
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&suppression).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("suppression not found")
+		}
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+func (r *suppressionRepository) FindByUserID(userID, channel, search string, page, limit int) ([]*models.Suppression, int64, error) {
+	var suppressions []*models.Suppression
+	var total int64
+
+	query := r.db.Model(&models.Suppression{}).Where("user_id = ?", userID)
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if search != "" {
+		query = query.Where("address ILIKE ?", "%"+search+"%")
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&suppressions).Error
+	return suppressions, total, err
+}
+
+func (r *suppressionRepository) Update(suppression *models.Suppression) error {
+	return r.db.Save(suppression).Error
+}
+
+func (r *suppressionRepository) Delete(id, userID string) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Suppression{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("suppression not found")
+	}
+	return nil
+}
+
+func (r *suppressionRepository) FindSuppressed(userID, channel string, addresses []string) ([]string, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+	normalized := make([]string, len(addresses))
+	for i, address := range addresses {
+		normalized[i] = models.NormalizeAddress(channel, address)
+	}
+	var suppressed []string
+	err := r.db.Model(&models.Suppression{}).
+		Where("user_id = ? AND channel = ? AND address IN ?", userID, channel, normalized).
+		Pluck("address", &suppressed).Error
+	return suppressed, err
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"s4s-backend/internal/modules/suppression/dto"
+	"s4s-backend/internal/modules/suppression/models"
+	suppressionRepo "s4s-backend/internal/modules/suppression/repository"
+)
+
+const (
+	// MaxImportRows bounds the rows of one CSV import
+	MaxImportRows  = 100000
+	maxInvalidRows = 100
+)
+
+// ErrAlreadySuppressed is returned when adding an address that is already on the list
+var ErrAlreadySuppressed = errors.New("address is already suppressed")
+
+var (
+	telegramChatRe = regexp.MustCompile(`^(-?\d+|@[A-Za-z0-9_]{5,32})$`)
+	slackTargetRe  = regexp.MustCompile(`^#?[A-Za-z0-9._-]+$`)
+)
+
+type SuppressionService interface {
+	CreateSuppression(ctx context.Context, suppression *models.Suppression) error
+	GetSuppression(ctx context.Context, id, userID string) (*models.Suppression, error)
+	ListSuppressions(ctx context.Context, userID, channel, search string, page, limit int) ([]*models.Suppression, int64, error)
+	UpdateSuppression(ctx context.Context, suppression *models.Suppression) error
+	DeleteSuppression(ctx context.Context, id, userID string) error
+	// Import adds the addresses of a CSV file. The file may have a header
+	// row naming address (or email), channel, reason and note columns;
+	// otherwise the first column holds the addresses.
+	Import(ctx context.Context, userID string, file io.Reader, channel, reason string) (*dto.ImportResult, error)
+}
+
+type suppressionService struct {
+	repo suppressionRepo.SuppressionRepository
+}
+
+func NewSuppressionService(repo suppressionRepo.SuppressionRepository) SuppressionService {
+	return &suppressionService{repo: repo}
+}
+
+func (s *suppressionService) CreateSuppression(ctx context.Context, suppression *models.Suppression) error {
+	if err := validate(suppression); err != nil {
+		return err
+	}
+	existing, err := s.repo.FindSuppressed(suppression.UserID, suppression.Channel, []string{suppression.Address})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return ErrAlreadySuppressed
+	}
+	return s.repo.Add(suppression)
+}
+
+func (s *suppressionService) GetSuppression(ctx context.Context, id, userID string) (*models.Suppression, error) {
+	return s.repo.GetByID(id, userID)
+}
+
+func (s *suppressionService) ListSuppressions(ctx context.Context, userID, channel, search string, page, limit int) ([]*models.Suppression, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	return s.repo.FindByUserID(userID, channel, strings.TrimSpace(search), page, limit)
+}
+
+func (s *suppressionService) UpdateSuppression(ctx context.Context, suppression *models.Suppression) error {
+	if !models.Reasons[suppression.Reason] {
+		return fmt.Errorf("unknown reason: %s", suppression.Reason)
+	}
+	return s.repo.Update(suppression)
+}
+
+func (s *suppressionService) DeleteSuppression(ctx context.Context, id, userID string) error {
+	return s.repo.Delete(id, userID)
+}
+
+func (s *suppressionService) Import(ctx context.Context, userID string, file io.Reader, channel, reason string) (*dto.ImportResult, error) {
+	if channel == "" {
+		channel = models.ChannelEmail
+	}
+	if reason == "" {
+		reason = models.ReasonImport
+	}
+	if !models.Channels[channel] {
+		return nil, fmt.Errorf("unknown channel: %s", channel)
+	}
+	if !models.Reasons[reason] {
+		return nil, fmt.Errorf("unknown reason: %s", reason)
+	}
+
+	reader, err := csvReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.ImportResult{Invalid: []dto.InvalidRow{}}
+	columns := map[string]int{"address": 0, "channel": -1, "reason": -1, "note": -1}
+	seen := make(map[string]bool)
+	var batch []*models.Suppression
+	flush := func() error {
+		added, err := s.repo.AddMany(batch)
+		if err != nil {
+			return err
+		}
+		result.Imported += added
+		result.Duplicates += int64(len(batch)) - added
+		batch = batch[:0]
+		return nil
+	}
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if line > MaxImportRows+1 {
+			return nil, fmt.Errorf("a file may have at most %d rows", MaxImportRows)
+		}
+		if line == 1 && len(record) > 0 {
+			// spreadsheet exports often start with a byte order mark
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			if readHeader(record, columns) {
+				continue
+			}
+		}
+
+		cell := func(name string) string {
+			if i := columns[name]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		suppression := &models.Suppression{
+			UserID:  userID,
+			Channel: strings.ToLower(cell("channel")),
+			Address: cell("address"),
+			Reason:  strings.ToLower(cell("reason")),
+			Note:    cell("note"),
+			Source:  "import",
+		}
+		if suppression.Channel == "" {
+			suppression.Channel = channel
+		}
+		if suppression.Reason == "" {
+			suppression.Reason = reason
+		}
+		if suppression.Address == "" {
+			continue
+		}
+		if err := validate(suppression); err != nil {
+			result.InvalidCount++
+			if len(result.Invalid) < maxInvalidRows {
+				result.Invalid = append(result.Invalid, dto.InvalidRow{Line: line, Address: suppression.Address, Error: err.Error()})
+			}
+			continue
+		}
+
+		key := suppression.Channel + "\x00" + models.NormalizeAddress(suppression.Channel, suppression.Address)
+		if seen[key] {
+			result.Duplicates++
+			continue
+		}
+		seen[key] = true
+		batch = append(batch, suppression)
+		if len(batch) == 1000 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// validate checks the channel, reason and address of a suppression and
+// fills in defaults
+func validate(suppression *models.Suppression) error {
+	if suppression.Channel == "" {
+		suppression.Channel = models.ChannelEmail
+	}
+	if suppression.Reason == "" {
+		suppression.Reason = models.ReasonManual
+	}
+	if !models.Channels[suppression.Channel] {
+		return fmt.Errorf("unknown channel: %s", suppression.Channel)
+	}
+	if !models.Reasons[suppression.Reason] {
+		return fmt.Errorf("unknown reason: %s", suppression.Reason)
+	}
+
+	address := strings.TrimSpace(suppression.Address)
+	switch suppression.Channel {
+	case models.ChannelEmail:
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return errors.New("invalid email address")
+		}
+		// "Name <address>" is accepted; only the address is stored
+		address = parsed.Address
+	case models.ChannelTelegram:
+		if !telegramChatRe.MatchString(address) {
+			return errors.New("invalid telegram chat: expected a chat ID or @username")
+		}
+	case models.ChannelSlack:
+		if !slackTargetRe.MatchString(address) {
+			return errors.New("invalid slack channel or user ID")
+		}
+	}
+	suppression.Address = address
+	return nil
+}
+
+// csvReader detects whether the file is comma or semicolon separated, as
+// spreadsheet exports in many locales use semicolons
+func csvReader(file io.Reader) (*csv.Reader, error) {
+	buffered := bufio.NewReader(file)
+	first, err := buffered.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	firstLine := string(first)
+	if i := strings.IndexAny(firstLine, "\r\n"); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	reader := csv.NewReader(buffered)
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	return reader, nil
+}
+
+// readHeader maps known column names of a header row and reports whether
+// record is one
+func readHeader(record []string, columns map[string]int) bool {
+	found := make(map[string]int)
+	for i, name := range record {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "address", "email", "e-mail", "email address", "recipient":
+			found["address"] = i
+		case "channel":
+			found["channel"] = i
+		case "reason":
+			found["reason"] = i
+		case "note", "notes", "comment":
+			found["note"] = i
+		}
+	}
+	if _, ok := found["address"]; !ok {
+		return false
+	}
+	for name, i := range found {
+		columns[name] = i
+	}
+	return true
+}
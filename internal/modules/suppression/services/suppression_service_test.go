@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"s4s-backend/internal/modules/suppression/dto"
+	"s4s-backend/internal/modules/suppression/models"
+)
+
+// memoryRepository keeps suppressions in memory, keyed like the unique index
+type memoryRepository struct {
+	stored map[string]*models.Suppression
+	order  []string
+}
+
+func newMemoryRepository(existing ...*models.Suppression) *memoryRepository {
+	r := &memoryRepository{stored: map[string]*models.Suppression{}}
+	for _, suppression := range existing {
+		r.Add(suppression)
+	}
+	return r
+}
+
+func (r *memoryRepository) key(s *models.Suppression) string {
+	return s.UserID + "\x00" + s.Channel + "\x00" + models.NormalizeAddress(s.Channel, s.Address)
+}
+
+func (r *memoryRepository) Add(suppression *models.Suppression) error {
+	_, err := r.AddMany([]*models.Suppression{suppression})
+	return err
+}
+
+func (r *memoryRepository) AddMany(suppressions []*models.Suppression) (int64, error) {
+	var added int64
+	for _, suppression := range suppressions {
+		key := r.key(suppression)
+		if _, ok := r.stored[key]; ok {
+			continue
+		}
+		copied := *suppression
+		r.stored[key] = &copied
+		r.order = append(r.order, key)
+		added++
+	}
+	return added, nil
+}
+
+func (r *memoryRepository) GetByID(id, userID string) (*models.Suppression, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *memoryRepository) FindByUserID(userID, channel, search string, page, limit int) ([]*models.Suppression, int64, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (r *memoryRepository) Update(suppression *models.Suppression) error {
+	return errors.New("not implemented")
+}
+
+func (r *memoryRepository) Delete(id, userID string) error {
+	return errors.New("not implemented")
+}
+
+func (r *memoryRepository) FindSuppressed(userID, channel string, addresses []string) ([]string, error) {
+	var found []string
+	for _, address := range addresses {
+		if _, ok := r.stored[r.key(&models.Suppression{UserID: userID, Channel: channel, Address: address})]; ok {
+			found = append(found, address)
+		}
+	}
+	return found, nil
+}
+
+// describe lists the stored suppressions as "channel address reason note"
+func (r *memoryRepository) describe() []string {
+	var rows []string
+	for _, key := range r.order {
+		s := r.stored[key]
+		rows = append(rows, strings.TrimSpace(strings.Join([]string{s.Channel, s.Address, s.Reason, s.Note}, " ")))
+	}
+	return rows
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name            string
+		csv             string
+		channel, reason string
+		existing        []*models.Suppression
+		want            []string
+		wantResult      dto.ImportResult
+	}{
+		{
+			name:       "addresses in the first column",
+			csv:        "anna@example.com,ignored\nBoss <BOSS@example.com>\n\n  carl@example.com  \n",
+			want:       []string{"email anna@example.com import", "email BOSS@example.com import", "email carl@example.com import"},
+			wantResult: dto.ImportResult{Imported: 3},
+		},
+		{
+			name:       "header with semicolons and a byte order mark",
+			csv:        "\ufeffNote;E-Mail;Reason\nasked by phone;anna@example.com;unsubscribe\n;boss@example.com;\n",
+			want:       []string{"email anna@example.com unsubscribe asked by phone", "email boss@example.com import"},
+			wantResult: dto.ImportResult{Imported: 2},
+		},
+		{
+			name:    "channel column and default channel",
+			csv:     "address,channel\n@sales_team_bot,telegram\n-100123,\nC024BE91L,slack\n",
+			channel: models.ChannelTelegram, reason: models.ReasonManual,
+			want:       []string{"telegram @sales_team_bot manual", "telegram -100123 manual", "slack C024BE91L manual"},
+			wantResult: dto.ImportResult{Imported: 3},
+		},
+		{
+			name:       "duplicates in the file and on the list",
+			csv:        "email\nanna@example.com\nANNA@example.com\nboss@example.com\n",
+			existing:   []*models.Suppression{{UserID: "user-1", Channel: "email", Address: "boss@example.com", Reason: "manual"}},
+			want:       []string{"email boss@example.com manual", "email anna@example.com import"},
+			wantResult: dto.ImportResult{Imported: 1, Duplicates: 2},
+		},
+		{
+			name: "invalid rows are reported with their line",
+			csv:  "email,reason\nnot an address,\nanna@example.com,spite\n\"\"\"Dora, Ops\"\" <dora@example.com>\",bounce\n",
+			want: []string{"email dora@example.com bounce"},
+			wantResult: dto.ImportResult{Imported: 1, InvalidCount: 2, Invalid: []dto.InvalidRow{
+				{Line: 2, Address: "not an address", Error: "invalid email address"},
+				{Line: 3, Address: "anna@example.com", Error: "unknown reason: spite"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemoryRepository(tt.existing...)
+			service := NewSuppressionService(repo)
+			result, err := service.Import(context.Background(), "user-1", strings.NewReader(tt.csv), tt.channel, tt.reason)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if got := repo.describe(); !slices.Equal(got, tt.want) {
+				t.Errorf("stored:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if tt.wantResult.Invalid == nil {
+				tt.wantResult.Invalid = []dto.InvalidRow{}
+			}
+			if result.Imported != tt.wantResult.Imported || result.Duplicates != tt.wantResult.Duplicates ||
+				result.InvalidCount != tt.wantResult.InvalidCount || !slices.Equal(result.Invalid, tt.wantResult.Invalid) {
+				t.Errorf("result = %+v, want %+v", *result, tt.wantResult)
+			}
+			for _, key := range repo.order {
+				if s := repo.stored[key]; s.UserID != "user-1" || (s.Source != "import" && tt.existing == nil) {
+					t.Errorf("stored %+v for the wrong user or source", s)
+				}
+			}
+		})
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	service := NewSuppressionService(newMemoryRepository())
+	tests := []struct {
+		name, csv, channel, reason, wantErr string
+	}{
+		{name: "unknown channel", csv: "anna@example.com\n", channel: "fax", wantErr: "unknown channel: fax"},
+		{name: "unknown reason", csv: "anna@example.com\n", reason: "spite", wantErr: "unknown reason: spite"},
+		{name: "too many rows", csv: strings.Repeat("a@example.com\n", MaxImportRows+2), wantErr: "at most"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Import(context.Background(), "user-1", strings.NewReader(tt.csv), tt.channel, tt.reason)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Import() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
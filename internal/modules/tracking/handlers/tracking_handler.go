@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"s4s-backend/internal/modules/tracking/services"
+)
+
+// transparentGIF is a 1x1 transparent GIF served as the open tracking pixel
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Unsubscribe</title></head>
+<body style="font-family:sans-serif;max-width:480px;margin:48px auto;padding:0 16px">
+{{if .Done}}<p>You have been unsubscribed. You will not receive further emails from this sender.</p>
+{{else if .Invalid}}<p>This unsubscribe link is invalid or has expired.</p>
+{{else}}<p>Stop receiving emails from this sender at {{.Recipient}}?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}</body></html>
+`))
+
+type TrackingHandler struct {
+	trackingService services.TrackingService
+}
+
+func NewTrackingHandler(trackingService services.TrackingService) *TrackingHandler {
+	return &TrackingHandler{trackingService: trackingService}
+}
+
+// Open records an email open and always serves the pixel, so a bad link
+// never shows a broken image
+func (h *TrackingHandler) Open(c *gin.Context) {
+	id := strings.TrimSuffix(c.Param("id"), ".gif")
+	if err := h.trackingService.RecordOpen(c.Request.Context(), id, c.Query("s"), client(c)); err != nil && !errors.Is(err, services.ErrInvalidLink) {
+		log.Printf("tracked email %s: failed to record open: %v", id, err)
+	}
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	c.Data(http.StatusOK, "image/gif", transparentGIF)
+}
+
+// Click records a click and redirects to the original link
+func (h *TrackingHandler) Click(c *gin.Context) {
+	target, err := h.trackingService.RecordClick(c.Request.Context(), c.Param("id"), c.Query("u"), c.Query("s"), client(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, target)
+}
+
+// UnsubscribeForm asks the recipient to confirm; link scanners that fetch
+// every URL of a message must not unsubscribe anyone
+func (h *TrackingHandler) UnsubscribeForm(c *gin.Context) {
+	recipient, err := h.trackingService.CheckUnsubscribe(c.Param("id"), c.Query("s"))
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		unsubscribePage.Execute(c.Writer, gin.H{"Invalid": true})
+		return
+	}
+	c.Status(http.StatusOK)
+	unsubscribePage.Execute(c.Writer, gin.H{"Recipient": recipient})
+}
+
+// Unsubscribe adds the link's recipient to the sender's suppression list. It also
+// serves one-click unsubscribe POSTs from mail clients (RFC 8058).
+func (h *TrackingHandler) Unsubscribe(c *gin.Context) {
+	err := h.trackingService.Unsubscribe(c.Request.Context(), c.Param("id"), c.Query("s"), client(c))
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	switch {
+	case errors.Is(err, services.ErrInvalidLink):
+		c.Status(http.StatusNotFound)
+		unsubscribePage.Execute(c.Writer, gin.H{"Invalid": true})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+	default:
+		c.Status(http.StatusOK)
+		unsubscribePage.Execute(c.Writer, gin.H{"Done": true})
+	}
+}
+
+func client(c *gin.Context) services.Client {
+	return services.Client{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	suppressionModels "s4s-backend/internal/modules/suppression/models"
+	suppressionRepo "s4s-backend/internal/modules/suppression/repository"
+	"s4s-backend/internal/modules/tracking/models"
+	"s4s-backend/internal/modules/tracking/services"
+	"s4s-backend/internal/pkg/tracking"
+)
+
+// memoryTracking keeps tracked emails and events in memory
+type memoryTracking struct {
+	emails map[string]*models.TrackedEmail
+	events []*models.EmailEvent
+}
+
+func (m *memoryTracking) CreateEmail(email *models.TrackedEmail) error {
+	m.emails[email.ID] = email
+	return nil
+}
+
+func (m *memoryTracking) GetEmail(id string) (*models.TrackedEmail, error) {
+	if email, ok := m.emails[id]; ok {
+		return email, nil
+	}
+	return nil, errors.New("tracked email not found")
+}
+
+func (m *memoryTracking) CreateEvent(event *models.EmailEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memoryTracking) CountEvents(trackedEmailID, eventType string) (int64, error) {
+	return 0, nil
+}
+
+// memorySuppressions records added suppressions; other methods are unused
+type memorySuppressions struct {
+	suppressionRepo.SuppressionRepository
+	added []*suppressionModels.Suppression
+}
+
+func (m *memorySuppressions) Add(suppression *suppressionModels.Suppression) error {
+	m.added = append(m.added, suppression)
+	return nil
+}
+
+func TestUnsubscribeCoversOnlyTheLinkRecipient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	links := tracking.NewLinks("https://api.example.com/api/v1", "tracking-secret")
+	repo := &memoryTracking{emails: map[string]*models.TrackedEmail{
+		"11111111-1111-1111-1111-111111111111": {
+			ID: "11111111-1111-1111-1111-111111111111", UserID: "user-1", Recipients: models.Recipients{"anna@example.com"},
+		},
+		"22222222-2222-2222-2222-222222222222": {
+			ID: "22222222-2222-2222-2222-222222222222", UserID: "user-1", Recipients: models.Recipients{"boss@example.com"},
+		},
+		// a row listing several recipients names nobody in particular
+		"33333333-3333-3333-3333-333333333333": {
+			ID: "33333333-3333-3333-3333-333333333333", UserID: "user-1", Recipients: models.Recipients{"anna@example.com", "audit@example.com"},
+		},
+	}}
+	suppressions := &memorySuppressions{}
+	handler := NewTrackingHandler(services.NewTrackingService(repo, suppressions, links, nil))
+
+	router := gin.New()
+	router.GET("/t/u/:id", handler.UnsubscribeForm)
+	router.POST("/t/u/:id", handler.Unsubscribe)
+	serve := func(method, id, signature string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/t/u/"+id+"?s="+signature, nil))
+		return w
+	}
+
+	anna := "11111111-1111-1111-1111-111111111111"
+	page := serve(http.MethodGet, anna, links.Sign(tracking.KindUnsubscribe, anna, ""))
+	if page.Code != http.StatusOK || !strings.Contains(page.Body.String(), "anna@example.com") {
+		t.Fatalf("form: %d %s", page.Code, page.Body.String())
+	}
+	if strings.Contains(page.Body.String(), "boss@example.com") || strings.Contains(page.Body.String(), "audit@example.com") {
+		t.Fatalf("form shows other recipients: %s", page.Body.String())
+	}
+
+	if w := serve(http.MethodPost, anna, links.Sign(tracking.KindUnsubscribe, anna, "")); w.Code != http.StatusOK {
+		t.Fatalf("unsubscribe: %d %s", w.Code, w.Body.String())
+	}
+	if len(suppressions.added) != 1 || suppressions.added[0].Address != "anna@example.com" || suppressions.added[0].Source != anna {
+		t.Fatalf("suppressed %+v, want only anna@example.com", suppressions.added)
+	}
+	if len(repo.events) != 1 || repo.events[0].Type != models.EventUnsubscribe {
+		t.Errorf("events = %+v", repo.events)
+	}
+
+	// another recipient's link signature does not carry over
+	boss := "22222222-2222-2222-2222-222222222222"
+	if w := serve(http.MethodPost, boss, links.Sign(tracking.KindUnsubscribe, anna, "")); w.Code != http.StatusNotFound {
+		t.Errorf("forged link: %d", w.Code)
+	}
+	shared := "33333333-3333-3333-3333-333333333333"
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		w := serve(method, shared, links.Sign(tracking.KindUnsubscribe, shared, ""))
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "@example.com") {
+			t.Errorf("%s link with several recipients: %d %s", method, w.Code, w.Body.String())
+		}
+	}
+	if len(suppressions.added) != 1 {
+		t.Errorf("suppressed %d addresses, want 1", len(suppressions.added))
+	}
+}
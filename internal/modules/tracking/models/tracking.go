@@ -0,0 +1,92 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TrackedEmail is one recipient's copy of an outgoing email with tracking
+// links. Its ID is the one the links are signed with. Recipients holds that
+// single recipient. EventDepth is the event depth of the run that sent it,
+// passed on with its events so email_event triggers cannot loop.
+type TrackedEmail struct {
+	ID          string     `gorm:"type:uuid;primary_key" json:"id"`
+	UserID      string     `gorm:"type:uuid;not null;index" json:"userId"`
+	WorkflowID  string     `gorm:"type:uuid;index" json:"workflowId"`
+	ExecutionID string     `gorm:"type:uuid;index" json:"executionId"`
+	NodeID      string     `gorm:"size:255" json:"nodeId"`
+	MessageID   string     `gorm:"size:998" json:"messageId"`
+	Recipients  Recipients `gorm:"type:jsonb;not null" json:"recipients"`
+	Subject     string     `json:"subject"`
+	EventDepth  int        `gorm:"not null;default:0" json:"eventDepth"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type Recipients []string
+
+func (r Recipients) Value() (driver.Value, error) {
+	if r == nil {
+		return "[]", nil
+	}
+	return json.Marshal(r)
+}
+
+func (r *Recipients) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, r)
+}
+
+func (e *TrackedEmail) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TrackedEmail) TableName() string {
+	return "tracked_emails"
+}
+
+// Email event types
+const (
+	EventOpen        = "open"
+	EventClick       = "click"
+	EventUnsubscribe = "unsubscribe"
+)
+
+// EmailEvent is an open, click or unsubscribe of a tracked email
+type EmailEvent struct {
+	ID             string    `gorm:"type:uuid;primary_key" json:"id"`
+	TrackedEmailID string    `gorm:"type:uuid;not null;index" json:"trackedEmailId"`
+	UserID         string    `gorm:"type:uuid;not null;index" json:"userId"`
+	WorkflowID     string    `gorm:"type:uuid" json:"workflowId"`
+	ExecutionID    string    `gorm:"type:uuid;index" json:"executionId"`
+	MessageID      string    `gorm:"size:998" json:"messageId"`
+	Type           string    `gorm:"size:20;not null" json:"type"`
+	URL            string    `json:"url,omitempty"`
+	IP             string    `gorm:"size:64" json:"ip"`
+	UserAgent      string    `json:"userAgent"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func (e *EmailEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (EmailEvent) TableName() string {
+	return "email_events"
+}
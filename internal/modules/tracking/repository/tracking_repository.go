@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"s4s-backend/internal/modules/tracking/models"
+)
+
+type TrackingRepository interface {
+	CreateEmail(email *models.TrackedEmail) error
+	GetEmail(id string) (*models.TrackedEmail, error)
+	CreateEvent(event *models.EmailEvent) error
+	// CountEvents returns how many events of eventType the email already has
+	CountEvents(trackedEmailID, eventType string) (int64, error)
+}
+
+type trackingRepository struct {
+	db *gorm.DB
+}
+
+func NewTrackingRepository(db *gorm.DB) TrackingRepository {
+	return &trackingRepository{db: db}
+}
+
+func (r *trackingRepository) CreateEmail(email *models.TrackedEmail) error {
+	return r.db.Create(email).Error
+}
+
+func (r *trackingRepository) GetEmail(id string) (*models.TrackedEmail, error) {
+	var email models.TrackedEmail
+	err := r.db.Where("id = ?", id).First(&email).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("tracked email not found")
+		}
+		return nil, err
+	}
+	return &email, nil
+}
+
+func (r *trackingRepository) CreateEvent(event *models.EmailEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *trackingRepository) CountEvents(trackedEmailID, eventType string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.EmailEvent{}).
+		Where("tracked_email_id = ? AND type = ?", trackedEmailID, eventType).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	suppressionModels "s4s-backend/internal/modules/suppression/models"
+	suppressionRepo "s4s-backend/internal/modules/suppression/repository"
+	"s4s-backend/internal/modules/tracking/models"
+	"s4s-backend/internal/modules/tracking/repository"
+	"s4s-backend/internal/pkg/tracking"
+)
+
+// ErrInvalidLink is returned for tracking links with a wrong signature or
+// an unknown message
+var ErrInvalidLink = errors.New("invalid tracking link")
+
+// EventDispatcher starts the workflows triggered by email events
+type EventDispatcher interface {
+	DispatchEmailEvent(ctx context.Context, userID string, event map[string]interface{})
+}
+
+// Client identifies who opened or clicked
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type TrackingService interface {
+	RecordOpen(ctx context.Context, id, signature string, client Client) error
+	// RecordClick returns the URL to redirect to
+	RecordClick(ctx context.Context, id, target, signature string, client Client) (string, error)
+	// CheckUnsubscribe validates an unsubscribe link and returns the recipient it covers
+	CheckUnsubscribe(id, signature string) (string, error)
+	Unsubscribe(ctx context.Context, id, signature string, client Client) error
+}
+
+type trackingService struct {
+	repo         repository.TrackingRepository
+	suppressions suppressionRepo.SuppressionRepository
+	links        *tracking.Links
+	dispatcher   EventDispatcher
+}
+
+func NewTrackingService(
+	repo repository.TrackingRepository,
+	suppressions suppressionRepo.SuppressionRepository,
+	links *tracking.Links,
+	dispatcher EventDispatcher,
+) TrackingService {
+	return &trackingService{repo: repo, suppressions: suppressions, links: links, dispatcher: dispatcher}
+}
+
+func (s *trackingService) RecordOpen(ctx context.Context, id, signature string, client Client) error {
+	email, err := s.verify(tracking.KindOpen, id, "", signature)
+	if err != nil {
+		return err
+	}
+	return s.record(ctx, email, models.EventOpen, "", client)
+}
+
+func (s *trackingService) RecordClick(ctx context.Context, id, target, signature string, client Client) (string, error) {
+	email, err := s.verify(tracking.KindClick, id, target, signature)
+	if err != nil {
+		return "", err
+	}
+	if err := s.record(ctx, email, models.EventClick, target, client); err != nil {
+		// the recipient still gets where they wanted to go
+		log.Printf("tracked email %s: failed to record click: %v", id, err)
+	}
+	return target, nil
+}
+
+func (s *trackingService) CheckUnsubscribe(id, signature string) (string, error) {
+	email, err := s.verify(tracking.KindUnsubscribe, id, "", signature)
+	if err != nil {
+		return "", err
+	}
+	return unsubscribeRecipient(email)
+}
+
+// Unsubscribe adds the one recipient the link was issued to to the sender's
+// suppression list
+func (s *trackingService) Unsubscribe(ctx context.Context, id, signature string, client Client) error {
+	email, err := s.verify(tracking.KindUnsubscribe, id, "", signature)
+	if err != nil {
+		return err
+	}
+	recipient, err := unsubscribeRecipient(email)
+	if err != nil {
+		return err
+	}
+	err = s.suppressions.Add(&suppressionModels.Suppression{
+		UserID:  email.UserID,
+		Channel: suppressionModels.ChannelEmail,
+		Address: recipient,
+		Reason:  suppressionModels.ReasonUnsubscribe,
+		Source:  email.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return s.record(ctx, email, models.EventUnsubscribe, "", client)
+}
+
+// unsubscribeRecipient returns the recipient of a tracked copy. Every copy
+// has exactly one, so a link can never unsubscribe or reveal anyone else;
+// anything else is refused as an invalid link.
+func unsubscribeRecipient(email *models.TrackedEmail) (string, error) {
+	if len(email.Recipients) != 1 {
+		return "", ErrInvalidLink
+	}
+	return email.Recipients[0], nil
+}
+
+func (s *trackingService) verify(kind, id, target, signature string) (*models.TrackedEmail, error) {
+	if !s.links.Verify(kind, id, target, signature) {
+		return nil, ErrInvalidLink
+	}
+	email, err := s.repo.GetEmail(id)
+	if err != nil {
+		return nil, ErrInvalidLink
+	}
+	return email, nil
+}
+
+// record stores the event and starts the workflows it triggers
+func (s *trackingService) record(ctx context.Context, email *models.TrackedEmail, eventType, url string, client Client) error {
+	previous, err := s.repo.CountEvents(email.ID, eventType)
+	if err != nil {
+		return err
+	}
+	event := &models.EmailEvent{
+		TrackedEmailID: email.ID,
+		UserID:         email.UserID,
+		WorkflowID:     email.WorkflowID,
+		ExecutionID:    email.ExecutionID,
+		MessageID:      email.MessageID,
+		Type:           eventType,
+		URL:            url,
+		IP:             client.IP,
+		UserAgent:      client.UserAgent,
+	}
+	if err := s.repo.CreateEvent(event); err != nil {
+		return err
+	}
+
+	if s.dispatcher == nil {
+		return nil
+	}
+	recipient := ""
+	if len(email.Recipients) > 0 {
+		recipient = email.Recipients[0]
+	}
+	recipients := make([]interface{}, len(email.Recipients))
+	for i, r := range email.Recipients {
+		recipients[i] = r
+	}
+	s.dispatcher.DispatchEmailEvent(ctx, email.UserID, map[string]interface{}{
+		"event":               eventType,
+		"first":               previous == 0,
+		"url":                 url,
+		"tracking_id":         email.ID,
+		"message_id":          email.MessageID,
+		"subject":             email.Subject,
+		"recipient":           recipient,
+		"recipients":          recipients,
+		"source_workflow_id":  email.WorkflowID,
+		"source_execution_id": email.ExecutionID,
+		"source_node_id":      email.NodeID,
+		"event_depth":         email.EventDepth,
+		"ip":                  client.IP,
+		"user_agent":          client.UserAgent,
+		"occurred_at":         event.CreatedAt.UTC().Format(time.RFC3339),
+	})
+	return nil
+}
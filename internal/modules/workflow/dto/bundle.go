@@ -0,0 +1,99 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// BundleFormat identifies workflow bundle files
+	BundleFormat = "s4s.workflow-bundle"
+	// BundleVersion is the bundle format version this server writes and reads
+	BundleVersion = 1
+)
+
+// Bundle is a portable export of one or more workflows. It never contains
+// credentials: connections and rule sets are replaced by placeholders the
+// importing user binds to their own, and credentials written into node
+// configs are removed.
+type Bundle struct {
+	Format       string              `json:"format"`
+	Version      int                 `json:"version"`
+	ExportedAt   time.Time           `json:"exportedAt"`
+	Workflows    []BundleWorkflow    `json:"workflows"`
+	Placeholders []BundlePlaceholder `json:"placeholders"`
+	// Warnings lists the node settings removed because they held credentials
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type BundleWorkflow struct {
+	// Ref identifies the workflow within the bundle, e.g. "workflow-1"
+	Ref      string          `json:"ref"`
+	Name     string          `json:"name"`
+	Graph    json.RawMessage `json:"graph"`
+	Settings BundleSettings  `json:"settings"`
+	Tags     []string        `json:"tags"`
+	// References are node settings that point at other workflows of the bundle
+	References []BundleReference `json:"references"`
+}
+
+type BundleSettings struct {
+	MaxTimeout int `json:"maxTimeout"`
+	RetryCount int `json:"retryCount"`
+	RetryDelay int `json:"retryDelay"`
+}
+
+// BundleReference is a node config field holding the ID of another bundled workflow
+type BundleReference struct {
+	NodeID   string `json:"nodeId"`
+	Field    string `json:"field"`
+	Workflow string `json:"workflow"`
+}
+
+// BundlePlaceholder stands for a connection or rule set of the exporting user
+type BundlePlaceholder struct {
+	Key string `json:"key"`
+	// Kind is "connection" or "rule_set"
+	Kind string `json:"kind"`
+	// Service is the connection's service, e.g. "hubspot"
+	Service string `json:"service,omitempty"`
+	// Name is the exporter's name for it, as a hint when binding
+	Name   string        `json:"name"`
+	Usages []BundleUsage `json:"usages"`
+}
+
+// BundleUsage is a node config field that holds a placeholder
+type BundleUsage struct {
+	Workflow string `json:"workflow"`
+	NodeID   string `json:"nodeId"`
+	Field    string `json:"field"`
+}
+
+// ImportBundleRequest carries either a bundle or an n8n workflow export,
+// which is converted into a bundle
+type ImportBundleRequest struct {
+	Bundle *Bundle         `json:"bundle" binding:"required_without=N8n"`
+	N8n    json.RawMessage `json:"n8n" binding:"required_without=Bundle"`
+	// Bindings maps placeholder keys to the importing user's connection or rule set IDs
+	Bindings map[string]string `json:"bindings"`
+}
+
+// UnboundPlaceholder is a placeholder the import request did not bind, with
+// the user's connections or rule sets that could be bound to it
+type UnboundPlaceholder struct {
+	BundlePlaceholder
+	Candidates []BindingCandidate `json:"candidates"`
+}
+
+type BindingCandidate struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ImportResult maps bundle refs to the IDs of the created workflows
+type ImportResult struct {
+	Workflows map[string]string `json:"workflows"`
+	// Warnings lists what an n8n import could not carry over and the
+	// settings the bundle's export removed
+	Warnings []string `json:"warnings,omitempty"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+type UseTemplateRequest struct {
+	// Name of the new workflow; defaults to the template's name
+	Name string `json:"name"`
+	// Parameters maps parameter keys to values; omitted ones use their default
+	Parameters map[string]interface{} `json:"parameters"`
+}
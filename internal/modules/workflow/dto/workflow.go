@@ -1,20 +1,30 @@
 package dto
 
 type CreateWorkflowRequest struct {
-	Name       string `json:"name" binding:"required"`
-	JSON       string `json:"json" binding:"required"`
-	MaxTimeout int    `json:"maxTimeout"`
-	RetryCount int    `json:"retryCount"`
-	RetryDelay int    `json:"retryDelay"`
+	Name       string   `json:"name" binding:"required"`
+	JSON       string   `json:"json" binding:"required"`
+	MaxTimeout int      `json:"maxTimeout"`
+	RetryCount int      `json:"retryCount"`
+	RetryDelay int      `json:"retryDelay"`
+	Tags       []string `json:"tags"`
+	// Message describes the first version
+	Message string `json:"message"`
 }
 
 type UpdateWorkflowRequest struct {
-	Name       string `json:"name"`
-	JSON       string `json:"json"`
-	Active     *bool  `json:"active"`
-	MaxTimeout int    `json:"maxTimeout"`
-	RetryCount int    `json:"retryCount"`
-	RetryDelay int    `json:"retryDelay"`
+	Name       string   `json:"name"`
+	JSON       string   `json:"json"`
+	Active     *bool    `json:"active"`
+	MaxTimeout int      `json:"maxTimeout"`
+	RetryCount int      `json:"retryCount"`
+	RetryDelay int      `json:"retryDelay"`
+	Tags       []string `json:"tags"`
+	// Message describes the version the save creates
+	Message string `json:"message"`
+}
+
+type RestoreVersionRequest struct {
+	Message string `json:"message"`
 }
 
 type TestWorkflowRequest struct {
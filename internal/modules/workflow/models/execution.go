@@ -9,6 +9,7 @@ import (
 type Execution struct {
 	ID              string     `gorm:"type:uuid;primary_key" json:"id"`
 	WorkflowID      string     `gorm:"type:uuid;not null" json:"workflowId"`
+	WorkflowVersion int        `json:"workflowVersion"`
 	Status          string     `gorm:"default:'pending'" json:"status"`
 	Log             string     `gorm:"type:text" json:"log"`
 	IsTest          bool       `gorm:"default:false" json:"isTest"`
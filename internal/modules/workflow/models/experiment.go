@@ -0,0 +1,54 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ExperimentAssignment is the variant a split node gave a lead. Leads are
+// identified by the SHA-256 of their key, so the assignment stays sticky
+// without storing contact data.
+type ExperimentAssignment struct {
+	ID          string    `gorm:"type:uuid;primary_key" json:"id"`
+	WorkflowID  string    `gorm:"type:uuid;not null;uniqueIndex:idx_experiment_assignments_key" json:"workflowId"`
+	NodeID      string    `gorm:"size:255;not null;uniqueIndex:idx_experiment_assignments_key" json:"nodeId"`
+	KeyHash     string    `gorm:"size:64;not null;uniqueIndex:idx_experiment_assignments_key" json:"keyHash"`
+	Variant     string    `gorm:"size:255;not null" json:"variant"`
+	ExecutionID string    `gorm:"type:uuid" json:"executionId"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (a *ExperimentAssignment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ExperimentAssignment) TableName() string {
+	return "experiment_assignments"
+}
+
+// ExperimentOutcome is a goal, e.g. a conversion, reached by an assigned lead
+type ExperimentOutcome struct {
+	ID          string    `gorm:"type:uuid;primary_key" json:"id"`
+	WorkflowID  string    `gorm:"type:uuid;not null;uniqueIndex:idx_experiment_outcomes_key" json:"workflowId"`
+	NodeID      string    `gorm:"size:255;not null;uniqueIndex:idx_experiment_outcomes_key" json:"nodeId"`
+	KeyHash     string    `gorm:"size:64;not null;uniqueIndex:idx_experiment_outcomes_key" json:"keyHash"`
+	Goal        string    `gorm:"size:255;not null;uniqueIndex:idx_experiment_outcomes_key" json:"goal"`
+	Variant     string    `gorm:"size:255;not null" json:"variant"`
+	ExecutionID string    `gorm:"type:uuid" json:"executionId"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (o *ExperimentOutcome) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ExperimentOutcome) TableName() string {
+	return "experiment_outcomes"
+}
@@ -1,18 +1,76 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
 )
 
+// Template is a workflow graph users start from. The graph refers to its
+// parameters as {{params.key}}; they are filled in when a workflow is
+// created from the template.
 type Template struct {
-	ID        string    `gorm:"type:uuid;primary_key" json:"id"`
-	Name      string    `gorm:"not null" json:"name"`
-	Category  string    `gorm:"not null" json:"category"`
-	JSON      string    `gorm:"type:text;not null" json:"json"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID          string             `gorm:"type:uuid;primary_key" json:"id"`
+	Name        string             `gorm:"not null" json:"name"`
+	Description string             `gorm:"type:text" json:"description"`
+	Category    string             `gorm:"not null" json:"category"`
+	Tags        Tags               `gorm:"type:jsonb;default:'[]'" json:"tags"`
+	JSON        string             `gorm:"type:text;not null" json:"json"`
+	Parameters  TemplateParameters `gorm:"type:jsonb;default:'[]'" json:"parameters"`
+	IsActive    bool               `gorm:"default:true;not null" json:"isActive"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// Template parameter types
+const (
+	ParameterString     = "string"
+	ParameterNumber     = "number"
+	ParameterBoolean    = "boolean"
+	ParameterConnection = "connection"
+	ParameterRuleSet    = "rule_set"
+)
+
+// TemplateParameter is a value the user supplies when using a template,
+// e.g. a Slack channel, a CRM connection or a lead score threshold
+type TemplateParameter struct {
+	Key         string `json:"key"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	// Type is string, number, boolean, connection or rule_set
+	Type string `json:"type"`
+	// Service limits connection parameters to one service, e.g. "slack"
+	Service  string      `json:"service,omitempty"`
+	Required bool        `json:"required"`
+	Default  interface{} `json:"default,omitempty"`
+	// Options limits a string parameter to these values
+	Options []string `json:"options,omitempty"`
+	// Min and Max bound a number parameter
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+type TemplateParameters []TemplateParameter
+
+func (p TemplateParameters) Value() (driver.Value, error) {
+	if p == nil {
+		return "[]", nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *TemplateParameters) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, p)
 }
 
 func (t *Template) BeforeCreate(tx *gorm.DB) error {
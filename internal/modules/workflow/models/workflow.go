@@ -1,11 +1,16 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"time"
 )
 
+// Workflow is edited as a draft (JSON); triggers and production runs use the
+// graph last published from it (PublishedJSON)
 type Workflow struct {
 	ID              string    `gorm:"type:uuid;primary_key" json:"id"`
 	UserID          string    `gorm:"type:uuid;not null" json:"userId"`
@@ -16,11 +21,41 @@ type Workflow struct {
 	RetryCount      int       `gorm:"default:3" json:"retryCount"`
 	RetryDelay      int       `gorm:"default:60" json:"retryDelay"`
 	TriggerType     string    `json:"triggerType"`
+	Version         int       `gorm:"default:1;not null" json:"version"`
+	Tags            Tags      `gorm:"type:jsonb;default:'[]'" json:"tags"`
 	TotalExecutions int       `gorm:"default:0" json:"totalExecutions"`
 	SuccessCount    int       `gorm:"default:0" json:"successCount"`
 	ErrorCount      int       `gorm:"default:0" json:"errorCount"`
 	CreatedAt       time.Time `json:"createdAt"`
 	UpdatedAt       time.Time `json:"updatedAt"`
+
+	// PublishedJSON is the graph production runs use; empty until the first publish
+	PublishedJSON    string     `gorm:"type:text" json:"publishedJson,omitempty"`
+	PublishedVersion int        `gorm:"default:0;not null" json:"publishedVersion"`
+	PublishedAt      *time.Time `json:"publishedAt,omitempty"`
+	// UnpublishedChanges reports a draft that differs from the published graph
+	UnpublishedChanges bool `gorm:"-" json:"unpublishedChanges"`
+}
+
+// Tags are free-form labels used to organize workflows
+type Tags []string
+
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	return json.Marshal(t)
+}
+
+func (t *Tags) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, t)
 }
 
 func (w *Workflow) BeforeCreate(tx *gorm.DB) error {
@@ -30,6 +65,26 @@ func (w *Workflow) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+func (w *Workflow) AfterFind(tx *gorm.DB) error {
+	w.UnpublishedChanges = w.HasUnpublishedChanges()
+	return nil
+}
+
+func (w *Workflow) AfterSave(tx *gorm.DB) error {
+	w.UnpublishedChanges = w.HasUnpublishedChanges()
+	return nil
+}
+
+// IsPublished reports whether the workflow has a published graph
+func (w *Workflow) IsPublished() bool {
+	return w.PublishedVersion > 0
+}
+
+// HasUnpublishedChanges reports whether the draft differs from the published graph
+func (w *Workflow) HasUnpublishedChanges() bool {
+	return !w.IsPublished() || w.JSON != w.PublishedJSON
+}
+
 func (Workflow) TableName() string {
 	return "workflows"
 }
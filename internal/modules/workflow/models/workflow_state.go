@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// WorkflowState is durable per-workflow state kept by triggers and nodes
+// between executions, e.g. the last seen IMAP UID
+type WorkflowState struct {
+	ID         string    `gorm:"type:uuid;primary_key" json:"id"`
+	WorkflowID string    `gorm:"type:uuid;not null;uniqueIndex:idx_workflow_states_key" json:"workflowId"`
+	Key        string    `gorm:"size:255;not null;uniqueIndex:idx_workflow_states_key" json:"key"`
+	Value      string    `gorm:"type:jsonb;not null" json:"value"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func (s *WorkflowState) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (WorkflowState) TableName() string {
+	return "workflow_states"
+}
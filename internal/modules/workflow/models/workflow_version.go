@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// WorkflowVersion is an immutable snapshot of a workflow, written on every
+// save that changes its definition or settings
+type WorkflowVersion struct {
+	ID         string `gorm:"type:uuid;primary_key" json:"id"`
+	WorkflowID string `gorm:"type:uuid;not null;uniqueIndex:idx_workflow_versions_number" json:"workflowId"`
+	Version    int    `gorm:"not null;uniqueIndex:idx_workflow_versions_number" json:"version"`
+	Name       string `gorm:"not null" json:"name"`
+	// JSON is omitted from version listings
+	JSON       string `gorm:"type:text;not null" json:"json,omitempty"`
+	MaxTimeout int    `json:"maxTimeout"`
+	RetryCount int    `json:"retryCount"`
+	RetryDelay int    `json:"retryDelay"`
+	AuthorID   string `gorm:"type:uuid" json:"authorId"`
+	Message    string `gorm:"type:text" json:"message"`
+	// RestoredFrom is the version this one was restored from
+	RestoredFrom *int      `json:"restoredFrom,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (v *WorkflowVersion) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (WorkflowVersion) TableName() string {
+	return "workflow_versions"
+}
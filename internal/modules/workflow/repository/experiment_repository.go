@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+
+	"s4s-backend/internal/modules/workflow/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExperimentRepository struct {
+	db *gorm.DB
+}
+
+func NewExperimentRepository(db *gorm.DB) *ExperimentRepository {
+	return &ExperimentRepository{db: db}
+}
+
+// Assign stores assignment unless the lead already has one and returns the
+// stored assignment, so concurrent executions agree on the variant
+func (r *ExperimentRepository) Assign(assignment *models.ExperimentAssignment) (*models.ExperimentAssignment, error) {
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(assignment).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.FindAssignment(assignment.WorkflowID, assignment.NodeID, assignment.KeyHash)
+}
+
+func (r *ExperimentRepository) FindAssignment(workflowID, nodeID, keyHash string) (*models.ExperimentAssignment, error) {
+	var assignment models.ExperimentAssignment
+	err := r.db.First(&assignment, "workflow_id = ? AND node_id = ? AND key_hash = ?", workflowID, nodeID, keyHash).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("assignment not found")
+		}
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// RecordOutcome stores outcome; a lead reaching the same goal again is ignored
+func (r *ExperimentRepository) RecordOutcome(outcome *models.ExperimentOutcome) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(outcome).Error
+}
+
+// VariantCount is the number of leads per split node and variant, and per
+// goal for outcomes
+type VariantCount struct {
+	NodeID  string
+	Variant string
+	Goal    string
+	Count   int64
+}
+
+func (r *ExperimentRepository) CountAssignments(workflowID string) ([]VariantCount, error) {
+	var counts []VariantCount
+	err := r.db.Model(&models.ExperimentAssignment{}).
+		Select("node_id, variant, COUNT(*) AS count").
+		Where("workflow_id = ?", workflowID).
+		Group("node_id, variant").
+		Order("node_id, variant").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (r *ExperimentRepository) CountOutcomes(workflowID string) ([]VariantCount, error) {
+	var counts []VariantCount
+	err := r.db.Model(&models.ExperimentOutcome{}).
+		Select("node_id, variant, goal, COUNT(*) AS count").
+		Where("workflow_id = ?", workflowID).
+		Group("node_id, variant, goal").
+		Order("node_id, variant, goal").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"encoding/json"
+
 	"s4s-backend/internal/modules/workflow/models"
 
 	"gorm.io/gorm"
@@ -27,15 +29,21 @@ func (r *TemplateRepository) FindByID(id string) (*models.Template, error) {
 	return &template, nil
 }
 
-func (r *TemplateRepository) FindByCategory(category string) ([]models.Template, error) {
+// FindByCategory lists active templates by name, optionally only those of
+// category that carry every one of tags
+func (r *TemplateRepository) FindByCategory(category string, tags []string) ([]models.Template, error) {
 	var templates []models.Template
-	query := r.db.Model(&models.Template{})
+	query := r.db.Model(&models.Template{}).Where("is_active = ?", true)
 
 	if category != "" {
 		query = query.Where("category = ?", category)
 	}
+	if len(tags) > 0 {
+		raw, _ := json.Marshal(tags)
+		query = query.Where("tags @> ?::jsonb", string(raw))
+	}
 
-	err := query.Find(&templates).Error
+	err := query.Order("name").Find(&templates).Error
 	return templates, err
 }
 
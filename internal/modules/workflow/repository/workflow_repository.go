@@ -47,10 +47,57 @@ func (r *WorkflowRepository) FindByUserID(userID string, active *bool, page, lim
 	return workflows, total, err
 }
 
+// FindActive returns every active workflow, for trigger services
+func (r *WorkflowRepository) FindActive() ([]models.Workflow, error) {
+	var workflows []models.Workflow
+	err := r.db.Where("active = ?", true).Find(&workflows).Error
+	return workflows, err
+}
+
+// FindActiveByUserID returns the user's active workflows, for event triggers
+func (r *WorkflowRepository) FindActiveByUserID(userID string) ([]models.Workflow, error) {
+	var workflows []models.Workflow
+	err := r.db.Where("user_id = ? AND active = ?", userID, true).Find(&workflows).Error
+	return workflows, err
+}
+
 func (r *WorkflowRepository) Update(workflow *models.Workflow) error {
 	return r.db.Save(workflow).Error
 }
 
+// CreateWithVersion creates the workflow and its first version together
+func (r *WorkflowRepository) CreateWithVersion(workflow *models.Workflow, version *models.WorkflowVersion) error {
+	return r.CreateAllWithVersions([]*models.Workflow{workflow}, []*models.WorkflowVersion{version})
+}
+
+// CreateAllWithVersions creates workflows and their first versions (same
+// order) in one transaction, e.g. for an imported bundle
+func (r *WorkflowRepository) CreateAllWithVersions(workflows []*models.Workflow, versions []*models.WorkflowVersion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i, workflow := range workflows {
+			if err := tx.Create(workflow).Error; err != nil {
+				return err
+			}
+			versions[i].WorkflowID = workflow.ID
+			if err := tx.Create(versions[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateWithVersion saves the workflow and records version, so a save never
+// lands without its history entry
+func (r *WorkflowRepository) UpdateWithVersion(workflow *models.Workflow, version *models.WorkflowVersion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+		return tx.Save(workflow).Error
+	})
+}
+
 func (r *WorkflowRepository) Delete(id string) error {
 	return r.db.Delete(&models.Workflow{}, "id = ?", id).Error
 }
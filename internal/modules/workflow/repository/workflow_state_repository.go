@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"s4s-backend/internal/modules/workflow/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WorkflowStateRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkflowStateRepository(db *gorm.DB) *WorkflowStateRepository {
+	return &WorkflowStateRepository{db: db}
+}
+
+// Get decodes the state stored under key into out and reports whether it exists
+func (r *WorkflowStateRepository) Get(workflowID, key string, out interface{}) (bool, error) {
+	var state models.WorkflowState
+	err := r.db.First(&state, "workflow_id = ? AND key = ?", workflowID, key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(state.Value), out)
+}
+
+// Put stores value under key, replacing any previous state
+func (r *WorkflowStateRepository) Put(workflowID, key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	state := &models.WorkflowState{
+		WorkflowID: workflowID,
+		Key:        key,
+		Value:      string(raw),
+		UpdatedAt:  time.Now(),
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "workflow_id"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(state).Error
+}
+
+// Update applies fn to the state under key while holding a row lock and
+// stores the value fn returns, so concurrent executions on any replica see
+// each other's changes. current is nil when nothing is stored yet.
+func (r *WorkflowStateRepository) Update(workflowID, key string, fn func(current []byte) (interface{}, error)) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.WorkflowState{
+			WorkflowID: workflowID,
+			Key:        key,
+			Value:      "null",
+			UpdatedAt:  now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		var state models.WorkflowState
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&state, "workflow_id = ? AND key = ?", workflowID, key).Error
+		if err != nil {
+			return err
+		}
+
+		var current []byte
+		if state.Value != "null" {
+			current = []byte(state.Value)
+		}
+		value, err := fn(current)
+		if err != nil {
+			return err
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&state).Updates(map[string]interface{}{"value": string(raw), "updated_at": now}).Error
+	})
+}
+
+// TryLease claims key for owner until ttl elapses. It succeeds when the lease
+// is free, expired or already held by owner, so that only one replica runs a
+// poller for a workflow at a time.
+func (r *WorkflowStateRepository) TryLease(workflowID, key, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	raw, err := json.Marshal(map[string]interface{}{"owner": owner, "expiresAt": now.Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+
+	created := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.WorkflowState{
+		WorkflowID: workflowID,
+		Key:        key,
+		Value:      string(raw),
+		UpdatedAt:  now,
+	})
+	if created.Error != nil {
+		return false, created.Error
+	}
+	if created.RowsAffected == 1 {
+		return true, nil
+	}
+
+	taken := r.db.Model(&models.WorkflowState{}).
+		Where("workflow_id = ? AND key = ?", workflowID, key).
+		Where("value->>'owner' = ? OR (value->>'expiresAt')::timestamptz < ?", owner, now).
+		Updates(map[string]interface{}{"value": string(raw), "updated_at": now})
+	return taken.RowsAffected == 1, taken.Error
+}
+
+// DeleteByWorkflowID removes all state of a workflow
+func (r *WorkflowStateRepository) DeleteByWorkflowID(workflowID string) error {
+	return r.db.Delete(&models.WorkflowState{}, "workflow_id = ?", workflowID).Error
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"s4s-backend/internal/modules/workflow/models"
+
+	"gorm.io/gorm"
+)
+
+type WorkflowVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkflowVersionRepository(db *gorm.DB) *WorkflowVersionRepository {
+	return &WorkflowVersionRepository{db: db}
+}
+
+func (r *WorkflowVersionRepository) Create(version *models.WorkflowVersion) error {
+	return r.db.Create(version).Error
+}
+
+func (r *WorkflowVersionRepository) FindByVersion(workflowID string, number int) (*models.WorkflowVersion, error) {
+	var version models.WorkflowVersion
+	err := r.db.Where("workflow_id = ? AND version = ?", workflowID, number).First(&version).Error
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// FindLatest returns the highest version of the workflow
+func (r *WorkflowVersionRepository) FindLatest(workflowID string) (*models.WorkflowVersion, error) {
+	var version models.WorkflowVersion
+	err := r.db.Where("workflow_id = ?", workflowID).Order("version DESC").First(&version).Error
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// FindByWorkflowID lists versions newest first, without their JSON
+func (r *WorkflowVersionRepository) FindByWorkflowID(workflowID string, page, limit int) ([]models.WorkflowVersion, int64, error) {
+	var versions []models.WorkflowVersion
+	var total int64
+
+	query := r.db.Model(&models.WorkflowVersion{}).Where("workflow_id = ?", workflowID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Omit("json").Offset(offset).Limit(limit).Order("version DESC").Find(&versions).Error
+
+	return versions, total, err
+}
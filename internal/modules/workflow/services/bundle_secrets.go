@@ -0,0 +1,129 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// sensitiveNameParts mark header and query parameter names that carry
+// credentials, compared lowercased without separators
+var sensitiveNameParts = []string{"authoriz", "token", "secret", "password", "passwd", "apikey", "accesskey", "credential", "signature", "session", "cookie"}
+
+// sensitiveSegments are words of a name, e.g. "auth" in "X-Auth-User", that
+// are too short to match inside other words
+var sensitiveSegments = map[string]bool{"auth": true, "oauth": true}
+
+// sensitiveParams are query parameter names that carry credentials on their own
+var sensitiveParams = map[string]bool{"key": true, "sig": true, "pass": true, "pwd": true}
+
+func sensitiveName(name string) bool {
+	segments := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	for _, segment := range segments {
+		if sensitiveSegments[segment] {
+			return true
+		}
+	}
+	normalized := strings.Join(segments, "")
+	for _, part := range sensitiveNameParts {
+		if strings.Contains(normalized, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// literalSecret reports whether value is written out rather than taken from
+// the run's data with a template
+func literalSecret(value string) bool {
+	return value != "" && !strings.Contains(value, "{{")
+}
+
+// redactSecrets blanks credentials a node config holds outside connections:
+// values of sensitive headers, and passwords and sensitive query parameters
+// of URLs. It returns what was removed, e.g. `"Authorization" header`.
+func redactSecrets(config map[string]interface{}) []string {
+	var removed []string
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch value := config[key].(type) {
+		case map[string]interface{}:
+			if key != "headers" {
+				continue
+			}
+			names := make([]string, 0, len(value))
+			for name := range value {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if header, ok := value[name].(string); ok && literalSecret(header) && sensitiveName(name) {
+					value[name] = ""
+					removed = append(removed, fmt.Sprintf("%q header", name))
+				}
+			}
+		case string:
+			if key != "url" && !strings.HasSuffix(key, "_url") {
+				continue
+			}
+			redacted, parts := redactURL(value)
+			config[key] = redacted
+			for _, part := range parts {
+				removed = append(removed, fmt.Sprintf("%s in %s", part, key))
+			}
+		}
+	}
+	return removed
+}
+
+// redactURL blanks the password of raw's user info and the values of
+// sensitive query parameters. Everything else stays as written so that
+// templates in the URL keep working.
+func redactURL(raw string) (string, []string) {
+	var removed []string
+	rest, fragment, hasFragment := strings.Cut(raw, "#")
+	rest, query, hasQuery := strings.Cut(rest, "?")
+
+	if i := strings.Index(rest, "://"); i >= 0 {
+		hostStart := i + 3
+		authority := rest[hostStart:]
+		if end := strings.IndexByte(authority, '/'); end >= 0 {
+			authority = authority[:end]
+		}
+		if at := strings.LastIndexByte(authority, '@'); at >= 0 {
+			user, password, found := strings.Cut(authority[:at], ":")
+			if found && literalSecret(password) {
+				rest = rest[:hostStart] + user + rest[hostStart+at:]
+				removed = append(removed, "password")
+			}
+		}
+	}
+
+	if hasQuery {
+		params := strings.Split(query, "&")
+		for i, param := range params {
+			name, value, _ := strings.Cut(param, "=")
+			decoded, err := url.QueryUnescape(name)
+			if err != nil {
+				decoded = name
+			}
+			if literalSecret(value) && (sensitiveName(decoded) || sensitiveParams[strings.ToLower(decoded)]) {
+				params[i] = name + "="
+				removed = append(removed, fmt.Sprintf("%q query parameter", decoded))
+			}
+		}
+		rest += "?" + strings.Join(params, "&")
+	}
+	if hasFragment {
+		rest += "#" + fragment
+	}
+	return rest, removed
+}
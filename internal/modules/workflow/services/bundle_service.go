@@ -0,0 +1,470 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	connectionRepo "s4s-backend/internal/modules/connection/repository"
+	scoringRepo "s4s-backend/internal/modules/scoring/repository"
+	"s4s-backend/internal/modules/workflow/dto"
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/repository"
+)
+
+// maxBundleWorkflows bounds the workflows of one bundle
+const maxBundleWorkflows = 50
+
+const (
+	placeholderConnection = "connection"
+	placeholderRuleSet    = "rule_set"
+)
+
+var (
+	// placeholderFields are node config fields holding account-specific IDs
+	placeholderFields = []struct{ field, kind string }{
+		{"connection_id", placeholderConnection},
+		{"rule_set_id", placeholderRuleSet},
+	}
+	// workflowRefFields are node config fields holding another workflow's ID
+	workflowRefFields = []string{"workflow_id"}
+	// nodeRefFields are node config fields holding the ID of a node in the same graph
+	nodeRefFields = []string{"split_node"}
+)
+
+// ErrUnsupportedBundle is returned for files that are not workflow bundles
+// of a version this server reads
+var ErrUnsupportedBundle = fmt.Errorf("not a %s version %d file", dto.BundleFormat, dto.BundleVersion)
+
+// BindingsRequiredError is returned by an import that leaves placeholders
+// unbound; it lists them with the user's candidates
+type BindingsRequiredError struct {
+	Unbound []dto.UnboundPlaceholder
+}
+
+func (e *BindingsRequiredError) Error() string {
+	keys := make([]string, len(e.Unbound))
+	for i, placeholder := range e.Unbound {
+		keys[i] = placeholder.Key
+	}
+	return "placeholders must be bound: " + strings.Join(keys, ", ")
+}
+
+// BundleService exports workflows as portable bundles and imports them into
+// another account or environment
+type BundleService struct {
+	workflowService *WorkflowService
+	workflowRepo    *repository.WorkflowRepository
+	connectionRepo  connectionRepo.ConnectionRepository
+	ruleSetRepo     scoringRepo.RuleSetRepository
+}
+
+func NewBundleService(
+	workflowService *WorkflowService,
+	workflowRepo *repository.WorkflowRepository,
+	connectionRepo connectionRepo.ConnectionRepository,
+	ruleSetRepo scoringRepo.RuleSetRepository,
+) *BundleService {
+	return &BundleService{
+		workflowService: workflowService,
+		workflowRepo:    workflowRepo,
+		connectionRepo:  connectionRepo,
+		ruleSetRepo:     ruleSetRepo,
+	}
+}
+
+// Export bundles the user's workflow draft together with the user's
+// workflows its nodes refer to. Credentials written into node configs
+// (sensitive headers, URL passwords and query parameters) are blanked and
+// listed in the bundle's warnings.
+func (s *BundleService) Export(workflowID, userID string) (*dto.Bundle, error) {
+	root, err := s.workflowService.ownedWorkflow(workflowID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &dto.Bundle{
+		Format:       dto.BundleFormat,
+		Version:      dto.BundleVersion,
+		ExportedAt:   time.Now().UTC(),
+		Workflows:    []dto.BundleWorkflow{},
+		Placeholders: []dto.BundlePlaceholder{},
+	}
+	refs := map[string]string{root.ID: "workflow-1"}
+	queue := []*models.Workflow{root}
+	placeholders := make(map[string]int) // kind + id -> index in bundle.Placeholders
+
+	for i := 0; i < len(queue); i++ {
+		workflow := queue[i]
+		ref := refs[workflow.ID]
+		graph, err := parseGenericGraph(workflow.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("workflow %s: %w", workflow.Name, err)
+		}
+
+		entry := dto.BundleWorkflow{
+			Ref:  ref,
+			Name: workflow.Name,
+			Settings: dto.BundleSettings{
+				MaxTimeout: workflow.MaxTimeout,
+				RetryCount: workflow.RetryCount,
+				RetryDelay: workflow.RetryDelay,
+			},
+			Tags:       append([]string{}, workflow.Tags...),
+			References: []dto.BundleReference{},
+		}
+		for _, node := range graph.Nodes {
+			nodeID := stringField(node, "id")
+			config := nodeConfig(node)
+			if config == nil {
+				continue
+			}
+
+			for _, field := range workflowRefFields {
+				id, _ := config[field].(string)
+				if id == "" {
+					continue
+				}
+				target, ok := refs[id]
+				if !ok {
+					other, err := s.workflowRepo.FindByID(id)
+					if err != nil || other.UserID != userID {
+						// left as is; it never matches in the importing account
+						continue
+					}
+					if len(queue) == maxBundleWorkflows {
+						return nil, fmt.Errorf("a bundle may have at most %d workflows", maxBundleWorkflows)
+					}
+					target = fmt.Sprintf("workflow-%d", len(queue)+1)
+					refs[id] = target
+					queue = append(queue, other)
+				}
+				config[field] = target
+				entry.References = append(entry.References, dto.BundleReference{NodeID: nodeID, Field: field, Workflow: target})
+			}
+
+			for _, spec := range placeholderFields {
+				field, kind := spec.field, spec.kind
+				id, _ := config[field].(string)
+				if id == "" || strings.Contains(id, "{{") {
+					continue
+				}
+				index, ok := placeholders[kind+":"+id]
+				if !ok {
+					index = len(bundle.Placeholders)
+					placeholders[kind+":"+id] = index
+					bundle.Placeholders = append(bundle.Placeholders, s.describePlaceholder(kind, id, userID, index+1))
+				}
+				placeholder := &bundle.Placeholders[index]
+				config[field] = placeholder.Key
+				placeholder.Usages = append(placeholder.Usages, dto.BundleUsage{Workflow: ref, NodeID: nodeID, Field: field})
+			}
+
+			for _, removed := range redactSecrets(config) {
+				bundle.Warnings = append(bundle.Warnings, fmt.Sprintf("%s: the %s of node %s was removed; set it again after import", ref, removed, nodeID))
+			}
+		}
+
+		entry.Graph, err = json.Marshal(graph)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Workflows = append(bundle.Workflows, entry)
+	}
+	return bundle, nil
+}
+
+// describePlaceholder names the exporter's connection or rule set without
+// any of its credentials
+func (s *BundleService) describePlaceholder(kind, id, userID string, number int) dto.BundlePlaceholder {
+	placeholder := dto.BundlePlaceholder{Kind: kind, Usages: []dto.BundleUsage{}}
+	switch kind {
+	case placeholderConnection:
+		placeholder.Key = fmt.Sprintf("connection-%d", number)
+		if conn, err := s.connectionRepo.GetByID(id, userID); err == nil {
+			placeholder.Service = conn.ServiceName
+			placeholder.Name = conn.ConnectionName
+		}
+	case placeholderRuleSet:
+		placeholder.Key = fmt.Sprintf("rule-set-%d", number)
+		if ruleSet, err := s.ruleSetRepo.GetByID(id, userID); err == nil {
+			placeholder.Name = ruleSet.Name
+		}
+	}
+	return placeholder
+}
+
+// Import creates the bundle's workflows as unpublished, inactive drafts of
+// the user. Node IDs are regenerated, references between bundled workflows
+// are rewired and every placeholder must be bound to one of the user's
+// connections or rule sets. n8n exports are converted first; what could not
+// be converted is returned as warnings, as are the bundle's own warnings.
+func (s *BundleService) Import(userID string, req *dto.ImportBundleRequest) (*dto.ImportResult, error) {
+	bundle := req.Bundle
+	message := "Imported from bundle"
+	var warnings []string
+	if len(req.N8n) > 0 {
+		converted, convertWarnings, err := ConvertN8n(req.N8n)
+		if err != nil {
+			return nil, err
+		}
+		bundle, warnings, message = converted, convertWarnings, "Imported from n8n"
+	}
+	if bundle == nil || bundle.Format != dto.BundleFormat || bundle.Version != dto.BundleVersion {
+		return nil, ErrUnsupportedBundle
+	}
+	// credentials removed on export have to be set again
+	warnings = append(warnings, bundle.Warnings...)
+	if len(bundle.Workflows) == 0 {
+		return nil, &ValidationError{Problems: []string{"bundle has no workflows"}}
+	}
+	if len(bundle.Workflows) > maxBundleWorkflows {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("a bundle may have at most %d workflows", maxBundleWorkflows)}}
+	}
+
+	bindings, err := s.checkBindings(userID, bundle.Placeholders, req.Bindings)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	ids := make(map[string]string, len(bundle.Workflows))
+	for _, entry := range bundle.Workflows {
+		if entry.Ref == "" || ids[entry.Ref] != "" {
+			problems = append(problems, fmt.Sprintf("workflow ref %q is missing or used twice", entry.Ref))
+			continue
+		}
+		ids[entry.Ref] = uuid.New().String()
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	workflows := make([]*models.Workflow, 0, len(bundle.Workflows))
+	versions := make([]*models.WorkflowVersion, 0, len(bundle.Workflows))
+	for _, entry := range bundle.Workflows {
+		graph, entryProblems := s.rewireGraph(entry, bundle.Placeholders, bindings, ids)
+		for _, problem := range entryProblems {
+			problems = append(problems, fmt.Sprintf("%s: %s", entry.Ref, problem))
+		}
+		if len(entryProblems) > 0 {
+			continue
+		}
+		for _, problem := range s.workflowService.ValidateDefinition(graph) {
+			problems = append(problems, fmt.Sprintf("%s: %s", entry.Ref, problem))
+		}
+
+		name := strings.TrimSpace(entry.Name)
+		if name == "" {
+			name = "Imported workflow"
+		}
+		workflow := &models.Workflow{
+			ID:         ids[entry.Ref],
+			UserID:     userID,
+			Name:       name,
+			JSON:       graph,
+			Active:     false,
+			MaxTimeout: entry.Settings.MaxTimeout,
+			RetryCount: entry.Settings.RetryCount,
+			RetryDelay: entry.Settings.RetryDelay,
+			Tags:       models.Tags(entry.Tags),
+			Version:    1,
+		}
+		if workflow.MaxTimeout <= 0 {
+			workflow.MaxTimeout = 300
+		}
+		if workflow.RetryCount < 0 {
+			workflow.RetryCount = 3
+		}
+		if workflow.RetryDelay <= 0 {
+			workflow.RetryDelay = 60
+		}
+		workflows = append(workflows, workflow)
+		versions = append(versions, snapshot(workflow, userID, message))
+	}
+	if len(problems) > 0 {
+		// conversion warnings usually explain why a converted graph is invalid
+		return nil, &ValidationError{Problems: append(problems, warnings...)}
+	}
+
+	if err := s.workflowRepo.CreateAllWithVersions(workflows, versions); err != nil {
+		return nil, err
+	}
+	return &dto.ImportResult{Workflows: ids, Warnings: warnings}, nil
+}
+
+// checkBindings verifies that every placeholder is bound to a connection of
+// the same service or a rule set owned by the user, keyed by placeholder
+func (s *BundleService) checkBindings(userID string, placeholders []dto.BundlePlaceholder, bindings map[string]string) (map[string]string, error) {
+	var unbound []dto.UnboundPlaceholder
+	var problems []string
+	resolved := make(map[string]string, len(placeholders))
+	for _, placeholder := range placeholders {
+		id := strings.TrimSpace(bindings[placeholder.Key])
+		if id == "" {
+			candidates, err := s.candidates(userID, placeholder)
+			if err != nil {
+				return nil, err
+			}
+			unbound = append(unbound, dto.UnboundPlaceholder{BundlePlaceholder: placeholder, Candidates: candidates})
+			continue
+		}
+
+		switch placeholder.Kind {
+		case placeholderConnection:
+			conn, err := s.connectionRepo.GetByID(id, userID)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: connection %s not found", placeholder.Key, id))
+				continue
+			}
+			if placeholder.Service != "" && conn.ServiceName != placeholder.Service {
+				problems = append(problems, fmt.Sprintf("%s: connection %s is a %s connection, expected %s", placeholder.Key, id, conn.ServiceName, placeholder.Service))
+				continue
+			}
+		case placeholderRuleSet:
+			if _, err := s.ruleSetRepo.GetByID(id, userID); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: rule set %s not found", placeholder.Key, id))
+				continue
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unknown placeholder kind %q", placeholder.Key, placeholder.Kind))
+			continue
+		}
+		resolved[placeholder.Key] = id
+	}
+
+	if len(unbound) > 0 {
+		return nil, &BindingsRequiredError{Unbound: unbound}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return resolved, nil
+}
+
+// candidates lists the user's connections of the placeholder's service, or
+// the user's rule sets
+func (s *BundleService) candidates(userID string, placeholder dto.BundlePlaceholder) ([]dto.BindingCandidate, error) {
+	candidates := []dto.BindingCandidate{}
+	switch placeholder.Kind {
+	case placeholderConnection:
+		conns, err := s.connectionRepo.FindByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, conn := range conns {
+			if placeholder.Service == "" || conn.ServiceName == placeholder.Service {
+				candidates = append(candidates, dto.BindingCandidate{ID: conn.ID, Name: conn.ConnectionName})
+			}
+		}
+	case placeholderRuleSet:
+		ruleSets, err := s.ruleSetRepo.FindByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ruleSet := range ruleSets {
+			candidates = append(candidates, dto.BindingCandidate{ID: ruleSet.ID, Name: ruleSet.Name})
+		}
+	}
+	return candidates, nil
+}
+
+// rewireGraph gives the bundled graph fresh node and edge IDs, fills in the
+// bound placeholders and the new IDs of referenced workflows
+func (s *BundleService) rewireGraph(entry dto.BundleWorkflow, placeholders []dto.BundlePlaceholder, bindings, ids map[string]string) (string, []string) {
+	graph, err := parseGenericGraph(string(entry.Graph))
+	if err != nil {
+		return "", []string{err.Error()}
+	}
+
+	var problems []string
+	nodeIDs := make(map[string]string, len(graph.Nodes))
+	nodes := make(map[string]map[string]interface{}, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		oldID := stringField(node, "id")
+		if oldID == "" {
+			continue
+		}
+		nodeIDs[oldID] = uuid.New().String()
+		nodes[oldID] = node
+	}
+
+	configField := func(nodeID, field string, value string) {
+		node, ok := nodes[nodeID]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("node %s does not exist", nodeID))
+			return
+		}
+		config := nodeConfig(node)
+		if config == nil {
+			problems = append(problems, fmt.Sprintf("node %s has no config", nodeID))
+			return
+		}
+		config[field] = value
+	}
+	for _, placeholder := range placeholders {
+		for _, usage := range placeholder.Usages {
+			if usage.Workflow == entry.Ref {
+				configField(usage.NodeID, usage.Field, bindings[placeholder.Key])
+			}
+		}
+	}
+	for _, reference := range entry.References {
+		id, ok := ids[reference.Workflow]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("node %s refers to workflow %s, which is not in the bundle", reference.NodeID, reference.Workflow))
+			continue
+		}
+		configField(reference.NodeID, reference.Field, id)
+	}
+
+	for oldID, node := range nodes {
+		node["id"] = nodeIDs[oldID]
+		if config := nodeConfig(node); config != nil {
+			for _, field := range nodeRefFields {
+				if ref, _ := config[field].(string); nodeIDs[ref] != "" {
+					config[field] = nodeIDs[ref]
+				}
+			}
+		}
+	}
+	for _, edge := range graph.Edges {
+		for _, end := range []string{"source", "target"} {
+			if id := nodeIDs[stringField(edge, end)]; id != "" {
+				edge[end] = id
+			}
+		}
+		if stringField(edge, "id") != "" {
+			edge["id"] = "e-" + uuid.New().String()
+		}
+	}
+	if len(problems) > 0 {
+		return "", problems
+	}
+
+	raw, err := json.Marshal(graph)
+	if err != nil {
+		return "", []string{err.Error()}
+	}
+	return string(raw), nil
+}
+
+// parseGenericGraph reads a graph keeping every node and edge property,
+// including editor-only ones such as positions
+func parseGenericGraph(raw string) (*rawGraph, error) {
+	var graph rawGraph
+	if err := json.Unmarshal([]byte(raw), &graph); err != nil {
+		return nil, errors.New("invalid workflow json")
+	}
+	return &graph, nil
+}
+
+func nodeConfig(node map[string]interface{}) map[string]interface{} {
+	data, _ := node["data"].(map[string]interface{})
+	config, _ := data["config"].(map[string]interface{})
+	return config
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	connectionRepo "s4s-backend/internal/modules/connection/repository"
+	"s4s-backend/internal/modules/workflow/services/engine"
+)
+
+// ConnectionResolver exposes the connections module to workflow executors
+type ConnectionResolver struct {
+	repo connectionRepo.ConnectionRepository
+}
+
+func NewConnectionResolver(repo connectionRepo.ConnectionRepository) *ConnectionResolver {
+	return &ConnectionResolver{repo: repo}
+}
+
+func (r *ConnectionResolver) Resolve(ctx context.Context, userID, connectionID string) (*engine.Connection, error) {
+	conn, err := r.repo.GetByID(connectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !conn.IsActive {
+		return nil, errors.New("connection is disabled")
+	}
+
+	return &engine.Connection{
+		ID:          conn.ID,
+		Service:     conn.ServiceName,
+		Credentials: conn.Credentials,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	contactDTO "s4s-backend/internal/modules/contacts/dto"
+	contactModels "s4s-backend/internal/modules/contacts/models"
+	contactRepo "s4s-backend/internal/modules/contacts/repository"
+	contactServices "s4s-backend/internal/modules/contacts/services"
+	"s4s-backend/internal/modules/workflow/services/engine"
+)
+
+// ContactStore exposes the run owner's contacts to contact nodes
+type ContactStore struct {
+	service contactServices.ContactService
+}
+
+func NewContactStore(service contactServices.ContactService) *ContactStore {
+	return &ContactStore{service: service}
+}
+
+func (s *ContactStore) Upsert(ctx context.Context, data engine.ContactData) (map[string]interface{}, bool, error) {
+	info := engine.RunInfoFromContext(ctx)
+	if info.UserID == "" {
+		return nil, false, errors.New("contacts store requires a workflow owner")
+	}
+
+	input := contactDTO.ContactInput{
+		Email:     &data.Email,
+		Phone:     &data.Phone,
+		FirstName: &data.FirstName,
+		LastName:  &data.LastName,
+		Company:   &data.Company,
+		Title:     &data.Title,
+		Owner:     &data.Owner,
+		Stage:     &data.Stage,
+		Source:    &data.Source,
+		Tags:      data.Tags,
+		Fields:    data.Fields,
+		Country:   data.Country,
+	}
+	contact, created, err := s.service.UpsertContact(ctx, info.UserID, input, contactServices.UpsertOptions{
+		ReplaceTags:      data.ReplaceTags,
+		SourceWorkflowID: info.WorkflowID,
+		EventDepth:       info.EventDepth,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return contact.Data(), created, nil
+}
+
+func (s *ContactStore) Find(ctx context.Context, query engine.ContactQuery) ([]map[string]interface{}, error) {
+	info := engine.RunInfoFromContext(ctx)
+	if info.UserID == "" {
+		return nil, errors.New("contacts store requires a workflow owner")
+	}
+
+	var contacts []*contactModels.Contact
+	switch {
+	case query.ID != "" || query.Email != "" || query.Phone != "":
+		var contact *contactModels.Contact
+		var err error
+		if query.ID != "" {
+			if _, parseErr := uuid.Parse(query.ID); parseErr != nil {
+				// not an id the store could have issued
+				break
+			}
+			contact, err = s.service.GetContact(ctx, query.ID, info.UserID)
+		} else {
+			contact, err = s.service.FindContact(ctx, info.UserID, query.Email, query.Phone, query.Country)
+		}
+		if errors.Is(err, contactRepo.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	default:
+		filter := contactRepo.Filter{Search: query.Search, Tag: query.Tag, Stage: query.Stage, Owner: query.Owner}
+		var err error
+		contacts, _, err = s.service.ListContacts(ctx, info.UserID, filter, 1, query.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]map[string]interface{}, len(contacts))
+	for i, contact := range contacts {
+		result[i] = contact.Data()
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+
+	suppressionRepo "s4s-backend/internal/modules/suppression/repository"
+	trackingModels "s4s-backend/internal/modules/tracking/models"
+	trackingRepo "s4s-backend/internal/modules/tracking/repository"
+	"s4s-backend/internal/modules/workflow/services/engine"
+	"s4s-backend/internal/pkg/tracking"
+)
+
+// EmailTracker registers tracked emails of workflow executions
+type EmailTracker struct {
+	*tracking.Links
+	repo trackingRepo.TrackingRepository
+}
+
+func NewEmailTracker(links *tracking.Links, repo trackingRepo.TrackingRepository) *EmailTracker {
+	return &EmailTracker{Links: links, repo: repo}
+}
+
+func (t *EmailTracker) Register(ctx context.Context, email engine.TrackedEmail) error {
+	info := engine.RunInfoFromContext(ctx)
+	return t.repo.CreateEmail(&trackingModels.TrackedEmail{
+		ID:          email.ID,
+		UserID:      info.UserID,
+		WorkflowID:  info.WorkflowID,
+		ExecutionID: info.ExecutionID,
+		NodeID:      email.NodeID,
+		MessageID:   email.MessageID,
+		Recipients:  email.Recipients,
+		Subject:     email.Subject,
+		EventDepth:  info.EventDepth,
+	})
+}
+
+// SuppressionChecker exposes the users' suppression lists to messaging executors
+type SuppressionChecker struct {
+	repo suppressionRepo.SuppressionRepository
+}
+
+func NewSuppressionChecker(repo suppressionRepo.SuppressionRepository) *SuppressionChecker {
+	return &SuppressionChecker{repo: repo}
+}
+
+func (c *SuppressionChecker) Suppressed(ctx context.Context, userID, channel string, addresses []string) ([]string, error) {
+	return c.repo.FindSuppressed(userID, channel, addresses)
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+
+	"s4s-backend/internal/pkg/amqpconn"
+)
+
+const amqpPublishTimeout = 30 * time.Second
+
+// AMQPPublishExecutor publishes a message to RabbitMQ through an amqp
+// connection and waits for the broker to confirm it
+type AMQPPublishExecutor struct {
+	Connections ConnectionResolver
+	Egress      *EgressPolicy
+}
+
+func (a *AMQPPublishExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid amqp publish configuration")
+	}
+
+	conn, err := resolveConnection(ctx, a.Connections, config, "amqp")
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := amqpconn.ConfigFromCredentials(conn.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amqp connection: %w", err)
+	}
+
+	exchange := replaceVariables(stringValue(config["exchange"]), input)
+	routingKey := replaceVariables(stringValue(config["routing_key"]), input)
+	if exchange == "" && routingKey == "" {
+		return nil, errors.New("exchange or routing_key is required")
+	}
+
+	msg := amqp.Publishing{
+		DeliveryMode:  amqp.Persistent,
+		Timestamp:     time.Now().UTC(),
+		ContentType:   replaceVariables(stringValue(config["content_type"]), input),
+		MessageId:     replaceVariables(stringValue(config["message_id"]), input),
+		CorrelationId: replaceVariables(stringValue(config["correlation_id"]), input),
+		Type:          replaceVariables(stringValue(config["type"]), input),
+	}
+	if persistent, ok := config["persistent"].(bool); ok && !persistent {
+		msg.DeliveryMode = amqp.Transient
+	}
+	if priority, ok := config["priority"].(float64); ok && priority >= 0 && priority <= 9 {
+		msg.Priority = uint8(priority)
+	}
+	if msg.MessageId == "" {
+		msg.MessageId = uuid.New().String()
+	}
+
+	// body is either a template string or a JSON value templated field by field
+	switch body := config["body"].(type) {
+	case nil:
+		return nil, errors.New("body is required")
+	case string:
+		msg.Body = []byte(replaceVariables(body, input))
+		if msg.ContentType == "" {
+			msg.ContentType = "text/plain"
+		}
+	default:
+		if msg.Body, err = json.Marshal(replaceVariablesDeep(body, input)); err != nil {
+			return nil, fmt.Errorf("invalid body: %w", err)
+		}
+		if msg.ContentType == "" {
+			msg.ContentType = "application/json"
+		}
+	}
+
+	if headers, ok := replaceVariablesDeep(config["headers"], input).(map[string]interface{}); ok {
+		msg.Headers = amqp.Table{}
+		for key, value := range headers {
+			msg.Headers[key] = amqpHeaderValue(value)
+		}
+	}
+	if info := RunInfoFromContext(ctx); info.ExecutionID != "" {
+		if msg.Headers == nil {
+			msg.Headers = amqp.Table{}
+		}
+		msg.Headers["x-s4s-execution-id"] = info.ExecutionID
+	}
+
+	egress := a.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+	ctx, cancel := context.WithTimeout(ctx, amqpPublishTimeout)
+	defer cancel()
+
+	broker, err := amqpconn.Dial(ctx, cfg, egress.DialContext)
+	if err != nil {
+		return nil, fmt.Errorf("amqp: %w", err)
+	}
+	defer broker.Close()
+
+	ch, err := broker.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("amqp: %w", err)
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("amqp: publisher confirms unavailable: %w", err)
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+
+	mandatory, _ := config["mandatory"].(bool)
+	if err := ch.Publish(exchange, routingKey, mandatory, false, msg); err != nil {
+		return nil, fmt.Errorf("amqp: %w", err)
+	}
+
+	// a mandatory message that cannot be routed is returned before its confirm
+	select {
+	case ret := <-returns:
+		return nil, fmt.Errorf("amqp: message returned: %s", ret.ReplyText)
+	case confirm := <-confirms:
+		if !confirm.Ack {
+			return nil, errors.New("amqp: broker rejected the message")
+		}
+	case <-ctx.Done():
+		return nil, fmt.Errorf("amqp: waiting for confirm: %w", ctx.Err())
+	}
+
+	Logf(ctx, "Published %d bytes to %s (exchange %q, routing key %q)", len(msg.Body), cfg.Redacted(), exchange, routingKey)
+
+	return map[string]interface{}{
+		"amqp_published":  true,
+		"amqp_message_id": msg.MessageId,
+	}, nil
+}
+
+// amqpHeaderValue converts JSON values into types the AMQP table encoder accepts
+func amqpHeaderValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string, bool, float64, nil:
+		return v
+	default:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+}
+
+// AMQPConsumeExecutor is the amqp_consume trigger node. The consumer places
+// the message in the execution data before the graph starts, so the node
+// passes it through like a webhook trigger.
+type AMQPConsumeExecutor struct{}
+
+func (a *AMQPConsumeExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	return input, nil
+}
+
+// AMQPDeliveryData turns a consumed message into execution data. JSON object
+// bodies are spread into the data so later nodes can use {{field}}; the raw
+// message is always available under "amqp".
+func AMQPDeliveryData(d amqp.Delivery) map[string]interface{} {
+	data := make(map[string]interface{})
+
+	message := map[string]interface{}{
+		"body":           string(d.Body),
+		"exchange":       d.Exchange,
+		"routing_key":    d.RoutingKey,
+		"message_id":     d.MessageId,
+		"correlation_id": d.CorrelationId,
+		"content_type":   d.ContentType,
+		"type":           d.Type,
+		"redelivered":    d.Redelivered,
+	}
+	if !d.Timestamp.IsZero() {
+		message["timestamp"] = d.Timestamp.UTC().Format(time.RFC3339)
+	}
+	headers := make(map[string]interface{}, len(d.Headers))
+	for key, value := range d.Headers {
+		headers[key] = amqpTableValue(value)
+	}
+	message["headers"] = headers
+
+	var parsed interface{}
+	if (d.ContentType == "" || strings.Contains(d.ContentType, "json")) && json.Unmarshal(d.Body, &parsed) == nil {
+		message["json"] = parsed
+		if object, ok := parsed.(map[string]interface{}); ok {
+			for key, value := range object {
+				data[key] = value
+			}
+		}
+	}
+
+	data["amqp"] = message
+	return data
+}
+
+func amqpTableValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case amqp.Table:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = amqpTableValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = amqpTableValue(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
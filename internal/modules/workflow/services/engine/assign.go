@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"s4s-backend/internal/pkg/scoring"
+)
+
+// StateStore keeps durable per-workflow state for nodes
+type StateStore interface {
+	// Update applies fn to the value stored under key while holding a lock
+	// on it and stores the value fn returns. current is nil when nothing is
+	// stored yet.
+	Update(ctx context.Context, workflowID, key string, fn func(current []byte) (interface{}, error)) error
+}
+
+// assignState is the rotation state of one assign node
+type assignState struct {
+	// Last is the ID of the rep picked last, per pool
+	Last map[string]string `json:"last,omitempty"`
+	// Weights are the smooth weighted round-robin counters, per pool and rep
+	Weights map[string]map[string]float64 `json:"weights,omitempty"`
+	// AssignedAt is when each rep last got a lead, in unix milliseconds
+	AssignedAt map[string]int64 `json:"assignedAt,omitempty"`
+	// Counts is the number of leads each rep got
+	Counts map[string]int `json:"counts,omitempty"`
+}
+
+type assignRep struct {
+	id     string
+	weight float64
+	fields map[string]interface{}
+}
+
+// AssignExecutor picks an owner for a lead from the configured reps. The
+// strategy is round_robin (default), weighted, least_recent or rules: rules
+// route leads to a territory's reps by lead fields (see scoring.Rule for
+// operators) and rotate within that pool using rule_strategy. The rotation
+// state is stored per workflow and node, so it stays fair across executions,
+// replicas and restarts; test runs read it without advancing it. The chosen
+// rep's fields are written to output_key (default "assignee").
+type AssignExecutor struct {
+	State StateStore
+
+	// Now returns the current time; nil means time.Now
+	Now func() time.Time
+}
+
+func (a *AssignExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid assign configuration")
+	}
+
+	reps, err := assignReps(replaceVariablesDeep(config["reps"], input))
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]assignRep, len(reps))
+	for _, rep := range reps {
+		byID[rep.id] = rep
+	}
+
+	strategy := stringValue(config["strategy"])
+	if strategy == "" {
+		strategy = "round_robin"
+	}
+
+	pool, poolName, matchedRule := reps, "default", ""
+	if strategy == "rules" {
+		strategy = stringValue(config["rule_strategy"])
+		if strategy == "" {
+			strategy = "round_robin"
+		}
+		if strategy == "rules" {
+			return nil, errors.New("rule_strategy cannot be rules")
+		}
+		pool, poolName, matchedRule, err = assignRulePool(config, input, byID, reps)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch strategy {
+	case "round_robin", "weighted", "least_recent":
+	default:
+		return nil, fmt.Errorf("unknown assign strategy: %s", strategy)
+	}
+	if len(pool) == 0 {
+		return nil, fmt.Errorf("no reps available for %s", poolName)
+	}
+
+	info := RunInfoFromContext(ctx)
+	if a.State == nil {
+		return nil, errors.New("workflow state is not available")
+	}
+	if info.WorkflowID == "" {
+		return nil, errors.New("assign needs a saved workflow to keep its rotation")
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+
+	var chosen assignRep
+	err = a.State.Update(ctx, info.WorkflowID, "assign:"+node.ID, func(current []byte) (interface{}, error) {
+		var state assignState
+		if current != nil {
+			if err := json.Unmarshal(current, &state); err != nil {
+				return nil, fmt.Errorf("invalid assign state: %w", err)
+			}
+		}
+		chosen = state.pick(strategy, poolName, pool)
+		if info.IsTest {
+			return json.RawMessage(current), nil
+		}
+		state.record(poolName, chosen.id, now())
+		return state, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	Logf(ctx, "assigned to %s (%s, %s)", chosen.id, strategy, poolName)
+
+	outputKey := stringValue(config["output_key"])
+	if outputKey == "" {
+		outputKey = "assignee"
+	}
+	assignee := make(map[string]interface{}, len(chosen.fields)+1)
+	for k, v := range chosen.fields {
+		assignee[k] = v
+	}
+	assignee["id"] = chosen.id
+	return map[string]interface{}{
+		outputKey:             assignee,
+		outputKey + "_id":     chosen.id,
+		"assignment_strategy": strategy,
+		"assignment_rule":     matchedRule,
+	}, nil
+}
+
+// assignReps reads the rep list: objects with any fields (id defaults to
+// email, then name; weight defaults to 1; active: false skips the rep) or
+// plain strings used as the id
+func assignReps(value interface{}) ([]assignRep, error) {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, errors.New("reps are required")
+	}
+
+	reps := make([]assignRep, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	for i, entry := range list {
+		var rep assignRep
+		switch v := entry.(type) {
+		case string:
+			rep = assignRep{id: v, weight: 1, fields: map[string]interface{}{}}
+		case map[string]interface{}:
+			if active, ok := v["active"].(bool); ok && !active {
+				continue
+			}
+			rep = assignRep{weight: 1, fields: v}
+			for _, key := range []string{"id", "email", "name"} {
+				if rep.id = stringValue(v[key]); rep.id != "" {
+					break
+				}
+			}
+			if weight, ok := v["weight"].(float64); ok {
+				if weight < 0 {
+					return nil, fmt.Errorf("rep %d: weight cannot be negative", i+1)
+				}
+				rep.weight = weight
+			}
+		default:
+			return nil, fmt.Errorf("rep %d: expected an object or a string", i+1)
+		}
+		if rep.id == "" {
+			return nil, fmt.Errorf("rep %d: id, email or name is required", i+1)
+		}
+		if seen[rep.id] {
+			return nil, fmt.Errorf("rep %s is listed twice", rep.id)
+		}
+		seen[rep.id] = true
+		reps = append(reps, rep)
+	}
+	return reps, nil
+}
+
+// assignRulePool returns the reps of the first territory rule matching the
+// lead, or default_reps (all reps when unset) if none matches. Each rule is
+// a scoring rule with a reps list of rep IDs.
+func assignRulePool(config, input map[string]interface{}, byID map[string]assignRep, all []assignRep) ([]assignRep, string, string, error) {
+	raw, _ := config["rules"].([]interface{})
+	if len(raw) == 0 {
+		return nil, "", "", errors.New("rules are required for the rules strategy")
+	}
+
+	rules := make([]scoring.Rule, len(raw))
+	pools := make([][]assignRep, len(raw))
+	for i, entry := range raw {
+		spec, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, "", "", fmt.Errorf("rule %d: expected an object", i+1)
+		}
+		rules[i] = scoring.Rule{
+			Name:     stringValue(spec["name"]),
+			Field:    stringValue(spec["field"]),
+			Operator: stringValue(spec["operator"]),
+			Value:    spec["value"],
+		}
+		pool, err := assignPool(stringList(spec["reps"]), byID)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		pools[i] = pool
+	}
+	ruleSet, err := scoring.Compile(rules, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	lead := input
+	if path := stringValue(config["lead"]); path != "" {
+		value, _ := lookupPath(input, path)
+		if lead, _ = value.(map[string]interface{}); lead == nil {
+			return nil, "", "", fmt.Errorf("%s is not an object", path)
+		}
+	}
+	if i := ruleSet.FirstMatch(lead); i >= 0 {
+		name := rules[i].Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+		return pools[i], fmt.Sprintf("rule:%d", i), name, nil
+	}
+
+	if ids := stringList(config["default_reps"]); len(ids) > 0 {
+		pool, err := assignPool(ids, byID)
+		return pool, "default", "", err
+	}
+	return all, "default", "", nil
+}
+
+func assignPool(ids []string, byID map[string]assignRep) ([]assignRep, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("reps are required")
+	}
+	pool := make([]assignRep, 0, len(ids))
+	for _, id := range ids {
+		rep, ok := byID[id]
+		if !ok {
+			// inactive reps drop out of their territories
+			continue
+		}
+		pool = append(pool, rep)
+	}
+	return pool, nil
+}
+
+// pick chooses a rep from pool; the weighted strategy also advances its counters
+func (s *assignState) pick(strategy, poolName string, pool []assignRep) assignRep {
+	switch strategy {
+	case "weighted":
+		// smooth weighted round-robin: every rep gains its weight, the
+		// leader is picked and pays back the total, which spreads picks
+		// evenly instead of in bursts
+		if s.Weights == nil {
+			s.Weights = make(map[string]map[string]float64)
+		}
+		current := s.Weights[poolName]
+		next := make(map[string]float64, len(pool))
+		total := 0.0
+		best := -1
+		for i, rep := range pool {
+			next[rep.id] = current[rep.id] + rep.weight
+			total += rep.weight
+			if rep.weight > 0 && (best < 0 || next[rep.id] > next[pool[best].id]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			// every weight is zero; fall back to plain rotation
+			return s.pick("round_robin", poolName, pool)
+		}
+		next[pool[best].id] -= total
+		s.Weights[poolName] = next
+		return pool[best]
+
+	case "least_recent":
+		best := 0
+		// ties, e.g. leads assigned within the same millisecond, go to the
+		// rep with fewer leads
+		for i, rep := range pool {
+			at, bestAt := s.AssignedAt[rep.id], s.AssignedAt[pool[best].id]
+			if at < bestAt || at == bestAt && s.Counts[rep.id] < s.Counts[pool[best].id] {
+				best = i
+			}
+		}
+		return pool[best]
+
+	default:
+		last := s.Last[poolName]
+		for i, rep := range pool {
+			if rep.id == last {
+				return pool[(i+1)%len(pool)]
+			}
+		}
+		return pool[0]
+	}
+}
+
+func (s *assignState) record(poolName, id string, at time.Time) {
+	if s.Last == nil {
+		s.Last = make(map[string]string)
+	}
+	if s.AssignedAt == nil {
+		s.AssignedAt = make(map[string]int64)
+	}
+	if s.Counts == nil {
+		s.Counts = make(map[string]int)
+	}
+	s.Last[poolName] = id
+	s.AssignedAt[id] = at.UnixMilli()
+	s.Counts[id]++
+}
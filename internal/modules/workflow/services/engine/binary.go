@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// BinaryKey is the execution data key holding files produced or received by nodes
+const BinaryKey = "binary"
+
+// BinaryData is a file passed between nodes. In execution data it lives under
+// data["binary"][name]; Data is base64 encoded when serialized to JSON.
+type BinaryData struct {
+	FileName string `json:"fileName"`
+	MimeType string `json:"mimeType"`
+	Data     []byte `json:"data"`
+}
+
+// GetBinary returns the named file from execution data. Files may be stored
+// as *BinaryData by earlier nodes or as plain JSON objects in test data.
+func GetBinary(data map[string]interface{}, name string) (*BinaryData, error) {
+	files, ok := data[BinaryKey].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("execution has no binary data")
+	}
+
+	switch value := files[name].(type) {
+	case *BinaryData:
+		return value, nil
+	case BinaryData:
+		return &value, nil
+	case map[string]interface{}:
+		file := &BinaryData{}
+		file.FileName, _ = value["fileName"].(string)
+		file.MimeType, _ = value["mimeType"].(string)
+		encoded, _ := value["data"].(string)
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("binary %q is not valid base64: %w", name, err)
+		}
+		file.Data = decoded
+		return file, nil
+	default:
+		return nil, fmt.Errorf("binary %q not found", name)
+	}
+}
+
+// WithBinary returns a copy of the execution binary map with file added under name,
+// suitable for returning as output[BinaryKey] without dropping earlier files
+func WithBinary(data map[string]interface{}, name string, file *BinaryData) map[string]interface{} {
+	files := make(map[string]interface{})
+	if existing, ok := data[BinaryKey].(map[string]interface{}); ok {
+		for k, v := range existing {
+			files[k] = v
+		}
+	}
+	files[name] = file
+	return files
+}
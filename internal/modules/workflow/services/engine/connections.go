@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Connection is the engine's view of a user's stored integration credentials
+type Connection struct {
+	ID          string
+	Service     string
+	Credentials map[string]interface{}
+}
+
+// ConnectionResolver loads connections owned by the user running the workflow
+type ConnectionResolver interface {
+	Resolve(ctx context.Context, userID, connectionID string) (*Connection, error)
+}
+
+// resolveConnection looks up config["connection_id"] for the current run and
+// checks it is of the expected service type
+func resolveConnection(ctx context.Context, resolver ConnectionResolver, config map[string]interface{}, service string) (*Connection, error) {
+	connectionID, _ := config["connection_id"].(string)
+	if connectionID == "" {
+		return nil, errors.New("connection_id is required")
+	}
+	if resolver == nil {
+		return nil, errors.New("connections are not available")
+	}
+
+	conn, err := resolver.Resolve(ctx, RunInfoFromContext(ctx).UserID, connectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load connection: %w", err)
+	}
+	if conn.Service != service {
+		return nil, fmt.Errorf("connection %s is a %s connection, expected %s", connectionID, conn.Service, service)
+	}
+	return conn, nil
+}
@@ -0,0 +1,293 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"s4s-backend/internal/pkg/emaildomains"
+	"s4s-backend/internal/pkg/phone"
+)
+
+const mxLookupTimeout = 5 * time.Second
+
+// MXResolver looks up mail exchangers; *net.Resolver satisfies it
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NormalizeContactExecutor cleans up a lead before it is deduplicated or
+// synced: it lowercases and validates the email (optionally checking MX
+// records), classifies the domain as free-mail, corporate or disposable,
+// converts the phone to E.164 using the country hint, and splits and
+// capitalizes the name. email, phone, name, first_name, last_name and
+// country default to the same-named execution data values. The result is
+// written to output_key (default "contact").
+type NormalizeContactExecutor struct {
+	// Resolver is used for MX checks; nil means net.DefaultResolver
+	Resolver MXResolver
+}
+
+func (n *NormalizeContactExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid normalize contact configuration")
+	}
+
+	field := func(key string) string {
+		if raw, ok := config[key]; ok {
+			return strings.TrimSpace(replaceVariables(stringValue(raw), input))
+		}
+		return strings.TrimSpace(stringValue(input[key]))
+	}
+
+	contact := make(map[string]interface{})
+	var issues []string
+	valid := true
+
+	name := field("name")
+	if raw := field("email"); raw != "" {
+		email, displayName, problem := normalizeEmail(raw)
+		if name == "" {
+			name = displayName
+		}
+		contact["email"] = email
+		contact["email_valid"] = problem == ""
+		if problem != "" {
+			valid = false
+			issues = append(issues, problem)
+		} else {
+			domain := emaildomains.Domain(email)
+			free := emaildomains.IsFreeMail(domain)
+			disposable := emaildomains.IsDisposable(domain)
+			contact["email_domain"] = domain
+			contact["email_free"] = free
+			contact["email_disposable"] = disposable
+			contact["email_corporate"] = !free && !disposable
+			if disposable {
+				issues = append(issues, "email uses a disposable domain")
+			}
+
+			if checkMX, _ := config["check_mx"].(bool); checkMX {
+				hasMX, err := n.hasMX(ctx, domain)
+				if err != nil {
+					// a lookup failure says nothing about the address
+					contact["email_mx"] = nil
+					issues = append(issues, "mx lookup failed: "+err.Error())
+				} else {
+					contact["email_mx"] = hasMX
+					if !hasMX {
+						valid = false
+						contact["email_valid"] = false
+						issues = append(issues, "email domain does not accept mail")
+					}
+				}
+			}
+		}
+	}
+
+	if raw := field("phone"); raw != "" {
+		e164, region, err := phone.Normalize(raw, field("country"))
+		if err != nil {
+			valid = false
+			contact["phone"] = raw
+			contact["phone_valid"] = false
+			issues = append(issues, err.Error())
+		} else {
+			contact["phone"] = e164
+			contact["phone_valid"] = true
+			contact["phone_country"] = region
+		}
+	}
+
+	order := stringValue(config["name_order"])
+	first, middle, last := splitFullName(name, order)
+	if v := field("first_name"); v != "" {
+		first = v
+	}
+	if v := field("last_name"); v != "" {
+		last = v
+	}
+	if first != "" || last != "" {
+		first, middle, last = capitalizeName(first), capitalizeName(middle), capitalizeName(last)
+		contact["first_name"] = first
+		contact["middle_name"] = middle
+		contact["last_name"] = last
+		parts := []string{first, middle, last}
+		if order == "last_first" {
+			parts = []string{last, first, middle}
+		}
+		contact["full_name"] = strings.Join(nonEmpty(parts), " ")
+	}
+
+	if issues == nil {
+		issues = []string{}
+	}
+	contact["valid"] = valid
+	contact["issues"] = issues
+
+	outputKey := stringValue(config["output_key"])
+	if outputKey == "" {
+		outputKey = "contact"
+	}
+	return map[string]interface{}{outputKey: contact}, nil
+}
+
+// normalizeEmail lowercases and validates an address. It also accepts the
+// "Name <address>" form and returns the display name.
+func normalizeEmail(raw string) (string, string, string) {
+	parsed, err := mail.ParseAddress(raw)
+	if err != nil {
+		return strings.ToLower(raw), "", "invalid email syntax"
+	}
+	email := strings.ToLower(parsed.Address)
+	at := strings.LastIndexByte(email, '@')
+	local, domain := email[:at], email[at+1:]
+
+	switch {
+	case len(email) > 254 || len(local) > 64:
+		return email, parsed.Name, "email is too long"
+	case strings.HasPrefix(domain, "[") || net.ParseIP(domain) != nil:
+		return email, parsed.Name, "email domain is an IP address"
+	case !validDomain(domain):
+		return email, parsed.Name, "invalid email domain"
+	}
+	return email, parsed.Name, ""
+}
+
+func validDomain(domain string) bool {
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return false
+			}
+		}
+	}
+	tld := labels[len(labels)-1]
+	return utf8.RuneCountInString(tld) >= 2 && !strings.ContainsAny(tld, "0123456789")
+}
+
+// hasMX reports whether domain accepts mail: it has MX records, or no MX
+// records but an address (the implicit MX of RFC 5321). A null MX (".")
+// means the domain accepts no mail.
+func (n *NormalizeContactExecutor) hasMX(ctx context.Context, domain string) (bool, error) {
+	resolver := n.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ctx, cancel := context.WithTimeout(ctx, mxLookupTimeout)
+	defer cancel()
+
+	records, err := resolver.LookupMX(ctx, domain)
+	if err == nil && len(records) > 0 {
+		for _, mx := range records {
+			if mx.Host != "." && mx.Host != "" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if err != nil && !isNotFound(err) {
+		return false, err
+	}
+
+	hosts, err := resolver.LookupHost(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(hosts) > 0, nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// nameParticles stay lowercase inside a surname, e.g. "Ludwig van Beethoven"
+var nameParticles = map[string]bool{
+	"van": true, "von": true, "der": true, "den": true, "de": true, "del": true,
+	"della": true, "da": true, "dos": true, "das": true, "di": true, "du": true,
+	"la": true, "le": true, "ter": true, "ten": true, "bin": true, "ibn": true,
+}
+
+// splitFullName splits a full name into first, middle and last names. order is
+// "first_last" (default) or "last_first" as in "Иванов Иван Иванович"; the
+// "Last, First" form is recognized either way.
+func splitFullName(name, order string) (string, string, string) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "", "", ""
+	}
+	if comma := strings.IndexByte(name, ','); comma > 0 {
+		rest := strings.Fields(name[comma+1:])
+		last := strings.TrimSpace(name[:comma])
+		if len(rest) == 0 {
+			return last, "", ""
+		}
+		return rest[0], strings.Join(rest[1:], " "), last
+	}
+
+	parts := strings.Fields(name)
+	if len(parts) == 1 {
+		return parts[0], "", ""
+	}
+	if order == "last_first" {
+		return parts[1], strings.Join(parts[2:], " "), parts[0]
+	}
+
+	// particles start the surname: "Ludwig van Beethoven"
+	lastStart := len(parts) - 1
+	for lastStart > 1 && nameParticles[strings.ToLower(parts[lastStart-1])] {
+		lastStart--
+	}
+	return parts[0], strings.Join(parts[1:lastStart], " "), strings.Join(parts[lastStart:], " ")
+}
+
+// capitalizeName title-cases each part of a name, including parts joined by
+// hyphens or apostrophes (Anne-Marie, O'Neil), and keeps particles lowercase
+func capitalizeName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		if i < len(words)-1 && nameParticles[lower] {
+			words[i] = lower
+			continue
+		}
+		runes := []rune(lower)
+		upper := true
+		for j, r := range runes {
+			if upper {
+				runes[j] = unicode.ToUpper(r)
+			}
+			upper = r == '-' || r == '\'' || r == '’'
+		}
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
+
+func nonEmpty(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ContactData is a contact written by contact_upsert. Empty values leave the
+// stored contact's values untouched.
+type ContactData struct {
+	Email     string
+	Phone     string
+	FirstName string
+	LastName  string
+	Company   string
+	Title     string
+	Owner     string
+	Stage     string
+	Source    string
+	Tags      []string
+	Fields    map[string]interface{}
+	// Country is the default region for phone numbers without an international prefix
+	Country string
+	// ReplaceTags overwrites the contact's tags instead of adding to them
+	ReplaceTags bool
+}
+
+// ContactQuery selects contacts for contact_find; ID, Email or Phone look
+// up a single contact, the other fields filter a listing
+type ContactQuery struct {
+	ID      string
+	Email   string
+	Phone   string
+	Country string
+	Search  string
+	Tag     string
+	Stage   string
+	Owner   string
+	Limit   int
+}
+
+// ContactStore is the built-in contacts store of the run's owner. Contacts
+// are returned as workflow data with snake_case keys.
+type ContactStore interface {
+	// Upsert updates the contact with the same email, else phone, or creates it
+	Upsert(ctx context.Context, contact ContactData) (result map[string]interface{}, created bool, err error)
+	Find(ctx context.Context, query ContactQuery) ([]map[string]interface{}, error)
+}
+
+// contactField reads a contact value: the config template under key, else
+// the same-named execution data value, else that of a contact object such as
+// the output of normalize_contact
+func contactField(config, input map[string]interface{}, key string) string {
+	if raw, ok := config[key]; ok {
+		return strings.TrimSpace(replaceVariables(stringValue(raw), input))
+	}
+	if value, ok := input[key]; ok {
+		return strings.TrimSpace(stringValue(value))
+	}
+	if contact, ok := input["contact"].(map[string]interface{}); ok {
+		return strings.TrimSpace(stringValue(contact[key]))
+	}
+	return ""
+}
+
+// ContactUpsertExecutor saves a lead to the contacts store, deduplicated by
+// email and then phone. email, phone, country, first_name, last_name,
+// company, title, owner and stage default to the execution data; tags are
+// added to the contact (tags_mode "replace" overwrites them) and fields are
+// merged into its custom fields. The contact is written to output_key
+// (default "contact").
+type ContactUpsertExecutor struct {
+	Contacts ContactStore
+}
+
+func (c *ContactUpsertExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid contact upsert configuration")
+	}
+	if c.Contacts == nil {
+		return nil, errors.New("contacts store is not available")
+	}
+
+	data := ContactData{
+		Email:     contactField(config, input, "email"),
+		Phone:     contactField(config, input, "phone"),
+		Country:   contactField(config, input, "country"),
+		FirstName: contactField(config, input, "first_name"),
+		LastName:  contactField(config, input, "last_name"),
+		Company:   contactField(config, input, "company"),
+		Title:     contactField(config, input, "title"),
+		Owner:     contactField(config, input, "owner"),
+		Stage:     contactField(config, input, "stage"),
+		Source:    replaceVariables(stringValue(config["source"]), input),
+	}
+	if data.Email == "" && data.Phone == "" {
+		return nil, errors.New("email or phone is required")
+	}
+	if data.Source == "" {
+		data.Source = "workflow"
+	}
+	if raw, ok := config["tags"]; ok {
+		data.Tags = stringList(replaceVariablesDeep(raw, input))
+	}
+	switch mode := stringValue(config["tags_mode"]); mode {
+	case "", "add":
+	case "replace":
+		data.ReplaceTags = true
+	default:
+		return nil, fmt.Errorf("unknown tags_mode: %s", mode)
+	}
+	if raw, ok := config["fields"]; ok {
+		fields, ok := replaceVariablesDeep(raw, input).(map[string]interface{})
+		if !ok {
+			return nil, errors.New("fields must be an object")
+		}
+		data.Fields = fields
+	}
+
+	contact, created, err := c.Contacts.Upsert(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	outputKey := stringValue(config["output_key"])
+	if outputKey == "" {
+		outputKey = "contact"
+	}
+	if created {
+		Logf(ctx, "contact %v created", contact["id"])
+	} else {
+		Logf(ctx, "contact %v updated", contact["id"])
+	}
+	return map[string]interface{}{
+		outputKey:         contact,
+		"contact_id":      contact["id"],
+		"contact_created": created,
+	}, nil
+}
+
+// ContactFindExecutor looks up contacts in the contacts store by id, email
+// or phone, or lists those matching query (full-text), tag, stage and owner.
+// It returns up to limit (default 1, at most 100) contacts as "contacts" and
+// the first one under output_key (default "contact").
+type ContactFindExecutor struct {
+	Contacts ContactStore
+}
+
+func (c *ContactFindExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid contact find configuration")
+	}
+	if c.Contacts == nil {
+		return nil, errors.New("contacts store is not available")
+	}
+
+	field := func(key string) string {
+		return strings.TrimSpace(replaceVariables(stringValue(config[key]), input))
+	}
+	query := ContactQuery{
+		ID:      field("id"),
+		Email:   field("email"),
+		Phone:   field("phone"),
+		Country: field("country"),
+		Search:  field("query"),
+		Tag:     field("tag"),
+		Stage:   field("stage"),
+		Owner:   field("owner"),
+		Limit:   1,
+	}
+	if limit, ok := config["limit"].(float64); ok {
+		query.Limit = int(limit)
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		return nil, errors.New("limit must be between 1 and 100")
+	}
+	if query.ID == "" && query.Email == "" && query.Phone == "" && query.Search == "" &&
+		query.Tag == "" && query.Stage == "" && query.Owner == "" {
+		return nil, errors.New("id, email, phone, query, tag, stage or owner is required")
+	}
+
+	contacts, err := c.Contacts.Find(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	outputKey := stringValue(config["output_key"])
+	if outputKey == "" {
+		outputKey = "contact"
+	}
+	list := make([]interface{}, len(contacts))
+	for i, contact := range contacts {
+		list[i] = contact
+	}
+	var first interface{}
+	if len(contacts) > 0 {
+		first = contacts[0]
+	}
+	return map[string]interface{}{
+		outputKey:       first,
+		"contacts":      list,
+		"contact_found": len(contacts) > 0,
+		"contact_count": len(contacts),
+	}, nil
+}
+
+// ContactEventTriggerExecutor is the contact_event trigger node. The
+// contacts service places the created or stage_changed event in the
+// execution data before the graph starts, so the node passes it through.
+type ContactEventTriggerExecutor struct{}
+
+func (e *ContactEventTriggerExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	return input, nil
+}
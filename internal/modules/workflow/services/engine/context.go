@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type runInfoKey struct{}
+
+// RunInfo carries per-execution metadata to executors
+type RunInfo struct {
+	ExecutionID string
+	WorkflowID  string
+	UserID      string
+	IsTest      bool
+	// EventDepth counts the contact and email events that led to this run:
+	// 0 for a run started any other way, 1 for a run started by an event of
+	// such a run, and so on
+	EventDepth int
+
+	// Log appends a line to the execution log
+	Log func(line string)
+}
+
+// WithRunInfo attaches run metadata to the context passed to executors
+func WithRunInfo(ctx context.Context, info *RunInfo) context.Context {
+	return context.WithValue(ctx, runInfoKey{}, info)
+}
+
+// RunInfoFromContext returns the run metadata, or an empty RunInfo when the
+// executor is invoked outside of a workflow run
+func RunInfoFromContext(ctx context.Context) *RunInfo {
+	if info, ok := ctx.Value(runInfoKey{}).(*RunInfo); ok && info != nil {
+		return info
+	}
+	return &RunInfo{}
+}
+
+// Logf writes a formatted line to the execution log of the current run
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	info := RunInfoFromContext(ctx)
+	if info.Log == nil {
+		return
+	}
+	info.Log(fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...)))
+}
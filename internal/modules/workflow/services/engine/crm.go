@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCRMNotFound is returned when a CRM lookup has no match
+var ErrCRMNotFound = errors.New("not found in crm")
+
+// CRMContact is a provider-neutral contact (HubSpot contact, Pipedrive person, amoCRM contact)
+type CRMContact struct {
+	ID         string                 `json:"id"`
+	Email      string                 `json:"email"`
+	FirstName  string                 `json:"firstName"`
+	LastName   string                 `json:"lastName"`
+	Phone      string                 `json:"phone,omitempty"`
+	Company    string                 `json:"company,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// CRMDeal is a provider-neutral deal (amoCRM calls them leads)
+type CRMDeal struct {
+	ID         string                 `json:"id"`
+	Title      string                 `json:"title"`
+	Amount     float64                `json:"amount,omitempty"`
+	Currency   string                 `json:"currency,omitempty"`
+	PipelineID string                 `json:"pipelineId,omitempty"`
+	StageID    string                 `json:"stageId,omitempty"`
+	ContactID  string                 `json:"contactId,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// CRMNote is a note attached to a contact or a deal
+type CRMNote struct {
+	ID         string `json:"id"`
+	TargetType string `json:"targetType"`
+	TargetID   string `json:"targetId"`
+	Content    string `json:"content"`
+}
+
+// CRMClient is implemented by every CRM adapter
+type CRMClient interface {
+	FindContactByEmail(ctx context.Context, email string) (*CRMContact, error)
+	UpsertContact(ctx context.Context, contact *CRMContact) (*CRMContact, error)
+	CreateDeal(ctx context.Context, deal *CRMDeal) (*CRMDeal, error)
+	MoveDealStage(ctx context.Context, dealID, stageID, pipelineID string) (*CRMDeal, error)
+	AddNote(ctx context.Context, note *CRMNote) (*CRMNote, error)
+}
+
+// crmFactory builds an adapter from connection credentials. baseURL overrides
+// the provider's default API location (used for tests against fake servers).
+type crmFactory func(creds map[string]interface{}, baseURL string, client *http.Client) (CRMClient, error)
+
+// crmProviders maps connection service names to adapters
+var crmProviders = map[string]crmFactory{
+	"hubspot":   newHubSpotClient,
+	"pipedrive": newPipedriveClient,
+	"amocrm":    newAmoCRMClient,
+}
+
+// CRMExecutor runs CRM operations against whichever provider the referenced connection belongs to
+type CRMExecutor struct {
+	Connections ConnectionResolver
+	Egress      *EgressPolicy
+
+	// BaseURLs overrides API base URLs per provider ("hubspot", "pipedrive", "amocrm")
+	BaseURLs map[string]string
+}
+
+func (c *CRMExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid crm configuration")
+	}
+
+	connectionID, _ := config["connection_id"].(string)
+	if connectionID == "" {
+		return nil, errors.New("connection_id is required")
+	}
+	if c.Connections == nil {
+		return nil, errors.New("connections are not available")
+	}
+	conn, err := c.Connections.Resolve(ctx, RunInfoFromContext(ctx).UserID, connectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load connection: %w", err)
+	}
+	factory, ok := crmProviders[conn.Service]
+	if !ok {
+		return nil, fmt.Errorf("connection %s is not a supported crm (%s)", connectionID, conn.Service)
+	}
+
+	egress := c.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+	client, err := factory(conn.Credentials, c.BaseURLs[conn.Service], egress.HTTPClient(30*time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s connection: %w", conn.Service, err)
+	}
+
+	field := func(key string) string {
+		return strings.TrimSpace(replaceVariables(stringValue(config[key]), input))
+	}
+	properties, _ := replaceVariablesDeep(config["properties"], input).(map[string]interface{})
+
+	operation, _ := config["operation"].(string)
+	switch operation {
+	case "search_contact":
+		email := field("email")
+		if email == "" {
+			return nil, errors.New("email is required")
+		}
+		contact, err := client.FindContactByEmail(ctx, email)
+		if errors.Is(err, ErrCRMNotFound) {
+			return map[string]interface{}{"crm_found": false, "contact": nil}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", conn.Service, err)
+		}
+		return map[string]interface{}{"crm_found": true, "contact": contact}, nil
+
+	case "upsert_contact":
+		contact := &CRMContact{
+			Email:      strings.ToLower(field("email")),
+			FirstName:  field("first_name"),
+			LastName:   field("last_name"),
+			Phone:      field("phone"),
+			Company:    field("company"),
+			Properties: properties,
+		}
+		if contact.Email == "" {
+			return nil, errors.New("email is required")
+		}
+		result, err := client.UpsertContact(ctx, contact)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", conn.Service, err)
+		}
+		return map[string]interface{}{"contact": result, "contact_id": result.ID}, nil
+
+	case "create_deal":
+		deal := &CRMDeal{
+			Title:      field("title"),
+			Currency:   field("currency"),
+			PipelineID: field("pipeline_id"),
+			StageID:    field("stage_id"),
+			ContactID:  field("contact_id"),
+			Properties: properties,
+		}
+		if deal.Title == "" {
+			return nil, errors.New("title is required")
+		}
+		if amount := field("amount"); amount != "" {
+			if deal.Amount, err = strconv.ParseFloat(amount, 64); err != nil {
+				return nil, fmt.Errorf("invalid amount %q", amount)
+			}
+		}
+		result, err := client.CreateDeal(ctx, deal)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", conn.Service, err)
+		}
+		return map[string]interface{}{"deal": result, "deal_id": result.ID}, nil
+
+	case "move_deal_stage":
+		dealID, stageID := field("deal_id"), field("stage_id")
+		if dealID == "" || stageID == "" {
+			return nil, errors.New("deal_id and stage_id are required")
+		}
+		result, err := client.MoveDealStage(ctx, dealID, stageID, field("pipeline_id"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", conn.Service, err)
+		}
+		return map[string]interface{}{"deal": result, "deal_id": result.ID}, nil
+
+	case "add_note":
+		note := &CRMNote{
+			TargetType: field("target"),
+			TargetID:   field("target_id"),
+			Content:    field("content"),
+		}
+		if note.TargetType == "" {
+			note.TargetType = "contact"
+		}
+		if note.TargetType != "contact" && note.TargetType != "deal" {
+			return nil, fmt.Errorf("unsupported note target %q", note.TargetType)
+		}
+		if note.TargetID == "" || note.Content == "" {
+			return nil, errors.New("target_id and content are required")
+		}
+		result, err := client.AddNote(ctx, note)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", conn.Service, err)
+		}
+		return map[string]interface{}{"note": result, "note_id": result.ID}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown crm operation: %s", operation)
+	}
+}
+
+// splitName splits a full name into first and last name
+func splitName(name string) (string, string) {
+	parts := strings.Fields(name)
+	switch len(parts) {
+	case 0:
+		return "", ""
+	case 1:
+		return parts[0], ""
+	default:
+		return parts[0], strings.Join(parts[1:], " ")
+	}
+}
+
+func joinName(first, last string) string {
+	return strings.TrimSpace(first + " " + last)
+}
@@ -0,0 +1,261 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// amoCRMClient talks to the amoCRM v4 API using a long-lived access token.
+// Deals are amoCRM "leads"; stages are lead statuses.
+type amoCRMClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+type amoCustomField struct {
+	FieldCode string `json:"field_code,omitempty"`
+	Values    []struct {
+		Value    interface{} `json:"value"`
+		EnumCode string      `json:"enum_code,omitempty"`
+	} `json:"values"`
+}
+
+type amoContact struct {
+	ID                 int64            `json:"id"`
+	Name               string           `json:"name"`
+	FirstName          string           `json:"first_name"`
+	LastName           string           `json:"last_name"`
+	CustomFieldsValues []amoCustomField `json:"custom_fields_values"`
+}
+
+type amoLead struct {
+	ID         int64   `json:"id"`
+	Name       string  `json:"name"`
+	Price      float64 `json:"price"`
+	StatusID   int64   `json:"status_id"`
+	PipelineID int64   `json:"pipeline_id"`
+}
+
+func newAmoCRMClient(creds map[string]interface{}, baseURL string, client *http.Client) (CRMClient, error) {
+	token, _ := creds["accessToken"].(string)
+	if token == "" {
+		return nil, errors.New("accessToken is required")
+	}
+	if baseURL == "" {
+		subdomain, _ := creds["subdomain"].(string)
+		if subdomain == "" {
+			return nil, errors.New("subdomain is required")
+		}
+		domain, _ := creds["domain"].(string)
+		if domain == "" {
+			domain = "amocrm.ru"
+		}
+		baseURL = fmt.Sprintf("https://%s.%s/api/v4", subdomain, domain)
+	}
+	return &amoCRMClient{baseURL: strings.TrimRight(baseURL, "/"), token: token, http: client}, nil
+}
+
+func (a *amoCRMClient) do(ctx context.Context, method, path string, payload, out interface{}) error {
+	headers := map[string]string{"Authorization": "Bearer " + a.token}
+	_, err := doJSON(ctx, a.http, method, a.baseURL+path, headers, payload, out)
+	return err
+}
+
+func amoField(code, value, enumCode string) map[string]interface{} {
+	entry := map[string]interface{}{"value": value}
+	if enumCode != "" {
+		entry["enum_code"] = enumCode
+	}
+	return map[string]interface{}{
+		"field_code": code,
+		"values":     []map[string]interface{}{entry},
+	}
+}
+
+func (a *amoCRMClient) contactFromAmo(c *amoContact) *CRMContact {
+	contact := &CRMContact{
+		ID:        strconv.FormatInt(c.ID, 10),
+		FirstName: c.FirstName,
+		LastName:  c.LastName,
+	}
+	if contact.FirstName == "" && contact.LastName == "" {
+		contact.FirstName, contact.LastName = splitName(c.Name)
+	}
+	for _, field := range c.CustomFieldsValues {
+		if len(field.Values) == 0 {
+			continue
+		}
+		switch field.FieldCode {
+		case "EMAIL":
+			contact.Email = stringValue(field.Values[0].Value)
+		case "PHONE":
+			contact.Phone = stringValue(field.Values[0].Value)
+		}
+	}
+	return contact
+}
+
+func (a *amoCRMClient) FindContactByEmail(ctx context.Context, email string) (*CRMContact, error) {
+	email = strings.ToLower(email)
+
+	var resp struct {
+		Embedded struct {
+			Contacts []amoContact `json:"contacts"`
+		} `json:"_embedded"`
+	}
+	// amoCRM answers 204 with an empty body when nothing matches
+	if err := a.do(ctx, http.MethodGet, "/contacts?query="+url.QueryEscape(email), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	// query is a full-text search; keep only exact email matches
+	for i := range resp.Embedded.Contacts {
+		contact := a.contactFromAmo(&resp.Embedded.Contacts[i])
+		if strings.EqualFold(contact.Email, email) {
+			return contact, nil
+		}
+	}
+	return nil, ErrCRMNotFound
+}
+
+func (a *amoCRMClient) UpsertContact(ctx context.Context, contact *CRMContact) (*CRMContact, error) {
+	fields := []map[string]interface{}{amoField("EMAIL", contact.Email, "WORK")}
+	if contact.Phone != "" {
+		fields = append(fields, amoField("PHONE", contact.Phone, "WORK"))
+	}
+
+	payload := map[string]interface{}{"custom_fields_values": fields}
+	for key, value := range contact.Properties {
+		payload[key] = value
+	}
+	if contact.FirstName != "" {
+		payload["first_name"] = contact.FirstName
+	}
+	if contact.LastName != "" {
+		payload["last_name"] = contact.LastName
+	}
+
+	existing, err := a.FindContactByEmail(ctx, contact.Email)
+	if err != nil && !errors.Is(err, ErrCRMNotFound) {
+		return nil, err
+	}
+
+	result := *contact
+	if existing != nil {
+		var updated amoContact
+		if err := a.do(ctx, http.MethodPatch, "/contacts/"+url.PathEscape(existing.ID), payload, &updated); err != nil {
+			return nil, err
+		}
+		result.ID = existing.ID
+		return &result, nil
+	}
+
+	if name := joinName(contact.FirstName, contact.LastName); name != "" {
+		payload["name"] = name
+	} else {
+		payload["name"] = contact.Email
+	}
+	var resp struct {
+		Embedded struct {
+			Contacts []amoContact `json:"contacts"`
+		} `json:"_embedded"`
+	}
+	if err := a.do(ctx, http.MethodPost, "/contacts", []interface{}{payload}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embedded.Contacts) == 0 {
+		return nil, errors.New("contact was not created")
+	}
+	result.ID = strconv.FormatInt(resp.Embedded.Contacts[0].ID, 10)
+	return &result, nil
+}
+
+func (a *amoCRMClient) CreateDeal(ctx context.Context, deal *CRMDeal) (*CRMDeal, error) {
+	payload := map[string]interface{}{"name": deal.Title}
+	for key, value := range deal.Properties {
+		payload[key] = value
+	}
+	if deal.Amount != 0 {
+		payload["price"] = int64(deal.Amount)
+	}
+	if deal.StageID != "" {
+		payload["status_id"] = numericID(deal.StageID)
+	}
+	if deal.PipelineID != "" {
+		payload["pipeline_id"] = numericID(deal.PipelineID)
+	}
+	if deal.ContactID != "" {
+		payload["_embedded"] = map[string]interface{}{
+			"contacts": []map[string]interface{}{{"id": numericID(deal.ContactID)}},
+		}
+	}
+
+	var resp struct {
+		Embedded struct {
+			Leads []amoLead `json:"leads"`
+		} `json:"_embedded"`
+	}
+	if err := a.do(ctx, http.MethodPost, "/leads", []interface{}{payload}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embedded.Leads) == 0 {
+		return nil, errors.New("lead was not created")
+	}
+	result := *deal
+	result.ID = strconv.FormatInt(resp.Embedded.Leads[0].ID, 10)
+	return &result, nil
+}
+
+func (a *amoCRMClient) MoveDealStage(ctx context.Context, dealID, stageID, pipelineID string) (*CRMDeal, error) {
+	payload := map[string]interface{}{"status_id": numericID(stageID)}
+	if pipelineID != "" {
+		payload["pipeline_id"] = numericID(pipelineID)
+	}
+
+	var lead amoLead
+	if err := a.do(ctx, http.MethodPatch, "/leads/"+url.PathEscape(dealID), payload, &lead); err != nil {
+		return nil, err
+	}
+	return &CRMDeal{
+		ID:         dealID,
+		Title:      lead.Name,
+		Amount:     lead.Price,
+		StageID:    stageID,
+		PipelineID: pipelineID,
+	}, nil
+}
+
+func (a *amoCRMClient) AddNote(ctx context.Context, note *CRMNote) (*CRMNote, error) {
+	entity := "contacts"
+	if note.TargetType == "deal" {
+		entity = "leads"
+	}
+	payload := []interface{}{map[string]interface{}{
+		"note_type": "common",
+		"params":    map[string]interface{}{"text": note.Content},
+	}}
+
+	var resp struct {
+		Embedded struct {
+			Notes []struct {
+				ID int64 `json:"id"`
+			} `json:"notes"`
+		} `json:"_embedded"`
+	}
+	path := fmt.Sprintf("/%s/%s/notes", entity, url.PathEscape(note.TargetID))
+	if err := a.do(ctx, http.MethodPost, path, payload, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embedded.Notes) == 0 {
+		return nil, errors.New("note was not created")
+	}
+	result := *note
+	result.ID = strconv.FormatInt(resp.Embedded.Notes[0].ID, 10)
+	return &result, nil
+}
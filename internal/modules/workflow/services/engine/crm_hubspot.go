@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultHubSpotAPIURL = "https://api.hubapi.com"
+
+// HubSpot association type ids (HUBSPOT_DEFINED)
+const (
+	hubspotDealToContact = 3
+	hubspotNoteToContact = 202
+	hubspotNoteToDeal    = 214
+)
+
+// hubspotClient talks to the HubSpot CRM v3 API using a private app token
+type hubspotClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+type hubspotObject struct {
+	ID         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+func newHubSpotClient(creds map[string]interface{}, baseURL string, client *http.Client) (CRMClient, error) {
+	token, _ := creds["accessToken"].(string)
+	if token == "" {
+		return nil, errors.New("accessToken is required")
+	}
+	if baseURL == "" {
+		baseURL = defaultHubSpotAPIURL
+	}
+	return &hubspotClient{baseURL: strings.TrimRight(baseURL, "/"), token: token, http: client}, nil
+}
+
+func (h *hubspotClient) do(ctx context.Context, method, path string, payload, out interface{}) error {
+	headers := map[string]string{"Authorization": "Bearer " + h.token}
+	_, err := doJSON(ctx, h.http, method, h.baseURL+path, headers, payload, out)
+	return err
+}
+
+func hubspotAssociation(id string, typeID int) []map[string]interface{} {
+	return []map[string]interface{}{{
+		"to": map[string]interface{}{"id": id},
+		"types": []map[string]interface{}{{
+			"associationCategory": "HUBSPOT_DEFINED",
+			"associationTypeId":   typeID,
+		}},
+	}}
+}
+
+func (h *hubspotClient) contactFromObject(obj *hubspotObject) *CRMContact {
+	prop := func(key string) string { return stringValue(obj.Properties[key]) }
+	return &CRMContact{
+		ID:         obj.ID,
+		Email:      prop("email"),
+		FirstName:  prop("firstname"),
+		LastName:   prop("lastname"),
+		Phone:      prop("phone"),
+		Company:    prop("company"),
+		Properties: obj.Properties,
+	}
+}
+
+func (h *hubspotClient) FindContactByEmail(ctx context.Context, email string) (*CRMContact, error) {
+	payload := map[string]interface{}{
+		"filterGroups": []map[string]interface{}{{
+			"filters": []map[string]interface{}{{
+				"propertyName": "email",
+				"operator":     "EQ",
+				"value":        strings.ToLower(email),
+			}},
+		}},
+		"properties": []string{"email", "firstname", "lastname", "phone", "company"},
+		"limit":      1,
+	}
+
+	var resp struct {
+		Results []hubspotObject `json:"results"`
+	}
+	if err := h.do(ctx, http.MethodPost, "/crm/v3/objects/contacts/search", payload, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, ErrCRMNotFound
+	}
+	return h.contactFromObject(&resp.Results[0]), nil
+}
+
+func (h *hubspotClient) UpsertContact(ctx context.Context, contact *CRMContact) (*CRMContact, error) {
+	properties := map[string]interface{}{"email": contact.Email}
+	for key, value := range contact.Properties {
+		properties[key] = value
+	}
+	setIf := func(key, value string) {
+		if value != "" {
+			properties[key] = value
+		}
+	}
+	setIf("firstname", contact.FirstName)
+	setIf("lastname", contact.LastName)
+	setIf("phone", contact.Phone)
+	setIf("company", contact.Company)
+
+	existing, err := h.FindContactByEmail(ctx, contact.Email)
+	if err != nil && !errors.Is(err, ErrCRMNotFound) {
+		return nil, err
+	}
+
+	var obj hubspotObject
+	payload := map[string]interface{}{"properties": properties}
+	if existing != nil {
+		err = h.do(ctx, http.MethodPatch, "/crm/v3/objects/contacts/"+url.PathEscape(existing.ID), payload, &obj)
+	} else {
+		err = h.do(ctx, http.MethodPost, "/crm/v3/objects/contacts", payload, &obj)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.contactFromObject(&obj), nil
+}
+
+func (h *hubspotClient) CreateDeal(ctx context.Context, deal *CRMDeal) (*CRMDeal, error) {
+	properties := map[string]interface{}{"dealname": deal.Title}
+	for key, value := range deal.Properties {
+		properties[key] = value
+	}
+	if deal.Amount != 0 {
+		properties["amount"] = deal.Amount
+	}
+	if deal.Currency != "" {
+		properties["deal_currency_code"] = deal.Currency
+	}
+	if deal.PipelineID != "" {
+		properties["pipeline"] = deal.PipelineID
+	}
+	if deal.StageID != "" {
+		properties["dealstage"] = deal.StageID
+	}
+
+	payload := map[string]interface{}{"properties": properties}
+	if deal.ContactID != "" {
+		payload["associations"] = hubspotAssociation(deal.ContactID, hubspotDealToContact)
+	}
+
+	var obj hubspotObject
+	if err := h.do(ctx, http.MethodPost, "/crm/v3/objects/deals", payload, &obj); err != nil {
+		return nil, err
+	}
+	result := *deal
+	result.ID = obj.ID
+	return &result, nil
+}
+
+func (h *hubspotClient) MoveDealStage(ctx context.Context, dealID, stageID, pipelineID string) (*CRMDeal, error) {
+	properties := map[string]interface{}{"dealstage": stageID}
+	if pipelineID != "" {
+		properties["pipeline"] = pipelineID
+	}
+
+	var obj hubspotObject
+	if err := h.do(ctx, http.MethodPatch, "/crm/v3/objects/deals/"+url.PathEscape(dealID), map[string]interface{}{"properties": properties}, &obj); err != nil {
+		return nil, err
+	}
+	return &CRMDeal{
+		ID:         obj.ID,
+		Title:      stringValue(obj.Properties["dealname"]),
+		PipelineID: stringValue(obj.Properties["pipeline"]),
+		StageID:    stringValue(obj.Properties["dealstage"]),
+	}, nil
+}
+
+func (h *hubspotClient) AddNote(ctx context.Context, note *CRMNote) (*CRMNote, error) {
+	typeID := hubspotNoteToContact
+	if note.TargetType == "deal" {
+		typeID = hubspotNoteToDeal
+	}
+	payload := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"hs_note_body": note.Content,
+			"hs_timestamp": time.Now().UTC().Format(time.RFC3339),
+		},
+		"associations": hubspotAssociation(note.TargetID, typeID),
+	}
+
+	var obj hubspotObject
+	if err := h.do(ctx, http.MethodPost, "/crm/v3/objects/notes", payload, &obj); err != nil {
+		return nil, err
+	}
+	result := *note
+	result.ID = obj.ID
+	return &result, nil
+}
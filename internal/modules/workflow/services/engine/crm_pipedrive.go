@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultPipedriveAPIURL = "https://api.pipedrive.com/v1"
+
+// pipedriveClient talks to the Pipedrive v1 API using a personal API token
+type pipedriveClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+type pipedriveValue struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary"`
+}
+
+type pipedrivePerson struct {
+	ID        int64            `json:"id"`
+	Name      string           `json:"name"`
+	FirstName string           `json:"first_name"`
+	LastName  string           `json:"last_name"`
+	Email     []pipedriveValue `json:"email"`
+	Phone     []pipedriveValue `json:"phone"`
+	OrgName   string           `json:"org_name"`
+}
+
+type pipedriveDeal struct {
+	ID         int64   `json:"id"`
+	Title      string  `json:"title"`
+	Value      float64 `json:"value"`
+	Currency   string  `json:"currency"`
+	StageID    int64   `json:"stage_id"`
+	PipelineID int64   `json:"pipeline_id"`
+}
+
+func newPipedriveClient(creds map[string]interface{}, baseURL string, client *http.Client) (CRMClient, error) {
+	token, _ := creds["apiToken"].(string)
+	if token == "" {
+		return nil, errors.New("apiToken is required")
+	}
+	if baseURL == "" {
+		// company domains expose the same API under https://<company>.pipedrive.com/api/v1
+		if domain, _ := creds["companyDomain"].(string); domain != "" {
+			baseURL = fmt.Sprintf("https://%s.pipedrive.com/api/v1", domain)
+		} else {
+			baseURL = defaultPipedriveAPIURL
+		}
+	}
+	return &pipedriveClient{baseURL: strings.TrimRight(baseURL, "/"), token: token, http: client}, nil
+}
+
+func (p *pipedriveClient) do(ctx context.Context, method, path string, query url.Values, payload, data interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api_token", p.token)
+
+	var resp struct {
+		Success bool        `json:"success"`
+		Error   string      `json:"error"`
+		Data    interface{} `json:"data"`
+	}
+	resp.Data = data
+
+	_, err := doJSON(ctx, p.http, method, p.baseURL+path+"?"+query.Encode(), nil, payload, &resp)
+	if err != nil {
+		// the token travels in the query string; keep it out of execution logs
+		return errors.New(strings.ReplaceAll(err.Error(), p.token, "***"))
+	}
+	if !resp.Success {
+		return fmt.Errorf("request failed: %s", resp.Error)
+	}
+	return nil
+}
+
+func (p *pipedriveClient) contactFromPerson(person *pipedrivePerson) *CRMContact {
+	contact := &CRMContact{
+		ID:        strconv.FormatInt(person.ID, 10),
+		FirstName: person.FirstName,
+		LastName:  person.LastName,
+		Company:   person.OrgName,
+	}
+	if contact.FirstName == "" && contact.LastName == "" {
+		contact.FirstName, contact.LastName = splitName(person.Name)
+	}
+	if len(person.Email) > 0 {
+		contact.Email = person.Email[0].Value
+	}
+	if len(person.Phone) > 0 {
+		contact.Phone = person.Phone[0].Value
+	}
+	return contact
+}
+
+func (p *pipedriveClient) FindContactByEmail(ctx context.Context, email string) (*CRMContact, error) {
+	query := url.Values{}
+	query.Set("term", strings.ToLower(email))
+	query.Set("fields", "email")
+	query.Set("exact_match", "true")
+	query.Set("limit", "1")
+
+	var data struct {
+		Items []struct {
+			Item struct {
+				ID int64 `json:"id"`
+			} `json:"item"`
+		} `json:"items"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/persons/search", query, nil, &data); err != nil {
+		return nil, err
+	}
+	if len(data.Items) == 0 {
+		return nil, ErrCRMNotFound
+	}
+
+	var person pipedrivePerson
+	if err := p.do(ctx, http.MethodGet, fmt.Sprintf("/persons/%d", data.Items[0].Item.ID), nil, nil, &person); err != nil {
+		return nil, err
+	}
+	return p.contactFromPerson(&person), nil
+}
+
+func (p *pipedriveClient) UpsertContact(ctx context.Context, contact *CRMContact) (*CRMContact, error) {
+	payload := map[string]interface{}{
+		"email": []pipedriveValue{{Value: contact.Email, Primary: true}},
+	}
+	for key, value := range contact.Properties {
+		payload[key] = value
+	}
+	if name := joinName(contact.FirstName, contact.LastName); name != "" {
+		payload["name"] = name
+	}
+	if contact.Phone != "" {
+		payload["phone"] = []pipedriveValue{{Value: contact.Phone, Primary: true}}
+	}
+
+	existing, err := p.FindContactByEmail(ctx, contact.Email)
+	if err != nil && !errors.Is(err, ErrCRMNotFound) {
+		return nil, err
+	}
+
+	var person pipedrivePerson
+	if existing != nil {
+		err = p.do(ctx, http.MethodPut, "/persons/"+url.PathEscape(existing.ID), nil, payload, &person)
+	} else {
+		if _, ok := payload["name"]; !ok {
+			payload["name"] = contact.Email
+		}
+		err = p.do(ctx, http.MethodPost, "/persons", nil, payload, &person)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.contactFromPerson(&person), nil
+}
+
+func (p *pipedriveClient) CreateDeal(ctx context.Context, deal *CRMDeal) (*CRMDeal, error) {
+	payload := map[string]interface{}{"title": deal.Title}
+	for key, value := range deal.Properties {
+		payload[key] = value
+	}
+	if deal.Amount != 0 {
+		payload["value"] = deal.Amount
+	}
+	if deal.Currency != "" {
+		payload["currency"] = deal.Currency
+	}
+	if deal.ContactID != "" {
+		payload["person_id"] = numericID(deal.ContactID)
+	}
+	if deal.StageID != "" {
+		payload["stage_id"] = numericID(deal.StageID)
+	}
+	if deal.PipelineID != "" {
+		payload["pipeline_id"] = numericID(deal.PipelineID)
+	}
+
+	var created pipedriveDeal
+	if err := p.do(ctx, http.MethodPost, "/deals", nil, payload, &created); err != nil {
+		return nil, err
+	}
+	result := *deal
+	result.ID = strconv.FormatInt(created.ID, 10)
+	return &result, nil
+}
+
+func (p *pipedriveClient) MoveDealStage(ctx context.Context, dealID, stageID, pipelineID string) (*CRMDeal, error) {
+	payload := map[string]interface{}{"stage_id": numericID(stageID)}
+	if pipelineID != "" {
+		payload["pipeline_id"] = numericID(pipelineID)
+	}
+
+	var updated pipedriveDeal
+	if err := p.do(ctx, http.MethodPut, "/deals/"+url.PathEscape(dealID), nil, payload, &updated); err != nil {
+		return nil, err
+	}
+	return &CRMDeal{
+		ID:         strconv.FormatInt(updated.ID, 10),
+		Title:      updated.Title,
+		Amount:     updated.Value,
+		Currency:   updated.Currency,
+		StageID:    strconv.FormatInt(updated.StageID, 10),
+		PipelineID: strconv.FormatInt(updated.PipelineID, 10),
+	}, nil
+}
+
+func (p *pipedriveClient) AddNote(ctx context.Context, note *CRMNote) (*CRMNote, error) {
+	payload := map[string]interface{}{"content": note.Content}
+	if note.TargetType == "deal" {
+		payload["deal_id"] = numericID(note.TargetID)
+	} else {
+		payload["person_id"] = numericID(note.TargetID)
+	}
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/notes", nil, payload, &created); err != nil {
+		return nil, err
+	}
+	result := *note
+	result.ID = strconv.FormatInt(created.ID, 10)
+	return &result, nil
+}
@@ -0,0 +1,321 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// crmFake answers "METHOD /path" with a status and a JSON value; unknown
+// requests get a 404
+type crmFake map[string]func(r apiRequest) (int, interface{})
+
+func (f crmFake) respond(r apiRequest) (int, interface{}) {
+	if handler, ok := f[r.Method+" "+r.Path]; ok {
+		return handler(r)
+	}
+	return http.StatusNotFound, map[string]interface{}{"message": "no route " + r.Method + " " + r.Path}
+}
+
+func crmNode(config map[string]interface{}) *Node {
+	config["connection_id"] = "conn-1"
+	return &Node{ID: "crm-1", Data: map[string]interface{}{"type": "crm", "config": config}}
+}
+
+// crmTest is one operation against a fake CRM
+type crmTest struct {
+	name    string
+	config  map[string]interface{}
+	want    map[string]interface{}
+	wantErr string
+	// wantRequests lists the requests as "METHOD /path"
+	wantRequests []string
+}
+
+func runCRMTests(t *testing.T, service string, credentials map[string]interface{}, fake crmFake, tests []crmTest, check func(t *testing.T, r apiRequest)) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAPIServer(t, fake.respond)
+			executor := &CRMExecutor{
+				Connections: staticConnections{conn: &Connection{ID: "conn-1", Service: service, Credentials: credentials}},
+				Egress:      localEgress(t),
+				BaseURLs:    map[string]string{service: server.URL + "/"},
+			}
+			output, err := executor.Execute(context.Background(), crmNode(tt.config), map[string]interface{}{"email": "Anna@Example.com"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() = %v, want an error containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Execute: %v", err)
+			} else if !reflect.DeepEqual(output, tt.want) {
+				t.Errorf("output = %#v\nwant %#v", output, tt.want)
+			}
+
+			var got []string
+			for _, r := range server.Requests() {
+				got = append(got, r.Method+" "+r.Path)
+				check(t, r)
+			}
+			if !slices.Equal(got, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestHubSpotAdapter(t *testing.T) {
+	fake := crmFake{
+		"POST /crm/v3/objects/contacts/search": func(r apiRequest) (int, interface{}) {
+			filter := r.Body["filterGroups"].([]interface{})[0].(map[string]interface{})["filters"].([]interface{})[0].(map[string]interface{})
+			if filter["propertyName"] != "email" || filter["value"] != "anna@example.com" {
+				return http.StatusOK, map[string]interface{}{"results": []interface{}{}}
+			}
+			return http.StatusOK, map[string]interface{}{"results": []interface{}{map[string]interface{}{
+				"id":         "a/b",
+				"properties": map[string]interface{}{"email": "anna@example.com", "firstname": "Anna", "lastname": "Smith"},
+			}}}
+		},
+		"PATCH /crm/v3/objects/contacts/a%2Fb": func(r apiRequest) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{"id": "a/b", "properties": r.Body["properties"]}
+		},
+		"POST /crm/v3/objects/contacts": func(r apiRequest) (int, interface{}) {
+			return http.StatusCreated, map[string]interface{}{"id": "102", "properties": r.Body["properties"]}
+		},
+		"PATCH /crm/v3/objects/deals/42": func(r apiRequest) (int, interface{}) {
+			properties := r.Body["properties"].(map[string]interface{})
+			properties["dealname"] = "Big deal"
+			return http.StatusOK, map[string]interface{}{"id": "42", "properties": properties}
+		},
+	}
+	check := func(t *testing.T, r apiRequest) {
+		if r.Authorization != "Bearer pat-secret" {
+			t.Errorf("%s %s with %q", r.Method, r.Path, r.Authorization)
+		}
+	}
+
+	runCRMTests(t, "hubspot", map[string]interface{}{"accessToken": "pat-secret"}, fake, []crmTest{
+		{
+			name:   "search finds the contact",
+			config: map[string]interface{}{"operation": "search_contact", "email": "{{email}}"},
+			want: map[string]interface{}{"crm_found": true, "contact": &CRMContact{
+				ID: "a/b", Email: "anna@example.com", FirstName: "Anna", LastName: "Smith",
+				Properties: map[string]interface{}{"email": "anna@example.com", "firstname": "Anna", "lastname": "Smith"},
+			}},
+			wantRequests: []string{"POST /crm/v3/objects/contacts/search"},
+		},
+		{
+			name:         "search without a match",
+			config:       map[string]interface{}{"operation": "search_contact", "email": "nobody@example.com"},
+			want:         map[string]interface{}{"crm_found": false, "contact": nil},
+			wantRequests: []string{"POST /crm/v3/objects/contacts/search"},
+		},
+		{
+			name:   "upsert updates the contact with the email",
+			config: map[string]interface{}{"operation": "upsert_contact", "email": "{{email}}", "company": "Acme"},
+			want: map[string]interface{}{"contact_id": "a/b", "contact": &CRMContact{
+				ID: "a/b", Email: "anna@example.com", Company: "Acme",
+				Properties: map[string]interface{}{"email": "anna@example.com", "company": "Acme"},
+			}},
+			wantRequests: []string{"POST /crm/v3/objects/contacts/search", "PATCH /crm/v3/objects/contacts/a%2Fb"},
+		},
+		{
+			name:   "upsert creates a new contact",
+			config: map[string]interface{}{"operation": "upsert_contact", "email": "dora@example.com", "first_name": "Dora"},
+			want: map[string]interface{}{"contact_id": "102", "contact": &CRMContact{
+				ID: "102", Email: "dora@example.com", FirstName: "Dora",
+				Properties: map[string]interface{}{"email": "dora@example.com", "firstname": "Dora"},
+			}},
+			wantRequests: []string{"POST /crm/v3/objects/contacts/search", "POST /crm/v3/objects/contacts"},
+		},
+		{
+			name:         "move deal stage",
+			config:       map[string]interface{}{"operation": "move_deal_stage", "deal_id": "42", "stage_id": "closedwon", "pipeline_id": "default"},
+			want:         map[string]interface{}{"deal_id": "42", "deal": &CRMDeal{ID: "42", Title: "Big deal", PipelineID: "default", StageID: "closedwon"}},
+			wantRequests: []string{"PATCH /crm/v3/objects/deals/42"},
+		},
+		{
+			name:         "deal ids are escaped",
+			config:       map[string]interface{}{"operation": "move_deal_stage", "deal_id": "42/../../contacts/7", "stage_id": "closedwon"},
+			wantErr:      "status 404",
+			wantRequests: []string{"PATCH /crm/v3/objects/deals/42%2F..%2F..%2Fcontacts%2F7"},
+		},
+	}, check)
+}
+
+func TestPipedriveAdapter(t *testing.T) {
+	person := map[string]interface{}{
+		"id": 7, "name": "Anna Smith", "org_name": "Acme",
+		"email": []interface{}{map[string]interface{}{"value": "anna@example.com", "primary": true}},
+		"phone": []interface{}{map[string]interface{}{"value": "+4930123456", "primary": true}},
+	}
+	ok := func(data interface{}) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"success": true, "data": data}
+	}
+	fake := crmFake{
+		"GET /persons/search": func(r apiRequest) (int, interface{}) {
+			query, _ := url.ParseQuery(r.Query)
+			if query.Get("term") != "anna@example.com" || query.Get("fields") != "email" || query.Get("exact_match") != "true" {
+				return ok(map[string]interface{}{"items": []interface{}{}})
+			}
+			return ok(map[string]interface{}{"items": []interface{}{map[string]interface{}{"item": map[string]interface{}{"id": 7}}}})
+		},
+		"GET /persons/7": func(r apiRequest) (int, interface{}) { return ok(person) },
+		"PUT /persons/7": func(r apiRequest) (int, interface{}) {
+			updated := map[string]interface{}{"id": 7, "name": r.Body["name"], "email": r.Body["email"], "org_name": "Acme"}
+			return ok(updated)
+		},
+		"POST /persons": func(r apiRequest) (int, interface{}) {
+			return ok(map[string]interface{}{"id": 8, "name": r.Body["name"], "email": r.Body["email"]})
+		},
+		"PUT /deals/42": func(r apiRequest) (int, interface{}) {
+			return ok(map[string]interface{}{"id": 42, "title": "Big deal", "value": 1000, "currency": "EUR", "stage_id": r.Body["stage_id"], "pipeline_id": 1})
+		},
+		"PUT /deals/43": func(r apiRequest) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{"success": false, "error": "Deal not found"}
+		},
+	}
+	check := func(t *testing.T, r apiRequest) {
+		if query, _ := url.ParseQuery(r.Query); query.Get("api_token") != "pd-secret" {
+			t.Errorf("%s %s without the api token", r.Method, r.Path)
+		}
+	}
+
+	runCRMTests(t, "pipedrive", map[string]interface{}{"apiToken": "pd-secret"}, fake, []crmTest{
+		{
+			name:   "search finds the person",
+			config: map[string]interface{}{"operation": "search_contact", "email": "{{email}}"},
+			want: map[string]interface{}{"crm_found": true, "contact": &CRMContact{
+				ID: "7", Email: "anna@example.com", FirstName: "Anna", LastName: "Smith", Phone: "+4930123456", Company: "Acme",
+			}},
+			wantRequests: []string{"GET /persons/search", "GET /persons/7"},
+		},
+		{
+			name:         "search without a match",
+			config:       map[string]interface{}{"operation": "search_contact", "email": "nobody@example.com"},
+			want:         map[string]interface{}{"crm_found": false, "contact": nil},
+			wantRequests: []string{"GET /persons/search"},
+		},
+		{
+			name:   "upsert updates the person with the email",
+			config: map[string]interface{}{"operation": "upsert_contact", "email": "{{email}}", "first_name": "Anna", "last_name": "Jones"},
+			want: map[string]interface{}{"contact_id": "7", "contact": &CRMContact{
+				ID: "7", Email: "anna@example.com", FirstName: "Anna", LastName: "Jones", Company: "Acme",
+			}},
+			wantRequests: []string{"GET /persons/search", "GET /persons/7", "PUT /persons/7"},
+		},
+		{
+			name:         "upsert creates a person named after the email",
+			config:       map[string]interface{}{"operation": "upsert_contact", "email": "dora@example.com"},
+			want:         map[string]interface{}{"contact_id": "8", "contact": &CRMContact{ID: "8", Email: "dora@example.com", FirstName: "dora@example.com"}},
+			wantRequests: []string{"GET /persons/search", "POST /persons"},
+		},
+		{
+			name:   "move deal stage",
+			config: map[string]interface{}{"operation": "move_deal_stage", "deal_id": "42", "stage_id": "5"},
+			want: map[string]interface{}{"deal_id": "42", "deal": &CRMDeal{
+				ID: "42", Title: "Big deal", Amount: 1000, Currency: "EUR", StageID: "5", PipelineID: "1",
+			}},
+			wantRequests: []string{"PUT /deals/42"},
+		},
+		{
+			name:         "errors keep the token out",
+			config:       map[string]interface{}{"operation": "move_deal_stage", "deal_id": "43", "stage_id": "5"},
+			wantErr:      "pipedrive: request failed: Deal not found",
+			wantRequests: []string{"PUT /deals/43"},
+		},
+		{
+			name:         "deal ids are escaped",
+			config:       map[string]interface{}{"operation": "move_deal_stage", "deal_id": "42?api_token=x", "stage_id": "5"},
+			wantErr:      "status 404",
+			wantRequests: []string{"PUT /deals/42%3Fapi_token=x"},
+		},
+	}, check)
+}
+
+func TestAmoCRMAdapter(t *testing.T) {
+	contact := func(id int, name, email string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name,
+			"custom_fields_values": []interface{}{map[string]interface{}{
+				"field_code": "EMAIL",
+				"values":     []interface{}{map[string]interface{}{"value": email, "enum_code": "WORK"}},
+			}},
+		}
+	}
+	fake := crmFake{
+		"GET /contacts": func(r apiRequest) (int, interface{}) {
+			query, _ := url.ParseQuery(r.Query)
+			if query.Get("query") != "anna@example.com" {
+				// amoCRM answers an empty search with 204 and no body
+				return http.StatusNoContent, nil
+			}
+			// the search is full-text, so it also finds similar addresses
+			return http.StatusOK, map[string]interface{}{"_embedded": map[string]interface{}{"contacts": []interface{}{
+				contact(4, "Anna Other", "anna@example.com.au"),
+				contact(5, "Anna Smith", "Anna@Example.com"),
+			}}}
+		},
+		"PATCH /contacts/5": func(r apiRequest) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{"id": 5}
+		},
+		"POST /contacts": func(r apiRequest) (int, interface{}) {
+			if len(r.List) != 1 {
+				return http.StatusBadRequest, nil
+			}
+			return http.StatusOK, map[string]interface{}{"_embedded": map[string]interface{}{"contacts": []interface{}{map[string]interface{}{"id": 6}}}}
+		},
+		"PATCH /leads/42": func(r apiRequest) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{"id": 42, "name": "Big deal", "price": 1000, "status_id": r.Body["status_id"]}
+		},
+	}
+	check := func(t *testing.T, r apiRequest) {
+		if r.Authorization != "Bearer amo-secret" {
+			t.Errorf("%s %s with %q", r.Method, r.Path, r.Authorization)
+		}
+	}
+
+	runCRMTests(t, "amocrm", map[string]interface{}{"accessToken": "amo-secret", "subdomain": "acme"}, fake, []crmTest{
+		{
+			name:         "search keeps the exact match",
+			config:       map[string]interface{}{"operation": "search_contact", "email": "{{email}}"},
+			want:         map[string]interface{}{"crm_found": true, "contact": &CRMContact{ID: "5", Email: "Anna@Example.com", FirstName: "Anna", LastName: "Smith"}},
+			wantRequests: []string{"GET /contacts"},
+		},
+		{
+			name:         "search without a match",
+			config:       map[string]interface{}{"operation": "search_contact", "email": "nobody@example.com"},
+			want:         map[string]interface{}{"crm_found": false, "contact": nil},
+			wantRequests: []string{"GET /contacts"},
+		},
+		{
+			name:         "upsert updates the contact with the email",
+			config:       map[string]interface{}{"operation": "upsert_contact", "email": "{{email}}", "last_name": "Jones"},
+			want:         map[string]interface{}{"contact_id": "5", "contact": &CRMContact{ID: "5", Email: "anna@example.com", LastName: "Jones"}},
+			wantRequests: []string{"GET /contacts", "PATCH /contacts/5"},
+		},
+		{
+			name:         "upsert creates a new contact",
+			config:       map[string]interface{}{"operation": "upsert_contact", "email": "dora@example.com", "phone": "+4930123456"},
+			want:         map[string]interface{}{"contact_id": "6", "contact": &CRMContact{ID: "6", Email: "dora@example.com", Phone: "+4930123456"}},
+			wantRequests: []string{"GET /contacts", "POST /contacts"},
+		},
+		{
+			name:         "move deal stage",
+			config:       map[string]interface{}{"operation": "move_deal_stage", "deal_id": "42", "stage_id": "142", "pipeline_id": "7"},
+			want:         map[string]interface{}{"deal_id": "42", "deal": &CRMDeal{ID: "42", Title: "Big deal", Amount: 1000, StageID: "142", PipelineID: "7"}},
+			wantRequests: []string{"PATCH /leads/42"},
+		},
+		{
+			name:         "deal ids are escaped",
+			config:       map[string]interface{}{"operation": "move_deal_stage", "deal_id": "../contacts/5", "stage_id": "142"},
+			wantErr:      "status 404",
+			wantRequests: []string{"PATCH /leads/..%2Fcontacts%2F5"},
+		},
+	}, check)
+}
@@ -0,0 +1,281 @@
+package engine
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"s4s-backend/internal/pkg/utils"
+)
+
+// secretConfigKeys are rejected in node config: keys belong in a secret
+// connection so they are encrypted at rest and never shown in the editor
+var secretConfigKeys = []string{"secret", "key", "private_key", "privateKey", "password"}
+
+// CryptoExecutor hashes, signs and encodes values: hash, hmac, hmac_verify,
+// base64_encode/decode, hex_encode/decode, uuid, jwt_sign, encrypt and
+// decrypt. Operations that need a key read it from a "secret" connection.
+// The result is written to output_key (default "crypto").
+type CryptoExecutor struct {
+	Connections ConnectionResolver
+}
+
+func (c *CryptoExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid crypto configuration")
+	}
+	for _, key := range secretConfigKeys {
+		if _, found := config[key]; found {
+			return nil, fmt.Errorf("%s is not accepted in node config; store it in a secret connection and set connection_id", key)
+		}
+	}
+
+	outputKey := stringValue(config["output_key"])
+	if outputKey == "" {
+		outputKey = "crypto"
+	}
+	algorithm := replaceVariables(stringValue(config["algorithm"]), input)
+
+	secret := func() (*utils.Secret, error) {
+		conn, err := resolveConnection(ctx, c.Connections, config, "secret")
+		if err != nil {
+			return nil, err
+		}
+		return utils.SecretFromCredentials(conn.Credentials)
+	}
+	sharedKey := func() ([]byte, error) {
+		s, err := secret()
+		if err != nil {
+			return nil, err
+		}
+		if s.Key == nil {
+			return nil, errors.New("secret connection has no secret")
+		}
+		return s.Key, nil
+	}
+
+	operation := stringValue(config["operation"])
+	switch operation {
+	case "hash":
+		data, err := cryptoInput(config, input)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := utils.Hash(algorithm, data)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := encodeBytes(sum, stringValue(config["encoding"]))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{outputKey: encoded}, nil
+
+	case "hmac", "hmac_verify":
+		data, err := cryptoInput(config, input)
+		if err != nil {
+			return nil, err
+		}
+		key, err := sharedKey()
+		if err != nil {
+			return nil, err
+		}
+		sum, err := utils.HMAC(algorithm, key, data)
+		if err != nil {
+			return nil, err
+		}
+		encoding := stringValue(config["encoding"])
+		if operation == "hmac" {
+			encoded, err := encodeBytes(sum, encoding)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{outputKey: encoded}, nil
+		}
+
+		// signature headers are often prefixed, e.g. "sha256=..."; base64
+		// padding only ever appears at the end
+		signature := strings.TrimSpace(replaceVariables(stringValue(config["signature"]), input))
+		if i := strings.IndexByte(signature, '='); i > 0 && i < len(signature)-1 && signature[i+1] != '=' {
+			signature = signature[i+1:]
+		}
+		expected, err := decodeBytes(signature, encoding)
+		valid := err == nil && subtle.ConstantTimeCompare(expected, sum) == 1
+		return map[string]interface{}{outputKey: valid}, nil
+
+	case "base64_encode", "hex_encode":
+		data, err := cryptoInput(config, input)
+		if err != nil {
+			return nil, err
+		}
+		encoding := strings.TrimSuffix(operation, "_encode")
+		if encoding == "base64" && config["url_safe"] == true {
+			encoding = "base64url"
+		}
+		encoded, _ := encodeBytes(data, encoding)
+		return map[string]interface{}{outputKey: encoded}, nil
+
+	case "base64_decode", "hex_decode":
+		encoding := strings.TrimSuffix(operation, "_decode")
+		data, err := decodeBytes(strings.TrimSpace(replaceVariables(stringValue(config["value"]), input)), encoding)
+		if err != nil {
+			return nil, fmt.Errorf("value is not valid %s", encoding)
+		}
+		if name := stringValue(config["binary_name"]); name != "" {
+			file := &BinaryData{
+				FileName: replaceVariables(stringValue(config["file_name"]), input),
+				MimeType: "application/octet-stream",
+				Data:     data,
+			}
+			if file.FileName == "" {
+				file.FileName = name
+			}
+			return map[string]interface{}{BinaryKey: WithBinary(input, name, file)}, nil
+		}
+		return map[string]interface{}{outputKey: string(data)}, nil
+
+	case "uuid":
+		var id uuid.UUID
+		var err error
+		switch version := stringValue(config["version"]); version {
+		case "", "4", "v4":
+			id, err = uuid.NewRandom()
+		case "7", "v7":
+			id, err = uuid.NewV7()
+		default:
+			return nil, fmt.Errorf("unsupported uuid version %q", version)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{outputKey: id.String()}, nil
+
+	case "jwt_sign":
+		s, err := secret()
+		if err != nil {
+			return nil, err
+		}
+		if algorithm == "" {
+			algorithm = "HS256"
+		}
+		var key interface{}
+		if strings.HasPrefix(strings.ToUpper(algorithm), "HS") {
+			if s.Key == nil {
+				return nil, errors.New("secret connection has no secret")
+			}
+			key = s.Key
+		} else {
+			if s.PrivateKey == nil {
+				return nil, errors.New("secret connection has no privateKey")
+			}
+			key = s.PrivateKey
+		}
+
+		claims := make(map[string]interface{})
+		if raw, ok := replaceVariablesDeep(config["claims"], input).(map[string]interface{}); ok {
+			for k, v := range raw {
+				claims[k] = v
+			}
+		}
+		now := time.Now()
+		if _, set := claims["iat"]; !set {
+			claims["iat"] = now.Unix()
+		}
+		if seconds, ok := config["expires_in"].(float64); ok && seconds > 0 {
+			claims["exp"] = now.Add(time.Duration(seconds) * time.Second).Unix()
+		}
+		headers, _ := replaceVariablesDeep(config["headers"], input).(map[string]interface{})
+
+		token, err := utils.SignJWT(claims, algorithm, key, headers)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{outputKey: token}, nil
+
+	case "encrypt", "decrypt":
+		key, err := sharedKey()
+		if err != nil {
+			return nil, err
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, errors.New("encryption secret must be 16, 24 or 32 bytes")
+		}
+		value := replaceVariables(stringValue(config["value"]), input)
+		var result string
+		if operation == "encrypt" {
+			result, err = utils.Encrypt(value, string(key))
+		} else {
+			result, err = utils.Decrypt(value, string(key))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %w", operation, err)
+		}
+		return map[string]interface{}{outputKey: result}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown crypto operation: %s", operation)
+	}
+}
+
+// cryptoInput returns the bytes to hash or encode: the named binary file,
+// or value with variables replaced, decoded per input_encoding
+func cryptoInput(config, input map[string]interface{}) ([]byte, error) {
+	if name := stringValue(config["binary"]); name != "" {
+		file, err := GetBinary(input, name)
+		if err != nil {
+			return nil, err
+		}
+		return file.Data, nil
+	}
+
+	value := replaceVariables(stringValue(config["value"]), input)
+	encoding := stringValue(config["input_encoding"])
+	if encoding == "" || encoding == "text" {
+		return []byte(value), nil
+	}
+	data, err := decodeBytes(value, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("value is not valid %s", encoding)
+	}
+	return data, nil
+}
+
+// encodeBytes renders a digest or payload as hex (default), base64 or base64url
+func encodeBytes(data []byte, encoding string) (string, error) {
+	switch encoding {
+	case "", "hex":
+		return hex.EncodeToString(data), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(data), nil
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	default:
+		return "", fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// decodeBytes reverses encodeBytes; base64 accepts padded, unpadded and url-safe forms
+func decodeBytes(text, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "hex":
+		return hex.DecodeString(text)
+	case "base64", "base64url":
+		text = strings.TrimRight(text, "=")
+		if strings.ContainsAny(text, "-_") {
+			return base64.RawURLEncoding.DecodeString(text)
+		}
+		return base64.RawStdEncoding.DecodeString(text)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
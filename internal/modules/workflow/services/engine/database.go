@@ -0,0 +1,386 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"s4s-backend/internal/pkg/dbconn"
+)
+
+const (
+	defaultQueryTimeout = 30 * time.Second
+	maxQueryTimeout     = 5 * time.Minute
+	defaultMaxRows      = 1000
+)
+
+var (
+	identifierPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	exactVariable       = regexp.MustCompile(`^\{\{\s*([^{}]+?)\s*\}\}$`)
+	errQueryInterpolate = errors.New("query must not contain {{variables}}; bind values through parameters")
+)
+
+// DatabasePools keeps one pool per database connection. A pool is replaced
+// when the connection's credentials change and closed once the executions
+// still querying it are done.
+type DatabasePools struct {
+	pools *sharedClients[*sql.DB]
+}
+
+// NewDatabasePools creates an empty pool cache
+func NewDatabasePools() *DatabasePools {
+	return &DatabasePools{pools: newSharedClients[*sql.DB]()}
+}
+
+// get returns the pool of conn; release must be called once the caller's
+// statements are done
+func (p *DatabasePools) get(conn *Connection, cfg *dbconn.Config, dial dbconn.DialFunc) (*sql.DB, func(), error) {
+	return p.pools.get(conn, func() (*sql.DB, error) {
+		return dbconn.Open(cfg, dial)
+	})
+}
+
+// Close closes every pool once it is no longer in use
+func (p *DatabasePools) Close() {
+	p.pools.Close()
+}
+
+// DatabaseQueryExecutor runs parameterized statements against a customer
+// database. Driver is dbconn.EnginePostgres or dbconn.EngineMySQL and must
+// match the connection's engine.
+type DatabaseQueryExecutor struct {
+	Driver      string
+	Connections ConnectionResolver
+	Egress      *EgressPolicy
+	Pools       *DatabasePools
+}
+
+func (d *DatabaseQueryExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid database query configuration")
+	}
+
+	conn, err := resolveConnection(ctx, d.Connections, config, "database")
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := dbconn.ConfigFromCredentials(conn.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database connection: %w", err)
+	}
+	if cfg.Engine != d.Driver {
+		return nil, fmt.Errorf("connection %s is a %s database, expected %s", conn.ID, cfg.Engine, d.Driver)
+	}
+
+	egress := d.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+	pools := d.Pools
+	if pools == nil {
+		pools = NewDatabasePools()
+		defer pools.Close()
+	}
+	db, release, err := pools.get(conn, cfg, egress.DialContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer release()
+
+	// the statement is cancelled with the execution or when the timeout expires
+	timeout := defaultQueryTimeout
+	if seconds, ok := config["timeout_seconds"].(float64); ok && seconds > 0 {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout > maxQueryTimeout {
+		timeout = maxQueryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	maxRows := defaultMaxRows
+	if n, ok := config["max_rows"].(float64); ok && n > 0 {
+		maxRows = int(n)
+	}
+
+	mode, _ := config["mode"].(string)
+	if mode == "" {
+		mode = "select"
+	}
+
+	var query string
+	var args []interface{}
+	returnsRows := mode == "select"
+
+	switch mode {
+	case "select", "execute":
+		query, args, err = bindQuery(config, input)
+		if err != nil {
+			return nil, err
+		}
+
+	case "insert", "upsert":
+		query, args, err = d.buildWrite(mode, config, input)
+		if err != nil {
+			return nil, err
+		}
+		returnsRows = d.Driver == dbconn.EnginePostgres && len(stringList(config["returning"])) > 0
+
+	default:
+		return nil, fmt.Errorf("unknown database mode: %s", mode)
+	}
+
+	Logf(ctx, "%s %s with %d parameters", d.Driver, mode, len(args))
+
+	if !returnsRows {
+		result, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Driver, err)
+		}
+		affected, _ := result.RowsAffected()
+		output := map[string]interface{}{"rows_affected": affected, "items": []map[string]interface{}{}}
+		if d.Driver == dbconn.EngineMySQL {
+			if id, err := result.LastInsertId(); err == nil && id > 0 {
+				output["last_insert_id"] = id
+			}
+		}
+		return output, nil
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Driver, err)
+	}
+	defer rows.Close()
+
+	items, truncated, err := scanRows(rows, maxRows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Driver, err)
+	}
+	output := map[string]interface{}{"items": items, "row_count": len(items)}
+	if truncated {
+		output["truncated"] = true
+		Logf(ctx, "result truncated to %d rows", maxRows)
+	}
+	return output, nil
+}
+
+// bindQuery returns the query as written and its bound parameters. Variables
+// are never interpolated into the SQL itself.
+func bindQuery(config, input map[string]interface{}) (string, []interface{}, error) {
+	query, _ := config["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return "", nil, errors.New("query is required")
+	}
+	if strings.Contains(query, "{{") {
+		return "", nil, errQueryInterpolate
+	}
+	params, _ := config["parameters"].([]interface{})
+	args := make([]interface{}, 0, len(params))
+	for _, param := range params {
+		args = append(args, bindValue(param, input))
+	}
+	return query, args, nil
+}
+
+// buildWrite builds an INSERT (optionally upserting) from the table and
+// values config. Values are always bound as parameters.
+func (d *DatabaseQueryExecutor) buildWrite(mode string, config, input map[string]interface{}) (string, []interface{}, error) {
+	table, _ := config["table"].(string)
+	if !identifierPattern.MatchString(table) {
+		return "", nil, fmt.Errorf("invalid table name %q", table)
+	}
+	values, _ := config["values"].(map[string]interface{})
+	if len(values) == 0 {
+		return "", nil, errors.New("values are required")
+	}
+
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		if !identifierPattern.MatchString(column) || strings.Contains(column, ".") {
+			return "", nil, fmt.Errorf("invalid column name %q", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		quoted[i] = d.quote(column)
+		placeholders[i] = d.placeholder(i + 1)
+		args[i] = bindValue(values[column], input)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES (%s)", d.quote(table), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+
+	if mode == "upsert" {
+		conflict := stringList(config["conflict_columns"])
+		if len(conflict) == 0 {
+			return "", nil, errors.New("conflict_columns are required for upsert")
+		}
+		isConflict := make(map[string]bool, len(conflict))
+		for i, column := range conflict {
+			if !identifierPattern.MatchString(column) || strings.Contains(column, ".") {
+				return "", nil, fmt.Errorf("invalid column name %q", column)
+			}
+			isConflict[column] = true
+			conflict[i] = d.quote(column)
+		}
+
+		var updates []string
+		for _, column := range columns {
+			if isConflict[column] {
+				continue
+			}
+			if d.Driver == dbconn.EngineMySQL {
+				updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", d.quote(column), d.quote(column)))
+			} else {
+				updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", d.quote(column), d.quote(column)))
+			}
+		}
+
+		if d.Driver == dbconn.EngineMySQL {
+			// MySQL resolves conflicts on whichever unique key is hit
+			if len(updates) == 0 {
+				updates = append(updates, fmt.Sprintf("%s = %s", conflict[0], conflict[0]))
+			}
+			fmt.Fprintf(&b, " ON DUPLICATE KEY UPDATE %s", strings.Join(updates, ", "))
+		} else if len(updates) == 0 {
+			fmt.Fprintf(&b, " ON CONFLICT (%s) DO NOTHING", strings.Join(conflict, ", "))
+		} else {
+			fmt.Fprintf(&b, " ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(conflict, ", "), strings.Join(updates, ", "))
+		}
+	}
+
+	if returning := stringList(config["returning"]); len(returning) > 0 && d.Driver == dbconn.EnginePostgres {
+		for i, column := range returning {
+			if column == "*" {
+				continue
+			}
+			if !identifierPattern.MatchString(column) || strings.Contains(column, ".") {
+				return "", nil, fmt.Errorf("invalid column name %q", column)
+			}
+			returning[i] = d.quote(column)
+		}
+		fmt.Fprintf(&b, " RETURNING %s", strings.Join(returning, ", "))
+	}
+
+	return b.String(), args, nil
+}
+
+func (d *DatabaseQueryExecutor) quote(identifier string) string {
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		if d.Driver == dbconn.EngineMySQL {
+			parts[i] = "`" + part + "`"
+		} else {
+			parts[i] = `"` + part + `"`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (d *DatabaseQueryExecutor) placeholder(n int) string {
+	if d.Driver == dbconn.EngineMySQL {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", n)
+}
+
+// bindValue resolves a parameter. A parameter that is exactly one
+// {{variable}} or {{object.path}} binds the raw value so numbers, booleans
+// and nulls keep their type; anything else is templated into a string.
+func bindValue(param interface{}, input map[string]interface{}) interface{} {
+	value := param
+	if text, ok := param.(string); ok {
+		if match := exactVariable.FindStringSubmatch(text); match != nil {
+			if raw, found := dataValue(input, match[1]); found {
+				value = raw
+			} else {
+				value = replaceVariables(text, input)
+			}
+		} else {
+			value = replaceVariables(text, input)
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	default:
+		return v
+	}
+}
+
+func scanRows(rows *sql.Rows, maxRows int) ([]map[string]interface{}, bool, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, false, err
+	}
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		if len(items) >= maxRows {
+			return items, true, nil
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, false, err
+		}
+
+		item := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			switch v := values[i].(type) {
+			case []byte:
+				item[column] = string(v)
+			case time.Time:
+				item[column] = v.UTC().Format(time.RFC3339Nano)
+			default:
+				item[column] = v
+			}
+		}
+		items = append(items, item)
+	}
+	return items, false, rows.Err()
+}
+
+// stringList reads a config value given either as an array or a comma-separated string
+func stringList(value interface{}) []string {
+	var list []string
+	switch v := value.(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s := strings.TrimSpace(stringValue(item)); s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
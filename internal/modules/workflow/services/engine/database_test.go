@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"s4s-backend/internal/pkg/dbconn"
+)
+
+// fakeCloser records whether it was closed
+type fakeCloser struct {
+	name   string
+	closed int
+}
+
+func (f *fakeCloser) Close() error {
+	f.closed++
+	return nil
+}
+
+func TestSharedClients(t *testing.T) {
+	clients := newSharedClients[*fakeCloser]()
+	conn := &Connection{ID: "conn-1", Credentials: map[string]interface{}{"password": "old"}}
+	opened := 0
+	open := func() (*fakeCloser, error) {
+		opened++
+		return &fakeCloser{name: conn.Credentials["password"].(string)}, nil
+	}
+
+	first, releaseFirst, err := clients.get(conn, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, releaseAgain, _ := clients.get(conn, open)
+	if again != first || opened != 1 {
+		t.Fatalf("unchanged credentials opened %d clients", opened)
+	}
+	releaseAgain()
+	releaseAgain()
+
+	// a client replaced while in use stays open until its last user is done
+	conn.Credentials = map[string]interface{}{"password": "new"}
+	second, releaseSecond, _ := clients.get(conn, open)
+	if second == first || second.name != "new" {
+		t.Fatalf("changed credentials returned the %s client", second.name)
+	}
+	if first.closed != 0 {
+		t.Fatal("replaced client was closed while in use")
+	}
+	releaseFirst()
+	if first.closed != 1 {
+		t.Fatalf("replaced client closed %d times after its release", first.closed)
+	}
+	releaseFirst()
+	if first.closed != 1 {
+		t.Fatal("releasing twice closed the client again")
+	}
+
+	// Close waits for clients in use
+	clients.Close()
+	if second.closed != 0 {
+		t.Fatal("Close closed a client in use")
+	}
+	releaseSecond()
+	if second.closed != 1 {
+		t.Fatalf("client closed %d times after Close and release", second.closed)
+	}
+
+	// an idle client is closed at once when replaced
+	idle, releaseIdle, _ := clients.get(conn, open)
+	releaseIdle()
+	conn.Credentials = map[string]interface{}{"password": "newer"}
+	_, releaseNewer, _ := clients.get(conn, open)
+	defer releaseNewer()
+	if idle.closed != 1 {
+		t.Fatal("idle client was not closed when replaced")
+	}
+
+	failing := func() (*fakeCloser, error) { return nil, errors.New("connection refused") }
+	if _, _, err := clients.get(&Connection{ID: "conn-2"}, failing); err == nil {
+		t.Fatal("open error was not returned")
+	}
+}
+
+func TestBindQuery(t *testing.T) {
+	input := map[string]interface{}{
+		"id":      42.0,
+		"price":   9.5,
+		"vip":     true,
+		"deleted": nil,
+		"name":    "Anna",
+		"lead":    map[string]interface{}{"email": "anna@example.com", "tags": []interface{}{"a", "b"}},
+	}
+
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		wantArgs []interface{}
+		wantErr  string
+	}{
+		{
+			name:     "values keep their type",
+			config:   map[string]interface{}{"query": "SELECT 1", "parameters": []interface{}{"{{id}}", "{{ price }}", "{{vip}}", "{{deleted}}", "{{lead.email}}"}},
+			wantArgs: []interface{}{int64(42), 9.5, true, nil, "anna@example.com"},
+		},
+		{
+			name:     "objects are bound as JSON",
+			config:   map[string]interface{}{"query": "SELECT 1", "parameters": []interface{}{"{{lead.tags}}", map[string]interface{}{"a": 1.0}}},
+			wantArgs: []interface{}{`["a","b"]`, `{"a":1}`},
+		},
+		{
+			name:     "text is templated",
+			config:   map[string]interface{}{"query": "SELECT 1", "parameters": []interface{}{"Hi {{name}} #{{id}}", "{{missing}}", 7.0, "plain"}},
+			wantArgs: []interface{}{"Hi Anna #42", "{{missing}}", int64(7), "plain"},
+		},
+		{
+			name:     "no parameters",
+			config:   map[string]interface{}{"query": "SELECT now()"},
+			wantArgs: []interface{}{},
+		},
+		{
+			name:    "variables in the query are rejected",
+			config:  map[string]interface{}{"query": "SELECT * FROM leads WHERE name = '{{name}}'"},
+			wantErr: errQueryInterpolate.Error(),
+		},
+		{
+			name:    "empty query",
+			config:  map[string]interface{}{"query": "  "},
+			wantErr: "query is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := bindQuery(tt.config, input)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("bindQuery() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.config["query"] {
+				t.Errorf("query = %q, want it unchanged", query)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBuildWrite(t *testing.T) {
+	input := map[string]interface{}{"email": "anna@example.com", "score": 75.0}
+	values := map[string]interface{}{"score": "{{score}}", "email": "{{email}}", "source": "form"}
+	wantArgs := []interface{}{"anna@example.com", int64(75), "form"}
+
+	tests := []struct {
+		name    string
+		driver  string
+		mode    string
+		config  map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "postgres insert",
+			driver: dbconn.EnginePostgres, mode: "insert",
+			config: map[string]interface{}{"table": "crm.leads"},
+			want:   `INSERT INTO "crm"."leads" ("email", "score", "source") VALUES ($1, $2, $3)`,
+		},
+		{
+			name:   "postgres upsert returning",
+			driver: dbconn.EnginePostgres, mode: "upsert",
+			config: map[string]interface{}{"table": "leads", "conflict_columns": []interface{}{"email"}, "returning": []interface{}{"id", "*"}},
+			want:   `INSERT INTO "leads" ("email", "score", "source") VALUES ($1, $2, $3) ON CONFLICT ("email") DO UPDATE SET "score" = EXCLUDED."score", "source" = EXCLUDED."source" RETURNING "id", *`,
+		},
+		{
+			name:   "postgres upsert without other columns",
+			driver: dbconn.EnginePostgres, mode: "upsert",
+			config: map[string]interface{}{"table": "leads", "values": map[string]interface{}{"email": "{{email}}"}, "conflict_columns": []interface{}{"email"}},
+			want:   `INSERT INTO "leads" ("email") VALUES ($1) ON CONFLICT ("email") DO NOTHING`,
+		},
+		{
+			name:   "mysql insert ignores returning",
+			driver: dbconn.EngineMySQL, mode: "insert",
+			config: map[string]interface{}{"table": "leads", "returning": []interface{}{"id"}},
+			want:   "INSERT INTO `leads` (`email`, `score`, `source`) VALUES (?, ?, ?)",
+		},
+		{
+			name:   "mysql upsert",
+			driver: dbconn.EngineMySQL, mode: "upsert",
+			config: map[string]interface{}{"table": "leads", "conflict_columns": []interface{}{"email"}},
+			want:   "INSERT INTO `leads` (`email`, `score`, `source`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `score` = VALUES(`score`), `source` = VALUES(`source`)",
+		},
+		{
+			name:   "mysql upsert without other columns",
+			driver: dbconn.EngineMySQL, mode: "upsert",
+			config: map[string]interface{}{"table": "leads", "values": map[string]interface{}{"email": "{{email}}"}, "conflict_columns": []interface{}{"email"}},
+			want:   "INSERT INTO `leads` (`email`) VALUES (?) ON DUPLICATE KEY UPDATE `email` = `email`",
+		},
+		{
+			name:   "table name injection",
+			driver: dbconn.EnginePostgres, mode: "insert",
+			config:  map[string]interface{}{"table": `leads"; DROP TABLE users; --`},
+			wantErr: "invalid table name",
+		},
+		{
+			name:   "column name injection",
+			driver: dbconn.EngineMySQL, mode: "insert",
+			config:  map[string]interface{}{"table": "leads", "values": map[string]interface{}{"email`) VALUES (1); --": "x"}},
+			wantErr: "invalid column name",
+		},
+		{
+			name:   "qualified column",
+			driver: dbconn.EnginePostgres, mode: "insert",
+			config:  map[string]interface{}{"table": "leads", "values": map[string]interface{}{"leads.email": "x"}},
+			wantErr: "invalid column name",
+		},
+		{
+			name:   "invalid conflict column",
+			driver: dbconn.EnginePostgres, mode: "upsert",
+			config:  map[string]interface{}{"table": "leads", "conflict_columns": []interface{}{"email)"}},
+			wantErr: "invalid column name",
+		},
+		{
+			name:   "invalid returning column",
+			driver: dbconn.EnginePostgres, mode: "insert",
+			config:  map[string]interface{}{"table": "leads", "returning": []interface{}{"id; DROP TABLE leads"}},
+			wantErr: "invalid column name",
+		},
+		{
+			name:   "upsert without conflict columns",
+			driver: dbconn.EnginePostgres, mode: "upsert",
+			config:  map[string]interface{}{"table": "leads"},
+			wantErr: "conflict_columns are required",
+		},
+		{
+			name:   "no values",
+			driver: dbconn.EnginePostgres, mode: "insert",
+			config:  map[string]interface{}{"table": "leads", "values": map[string]interface{}{}},
+			wantErr: "values are required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := tt.config["values"]; !ok {
+				tt.config["values"] = values
+			}
+			d := &DatabaseQueryExecutor{Driver: tt.driver}
+			query, args, err := d.buildWrite(tt.mode, tt.config, input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("buildWrite() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.want {
+				t.Errorf("query:\n%s\nwant:\n%s", query, tt.want)
+			}
+			if len(args) == len(wantArgs) && !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, wantArgs)
+			}
+		})
+	}
+}
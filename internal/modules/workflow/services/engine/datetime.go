@@ -0,0 +1,831 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// the runtime image has no zoneinfo; embed it so timezones always resolve
+	_ "time/tzdata"
+)
+
+// maxSlotSearchDays bounds the search for the next business-hours slot
+const maxSlotSearchDays = 400
+
+var locations sync.Map
+
+// loadLocation resolves an IANA timezone name; "" means UTC
+func loadLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, "utc") {
+		return time.UTC, nil
+	}
+	if cached, ok := locations.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// inputLayouts are tried in order when no input format is configured. Values
+// without an offset are read in the configured timezone.
+var inputLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+}
+
+// parseTime reads a date from a node value: a time, a unix timestamp in
+// seconds or milliseconds, or text in format (see compileDateFormat) or one
+// of the common layouts above
+func parseTime(value interface{}, format string, loc *time.Location) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case float64:
+		return unixTime(v), nil
+	case int:
+		return unixTime(float64(v)), nil
+	case int64:
+		return unixTime(float64(v)), nil
+	case nil:
+		return time.Time{}, errors.New("date is empty")
+	}
+
+	text := strings.TrimSpace(stringValue(value))
+	if text == "" {
+		return time.Time{}, errors.New("date is empty")
+	}
+	switch format {
+	case "unix":
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid unix timestamp %q", text)
+		}
+		return time.Unix(0, int64(n*float64(time.Second))), nil
+	case "unix_ms":
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid unix timestamp %q", text)
+		}
+		return time.UnixMilli(n), nil
+	case "":
+	default:
+		t, err := compileDateFormat(format).parse(text, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("date %q does not match format %q", text, format)
+		}
+		return t, nil
+	}
+
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return unixTime(n), nil
+	}
+	for _, layout := range inputLayouts {
+		if t, err := time.ParseInLocation(layout, text, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", text)
+}
+
+// unixTime treats values beyond the year 5000 in seconds as milliseconds
+func unixTime(n float64) time.Time {
+	if math.Abs(n) > 1e11 {
+		return time.UnixMilli(int64(n))
+	}
+	return time.Unix(0, int64(n*float64(time.Second)))
+}
+
+// namedFormats are shortcuts accepted wherever a date format is configured
+var namedFormats = map[string]string{
+	"":         time.RFC3339,
+	"rfc3339":  time.RFC3339,
+	"iso":      time.RFC3339,
+	"date":     "2006-01-02",
+	"time":     "15:04",
+	"datetime": "2006-01-02 15:04",
+	"rfc1123":  time.RFC1123Z,
+}
+
+// formatTokens are the moment-style tokens (YYYY-MM-DD HH:mm) with the Go
+// layout that renders each one and a pattern matching its value. Longer
+// tokens come first so MMMM is not read as MM twice.
+var formatTokens = []struct{ token, layout, pattern string }{
+	{"YYYY", "2006", `\d{4}`}, {"YY", "06", `\d{2}`},
+	{"MMMM", "January", `[a-z]+`}, {"MMM", "Jan", `[a-z]{3}`}, {"MM", "01", `\d{2}`}, {"M", "1", `\d{1,2}`},
+	{"dddd", "Monday", `[a-z]+`}, {"ddd", "Mon", `[a-z]{3}`},
+	{"DD", "02", `\d{2}`}, {"D", "2", `\d{1,2}`},
+	{"HH", "15", `\d{2}`}, {"hh", "03", `\d{2}`}, {"h", "3", `\d{1,2}`},
+	{"mm", "04", `\d{2}`}, {"m", "4", `\d{1,2}`},
+	{"ss", "05", `\d{2}`}, {"s", "5", `\d{1,2}`},
+	// Go only reads fractional seconds after a dot, which is dropped again
+	{"SSS", ".000", `\d{3}`},
+	{"A", "PM", `[ap]m`}, {"a", "pm", `[ap]m`},
+	{"ZZ", "-0700", `[+-]\d{4}`}, {"Z", "-07:00", `[+-]\d{2}:\d{2}`},
+	{"z", "MST", `[a-z]+|[+-]\d{2,4}`},
+}
+
+// datePart is one token of a date pattern, or literal text when layout is
+// empty
+type datePart struct {
+	layout, pattern, text string
+}
+
+// dateFormat is a named format or Go layout (layout), or a moment-style
+// pattern split into parts. Patterns are formatted and parsed a part at a
+// time, so literal text is never taken for a Go layout directive.
+type dateFormat struct {
+	layout string
+	parts  []datePart
+}
+
+// compileDateFormat reads a named format, a moment-style pattern such as
+// "DD.MM.YYYY [at] HH:mm" where [text] is literal, or a Go layout (no
+// brackets, with 2006 or 15:04) passed through as is
+func compileDateFormat(format string) dateFormat {
+	if layout, ok := namedFormats[strings.ToLower(format)]; ok {
+		return dateFormat{layout: layout}
+	}
+	if !strings.Contains(format, "[") && (strings.Contains(format, "2006") || strings.Contains(format, "15:04")) {
+		return dateFormat{layout: format}
+	}
+
+	var parts []datePart
+	literal := func(text string) {
+		if n := len(parts); n > 0 && parts[n-1].layout == "" {
+			parts[n-1].text += text
+			return
+		}
+		parts = append(parts, datePart{text: text})
+	}
+	for i := 0; i < len(format); {
+		if format[i] == '[' {
+			if end := strings.IndexByte(format[i:], ']'); end > 0 {
+				literal(format[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+		matched := false
+		for _, t := range formatTokens {
+			if strings.HasPrefix(format[i:], t.token) {
+				parts = append(parts, datePart{layout: t.layout, pattern: t.pattern})
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			literal(format[i : i+1])
+			i++
+		}
+	}
+	return dateFormat{parts: parts}
+}
+
+func (f dateFormat) format(t time.Time) string {
+	if f.parts == nil {
+		return t.Format(f.layout)
+	}
+	var b strings.Builder
+	for _, part := range f.parts {
+		if part.layout == "" {
+			b.WriteString(part.text)
+			continue
+		}
+		b.WriteString(strings.TrimPrefix(t.Format(part.layout), "."))
+	}
+	return b.String()
+}
+
+// parse matches text against the pattern, then hands only the token values
+// to time.Parse with a layout made of just those tokens
+func (f dateFormat) parse(text string, loc *time.Location) (time.Time, error) {
+	if f.parts == nil {
+		return time.ParseInLocation(f.layout, text, loc)
+	}
+	var expr strings.Builder
+	expr.WriteString("(?i)^")
+	var layouts []string
+	for _, part := range f.parts {
+		if part.layout == "" {
+			expr.WriteString(regexp.QuoteMeta(part.text))
+			continue
+		}
+		expr.WriteString("(" + part.pattern + ")")
+		layouts = append(layouts, part.layout)
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return time.Time{}, err
+	}
+	match := re.FindStringSubmatch(text)
+	if match == nil {
+		return time.Time{}, errors.New("text does not match the pattern")
+	}
+	values := match[1:]
+	for i, layout := range layouts {
+		switch layout {
+		case ".000":
+			values[i] = "." + values[i]
+		case "PM":
+			values[i] = strings.ToUpper(values[i])
+		case "pm":
+			values[i] = strings.ToLower(values[i])
+		}
+	}
+	return time.ParseInLocation(strings.Join(layouts, " "), strings.Join(values, " "), loc)
+}
+
+// formatTime renders t with a named format, a pattern, or as a unix timestamp
+func formatTime(t time.Time, format string) interface{} {
+	switch strings.ToLower(format) {
+	case "unix":
+		return t.Unix()
+	case "unix_ms":
+		return t.UnixMilli()
+	}
+	return compileDateFormat(format).format(t)
+}
+
+// calendarDuration is a duration with calendar parts, so "1mo" lands on the
+// same day next month and "1d" keeps the wall-clock time across DST changes
+type calendarDuration struct {
+	years, months, days int
+	clock               time.Duration
+}
+
+var durationPart = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(mo|ms|y|w|d|h|m|s)`)
+
+// parseDuration reads durations such as "3d", "1w2d", "-1h30m", "1mo" or "1.5h"
+func parseDuration(text string) (calendarDuration, error) {
+	var d calendarDuration
+	s := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(text), " ", ""))
+	sign := 1
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	if s == "" {
+		return d, fmt.Errorf("invalid duration %q", text)
+	}
+
+	matches := durationPart.FindAllStringSubmatchIndex(s, -1)
+	consumed := 0
+	for _, m := range matches {
+		if m[0] != consumed {
+			return d, fmt.Errorf("invalid duration %q", text)
+		}
+		consumed = m[1]
+		n, _ := strconv.ParseFloat(s[m[2]:m[3]], 64)
+		unit := s[m[4]:m[5]]
+		whole := n == math.Trunc(n)
+		switch unit {
+		case "y", "mo", "w", "d":
+			if !whole {
+				if unit != "d" && unit != "w" {
+					return d, fmt.Errorf("invalid duration %q: fractional %s", text, unit)
+				}
+				hours := n * 24
+				if unit == "w" {
+					hours *= 7
+				}
+				d.clock += time.Duration(hours * float64(time.Hour))
+				continue
+			}
+			switch unit {
+			case "y":
+				d.years += int(n)
+			case "mo":
+				d.months += int(n)
+			case "w":
+				d.days += 7 * int(n)
+			case "d":
+				d.days += int(n)
+			}
+		case "h":
+			d.clock += time.Duration(n * float64(time.Hour))
+		case "m":
+			d.clock += time.Duration(n * float64(time.Minute))
+		case "s":
+			d.clock += time.Duration(n * float64(time.Second))
+		case "ms":
+			d.clock += time.Duration(n * float64(time.Millisecond))
+		}
+	}
+	if consumed != len(s) {
+		return d, fmt.Errorf("invalid duration %q", text)
+	}
+	if sign < 0 {
+		d.years, d.months, d.days, d.clock = -d.years, -d.months, -d.days, -d.clock
+	}
+	return d, nil
+}
+
+// addTo adds d to t. Adding months or years clamps to the end of a shorter
+// month, so Jan 31 plus one month is Feb 28 rather than Mar 3.
+func (d calendarDuration) addTo(t time.Time) time.Time {
+	if d.years != 0 || d.months != 0 {
+		y, m, day := t.Date()
+		first := time.Date(y+d.years, m+time.Month(d.months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		t = first.AddDate(0, 0, min(day, lastDay)-1)
+	}
+	return t.AddDate(0, 0, d.days).Add(d.clock)
+}
+
+// durationFromAmount builds a duration from an amount and a unit name
+func durationFromAmount(amount float64, unit string) (calendarDuration, error) {
+	units := map[string]string{
+		"millisecond": "ms", "second": "s", "minute": "m", "hour": "h",
+		"day": "d", "week": "w", "month": "mo", "year": "y",
+	}
+	short, ok := units[strings.TrimSuffix(strings.ToLower(unit), "s")]
+	if !ok {
+		return calendarDuration{}, fmt.Errorf("unknown unit %q", unit)
+	}
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	return parseDuration(sign + strconv.FormatFloat(math.Abs(amount), 'f', -1, 64) + short)
+}
+
+// unitDurations are the units date differences can be expressed in
+var unitDurations = map[string]time.Duration{
+	"millisecond": time.Millisecond,
+	"second":      time.Second,
+	"minute":      time.Minute,
+	"hour":        time.Hour,
+	"day":         24 * time.Hour,
+	"week":        7 * 24 * time.Hour,
+}
+
+// dateDiff returns to - from in unit; months and years count whole calendar units
+func dateDiff(from, to time.Time, unit string) (float64, error) {
+	unit = strings.TrimSuffix(strings.ToLower(unit), "s")
+	if unit == "" {
+		unit = "second"
+	}
+	if d, ok := unitDurations[unit]; ok {
+		return float64(to.Sub(from)) / float64(d), nil
+	}
+	if unit != "month" && unit != "year" {
+		return 0, fmt.Errorf("unknown unit %q", unit)
+	}
+
+	to = to.In(from.Location())
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	// a month only counts once the day and time have been reached
+	if months > 0 && (calendarDuration{months: months}).addTo(from).After(to) {
+		months--
+	} else if months < 0 && (calendarDuration{months: months}).addTo(from).Before(to) {
+		months++
+	}
+	if unit == "year" {
+		return float64(months / 12), nil
+	}
+	return float64(months), nil
+}
+
+// startOf truncates t to the start of unit in t's location; weeks start on Monday
+func startOf(t time.Time, unit string) (time.Time, error) {
+	y, m, d := t.Date()
+	switch strings.TrimSuffix(strings.ToLower(unit), "s") {
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, t.Location()), nil
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location()), nil
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location()), nil
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location()), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location()), nil
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown unit %q", unit)
+}
+
+// endOf is the last instant (to the second) of unit containing t
+func endOf(t time.Time, unit string) (time.Time, error) {
+	start, err := startOf(t, unit)
+	if err != nil {
+		return start, err
+	}
+	var next time.Time
+	switch strings.TrimSuffix(strings.ToLower(unit), "s") {
+	case "minute":
+		next = start.Add(time.Minute)
+	case "hour":
+		next = start.Add(time.Hour)
+	case "day":
+		next = start.AddDate(0, 0, 1)
+	case "week":
+		next = start.AddDate(0, 0, 7)
+	case "month":
+		next = start.AddDate(0, 1, 0)
+	case "year":
+		next = start.AddDate(1, 0, 0)
+	}
+	return next.Add(-time.Second), nil
+}
+
+// businessCalendar is a weekly schedule of opening hours in a timezone, with holidays
+type businessCalendar struct {
+	loc      *time.Location
+	days     map[time.Weekday][]hoursWindow
+	holidays map[string]bool // "2006-01-02" or, recurring every year, "01-02"
+}
+
+// hoursWindow is an opening window in minutes since midnight; end is exclusive
+type hoursWindow struct {
+	start, end int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// newBusinessCalendar builds a calendar. days lists the open weekdays
+// ("mon-fri" or "mon,wed,fri"), hours the daily windows ("09:00-18:00" or
+// "09:00-13:00,14:00-18:00"), schedule overrides hours per weekday, and
+// holidays are dates that are closed all day. Defaults are Monday to Friday,
+// 09:00 to 18:00.
+func newBusinessCalendar(timezone string, days, hours interface{}, schedule map[string]interface{}, holidays interface{}) (*businessCalendar, error) {
+	loc, err := loadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	c := &businessCalendar{loc: loc, days: make(map[time.Weekday][]hoursWindow), holidays: make(map[string]bool)}
+
+	openDays, err := parseWeekdays(days)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := parseHours(hours)
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range openDays {
+		c.days[day] = windows
+	}
+	for name, value := range schedule {
+		day, ok := weekdayNames[strings.ToLower(name)[:min(3, len(name))]]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", name)
+		}
+		if text, _ := value.(string); text == "" || strings.EqualFold(text, "closed") {
+			delete(c.days, day)
+			continue
+		}
+		windows, err := parseHours(value)
+		if err != nil {
+			return nil, err
+		}
+		c.days[day] = windows
+	}
+	if len(c.days) == 0 {
+		return nil, errors.New("business hours have no open days")
+	}
+
+	for _, holiday := range stringList(holidays) {
+		if _, err := time.Parse("2006-01-02", holiday); err == nil {
+			c.holidays[holiday] = true
+		} else if _, err := time.Parse("01-02", holiday); err == nil {
+			c.holidays[holiday] = true
+		} else {
+			return nil, fmt.Errorf("invalid holiday %q; use YYYY-MM-DD or MM-DD", holiday)
+		}
+	}
+	return c, nil
+}
+
+func parseWeekdays(value interface{}) ([]time.Weekday, error) {
+	list := stringList(value)
+	if len(list) == 0 {
+		list = []string{"mon-fri"}
+	}
+	var days []time.Weekday
+	for _, item := range list {
+		item = strings.ToLower(item)
+		from, to, isRange := strings.Cut(item, "-")
+		if !isRange {
+			to = from
+		}
+		first, ok1 := weekdayNames[from[:min(3, len(from))]]
+		last, ok2 := weekdayNames[to[:min(3, len(to))]]
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("unknown weekday %q", item)
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseHours(value interface{}) ([]hoursWindow, error) {
+	list := stringList(value)
+	if len(list) == 0 {
+		list = []string{"09:00-18:00"}
+	}
+	var windows []hoursWindow
+	for _, item := range list {
+		from, to, ok := strings.Cut(item, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid hours %q; use HH:MM-HH:MM", item)
+		}
+		start, err1 := parseClock(from)
+		end, err2 := parseClock(to)
+		if err1 != nil || err2 != nil || end <= start {
+			return nil, fmt.Errorf("invalid hours %q; use HH:MM-HH:MM", item)
+		}
+		windows = append(windows, hoursWindow{start, end})
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].start < windows[j].start })
+	return windows, nil
+}
+
+// parseClock reads "9", "09:30" or "24:00" as minutes since midnight
+func parseClock(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	hour, minute, _ := strings.Cut(text, ":")
+	h, err := strconv.Atoi(hour)
+	if err != nil {
+		return 0, err
+	}
+	m := 0
+	if minute != "" {
+		if m, err = strconv.Atoi(minute); err != nil {
+			return 0, err
+		}
+	}
+	if h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, errors.New("out of range")
+	}
+	return h*60 + m, nil
+}
+
+func (c *businessCalendar) holiday(day time.Time) bool {
+	return c.holidays[day.Format("2006-01-02")] || c.holidays[day.Format("01-02")]
+}
+
+// isOpen reports whether t falls within business hours
+func (c *businessCalendar) isOpen(t time.Time) bool {
+	t = t.In(c.loc)
+	if c.holiday(t) {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range c.days[t.Weekday()] {
+		if minute >= w.start && minute < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+// nextSlot returns t if it is within business hours, otherwise the start of
+// the next opening window, in the calendar's timezone
+func (c *businessCalendar) nextSlot(t time.Time) (time.Time, error) {
+	t = t.In(c.loc)
+	if c.isOpen(t) {
+		return t, nil
+	}
+	y, m, d := t.Date()
+	for offset := 0; offset < maxSlotSearchDays; offset++ {
+		day := time.Date(y, m, d+offset, 0, 0, 0, 0, c.loc)
+		if c.holiday(day) {
+			continue
+		}
+		for _, w := range c.days[day.Weekday()] {
+			start := time.Date(day.Year(), day.Month(), day.Day(), 0, w.start, 0, 0, c.loc)
+			if start.After(t) {
+				return start, nil
+			}
+		}
+	}
+	return time.Time{}, errors.New("no business hours found within a year")
+}
+
+// DateTimeExecutor parses, formats, shifts and compares dates, converts
+// timezones and finds business-hours slots. The result is written to
+// output_key (default "datetime"); dates also get <output_key>_unix.
+type DateTimeExecutor struct {
+	// Now returns the current time; nil means time.Now
+	Now func() time.Time
+}
+
+func (d *DateTimeExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid datetime configuration")
+	}
+
+	now := time.Now
+	if d.Now != nil {
+		now = d.Now
+	}
+	text := func(key string) string {
+		return replaceVariables(stringValue(config[key]), input)
+	}
+
+	// timezone is the zone results are expressed in; input_timezone is used
+	// to read dates without an offset and defaults to timezone
+	loc, err := loadLocation(text("timezone"))
+	if err != nil {
+		return nil, err
+	}
+	inputLoc := loc
+	if name := text("input_timezone"); name != "" {
+		if inputLoc, err = loadLocation(name); err != nil {
+			return nil, err
+		}
+	}
+
+	value := func(key string) (time.Time, error) {
+		raw, ok := config[key]
+		if !ok || raw == "" {
+			return now().In(loc), nil
+		}
+		if s, isString := raw.(string); isString {
+			raw = replaceVariables(s, input)
+			// an exact {{variable}} keeps its type, e.g. a unix timestamp number
+			if match := exactVariable.FindStringSubmatch(s); match != nil {
+				if v, found := input[match[1]]; found {
+					raw = v
+				}
+			}
+		}
+		t, err := parseTime(raw, text("input_format"), inputLoc)
+		if err != nil {
+			return t, fmt.Errorf("%s: %w", key, err)
+		}
+		return t.In(loc), nil
+	}
+
+	shift := func(sign float64) (calendarDuration, error) {
+		if duration := text("duration"); duration != "" {
+			parsed, err := parseDuration(duration)
+			if err == nil && sign < 0 {
+				parsed = calendarDuration{-parsed.years, -parsed.months, -parsed.days, -parsed.clock}
+			}
+			return parsed, err
+		}
+		amount, ok := config["amount"].(float64)
+		if !ok {
+			if amount, err = strconv.ParseFloat(text("amount"), 64); err != nil {
+				return calendarDuration{}, errors.New("duration or amount is required")
+			}
+		}
+		return durationFromAmount(sign*amount, text("unit"))
+	}
+
+	outputKey := stringValue(config["output_key"])
+	if outputKey == "" {
+		outputKey = "datetime"
+	}
+	format := text("format")
+	dateOutput := func(t time.Time) map[string]interface{} {
+		return map[string]interface{}{
+			outputKey:           formatTime(t, format),
+			outputKey + "_unix": t.Unix(),
+		}
+	}
+
+	operation := stringValue(config["operation"])
+	switch operation {
+	case "now":
+		return dateOutput(now().In(loc)), nil
+
+	case "", "format", "parse", "convert":
+		t, err := value("value")
+		if err != nil {
+			return nil, err
+		}
+		return dateOutput(t), nil
+
+	case "add", "subtract":
+		t, err := value("value")
+		if err != nil {
+			return nil, err
+		}
+		sign := 1.0
+		if operation == "subtract" {
+			sign = -1
+		}
+		duration, err := shift(sign)
+		if err != nil {
+			return nil, err
+		}
+		return dateOutput(duration.addTo(t)), nil
+
+	case "start_of", "end_of":
+		t, err := value("value")
+		if err != nil {
+			return nil, err
+		}
+		if operation == "start_of" {
+			t, err = startOf(t, text("unit"))
+		} else {
+			t, err = endOf(t, text("unit"))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return dateOutput(t), nil
+
+	case "diff":
+		from, err := value("value")
+		if err != nil {
+			return nil, err
+		}
+		to, err := value("to")
+		if err != nil {
+			return nil, err
+		}
+		diff, err := dateDiff(from, to, text("unit"))
+		if err != nil {
+			return nil, err
+		}
+		if round, _ := config["round"].(bool); round {
+			diff = math.Trunc(diff)
+		}
+		return map[string]interface{}{outputKey: diff}, nil
+
+	case "is_business_hours", "next_business_slot":
+		t, err := value("value")
+		if err != nil {
+			return nil, err
+		}
+		calendar, err := calendarFromConfig(config, input, loc)
+		if err != nil {
+			return nil, err
+		}
+		if operation == "is_business_hours" {
+			return map[string]interface{}{outputKey: calendar.isOpen(t)}, nil
+		}
+		slot, err := calendar.nextSlot(t)
+		if err != nil {
+			return nil, err
+		}
+		output := dateOutput(slot)
+		wait := slot.Sub(now())
+		if wait < 0 {
+			wait = 0
+		}
+		output[outputKey+"_wait_seconds"] = int64(wait / time.Second)
+		return output, nil
+
+	default:
+		return nil, fmt.Errorf("unknown datetime operation: %s", operation)
+	}
+}
+
+// calendarFromConfig reads the business_hours object of a datetime node. Its
+// timezone, typically the recipient's, defaults to the node's timezone.
+func calendarFromConfig(config, input map[string]interface{}, loc *time.Location) (*businessCalendar, error) {
+	hours, _ := replaceVariablesDeep(config["business_hours"], input).(map[string]interface{})
+	if hours == nil {
+		hours = map[string]interface{}{}
+	}
+	timezone := stringValue(hours["timezone"])
+	if timezone == "" {
+		timezone = loc.String()
+	}
+	schedule, _ := hours["schedule"].(map[string]interface{})
+	return newBusinessCalendar(timezone, hours["days"], hours["hours"], schedule, hours["holidays"])
+}
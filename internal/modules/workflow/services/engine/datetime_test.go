@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	at := time.Date(2026, time.March, 5, 14, 7, 9, 42_000_000, time.FixedZone("MSK", 3*3600))
+
+	tests := []struct {
+		format string
+		want   interface{}
+	}{
+		{"", "2026-03-05T14:07:09+03:00"},
+		{"date", "2026-03-05"},
+		{"datetime", "2026-03-05 14:07"},
+		{"unix", int64(1772708829)},
+		{"unix_ms", int64(1772708829042)},
+		{"DD.MM.YYYY HH:mm", "05.03.2026 14:07"},
+		{"D/M/YY", "5/3/26"},
+		{"YYYYMMDD", "20260305"},
+		{"MMMM D, YYYY", "March 5, 2026"},
+		{"ddd, MMM D", "Thu, Mar 5"},
+		{"dddd", "Thursday"},
+		{"h:mm A", "2:07 PM"},
+		{"hh:mm a", "02:07 pm"},
+		{"HH:mm:ss.SSS", "14:07:09.042"},
+		{"YYYY-MM-DD[T]HH:mmZ", "2026-03-05T14:07+03:00"},
+		{"HH:mm ZZ z", "14:07 +0300 MSK"},
+		// bracketed text is never read as tokens or Go layout directives
+		{"DD.MM.YYYY [at] HH:mm", "05.03.2026 at 14:07"},
+		{"[Monday January 2 15:04 PM MST] YYYY", "Monday January 2 15:04 PM MST 2026"},
+		{"[Q1] YYYY", "Q1 2026"},
+		// untokenized letters and digits are copied as is
+		{"YYYY-MM-DD v1", "2026-03-05 v1"},
+		{"YYYY_2_MM", "2026_2_03"},
+		{"Jun YYYY", "Jun 2026"},
+		{"[no tokens]", "no tokens"},
+		// Go layouts pass through
+		{"2006-01-02 15:04", "2026-03-05 14:07"},
+		{"Jan 2, 2006", "Mar 5, 2026"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := formatTime(at, tt.format); got != tt.want {
+				t.Errorf("formatTime(%q) = %v, want %v", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeWithFormat(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*3600)
+
+	tests := []struct {
+		text, format string
+		want         time.Time
+	}{
+		{"05.03.2026 14:07", "DD.MM.YYYY HH:mm", time.Date(2026, 3, 5, 14, 7, 0, 0, moscow)},
+		{"5/3/26", "D/M/YY", time.Date(2026, 3, 5, 0, 0, 0, 0, moscow)},
+		{"20260305", "YYYYMMDD", time.Date(2026, 3, 5, 0, 0, 0, 0, moscow)},
+		{"march 5, 2026", "MMMM D, YYYY", time.Date(2026, 3, 5, 0, 0, 0, 0, moscow)},
+		{"Thu, Mar 5 2026", "ddd, MMM D YYYY", time.Date(2026, 3, 5, 0, 0, 0, 0, moscow)},
+		{"2026-03-05 2:07 pm", "YYYY-MM-DD h:mm A", time.Date(2026, 3, 5, 14, 7, 0, 0, moscow)},
+		{"2026-03-05 12:30 AM", "YYYY-MM-DD hh:mm a", time.Date(2026, 3, 5, 0, 30, 0, 0, moscow)},
+		{"2026-03-05 14:07:09.042", "YYYY-MM-DD HH:mm:ss.SSS", time.Date(2026, 3, 5, 14, 7, 9, 42_000_000, moscow)},
+		{"2026-03-05T14:07+00:00", "YYYY-MM-DD[T]HH:mmZ", time.Date(2026, 3, 5, 14, 7, 0, 0, time.UTC)},
+		{"2026-03-05 14:07 -0500", "YYYY-MM-DD HH:mm ZZ", time.Date(2026, 3, 5, 19, 7, 0, 0, time.UTC)},
+		{"05.03.2026 at 14:07", "DD.MM.YYYY [at] HH:mm", time.Date(2026, 3, 5, 14, 7, 0, 0, moscow)},
+		{"Q1 2026-03-05", "[Q1] YYYY-MM-DD", time.Date(2026, 3, 5, 0, 0, 0, 0, moscow)},
+		{"2026-03-05 v1", "YYYY-MM-DD v1", time.Date(2026, 3, 5, 0, 0, 0, 0, moscow)},
+		{"2026-03-05", "date", time.Date(2026, 3, 5, 0, 0, 0, 0, moscow)},
+		{"05 Mar 2026", "02 Jan 2006", time.Date(2026, 3, 5, 0, 0, 0, 0, moscow)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := parseTime(tt.text, tt.format, moscow)
+			if err != nil {
+				t.Fatalf("parseTime(%q, %q): %v", tt.text, tt.format, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTime(%q, %q) = %s, want %s", tt.text, tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeRejectsMismatch(t *testing.T) {
+	tests := []struct{ text, format string }{
+		{"05-03-2026", "DD.MM.YYYY"},
+		{"05.03.2026 14:07", "DD.MM.YYYY"},
+		{"2026-03-05 v2", "YYYY-MM-DD v1"},
+		{"32.03.2026", "DD.MM.YYYY"},
+		{"2026-13-01", "YYYY-MM-DD"},
+		{"05.03.2026 on 14:07", "DD.MM.YYYY [at] HH:mm"},
+	}
+
+	for _, tt := range tests {
+		if got, err := parseTime(tt.text, tt.format, time.UTC); err == nil {
+			t.Errorf("parseTime(%q, %q) = %s, want an error", tt.text, tt.format, got)
+		}
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	at := time.Date(2026, time.November, 23, 9, 5, 1, 500_000_000, time.UTC)
+	for _, format := range []string{
+		"DD.MM.YYYY [at] HH:mm:ss.SSS",
+		"dddd, MMMM D, YYYY h:mm:ss.SSS A",
+		"YYYYMMDD[T]HHmmss.SSSZZ",
+	} {
+		text := formatTime(at, format).(string)
+		got, err := parseTime(text, format, time.UTC)
+		if err != nil {
+			t.Errorf("%q: parsing %q: %v", format, text, err)
+			continue
+		}
+		if !got.Equal(at) {
+			t.Errorf("%q: %q parsed as %s, want %s", format, text, got, at)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrEgressBlocked is returned when an outbound destination is rejected by the egress policy
+var ErrEgressBlocked = errors.New("destination blocked by egress policy")
+
+const maxRedirects = 10
+
+// Ranges that are never reachable from user workflows unless explicitly allowlisted.
+// Loopback, RFC 1918, link-local and multicast are covered by netip helpers.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	// 6to4 and Teredo addresses carry an IPv4 address that a relay may forward to
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("2001::/32"),
+}
+
+// EgressPolicy decides which hosts outbound executors may connect to.
+//
+// Deny rules always win. Allow rules exempt a host or network from the
+// private-range block. Everything else is allowed unless it resolves to a
+// loopback, private, link-local or otherwise reserved address.
+type EgressPolicy struct {
+	AllowPrivate bool
+
+	allow    []egressRule
+	deny     []egressRule
+	resolver *net.Resolver
+	dialer   *net.Dialer
+}
+
+// egressRule matches either a host name ("example.com", "*.example.com") or a CIDR/IP
+type egressRule struct {
+	host   string
+	prefix netip.Prefix
+}
+
+// NewEgressPolicy builds a policy from allowlist and denylist entries.
+// Entries may be host names, wildcard domains ("*.corp.local"), IPs or CIDRs.
+func NewEgressPolicy(allowlist, denylist []string, allowPrivate bool) (*EgressPolicy, error) {
+	allow, err := parseEgressRules(allowlist)
+	if err != nil {
+		return nil, fmt.Errorf("invalid egress allowlist: %w", err)
+	}
+	deny, err := parseEgressRules(denylist)
+	if err != nil {
+		return nil, fmt.Errorf("invalid egress denylist: %w", err)
+	}
+
+	return &EgressPolicy{
+		AllowPrivate: allowPrivate,
+		allow:        allow,
+		deny:         deny,
+		resolver:     net.DefaultResolver,
+		dialer:       &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second},
+	}, nil
+}
+
+// DefaultEgressPolicy blocks private ranges and has no allow or deny rules
+func DefaultEgressPolicy() *EgressPolicy {
+	policy, _ := NewEgressPolicy(nil, nil, false)
+	return policy
+}
+
+// SplitEgressList splits a comma separated config value into entries
+func SplitEgressList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func parseEgressRules(entries []string) ([]egressRule, error) {
+	rules := make([]egressRule, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			rules = append(rules, egressRule{prefix: prefix.Masked()})
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			rules = append(rules, egressRule{prefix: netip.PrefixFrom(addr, addr.BitLen())})
+			continue
+		}
+		if strings.ContainsAny(entry, "/:") {
+			return nil, fmt.Errorf("cannot parse %q", entry)
+		}
+		rules = append(rules, egressRule{host: strings.TrimSuffix(entry, ".")})
+	}
+	return rules, nil
+}
+
+func (r egressRule) matches(host string, addr netip.Addr) bool {
+	if r.host == "" {
+		return addr.IsValid() && r.prefix.Contains(addr)
+	}
+	if strings.HasPrefix(r.host, "*.") {
+		return strings.HasSuffix(host, r.host[1:])
+	}
+	return host == r.host
+}
+
+func matchAny(rules []egressRule, host string, addr netip.Addr) bool {
+	for _, rule := range rules {
+		if rule.matches(host, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func isReserved(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddr validates a single resolved address for the given host name
+func (p *EgressPolicy) checkAddr(host string, addr netip.Addr) error {
+	addr = addr.Unmap()
+	if matchAny(p.deny, host, addr) {
+		return fmt.Errorf("%w: %s (%s) is denylisted", ErrEgressBlocked, host, addr)
+	}
+	if matchAny(p.allow, host, addr) || p.AllowPrivate {
+		return nil
+	}
+	if isReserved(addr) {
+		return fmt.Errorf("%w: %s resolves to non-public address %s", ErrEgressBlocked, host, addr)
+	}
+	return nil
+}
+
+// Resolve looks up host and returns the addresses the policy permits connecting to.
+// Every resolved address must pass, so a name cannot mix public and private records.
+func (p *EgressPolicy) Resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return nil, fmt.Errorf("%w: empty host", ErrEgressBlocked)
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		if matchAny(p.deny, host, netip.Addr{}) {
+			err := fmt.Errorf("%w: %s is denylisted", ErrEgressBlocked, host)
+			Logf(ctx, "Egress blocked: %v", err)
+			return nil, err
+		}
+		addrs, err = p.resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("failed to resolve %s: no addresses", host)
+		}
+	}
+
+	for _, addr := range addrs {
+		if err := p.checkAddr(host, addr); err != nil {
+			Logf(ctx, "Egress blocked: %v", err)
+			return nil, err
+		}
+	}
+	return addrs, nil
+}
+
+// CheckURL validates the scheme and destination of an outbound URL
+func (p *EgressPolicy) CheckURL(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		err := fmt.Errorf("%w: scheme %q is not allowed", ErrEgressBlocked, u.Scheme)
+		Logf(ctx, "Egress blocked: %v", err)
+		return err
+	}
+	_, err := p.Resolve(ctx, u.Hostname())
+	return err
+}
+
+// DialContext resolves the address, validates it and connects to the vetted IP,
+// so the checked address is the one actually dialed (no DNS rebinding window)
+func (p *EgressPolicy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := p.Resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := p.dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// HTTPClient returns a client whose connections and redirects are checked against the policy
+func (p *EgressPolicy) HTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = p.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return p.CheckURL(req.Context(), req.URL)
+		},
+	}
+}
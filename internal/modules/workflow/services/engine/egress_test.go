@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		allow     []string
+		deny      []string
+		private   bool
+		wantBlock bool
+	}{
+		{name: "public IPv4", url: "https://93.184.216.34/path"},
+		{name: "public IPv6", url: "https://[2606:2800:220:1:248:1893:25c8:1946]/"},
+		{name: "loopback", url: "http://127.0.0.1:8080/", wantBlock: true},
+		{name: "IPv6 loopback", url: "http://[::1]/", wantBlock: true},
+		{name: "IPv4-mapped loopback", url: "http://[::ffff:127.0.0.1]/", wantBlock: true},
+		{name: "RFC 1918", url: "http://10.1.2.3/", wantBlock: true},
+		{name: "link-local metadata", url: "http://169.254.169.254/latest/meta-data", wantBlock: true},
+		{name: "unspecified", url: "http://0.0.0.0/", wantBlock: true},
+		{name: "carrier-grade NAT", url: "http://100.64.0.1/", wantBlock: true},
+		{name: "unique local IPv6", url: "http://[fd00::1]/", wantBlock: true},
+		{name: "NAT64 well-known prefix", url: "http://[64:ff9b::7f00:1]/", wantBlock: true},
+		{name: "NAT64 local-use prefix", url: "http://[64:ff9b:1::a00:1]/", wantBlock: true},
+		{name: "6to4", url: "http://[2002:7f00:1::1]/", wantBlock: true},
+		{name: "Teredo", url: "http://[2001:0:4136:e378:8000:63bf:3fff:fdd2]/", wantBlock: true},
+		{name: "documentation IPv6", url: "http://[2001:db8::1]/", wantBlock: true},
+		{name: "scheme", url: "file:///etc/passwd", wantBlock: true},
+		{name: "empty host", url: "http:///path", wantBlock: true},
+		{name: "allowlisted CIDR", url: "http://10.1.2.3/", allow: []string{"10.0.0.0/8"}},
+		{name: "allowlisted IP only", url: "http://10.1.2.4/", allow: []string{"10.1.2.3"}, wantBlock: true},
+		{name: "allow private", url: "http://192.168.1.10/", private: true},
+		{name: "denylisted IP", url: "https://93.184.216.34/", deny: []string{"93.184.216.0/24"}, wantBlock: true},
+		{name: "deny beats allow", url: "http://10.1.2.3/", allow: []string{"10.0.0.0/8"}, deny: []string{"10.1.2.3"}, wantBlock: true},
+		{name: "denylisted host", url: "https://api.evil.example/", deny: []string{"*.evil.example"}, wantBlock: true},
+		{name: "denylisted host with trailing dot", url: "https://API.evil.example./", deny: []string{"api.evil.example"}, wantBlock: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewEgressPolicy(tt.allow, tt.deny, tt.private)
+			if err != nil {
+				t.Fatalf("NewEgressPolicy: %v", err)
+			}
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("parse %s: %v", tt.url, err)
+			}
+
+			err = policy.CheckURL(context.Background(), u)
+			if blocked := errors.Is(err, ErrEgressBlocked); blocked != tt.wantBlock {
+				t.Fatalf("CheckURL(%s) = %v, want blocked %v", tt.url, err, tt.wantBlock)
+			}
+		})
+	}
+}
+
+func TestNewEgressPolicyRejectsBadEntries(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/33", "http://example.com", "::1/200"} {
+		if _, err := NewEgressPolicy([]string{entry}, nil, false); err == nil {
+			t.Errorf("allowlist entry %q: expected an error", entry)
+		}
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	address := listener.Addr().String()
+
+	if _, err := DefaultEgressPolicy().DialContext(context.Background(), "tcp", address); !errors.Is(err, ErrEgressBlocked) {
+		t.Fatalf("default policy dialing %s: got %v, want ErrEgressBlocked", address, err)
+	}
+
+	policy, err := NewEgressPolicy([]string{"127.0.0.1"}, nil, false)
+	if err != nil {
+		t.Fatalf("NewEgressPolicy: %v", err)
+	}
+	conn, err := policy.DialContext(context.Background(), "tcp", address)
+	if err != nil {
+		t.Fatalf("allowlisted dial: %v", err)
+	}
+	conn.Close()
+
+	if _, err := policy.DialContext(context.Background(), "tcp", "127.0.0.1"); err == nil {
+		t.Fatal("dialing an address without a port: expected an error")
+	}
+}
+
+func TestHTTPClientChecksEveryRedirect(t *testing.T) {
+	var target string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusNoContent)
+		case "/internal":
+			http.Redirect(w, r, "http://10.0.0.1/admin", http.StatusFound)
+		case "/6to4":
+			http.Redirect(w, r, "http://[2002:a00:1::1]/", http.StatusFound)
+		case "/hop":
+			// an allowed hop first, then a blocked one
+			http.Redirect(w, r, target+"/internal", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+	target = server.URL
+
+	policy, err := NewEgressPolicy([]string{"127.0.0.1"}, nil, false)
+	if err != nil {
+		t.Fatalf("NewEgressPolicy: %v", err)
+	}
+	client := policy.HTTPClient(5 * time.Second)
+
+	resp, err := client.Get(server.URL + "/start")
+	if err != nil {
+		t.Fatalf("allowed redirect: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("allowed redirect: status %d", resp.StatusCode)
+	}
+
+	for _, path := range []string{"/internal", "/6to4", "/hop"} {
+		resp, err := client.Get(server.URL + path)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if !errors.Is(err, ErrEgressBlocked) {
+			t.Errorf("redirect from %s: got %v, want ErrEgressBlocked", path, err)
+		}
+	}
+
+	resp, err = client.Get(server.URL + "/loop")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("redirect loop: expected an error")
+	}
+}
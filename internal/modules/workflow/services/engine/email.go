@@ -0,0 +1,700 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"s4s-backend/internal/pkg/mailer"
+
+	"github.com/google/uuid"
+)
+
+// Headers the builder owns; custom headers cannot override them
+var reservedEmailHeaders = map[string]bool{
+	"From": true, "To": true, "Cc": true, "Bcc": true, "Reply-To": true,
+	"Subject": true, "Date": true, "Message-Id": true, "Mime-Version": true,
+	"Content-Type": true, "Content-Transfer-Encoding": true,
+}
+
+// EmailAttachment is a file attached to an outgoing message
+type EmailAttachment struct {
+	FileName string
+	MimeType string
+	Data     []byte
+}
+
+// EmailMessage describes an outgoing email independent of transport
+type EmailMessage struct {
+	From        *mail.Address
+	To          []*mail.Address
+	Cc          []*mail.Address
+	Bcc         []*mail.Address
+	ReplyTo     []*mail.Address
+	Subject     string
+	Text        string
+	HTML        string
+	Headers     map[string]string
+	Attachments []EmailAttachment
+	MessageID   string
+	Date        time.Time
+}
+
+// Recipients returns the envelope recipients (To, Cc and Bcc)
+func (m *EmailMessage) Recipients() []string {
+	var rcpt []string
+	for _, list := range [][]*mail.Address{m.To, m.Cc, m.Bcc} {
+		for _, addr := range list {
+			rcpt = append(rcpt, addr.Address)
+		}
+	}
+	return rcpt
+}
+
+// Build renders the message as RFC 5322 / MIME bytes.
+// Bcc recipients are never written to the headers.
+func (m *EmailMessage) Build() ([]byte, error) {
+	if m.From == nil {
+		return nil, errors.New("from address is required")
+	}
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+	if m.Text == "" && m.HTML == "" {
+		return nil, errors.New("message body is required")
+	}
+	if m.Date.IsZero() {
+		m.Date = time.Now()
+	}
+	if m.MessageID == "" {
+		m.MessageID = newMessageID(m.From.Address)
+	}
+	text := m.Text
+	if text == "" {
+		text = htmlToText(m.HTML)
+	}
+
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+
+	writeHeader("From", m.From.String())
+	if len(m.To) > 0 {
+		writeHeader("To", joinAddresses(m.To))
+	} else if len(m.Cc) == 0 {
+		// only Bcc recipients, e.g. when every To address is suppressed
+		writeHeader("To", "undisclosed-recipients:;")
+	}
+	if len(m.Cc) > 0 {
+		writeHeader("Cc", joinAddresses(m.Cc))
+	}
+	if len(m.ReplyTo) > 0 {
+		writeHeader("Reply-To", joinAddresses(m.ReplyTo))
+	}
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	writeHeader("Date", m.Date.Format(time.RFC1123Z))
+	writeHeader("Message-ID", m.MessageID)
+	writeHeader("MIME-Version", "1.0")
+
+	keys := make([]string, 0, len(m.Headers))
+	for key := range m.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		canonical := textproto.CanonicalMIMEHeaderKey(key)
+		if reservedEmailHeaders[canonical] {
+			return nil, fmt.Errorf("header %s cannot be overridden", canonical)
+		}
+		if err := checkHeaderValue(canonical); err != nil {
+			return nil, err
+		}
+		if err := checkHeaderValue(m.Headers[key]); err != nil {
+			return nil, err
+		}
+		writeHeader(canonical, mime.QEncoding.Encode("utf-8", m.Headers[key]))
+	}
+
+	if len(m.Attachments) == 0 {
+		if m.HTML == "" {
+			return finishSinglePart(&buf, "text/plain", text)
+		}
+		return finishAlternative(&buf, text, m.HTML)
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	writeHeader("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	buf.WriteString("\r\n")
+
+	if m.HTML == "" {
+		if err := writeTextPart(mixed, "text/plain", text); err != nil {
+			return nil, err
+		}
+	} else {
+		boundary := multipart.NewWriter(nil).Boundary()
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": boundary})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		alt := multipart.NewWriter(part)
+		if err := alt.SetBoundary(boundary); err != nil {
+			return nil, err
+		}
+		if err := writeAlternativeParts(alt, text, m.HTML); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, attachment := range m.Attachments {
+		if err := writeAttachment(mixed, attachment); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func finishSinglePart(buf *bytes.Buffer, contentType, body string) ([]byte, error) {
+	buf.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(buf)
+	if _, err := qp.Write([]byte(normalizeNewlines(body))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func finishAlternative(buf *bytes.Buffer, text, htmlBody string) ([]byte, error) {
+	alt := multipart.NewWriter(buf)
+	buf.WriteString("Content-Type: " + mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": alt.Boundary()}) + "\r\n\r\n")
+	if err := writeAlternativeParts(alt, text, htmlBody); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeAlternativeParts(alt *multipart.Writer, text, htmlBody string) error {
+	if err := writeTextPart(alt, "text/plain", text); err != nil {
+		return err
+	}
+	if err := writeTextPart(alt, "text/html", htmlBody); err != nil {
+		return err
+	}
+	return alt.Close()
+}
+
+func writeTextPart(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(normalizeNewlines(body))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeAttachment(w *multipart.Writer, attachment EmailAttachment) error {
+	contentType := attachment.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	fileName := attachment.FileName
+	if fileName == "" {
+		fileName = "attachment"
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": fileName})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": fileName})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+	for len(encoded) > 76 {
+		if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+func joinAddresses(list []*mail.Address) string {
+	parts := make([]string, len(list))
+	for i, addr := range list {
+		parts[i] = addr.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func checkHeaderValue(value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("header values must not contain line breaks")
+	}
+	return nil
+}
+
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+func newMessageID(from string) string {
+	domain := "s4s.local"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", uuid.New().String(), domain)
+}
+
+var (
+	htmlBreakRe   = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</h[1-6]>|</li>|</tr>`)
+	htmlTagRe     = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlDropRe    = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	blankLinesRe  = regexp.MustCompile(`\n{3,}`)
+	inlineSpaceRe = regexp.MustCompile(`[ \t]+`)
+)
+
+// htmlToText derives a plain-text alternative from an HTML body
+func htmlToText(body string) string {
+	text := htmlDropRe.ReplaceAllString(body, "")
+	text = htmlBreakRe.ReplaceAllString(text, "\n")
+	text = htmlTagRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = inlineSpaceRe.ReplaceAllString(text, " ")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLinesRe.ReplaceAllString(text, "\n\n"))
+}
+
+// parseAddressList accepts a comma separated string or a JSON array of addresses
+func parseAddressList(value interface{}, input map[string]interface{}) ([]*mail.Address, error) {
+	var entries []string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		entries = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				entries = append(entries, s)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported address list %v", value)
+	}
+
+	var result []*mail.Address
+	for _, entry := range entries {
+		list, err := mail.ParseAddressList(replaceVariables(entry, input))
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", entry, err)
+		}
+		result = append(result, list...)
+	}
+	return result, nil
+}
+
+// EmailExecutor sends emails through the user's smtp connection, or through
+// the platform relay when no connection is configured. The relay always sends
+// from its own address; only smtp connections honour from. Recipients on the
+// user's suppression list are dropped. With a tracker, track_opens adds a
+// tracking pixel, track_clicks routes the HTML body's links through signed
+// redirects and {{unsubscribe_url}} is available in the templates. Only such
+// tracked emails are sent to each recipient separately (see sendTracked), with
+// a one-click List-Unsubscribe link; other emails get a mailto one. Either is
+// left out when list_unsubscribe is false.
+type EmailExecutor struct {
+	Connections   ConnectionResolver
+	PlatformRelay *mailer.Config
+	Egress        *EgressPolicy
+	Tracker       EmailTracker
+	Suppressions  SuppressionChecker
+}
+
+func (e *EmailExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid email configuration")
+	}
+
+	subject, _ := config["subject"].(string)
+	body, _ := config["body"].(string)
+	htmlBody, _ := config["html"].(string)
+	from, _ := config["from"].(string)
+	fromName, _ := config["from_name"].(string)
+
+	if subject == "" || (body == "" && htmlBody == "") {
+		return nil, errors.New("to, subject, and body or html are required")
+	}
+
+	trackOpens, _ := config["track_opens"].(bool)
+	trackClicks, _ := config["track_clicks"].(bool)
+	listUnsubscribe := true
+	if v, ok := config["list_unsubscribe"].(bool); ok {
+		listUnsubscribe = v
+	}
+	// tracking is opt-in: only tracked emails are split into a copy per recipient
+	tracked := false
+	if e.Tracker != nil {
+		tracked = trackOpens || trackClicks || strings.Contains(subject+body+htmlBody, "unsubscribe_url")
+	} else if trackOpens || trackClicks {
+		return nil, errors.New("email tracking is not configured")
+	}
+
+	msg := &EmailMessage{Headers: map[string]string{}}
+
+	var err error
+	if msg.To, err = parseAddressList(config["to"], input); err != nil {
+		return nil, err
+	}
+	if msg.Cc, err = parseAddressList(config["cc"], input); err != nil {
+		return nil, err
+	}
+	if msg.Bcc, err = parseAddressList(config["bcc"], input); err != nil {
+		return nil, err
+	}
+	if msg.ReplyTo, err = parseAddressList(config["reply_to"], input); err != nil {
+		return nil, err
+	}
+	if len(msg.To) == 0 {
+		return nil, errors.New("to, subject, and body or html are required")
+	}
+
+	suppressed, err := e.dropSuppressed(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Recipients()) == 0 {
+		return suppressedOutput(ctx, "email_sent", suppressed), nil
+	}
+	if len(suppressed) > 0 {
+		Logf(ctx, "email: skipped suppressed recipients %s", strings.Join(suppressed, ", "))
+	}
+
+	smtpConfig, err := e.smtpConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if connectionID, _ := config["connection_id"].(string); connectionID == "" {
+		// the relay sends with the platform's SPF and DKIM, so it only sends
+		// from its own address; from_name and reply_to stay the node's
+		if from != "" {
+			Logf(ctx, "email: from %s is ignored; the platform relay sends from %s", from, smtpConfig.FromAddress)
+		}
+		from = smtpConfig.FromAddress
+	} else if from == "" {
+		from = smtpConfig.FromAddress
+	}
+	if fromName == "" {
+		fromName = smtpConfig.FromName
+	}
+	if from == "" {
+		return nil, errors.New("from address is required")
+	}
+	msg.From, err = mail.ParseAddress(replaceVariables(from, input))
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	if fromName != "" {
+		msg.From.Name = replaceVariables(fromName, input)
+	}
+
+	if headers, ok := config["headers"].(map[string]interface{}); ok {
+		for key, value := range headers {
+			msg.Headers[key] = replaceVariables(fmt.Sprintf("%v", value), input)
+		}
+	}
+
+	attachments, _ := config["attachments"].([]interface{})
+	for _, item := range attachments {
+		name, _ := item.(string)
+		file, err := GetBinary(input, name)
+		if err != nil {
+			return nil, fmt.Errorf("attachment: %w", err)
+		}
+		msg.Attachments = append(msg.Attachments, EmailAttachment{
+			FileName: file.FileName,
+			MimeType: file.MimeType,
+			Data:     file.Data,
+		})
+	}
+
+	if tracked {
+		copies, err := e.sendTracked(ctx, node, smtpConfig, msg, trackedEmail{
+			subject:         subject,
+			text:            body,
+			html:            htmlBody,
+			input:           input,
+			trackOpens:      trackOpens,
+			trackClicks:     trackClicks,
+			listUnsubscribe: listUnsubscribe,
+		})
+		if err != nil {
+			if len(copies) > 0 {
+				Logf(ctx, "email: sent to %s before the failure", strings.Join(trackedRecipients(copies), ", "))
+			}
+			return nil, err
+		}
+		trackingIDs := make(map[string]interface{}, len(copies))
+		for _, sent := range copies {
+			trackingIDs[sent.Recipients[0]] = sent.ID
+		}
+		return map[string]interface{}{
+			"email_sent":   true,
+			"to":           addressStrings(msg.To),
+			"cc":           addressStrings(msg.Cc),
+			"subject":      copies[0].Subject,
+			"message_id":   copies[0].MessageID,
+			"tracking_id":  copies[0].ID,
+			"tracking_ids": trackingIDs,
+			"suppressed":   suppressed,
+		}, nil
+	}
+
+	msg.Subject = replaceVariables(subject, input)
+	msg.Text = replaceVariables(body, input)
+	msg.HTML = replaceVariables(htmlBody, input)
+	if listUnsubscribe && !hasHeader(msg.Headers, "List-Unsubscribe") {
+		// one copy goes to everyone, so there is no per-recipient link;
+		// unsubscribe requests are mailed to the sender instead
+		msg.Headers["List-Unsubscribe"] = mailtoUnsubscribe(msg)
+	}
+	message, err := msg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+
+	if err := mailer.Send(ctx, smtpConfig, msg.From.Address, msg.Recipients(), message); err != nil {
+		return nil, fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return map[string]interface{}{
+		"email_sent":  true,
+		"to":          addressStrings(msg.To),
+		"cc":          addressStrings(msg.Cc),
+		"subject":     msg.Subject,
+		"message_id":  msg.MessageID,
+		"tracking_id": "",
+		"suppressed":  suppressed,
+	}, nil
+}
+
+// trackedEmail holds the templates of a tracked email, rendered again for
+// every recipient since {{unsubscribe_url}} differs per copy
+type trackedEmail struct {
+	subject, text, html string
+	input               map[string]interface{}
+
+	trackOpens, trackClicks, listUnsubscribe bool
+}
+
+// sendTracked sends every recipient their own copy with its own tracking ID
+// and Message-ID, so opens, clicks and unsubscribes are attributed to the one
+// who made them and an unsubscribe link never covers anyone else. Bcc
+// recipients stay out of the headers. A copy is registered once it was sent;
+// on failure the copies sent so far are returned with the error.
+func (e *EmailExecutor) sendTracked(ctx context.Context, node *Node, smtpConfig *mailer.Config, msg *EmailMessage, email trackedEmail) ([]TrackedEmail, error) {
+	recipients := uniqueAddresses(msg.Recipients())
+
+	sent := make([]TrackedEmail, 0, len(recipients))
+	for _, recipient := range recipients {
+		id := uuid.New().String()
+		vars := make(map[string]interface{}, len(email.input)+1)
+		for k, v := range email.input {
+			vars[k] = v
+		}
+		vars["unsubscribe_url"] = e.Tracker.UnsubscribeURL(id)
+
+		personal := *msg
+		personal.MessageID = newMessageID(msg.From.Address)
+		personal.Headers = make(map[string]string, len(msg.Headers)+2)
+		for k, v := range msg.Headers {
+			personal.Headers[k] = v
+		}
+		personal.Subject = replaceVariables(email.subject, vars)
+		personal.Text = replaceVariables(email.text, vars)
+		personal.HTML = replaceVariables(email.html, vars)
+
+		if email.listUnsubscribe && !hasHeader(personal.Headers, "List-Unsubscribe") {
+			// lets mail clients offer an unsubscribe button, with one-click
+			// unsubscribe (RFC 8058) posting to the same link
+			personal.Headers["List-Unsubscribe"] = "<" + e.Tracker.UnsubscribeURL(id) + ">"
+			personal.Headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+		}
+		if personal.HTML != "" {
+			if personal.Text == "" {
+				// derive the text part before links are rewritten
+				personal.Text = htmlToText(personal.HTML)
+			}
+			if email.trackClicks {
+				personal.HTML = trackLinks(personal.HTML, id, e.Tracker)
+			}
+			if email.trackOpens {
+				personal.HTML = addOpenPixel(personal.HTML, id, e.Tracker)
+			}
+		}
+
+		message, err := personal.Build()
+		if err != nil {
+			return sent, fmt.Errorf("failed to build email: %w", err)
+		}
+		if err := mailer.Send(ctx, smtpConfig, personal.From.Address, []string{recipient}, message); err != nil {
+			if len(sent) > 0 {
+				return sent, fmt.Errorf("failed to send email to %s after %d of %d recipients: %w", recipient, len(sent), len(recipients), err)
+			}
+			return sent, fmt.Errorf("failed to send email: %w", err)
+		}
+
+		tracked := TrackedEmail{
+			ID:         id,
+			NodeID:     node.ID,
+			MessageID:  personal.MessageID,
+			Subject:    personal.Subject,
+			Recipients: []string{recipient},
+		}
+		if err := e.Tracker.Register(ctx, tracked); err != nil {
+			return sent, fmt.Errorf("email to %s was sent but could not be tracked: %w", recipient, err)
+		}
+		sent = append(sent, tracked)
+	}
+	return sent, nil
+}
+
+func trackedRecipients(copies []TrackedEmail) []string {
+	recipients := make([]string, len(copies))
+	for i, email := range copies {
+		recipients[i] = email.Recipients[0]
+	}
+	return recipients
+}
+
+// mailtoUnsubscribe is the List-Unsubscribe header of an untracked email: a
+// mail to its Reply-To, or its sender, asking to unsubscribe
+func mailtoUnsubscribe(msg *EmailMessage) string {
+	address := msg.From.Address
+	if len(msg.ReplyTo) > 0 {
+		address = msg.ReplyTo[0].Address
+	}
+	return "<mailto:" + address + "?subject=unsubscribe>"
+}
+
+// uniqueAddresses drops repeated addresses, compared case-insensitively, so
+// nobody listed twice gets two copies
+func uniqueAddresses(addresses []string) []string {
+	seen := make(map[string]bool, len(addresses))
+	unique := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		key := strings.ToLower(address)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, address)
+	}
+	return unique
+}
+
+// dropSuppressed removes recipients on the user's suppression list from
+// msg and returns them
+func (e *EmailExecutor) dropSuppressed(ctx context.Context, msg *EmailMessage) ([]string, error) {
+	suppressed := []string{}
+	skip, err := suppressedSet(ctx, e.Suppressions, "email", msg.Recipients()...)
+	if err != nil || len(skip) == 0 {
+		return suppressed, err
+	}
+	keep := func(list []*mail.Address) []*mail.Address {
+		kept := list[:0]
+		for _, addr := range list {
+			if skip[strings.ToLower(addr.Address)] {
+				suppressed = append(suppressed, addr.Address)
+				continue
+			}
+			kept = append(kept, addr)
+		}
+		return kept
+	}
+	msg.To, msg.Cc, msg.Bcc = keep(msg.To), keep(msg.Cc), keep(msg.Bcc)
+	return suppressed, nil
+}
+
+// smtpConfig picks the transport: the referenced smtp connection, or the platform relay
+func (e *EmailExecutor) smtpConfig(ctx context.Context, config map[string]interface{}) (*mailer.Config, error) {
+	if connectionID, _ := config["connection_id"].(string); connectionID == "" {
+		if e.PlatformRelay == nil {
+			return nil, errors.New("connection_id is required: no platform smtp relay is configured")
+		}
+		relay := *e.PlatformRelay
+		return &relay, nil
+	}
+
+	conn, err := resolveConnection(ctx, e.Connections, config, "smtp")
+	if err != nil {
+		return nil, err
+	}
+	smtpConfig, err := mailer.ConfigFromCredentials(conn.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp connection: %w", err)
+	}
+
+	egress := e.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+	smtpConfig.Dial = egress.DialContext
+	return smtpConfig, nil
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if textproto.CanonicalMIMEHeaderKey(key) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func addressStrings(list []*mail.Address) []string {
+	result := make([]string, len(list))
+	for i, addr := range list {
+		result[i] = addr.Address
+	}
+	return result
+}
@@ -0,0 +1,740 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"s4s-backend/internal/pkg/mailer"
+	"s4s-backend/internal/pkg/mailer/mailertest"
+)
+
+// mimePart is a decoded leaf part of a parsed message
+type mimePart struct {
+	contentType string
+	fileName    string
+	body        string
+}
+
+// parseMessage parses raw with net/mail and walks its MIME tree
+func parseMessage(t *testing.T, raw []byte) (*mail.Message, []mimePart) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("message does not parse: %v\n%s", err, raw)
+	}
+	parts := collectParts(t, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), "", msg.Body)
+	return msg, parts
+}
+
+func collectParts(t *testing.T, contentType, encoding, disposition string, body io.Reader) []mimePart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("bad Content-Type %q: %v", contentType, err)
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var parts []mimePart
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return parts
+			}
+			if err != nil {
+				t.Fatalf("bad %s: %v", mediaType, err)
+			}
+			parts = append(parts, collectParts(t, part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part.Header.Get("Content-Disposition"), part)...)
+		}
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("failed to decode %s part: %v", mediaType, err)
+	}
+	part := mimePart{contentType: mediaType, body: string(data)}
+	if disposition != "" {
+		_, dispositionParams, _ := mime.ParseMediaType(disposition)
+		part.fileName = dispositionParams["filename"]
+	}
+	return []mimePart{part}
+}
+
+func testMessage() *EmailMessage {
+	return &EmailMessage{
+		From:    &mail.Address{Name: "Sales Team", Address: "sales@example.com"},
+		To:      []*mail.Address{{Name: "Анна", Address: "anna@example.com"}},
+		Cc:      []*mail.Address{{Address: "boss@example.com"}},
+		Bcc:     []*mail.Address{{Address: "audit@example.com"}},
+		Subject: "Привет, your quote",
+		Text:    "Hello Anna,\nline two",
+		Date:    time.Date(2026, 3, 5, 14, 7, 0, 0, time.UTC),
+	}
+}
+
+func TestEmailMessageBuild(t *testing.T) {
+	tests := []struct {
+		name      string
+		edit      func(m *EmailMessage)
+		wantType  string
+		wantParts []mimePart
+	}{
+		{
+			name:      "text only",
+			wantType:  "text/plain",
+			wantParts: []mimePart{{contentType: "text/plain", body: "Hello Anna,\r\nline two"}},
+		},
+		{
+			name:     "html with derived text",
+			edit:     func(m *EmailMessage) { m.Text, m.HTML = "", "<p>Hello <b>Anna</b></p><p>Bye &amp; thanks</p>" },
+			wantType: "multipart/alternative",
+			wantParts: []mimePart{
+				{contentType: "text/plain", body: "Hello Anna\r\nBye & thanks"},
+				{contentType: "text/html", body: "<p>Hello <b>Anna</b></p><p>Bye &amp; thanks</p>"},
+			},
+		},
+		{
+			name: "attachments",
+			edit: func(m *EmailMessage) {
+				m.HTML = "<p>See attached</p>"
+				m.Attachments = []EmailAttachment{{FileName: "отчёт.csv", MimeType: "text/csv", Data: []byte("a,b\n1,2\n")}}
+			},
+			wantType: "multipart/mixed",
+			wantParts: []mimePart{
+				{contentType: "text/plain", body: "Hello Anna,\r\nline two"},
+				{contentType: "text/html", body: "<p>See attached</p>"},
+				{contentType: "text/csv", fileName: "отчёт.csv", body: "a,b\n1,2\n"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testMessage()
+			if tt.edit != nil {
+				tt.edit(m)
+			}
+			raw, err := m.Build()
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			msg, parts := parseMessage(t, raw)
+
+			decoder := new(mime.WordDecoder)
+			subject, _ := decoder.DecodeHeader(msg.Header.Get("Subject"))
+			if subject != m.Subject {
+				t.Errorf("Subject = %q, want %q", subject, m.Subject)
+			}
+			to, err := msg.Header.AddressList("To")
+			if err != nil || len(to) != 1 || to[0].Name != "Анна" || to[0].Address != "anna@example.com" {
+				t.Errorf("To = %v (%v)", to, err)
+			}
+			if msg.Header.Get("Cc") != "<boss@example.com>" {
+				t.Errorf("Cc = %q", msg.Header.Get("Cc"))
+			}
+			if msg.Header.Get("Bcc") != "" || strings.Contains(string(raw), "audit@example.com") {
+				t.Error("Bcc recipient is visible in the message")
+			}
+			if msg.Header.Get("Message-Id") != m.MessageID || !strings.HasSuffix(m.MessageID, "@example.com>") {
+				t.Errorf("Message-ID = %q, generated %q", msg.Header.Get("Message-Id"), m.MessageID)
+			}
+			if msg.Header.Get("Mime-Version") != "1.0" || msg.Header.Get("Date") != "Thu, 05 Mar 2026 14:07:00 +0000" {
+				t.Errorf("MIME-Version %q, Date %q", msg.Header.Get("Mime-Version"), msg.Header.Get("Date"))
+			}
+			if mediaType, _, _ := mime.ParseMediaType(msg.Header.Get("Content-Type")); mediaType != tt.wantType {
+				t.Errorf("Content-Type = %s, want %s", mediaType, tt.wantType)
+			}
+			if !slices.Equal(parts, tt.wantParts) {
+				t.Errorf("parts = %+v\nwant %+v", parts, tt.wantParts)
+			}
+		})
+	}
+}
+
+func TestEmailMessageBuildRejectsHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr string
+	}{
+		{name: "line break in value", headers: map[string]string{"X-Campaign": "spring\r\nBcc: victim@example.com"}, wantErr: "line breaks"},
+		{name: "bare newline in value", headers: map[string]string{"X-Campaign": "spring\nBcc: victim@example.com"}, wantErr: "line breaks"},
+		{name: "line break in name", headers: map[string]string{"X-Campaign\r\nBcc": "victim@example.com"}, wantErr: "line breaks"},
+		{name: "bcc override", headers: map[string]string{"bcc": "victim@example.com"}, wantErr: "cannot be overridden"},
+		{name: "content type override", headers: map[string]string{"content-type": "text/html"}, wantErr: "cannot be overridden"},
+		{name: "from override", headers: map[string]string{"FROM": "ceo@example.com"}, wantErr: "cannot be overridden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testMessage()
+			m.Headers = tt.headers
+			_, err := m.Build()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Build() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// text fields are encoded, so line breaks in them cannot start a header
+	m := testMessage()
+	m.Subject = "Hello\r\nBcc: victim@example.com"
+	m.From.Name = "Sales\r\nBcc: victim@example.com"
+	m.Headers = map[string]string{"X-Campaign": "Весна"}
+	raw, err := m.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	msg, _ := parseMessage(t, raw)
+	if len(msg.Header["Bcc"]) != 0 {
+		t.Fatalf("injected Bcc header: %q", msg.Header["Bcc"])
+	}
+	decoder := new(mime.WordDecoder)
+	if subject, _ := decoder.DecodeHeader(msg.Header.Get("Subject")); subject != m.Subject {
+		t.Errorf("Subject = %q, want %q", subject, m.Subject)
+	}
+	if campaign, _ := decoder.DecodeHeader(msg.Header.Get("X-Campaign")); campaign != "Весна" {
+		t.Errorf("X-Campaign = %q", campaign)
+	}
+}
+
+func TestEmailMessageBuildErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(m *EmailMessage)
+		wantErr string
+	}{
+		{name: "no sender", edit: func(m *EmailMessage) { m.From = nil }, wantErr: "from address is required"},
+		{name: "no recipients", edit: func(m *EmailMessage) { m.To, m.Cc, m.Bcc = nil, nil, nil }, wantErr: "at least one recipient"},
+		{name: "no body", edit: func(m *EmailMessage) { m.Text = "" }, wantErr: "body is required"},
+	}
+	for _, tt := range tests {
+		m := testMessage()
+		tt.edit(m)
+		if _, err := m.Build(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Build() = %v, want error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// staticConnections resolves every connection ID to the same connection
+type staticConnections struct {
+	conn *Connection
+}
+
+func (s staticConnections) Resolve(ctx context.Context, userID, connectionID string) (*Connection, error) {
+	return s.conn, nil
+}
+
+// smtpConnection is an smtp connection to server
+func smtpConnection(server *mailertest.Server) staticConnections {
+	return staticConnections{conn: &Connection{ID: "conn-1", Service: "smtp", Credentials: map[string]interface{}{
+		"host":        server.Host,
+		"port":        float64(server.Port),
+		"tlsMode":     "none",
+		"username":    "user",
+		"password":    "secret",
+		"fromAddress": "sales@example.com",
+		"fromName":    "Sales Team",
+	}}}
+}
+
+func emailNode(config map[string]interface{}) *Node {
+	return &Node{ID: "email-1", Data: map[string]interface{}{"type": "email", "config": config}}
+}
+
+func localEgress(t *testing.T) *EgressPolicy {
+	t.Helper()
+	policy, err := NewEgressPolicy([]string{"127.0.0.1"}, nil, false)
+	if err != nil {
+		t.Fatalf("NewEgressPolicy: %v", err)
+	}
+	return policy
+}
+
+func TestEmailExecutorSends(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{Username: "user", Password: "secret"})
+	defer server.Close()
+
+	executor := &EmailExecutor{Connections: smtpConnection(server), Egress: localEgress(t)}
+	output, err := executor.Execute(context.Background(), emailNode(map[string]interface{}{
+		"connection_id": "conn-1",
+		"to":            "{{lead.email}}",
+		"cc":            []interface{}{"boss@example.com"},
+		"bcc":           "audit@example.com",
+		"subject":       "Quote for {{lead.company}}",
+		"body":          "Hi {{lead.name}}",
+		"headers":       map[string]interface{}{"X-Lead-Id": "{{lead.id}}"},
+	}), map[string]interface{}{
+		"lead": map[string]interface{}{"id": "L-1", "name": "Anna", "email": "Anna <anna@example.com>", "company": "Acme"},
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if output["email_sent"] != true || output["subject"] != "Quote for Acme" {
+		t.Errorf("output = %v", output)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("server got %d messages, want 1", len(messages))
+	}
+	sent := messages[0]
+	if sent.From != "sales@example.com" || sent.Username != "user" {
+		t.Errorf("envelope from %q, authenticated as %q", sent.From, sent.Username)
+	}
+	if want := []string{"anna@example.com", "boss@example.com", "audit@example.com"}; !slices.Equal(sent.To, want) {
+		t.Errorf("envelope recipients %v, want %v", sent.To, want)
+	}
+	msg, parts := parseMessage(t, sent.Data)
+	if msg.Header.Get("From") != `"Sales Team" <sales@example.com>` || msg.Header.Get("X-Lead-Id") != "L-1" {
+		t.Errorf("From %q, X-Lead-Id %q", msg.Header.Get("From"), msg.Header.Get("X-Lead-Id"))
+	}
+	if msg.Header.Get("Bcc") != "" {
+		t.Error("Bcc header was sent")
+	}
+	if len(parts) != 1 || strings.TrimSpace(parts[0].body) != "Hi Anna" {
+		t.Errorf("parts = %+v", parts)
+	}
+}
+
+func TestEmailExecutorPlatformRelay(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{TLS: mailertest.TLSStartTLS})
+	defer server.Close()
+
+	executor := &EmailExecutor{PlatformRelay: &mailer.Config{
+		Host:          server.Host,
+		Port:          server.Port,
+		TLSMode:       mailer.TLSModeStartTLS,
+		AuthMechanism: mailer.AuthNone,
+		FromAddress:   "noreply@example.com",
+		RootCAs:       server.RootCAs,
+	}}
+	_, err := executor.Execute(context.Background(), emailNode(map[string]interface{}{
+		"to":        "anna@example.com",
+		"subject":   "Welcome",
+		"html":      "<p>Hello</p>",
+		"from":      "ceo@bank.example.org",
+		"from_name": "Acme Sales",
+		"reply_to":  "sales@acme.example.com",
+	}), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 || !messages[0].TLS || messages[0].From != "noreply@example.com" {
+		t.Fatalf("messages = %+v", messages)
+	}
+	// the relay never sends from the node's address, in the envelope or the headers
+	msg, _ := parseMessage(t, messages[0].Data)
+	if got := msg.Header.Get("From"); got != `"Acme Sales" <noreply@example.com>` {
+		t.Errorf("From = %q", got)
+	}
+	if got := msg.Header.Get("Reply-To"); got != "<sales@acme.example.com>" {
+		t.Errorf("Reply-To = %q", got)
+	}
+	if strings.Contains(string(messages[0].Data), "bank.example.org") {
+		t.Errorf("relay message carries the node's from address:\n%s", messages[0].Data)
+	}
+}
+
+func TestEmailExecutorErrors(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{Username: "user", Password: "secret", RejectRecipients: []string{"gone@example.com"}})
+	defer server.Close()
+
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"connection_id": "conn-1",
+			"to":            "anna@example.com",
+			"subject":       "Hello",
+			"body":          "Hi",
+		}
+	}
+	tests := []struct {
+		name     string
+		edit     func(config map[string]interface{})
+		executor *EmailExecutor
+		wantErr  string
+	}{
+		{name: "no subject", edit: func(c map[string]interface{}) { delete(c, "subject") }, wantErr: "subject"},
+		{name: "no recipient", edit: func(c map[string]interface{}) { c["to"] = "" }, wantErr: "to, subject"},
+		{name: "invalid address", edit: func(c map[string]interface{}) { c["to"] = "not an address" }, wantErr: "invalid address"},
+		{name: "header injection", edit: func(c map[string]interface{}) {
+			c["headers"] = map[string]interface{}{"X-Note": "{{note}}"}
+		}, wantErr: "line breaks"},
+		{name: "reserved header", edit: func(c map[string]interface{}) {
+			c["headers"] = map[string]interface{}{"Bcc": "spy@example.com"}
+		}, wantErr: "cannot be overridden"},
+		{name: "rejected recipient", edit: func(c map[string]interface{}) { c["to"] = "gone@example.com" }, wantErr: "recipient gone@example.com rejected"},
+		{name: "egress blocks the server", executor: &EmailExecutor{Connections: smtpConnection(server)}, wantErr: "egress policy"},
+		{name: "no relay without connection", executor: &EmailExecutor{}, edit: func(c map[string]interface{}) { delete(c, "connection_id") }, wantErr: "no platform smtp relay"},
+		{name: "tracking without tracker", edit: func(c map[string]interface{}) { c["track_opens"] = true }, wantErr: "tracking is not configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := tt.executor
+			if executor == nil {
+				executor = &EmailExecutor{Connections: smtpConnection(server), Egress: localEgress(t)}
+			}
+			config := base()
+			if tt.edit != nil {
+				tt.edit(config)
+			}
+			_, err := executor.Execute(context.Background(), emailNode(config), map[string]interface{}{
+				"note": "hi\r\nBcc: spy@example.com",
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Execute() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+	if n := len(server.Messages()); n != 0 {
+		t.Errorf("server accepted %d messages", n)
+	}
+}
+
+// recordingTracker registers tracked emails in memory
+type recordingTracker struct {
+	registered []TrackedEmail
+}
+
+func (r *recordingTracker) Register(ctx context.Context, email TrackedEmail) error {
+	r.registered = append(r.registered, email)
+	return nil
+}
+
+func (r *recordingTracker) OpenURL(id string) string {
+	return "https://t.example.com/o/" + id + ".gif"
+}
+
+func (r *recordingTracker) ClickURL(id, target string) string {
+	return "https://t.example.com/c/" + id + "?u=" + target
+}
+
+func (r *recordingTracker) UnsubscribeURL(id string) string {
+	return "https://t.example.com/u/" + id
+}
+
+func TestEmailExecutorTracksEachRecipient(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{Username: "user", Password: "secret"})
+	defer server.Close()
+
+	tracker := &recordingTracker{}
+	executor := &EmailExecutor{Connections: smtpConnection(server), Egress: localEgress(t), Tracker: tracker}
+	output, err := executor.Execute(context.Background(), emailNode(map[string]interface{}{
+		"connection_id": "conn-1",
+		"to":            "anna@example.com, Boss <boss@example.com>",
+		"cc":            "ANNA@example.com",
+		"bcc":           "audit@example.com",
+		"subject":       "Offer",
+		"html":          `<p>See <a href="https://shop.example.com/offer">the offer</a></p><p><a href="{{unsubscribe_url}}">Unsubscribe</a></p>`,
+		"track_opens":   true,
+		"track_clicks":  true,
+	}), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	recipients := []string{"anna@example.com", "boss@example.com", "audit@example.com"}
+	if len(tracker.registered) != len(recipients) {
+		t.Fatalf("registered %d tracked copies, want %d", len(tracker.registered), len(recipients))
+	}
+	messages := server.Messages()
+	if len(messages) != len(recipients) {
+		t.Fatalf("server got %d messages, want %d", len(messages), len(recipients))
+	}
+
+	ids := output["tracking_ids"].(map[string]interface{})
+	seen := map[string]bool{}
+	seenMessageIDs := map[string]bool{}
+	for i, recipient := range recipients {
+		tracked := tracker.registered[i]
+		if !slices.Equal(tracked.Recipients, []string{recipient}) {
+			t.Errorf("copy %d registered for %v, want only %s", i, tracked.Recipients, recipient)
+		}
+		if seen[tracked.ID] {
+			t.Errorf("tracking ID %s reused", tracked.ID)
+		}
+		seen[tracked.ID] = true
+		if ids[recipient] != tracked.ID {
+			t.Errorf("tracking_ids[%s] = %v, want %s", recipient, ids[recipient], tracked.ID)
+		}
+
+		sent := messages[i]
+		if !slices.Equal(sent.To, []string{recipient}) {
+			t.Errorf("copy %d delivered to %v, want only %s", i, sent.To, recipient)
+		}
+		msg, parts := parseMessage(t, sent.Data)
+		if msg.Header.Get("Bcc") != "" || strings.Contains(msg.Header.Get("To")+msg.Header.Get("Cc"), "audit@example.com") {
+			t.Errorf("copy for %s reveals the Bcc recipient", recipient)
+		}
+		unsubscribe := "https://t.example.com/u/" + tracked.ID
+		if msg.Header.Get("List-Unsubscribe") != "<"+unsubscribe+">" {
+			t.Errorf("copy for %s: List-Unsubscribe = %q", recipient, msg.Header.Get("List-Unsubscribe"))
+		}
+		htmlBody := parts[len(parts)-1].body
+		for _, want := range []string{
+			`href="` + unsubscribe + `"`,
+			"https://t.example.com/c/" + tracked.ID + "?u=https://shop.example.com/offer",
+			"https://t.example.com/o/" + tracked.ID + ".gif",
+		} {
+			if !strings.Contains(htmlBody, want) {
+				t.Errorf("copy for %s lacks %s:\n%s", recipient, want, htmlBody)
+			}
+		}
+		for other := range seen {
+			if other != tracked.ID && strings.Contains(string(sent.Data), other) {
+				t.Errorf("copy for %s carries another recipient's tracking ID", recipient)
+			}
+		}
+		// every copy is a message of its own
+		if got := msg.Header.Get("Message-Id"); got != tracked.MessageID || seenMessageIDs[got] {
+			t.Errorf("copy for %s: Message-ID %q, registered %q, seen before %v", recipient, got, tracked.MessageID, seenMessageIDs[got])
+		}
+		seenMessageIDs[msg.Header.Get("Message-Id")] = true
+	}
+	if output["tracking_id"] != tracker.registered[0].ID || output["message_id"] != tracker.registered[0].MessageID {
+		t.Errorf("output = %v", output)
+	}
+}
+
+func TestEmailExecutorSendsUntrackedEmailOnce(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{Username: "user", Password: "secret"})
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   string
+	}{
+		{name: "mailto sender", config: map[string]interface{}{}, want: "<mailto:sales@example.com?subject=unsubscribe>"},
+		{name: "mailto reply-to", config: map[string]interface{}{"reply_to": "Desk <desk@example.com>"}, want: "<mailto:desk@example.com?subject=unsubscribe>"},
+		{name: "own header", config: map[string]interface{}{"headers": map[string]interface{}{"List-Unsubscribe": "<https://example.com/u>"}}, want: "<https://example.com/u>"},
+		{name: "turned off", config: map[string]interface{}{"list_unsubscribe": false}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &recordingTracker{}
+			executor := &EmailExecutor{Connections: smtpConnection(server), Egress: localEgress(t), Tracker: tracker}
+			config := map[string]interface{}{
+				"connection_id": "conn-1",
+				"to":            "anna@example.com, boss@example.com",
+				"bcc":           "audit@example.com",
+				"subject":       "Newsletter",
+				"html":          "<p>News</p>",
+			}
+			for key, value := range tt.config {
+				config[key] = value
+			}
+			before := len(server.Messages())
+			output, err := executor.Execute(context.Background(), emailNode(config), map[string]interface{}{})
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+
+			// a tracker alone does not split the email or track it
+			messages := server.Messages()[before:]
+			if len(messages) != 1 || len(messages[0].To) != 3 {
+				t.Fatalf("messages = %+v, want one to all three recipients", messages)
+			}
+			if len(tracker.registered) != 0 || output["tracking_id"] != "" {
+				t.Errorf("untracked email was registered: %v, output %v", tracker.registered, output)
+			}
+			msg, _ := parseMessage(t, messages[0].Data)
+			if got := msg.Header.Get("List-Unsubscribe"); got != tt.want {
+				t.Errorf("List-Unsubscribe = %q, want %q", got, tt.want)
+			}
+			if got := msg.Header.Get("List-Unsubscribe-Post"); got != "" {
+				t.Errorf("List-Unsubscribe-Post = %q without a one-click link", got)
+			}
+		})
+	}
+}
+
+func TestEmailExecutorTrackedSendFailure(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{Username: "user", Password: "secret", RejectRecipients: []string{"gone@example.com"}})
+	defer server.Close()
+
+	tracker := &recordingTracker{}
+	var log []string
+	ctx := WithRunInfo(context.Background(), &RunInfo{Log: func(line string) { log = append(log, line) }})
+	executor := &EmailExecutor{Connections: smtpConnection(server), Egress: localEgress(t), Tracker: tracker}
+	_, err := executor.Execute(ctx, emailNode(map[string]interface{}{
+		"connection_id": "conn-1",
+		"to":            "anna@example.com, gone@example.com, boss@example.com",
+		"subject":       "Offer",
+		"html":          "<p>Offer</p>",
+		"track_opens":   true,
+	}), map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "to gone@example.com after 1 of 3 recipients") {
+		t.Fatalf("Execute() = %v", err)
+	}
+
+	// only the copy that went out is tracked, and the log names who got it
+	if len(tracker.registered) != 1 || !slices.Equal(tracker.registered[0].Recipients, []string{"anna@example.com"}) {
+		t.Errorf("registered = %+v, want only anna's copy", tracker.registered)
+	}
+	if len(server.Messages()) != 1 {
+		t.Errorf("server got %d messages, want 1", len(server.Messages()))
+	}
+	if len(log) != 1 || !strings.HasSuffix(log[0], "email: sent to anna@example.com before the failure") {
+		t.Errorf("log = %q", log)
+	}
+}
+
+// staticSuppressions suppresses a fixed set of addresses per channel
+type staticSuppressions struct {
+	channel   string
+	addresses []string
+	err       error
+	// userID is the user of the last check
+	userID string
+}
+
+func (s *staticSuppressions) Suppressed(ctx context.Context, userID, channel string, addresses []string) ([]string, error) {
+	s.userID = userID
+	if s.err != nil {
+		return nil, s.err
+	}
+	var found []string
+	for _, address := range addresses {
+		if channel == s.channel && slices.ContainsFunc(s.addresses, func(s string) bool { return strings.EqualFold(s, address) }) {
+			found = append(found, address)
+		}
+	}
+	return found, nil
+}
+
+func TestEmailExecutorSuppression(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{Username: "user", Password: "secret"})
+	defer server.Close()
+
+	suppressions := &staticSuppressions{channel: "email", addresses: []string{"anna@example.com", "boss@example.com", "audit@example.com"}}
+	tests := []struct {
+		name           string
+		to, cc, bcc    string
+		wantSent       bool
+		wantEnvelope   []string
+		wantTo, wantCc string
+		wantSuppressed []string
+	}{
+		{
+			name: "every recipient suppressed", to: "Anna@example.com", cc: "boss@example.com", bcc: "audit@example.com",
+			wantSuppressed: []string{"Anna@example.com", "boss@example.com", "audit@example.com"},
+		},
+		{
+			name: "some To recipients suppressed", to: "anna@example.com, carl@example.com", cc: "dora@example.com",
+			wantSent: true, wantEnvelope: []string{"carl@example.com", "dora@example.com"},
+			wantTo: "<carl@example.com>", wantCc: "<dora@example.com>", wantSuppressed: []string{"anna@example.com"},
+		},
+		{
+			name: "every To recipient suppressed but Cc", to: "anna@example.com", cc: "dora@example.com",
+			wantSent: true, wantEnvelope: []string{"dora@example.com"},
+			wantCc: "<dora@example.com>", wantSuppressed: []string{"anna@example.com"},
+		},
+		{
+			name: "only Bcc left", to: "anna@example.com", cc: "boss@example.com", bcc: "erik@example.com",
+			wantSent: true, wantEnvelope: []string{"erik@example.com"},
+			wantTo: "undisclosed-recipients:;", wantSuppressed: []string{"anna@example.com", "boss@example.com"},
+		},
+		{
+			name: "nobody suppressed", to: "carl@example.com",
+			wantSent: true, wantEnvelope: []string{"carl@example.com"}, wantTo: "<carl@example.com>", wantSuppressed: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			ctx := WithRunInfo(context.Background(), &RunInfo{UserID: "user-1", Log: func(line string) { log = append(log, line) }})
+			executor := &EmailExecutor{Connections: smtpConnection(server), Egress: localEgress(t), Suppressions: suppressions}
+			before := len(server.Messages())
+			output, err := executor.Execute(ctx, emailNode(map[string]interface{}{
+				"connection_id": "conn-1",
+				"to":            tt.to,
+				"cc":            tt.cc,
+				"bcc":           tt.bcc,
+				"subject":       "Hello",
+				"body":          "Hi",
+			}), map[string]interface{}{})
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if suppressions.userID != "user-1" {
+				t.Errorf("checked the list of %q", suppressions.userID)
+			}
+			if got, _ := output["suppressed"].([]string); !slices.Equal(got, tt.wantSuppressed) {
+				t.Errorf("suppressed = %v, want %v", output["suppressed"], tt.wantSuppressed)
+			}
+
+			messages := server.Messages()[before:]
+			if !tt.wantSent {
+				if len(messages) != 0 || output["email_sent"] != false || output["skip_reason"] != "suppressed" {
+					t.Errorf("messages %d, output %v; want the send skipped", len(messages), output)
+				}
+				if len(log) != 1 || !strings.Contains(log[0], "message not sent") {
+					t.Errorf("log = %q", log)
+				}
+				return
+			}
+			if len(messages) != 1 || output["email_sent"] != true {
+				t.Fatalf("messages %d, output %v; want one message", len(messages), output)
+			}
+			if !slices.Equal(messages[0].To, tt.wantEnvelope) {
+				t.Errorf("envelope recipients %v, want %v", messages[0].To, tt.wantEnvelope)
+			}
+			msg, _ := parseMessage(t, messages[0].Data)
+			if msg.Header.Get("To") != tt.wantTo || msg.Header.Get("Cc") != tt.wantCc {
+				t.Errorf("To %q, Cc %q; want %q, %q", msg.Header.Get("To"), msg.Header.Get("Cc"), tt.wantTo, tt.wantCc)
+			}
+			for _, address := range suppressions.addresses {
+				if strings.Contains(strings.ToLower(string(messages[0].Data)), address) {
+					t.Errorf("message mentions suppressed %s", address)
+				}
+			}
+		})
+	}
+}
+
+func TestEmailExecutorSuppressionErrors(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{Username: "user", Password: "secret"})
+	defer server.Close()
+
+	config := map[string]interface{}{"connection_id": "conn-1", "to": "anna@example.com", "subject": "Hello", "body": "Hi"}
+
+	// a failing list stops the send rather than mailing people who opted out
+	failing := &staticSuppressions{err: errors.New("database is down")}
+	executor := &EmailExecutor{Connections: smtpConnection(server), Egress: localEgress(t), Suppressions: failing}
+	ctx := WithRunInfo(context.Background(), &RunInfo{UserID: "user-1"})
+	if _, err := executor.Execute(ctx, emailNode(config), map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), "suppression list") {
+		t.Errorf("Execute() = %v, want a suppression list error", err)
+	}
+	if len(server.Messages()) != 0 {
+		t.Errorf("server got %d messages", len(server.Messages()))
+	}
+
+	// runs without an owner, e.g. outside a workflow, have no list to check
+	executor.Suppressions = &staticSuppressions{channel: "email", addresses: []string{"anna@example.com"}}
+	if _, err := executor.Execute(context.Background(), emailNode(config), map[string]interface{}{}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(server.Messages()) != 1 {
+		t.Errorf("server got %d messages, want 1", len(server.Messages()))
+	}
+}
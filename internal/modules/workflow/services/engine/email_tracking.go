@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// TrackedEmail is one recipient's copy of an outgoing email, registered
+// for open, click and unsubscribe tracking
+type TrackedEmail struct {
+	// ID is the tracking ID the links are signed with
+	ID        string
+	NodeID    string
+	MessageID string
+	Subject   string
+	// Recipients holds the single recipient of this copy
+	Recipients []string
+}
+
+// EmailTracker registers tracked emails and builds their signed tracking links
+type EmailTracker interface {
+	// Register stores the email for the current execution; links work once it returns
+	Register(ctx context.Context, email TrackedEmail) error
+	OpenURL(id string) string
+	ClickURL(id, target string) string
+	UnsubscribeURL(id string) string
+}
+
+var (
+	trackedLinkRe = regexp.MustCompile(`(?is)(<a\b[^>]*?\bhref\s*=\s*)("([^"]*)"|'([^']*)')`)
+	bodyCloseRe   = regexp.MustCompile(`(?i)</body\s*>`)
+)
+
+// trackLinks rewrites the http(s) links of an HTML body through the click
+// redirect. The unsubscribe link is left alone so it keeps working even
+// when the redirect is blocked.
+func trackLinks(body, id string, tracker EmailTracker) string {
+	unsubscribe := tracker.UnsubscribeURL(id)
+	return trackedLinkRe.ReplaceAllStringFunc(body, func(tag string) string {
+		parts := trackedLinkRe.FindStringSubmatch(tag)
+		raw := parts[3] + parts[4]
+		target := strings.TrimSpace(html.UnescapeString(raw))
+		lower := strings.ToLower(target)
+		if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") || target == unsubscribe {
+			return tag
+		}
+		return parts[1] + `"` + html.EscapeString(tracker.ClickURL(id, target)) + `"`
+	})
+}
+
+// addOpenPixel appends a 1x1 tracking image to an HTML body, inside <body> when present
+func addOpenPixel(body, id string, tracker EmailTracker) string {
+	pixel := `<img src="` + html.EscapeString(tracker.OpenURL(id)) + `" width="1" height="1" alt="" style="display:block;border:0;width:1px;height:1px">`
+	if loc := bodyCloseRe.FindAllStringIndex(body, -1); len(loc) > 0 {
+		at := loc[len(loc)-1][0]
+		return body[:at] + pixel + body[at:]
+	}
+	return body + pixel
+}
+
+// EmailEventTriggerExecutor is the email_event trigger node. The tracking
+// service places the open, click or unsubscribe event in the execution data
+// before the graph starts, so the node passes it through.
+type EmailEventTriggerExecutor struct{}
+
+func (e *EmailEventTriggerExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	return input, nil
+}
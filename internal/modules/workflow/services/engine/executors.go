@@ -1,13 +1,14 @@
 package engine
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/smtp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -30,8 +31,11 @@ type NodeExecutor interface {
 	Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error)
 }
 
-// HTTPRequestExecutor executes HTTP requests
-type HTTPRequestExecutor struct{}
+// HTTPRequestExecutor executes HTTP requests; the url, header values and
+// strings in the JSON body are templates
+type HTTPRequestExecutor struct {
+	Egress *EgressPolicy
+}
 
 func (h *HTTPRequestExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
 	config, ok := node.Data["config"].(map[string]interface{})
@@ -53,9 +57,14 @@ func (h *HTTPRequestExecutor) Execute(ctx context.Context, node *Node, input map
 
 	url = replaceVariables(url, input)
 
+	egress := h.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+
 	var bodyReader io.Reader
 	if body != nil {
-		bodyJSON, _ := json.Marshal(body)
+		bodyJSON, _ := json.Marshal(replaceVariablesDeep(body, input))
 		bodyReader = strings.NewReader(string(bodyJSON))
 	}
 
@@ -66,7 +75,7 @@ func (h *HTTPRequestExecutor) Execute(ctx context.Context, node *Node, input map
 
 	for key, value := range headers {
 		if strValue, ok := value.(string); ok {
-			req.Header.Set(key, strValue)
+			req.Header.Set(key, replaceVariables(strValue, input))
 		}
 	}
 
@@ -74,7 +83,11 @@ func (h *HTTPRequestExecutor) Execute(ctx context.Context, node *Node, input map
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	if err := egress.CheckURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	client := egress.HTTPClient(30 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -103,59 +116,6 @@ func (h *HTTPRequestExecutor) Execute(ctx context.Context, node *Node, input map
 	}, nil
 }
 
-// EmailExecutor sends emails
-type EmailExecutor struct{}
-
-func (e *EmailExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
-	config, ok := node.Data["config"].(map[string]interface{})
-	if !ok {
-		return nil, errors.New("invalid email configuration")
-	}
-
-	to, _ := config["to"].(string)
-	subject, _ := config["subject"].(string)
-	body, _ := config["body"].(string)
-	from, _ := config["from"].(string)
-	smtpHost, _ := config["smtp_host"].(string)
-	smtpPort, _ := config["smtp_port"].(string)
-	smtpUser, _ := config["smtp_user"].(string)
-	smtpPass, _ := config["smtp_pass"].(string)
-
-	if to == "" || subject == "" || body == "" {
-		return nil, errors.New("to, subject, and body are required")
-	}
-
-	to = replaceVariables(to, input)
-	subject = replaceVariables(subject, input)
-	body = replaceVariables(body, input)
-
-	if smtpHost == "" {
-		smtpHost = "smtp.gmail.com"
-	}
-	if smtpPort == "" {
-		smtpPort = "587"
-	}
-	if from == "" {
-		from = smtpUser
-	}
-
-	message := []byte(fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", to, subject, body))
-
-	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
-	addr := fmt.Sprintf("%s:%s", smtpHost, smtpPort)
-
-	err := smtp.SendMail(addr, auth, from, []string{to}, message)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send email: %w", err)
-	}
-
-	return map[string]interface{}{
-		"email_sent": true,
-		"to":         to,
-		"subject":    subject,
-	}, nil
-}
-
 // WebhookExecutor handles webhook triggers
 type WebhookExecutor struct{}
 
@@ -187,7 +147,8 @@ func (d *DelayExecutor) Execute(ctx context.Context, node *Node, input map[strin
 	}
 }
 
-// IfExecutor handles conditional logic
+// IfExecutor handles conditional logic. Edges leaving from the "true" or
+// "false" handle only run for that result.
 type IfExecutor struct{}
 
 func (i *IfExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
@@ -201,34 +162,216 @@ func (i *IfExecutor) Execute(ctx context.Context, node *Node, input map[string]i
 
 	return map[string]interface{}{
 		"condition_result": result,
+		BranchesKey:        map[string]map[string]interface{}{strconv.FormatBool(result): {}},
 	}, nil
 }
 
+// ScheduleTriggerExecutor is the schedule trigger node. The scheduler starts
+// the run with the fire time in the execution data, so the node passes it
+// through like a webhook trigger.
+type ScheduleTriggerExecutor struct{}
+
+func (s *ScheduleTriggerExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	return input, nil
+}
+
 // Helper functions
+
+// replaceVariables substitutes {{key}} with the value of key in data (a
+// dotted path such as {{body.email}} reads nested values) and
+// {{function(...)}} with the result of an expression function. Only the
+// template itself is scanned, so substituted values are never evaluated.
+// Unknown variables and failing expressions are left as written.
 func replaceVariables(text string, data map[string]interface{}) string {
-	result := text
-	for key, value := range data {
-		placeholder := fmt.Sprintf("{{%s}}", key)
-		if strValue, ok := value.(string); ok {
-			result = strings.ReplaceAll(result, placeholder, strValue)
-		} else {
-			result = strings.ReplaceAll(result, placeholder, fmt.Sprintf("%v", value))
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start+2:], "}}")
+		if end < 0 {
+			break
+		}
+		body := text[start+2 : start+2+end]
+		placeholder := text[start : start+4+end]
+		b.WriteString(text[:start])
+		text = text[start+4+end:]
+
+		if value, ok := dataValue(data, body); ok {
+			if strValue, ok := value.(string); ok {
+				b.WriteString(strValue)
+			} else {
+				b.WriteString(fmt.Sprintf("%v", value))
+			}
+			continue
 		}
+		if isExpression(body) {
+			if value, err := evaluateExpression(body, data); err == nil {
+				b.WriteString(formatTemplateValue(value))
+				continue
+			}
+		}
+		b.WriteString(placeholder)
 	}
-	return result
+	b.WriteString(text)
+	return b.String()
 }
 
+// dataValue reads key from data, or the nested value at key when it is a
+// dotted path
+func dataValue(data map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := data[key]; ok {
+		return value, true
+	}
+	if !strings.Contains(key, ".") {
+		return nil, false
+	}
+	return lookupPath(data, key)
+}
+
+// stringValue formats a JSON config value as a string; whole numbers are
+// printed without exponent so IDs like chat ids survive float64 decoding
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// numericID sends numeric ids as JSON numbers and keeps anything else
+// (e.g. "@channel" usernames) as a string, for APIs that are strict about types
+func numericID(id string) interface{} {
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return n
+	}
+	return id
+}
+
+// replaceVariablesDeep applies replaceVariables to every string inside
+// nested maps and slices, e.g. Slack blocks or request bodies
+func replaceVariablesDeep(value interface{}, data map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return replaceVariables(v, data)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = replaceVariablesDeep(item, data)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = replaceVariablesDeep(item, data)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// conditionOperators are the comparisons of if conditions, two-character
+// operators first so that ">=" is not read as ">"
+var conditionOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// evaluateCondition compares "left <op> right", where op is ==, !=, >, >=, <
+// or <=, left names a data value (a dotted path for nested values) and right
+// is a literal; either side may also be an expression function, e.g.
+// is_business_hours(now(), "Europe/Berlin") == true. Ordering compares
+// numbers when both sides are numeric and strings otherwise, so ISO dates
+// order correctly.
 func evaluateCondition(condition string, data map[string]interface{}) bool {
-	if strings.Contains(condition, "==") {
-		parts := strings.Split(condition, "==")
-		if len(parts) == 2 {
-			left := strings.TrimSpace(parts[0])
-			right := strings.TrimSpace(parts[1])
-
-			leftValue := data[left]
-			return fmt.Sprintf("%v", leftValue) == right
+	left, operator, right, ok := splitCondition(condition)
+	if !ok {
+		return false
+	}
+
+	leftValue, _ := dataValue(data, left)
+	if isExpression(left) {
+		value, err := evaluateExpression(left, data)
+		if err != nil {
+			return false
+		}
+		leftValue = formatTemplateValue(value)
+	}
+	if isExpression(right) {
+		value, err := evaluateExpression(right, data)
+		if err != nil {
+			return false
 		}
+		right = formatTemplateValue(value)
 	}
+	leftText := fmt.Sprintf("%v", leftValue)
 
-	return false
+	switch operator {
+	case "==":
+		return leftText == right
+	case "!=":
+		return leftText != right
+	}
+	var order int
+	leftNumber, leftErr := strconv.ParseFloat(leftText, 64)
+	rightNumber, rightErr := strconv.ParseFloat(right, 64)
+	if leftErr == nil && rightErr == nil {
+		order = cmp.Compare(leftNumber, rightNumber)
+	} else {
+		order = strings.Compare(leftText, right)
+	}
+	switch operator {
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	case "<":
+		return order < 0
+	default:
+		return order <= 0
+	}
+}
+
+// splitCondition splits condition at its first comparison operator outside
+// quotes and parentheses
+func splitCondition(condition string) (string, string, string, bool) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(condition); i++ {
+		ch := condition[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+			continue
+		case ch == '"' || ch == '\'':
+			quote = ch
+			continue
+		case ch == '(':
+			depth++
+			continue
+		case ch == ')':
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+		for _, operator := range conditionOperators {
+			if strings.HasPrefix(condition[i:], operator) {
+				left := strings.TrimSpace(condition[:i])
+				right := strings.TrimSpace(condition[i+len(operator):])
+				return left, operator, right, left != ""
+			}
+		}
+	}
+	return "", "", "", false
 }
@@ -0,0 +1,55 @@
+package engine
+
+import "testing"
+
+func TestEvaluateCondition(t *testing.T) {
+	data := map[string]interface{}{
+		"status":     "won",
+		"score":      75.0,
+		"count":      "9",
+		"created_at": "2026-03-05T14:07:09Z",
+		"lead":       map[string]interface{}{"email": "anna@example.com", "vip": true},
+	}
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{"status == won", true},
+		{"status == lost", false},
+		{"status != lost", true},
+		{"status != won", false},
+		{"lead.email == anna@example.com", true},
+		{"lead.vip == true", true},
+		{"missing == ", false},
+		// numbers compare numerically, not as text
+		{"score > 50", true},
+		{"score > 100", false},
+		{"score >= 75", true},
+		{"score <= 74.5", false},
+		{"count < 10", true},
+		{"score == 75", true},
+		// other values compare as text, which orders ISO dates
+		{"created_at > 2026-01-01", true},
+		{"created_at < 2026-03-05T14:07:09Z", false},
+		{"status >= won", true},
+		// expression functions on either side
+		{`weekday(created_at) == thursday`, true},
+		{`format_date(created_at, "YYYY") >= 2026`, true},
+		{`status == format_date(created_at, "YYYY")`, false},
+		{`format_date(created_at, "a>b") != x`, true},
+		// malformed conditions are false
+		{"", false},
+		{"status", false},
+		{"== won", false},
+		{`weekday(created_at, "Nowhere/Nothing") == thursday`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			if got := evaluateCondition(tt.condition, data); got != tt.want {
+				t.Errorf("evaluateCondition(%q) = %v, want %v", tt.condition, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxExpressionDepth bounds nested function calls in a template expression
+const maxExpressionDepth = 16
+
+// expressionFunction implements a function callable from templates, e.g.
+// {{format_date(created_at, "DD.MM.YYYY")}}
+type expressionFunction func(args []interface{}) (interface{}, error)
+
+// expressionFunctions are the functions available inside {{ }}. Arguments
+// are string or number literals, true/false/null, nested calls, or names of
+// execution data values (dotted paths such as lead.created_at).
+var expressionFunctions = map[string]expressionFunction{
+	"now":                exprNow,
+	"today":              exprToday,
+	"parse_date":         exprParseDate,
+	"format_date":        exprFormatDate,
+	"to_timezone":        exprToTimezone,
+	"date_add":           exprDateAdd,
+	"date_subtract":      exprDateSubtract,
+	"date_diff":          exprDateDiff,
+	"start_of":           exprStartOf,
+	"end_of":             exprEndOf,
+	"weekday":            exprWeekday,
+	"is_business_hours":  exprIsBusinessHours,
+	"next_business_slot": exprNextBusinessSlot,
+}
+
+// expressionNow is the clock used by expression functions
+var expressionNow = time.Now
+
+// isExpression reports whether a {{ }} body is a function call rather than a variable name
+func isExpression(body string) bool {
+	body = strings.TrimSpace(body)
+	open := strings.IndexByte(body, '(')
+	if open <= 0 || !strings.HasSuffix(body, ")") {
+		return false
+	}
+	_, known := expressionFunctions[strings.TrimSpace(body[:open])]
+	return known
+}
+
+// evaluateExpression evaluates a function call such as
+// date_add(now("Europe/Moscow"), "1d")
+func evaluateExpression(body string, data map[string]interface{}) (interface{}, error) {
+	p := &expressionParser{src: body, data: data}
+	p.skipSpace()
+	value, err := p.value(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q in expression", p.src[p.pos:])
+	}
+	return value, nil
+}
+
+type expressionParser struct {
+	src  string
+	pos  int
+	data map[string]interface{}
+}
+
+func (p *expressionParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *expressionParser) value(depth int) (interface{}, error) {
+	if depth > maxExpressionDepth {
+		return nil, errors.New("expression is nested too deeply")
+	}
+	if p.pos >= len(p.src) {
+		return nil, errors.New("unexpected end of expression")
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '"' || c == '\'':
+		end := strings.IndexByte(p.src[p.pos+1:], c)
+		if end < 0 {
+			return nil, errors.New("unterminated string in expression")
+		}
+		s := p.src[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return s, nil
+
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q in expression", p.src[start:p.pos])
+		}
+		return n, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '(' || c == ')' || c == ',' || c == ' ' || c == '\t' {
+			break
+		}
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "" {
+		return nil, fmt.Errorf("unexpected %q in expression", p.src[p.pos:])
+	}
+
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '(' {
+		switch name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if v, ok := p.data[name]; ok {
+			return v, nil
+		}
+		v, _ := lookupPath(p.data, name)
+		return v, nil
+	}
+
+	fn, ok := expressionFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s()", name)
+	}
+	p.pos++ // (
+	var args []interface{}
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == ')' {
+		p.pos++
+	} else {
+		for {
+			p.skipSpace()
+			arg, err := p.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			p.skipSpace()
+			if p.pos >= len(p.src) {
+				return nil, fmt.Errorf("missing ) after %s arguments", name)
+			}
+			if p.src[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			if p.src[p.pos] != ',' {
+				return nil, fmt.Errorf("expected , or ) in %s()", name)
+			}
+			p.pos++
+		}
+	}
+
+	result, err := fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", name, err)
+	}
+	return result, nil
+}
+
+// formatTemplateValue renders a value substituted into a template
+func formatTemplateValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if f, ok := value.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func stringArg(args []interface{}, i int) string {
+	if i < len(args) {
+		return stringValue(args[i])
+	}
+	return ""
+}
+
+// timeArg reads a date argument; dates without an offset are read in tz
+func timeArg(args []interface{}, i int, tz string) (time.Time, error) {
+	if i >= len(args) {
+		return time.Time{}, errors.New("date argument is required")
+	}
+	loc, err := loadLocation(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := parseTime(args[i], "", loc)
+	if err != nil {
+		return t, err
+	}
+	if tz != "" {
+		t = t.In(loc)
+	}
+	return t, nil
+}
+
+func exprNow(args []interface{}) (interface{}, error) {
+	loc, err := loadLocation(stringArg(args, 0))
+	if err != nil {
+		return nil, err
+	}
+	return expressionNow().In(loc).Format(time.RFC3339), nil
+}
+
+func exprToday(args []interface{}) (interface{}, error) {
+	loc, err := loadLocation(stringArg(args, 0))
+	if err != nil {
+		return nil, err
+	}
+	return expressionNow().In(loc).Format("2006-01-02"), nil
+}
+
+// parse_date(value, [format], [timezone])
+func exprParseDate(args []interface{}) (interface{}, error) {
+	loc, err := loadLocation(stringArg(args, 2))
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("date argument is required")
+	}
+	t, err := parseTime(args[0], stringArg(args, 1), loc)
+	if err != nil {
+		return nil, err
+	}
+	return t.In(loc).Format(time.RFC3339), nil
+}
+
+// format_date(value, format, [timezone])
+func exprFormatDate(args []interface{}) (interface{}, error) {
+	t, err := timeArg(args, 0, stringArg(args, 2))
+	if err != nil {
+		return nil, err
+	}
+	return formatTime(t, stringArg(args, 1)), nil
+}
+
+// to_timezone(value, timezone)
+func exprToTimezone(args []interface{}) (interface{}, error) {
+	t, err := timeArg(args, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadLocation(stringArg(args, 1))
+	if err != nil {
+		return nil, err
+	}
+	return t.In(loc).Format(time.RFC3339), nil
+}
+
+// date_add(value, duration, [timezone]); the timezone decides where day
+// boundaries fall when adding days across DST changes
+func exprDateAdd(args []interface{}) (interface{}, error) {
+	t, err := timeArg(args, 0, stringArg(args, 2))
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseDuration(stringArg(args, 1))
+	if err != nil {
+		return nil, err
+	}
+	return d.addTo(t).Format(time.RFC3339), nil
+}
+
+func exprDateSubtract(args []interface{}) (interface{}, error) {
+	duration := strings.TrimSpace(stringArg(args, 1))
+	if strings.HasPrefix(duration, "-") {
+		duration = duration[1:]
+	} else {
+		duration = "-" + duration
+	}
+	return exprDateAdd(append([]interface{}{argOrNil(args, 0), duration}, args[min(2, len(args)):]...))
+}
+
+func argOrNil(args []interface{}, i int) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// date_diff(from, to, [unit]) is to - from in unit (default seconds)
+func exprDateDiff(args []interface{}) (interface{}, error) {
+	from, err := timeArg(args, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	to, err := timeArg(args, 1, "")
+	if err != nil {
+		return nil, err
+	}
+	return dateDiff(from, to, stringArg(args, 2))
+}
+
+// start_of(value, unit, [timezone])
+func exprStartOf(args []interface{}) (interface{}, error) {
+	t, err := timeArg(args, 0, stringArg(args, 2))
+	if err != nil {
+		return nil, err
+	}
+	start, err := startOf(t, stringArg(args, 1))
+	if err != nil {
+		return nil, err
+	}
+	return start.Format(time.RFC3339), nil
+}
+
+func exprEndOf(args []interface{}) (interface{}, error) {
+	t, err := timeArg(args, 0, stringArg(args, 2))
+	if err != nil {
+		return nil, err
+	}
+	end, err := endOf(t, stringArg(args, 1))
+	if err != nil {
+		return nil, err
+	}
+	return end.Format(time.RFC3339), nil
+}
+
+// weekday(value, [timezone]) is the lowercase English day name
+func exprWeekday(args []interface{}) (interface{}, error) {
+	t, err := timeArg(args, 0, stringArg(args, 1))
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToLower(t.Weekday().String()), nil
+}
+
+// calendarArgs reads (value, [timezone], [hours], [days], [holidays]) as used
+// by is_business_hours and next_business_slot
+func calendarArgs(args []interface{}) (time.Time, *businessCalendar, error) {
+	timezone := stringArg(args, 1)
+	t, err := timeArg(args, 0, timezone)
+	if err != nil {
+		return t, nil, err
+	}
+	calendar, err := newBusinessCalendar(timezone, argOrNil(args, 3), argOrNil(args, 2), nil, argOrNil(args, 4))
+	return t, calendar, err
+}
+
+func exprIsBusinessHours(args []interface{}) (interface{}, error) {
+	t, calendar, err := calendarArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return calendar.isOpen(t), nil
+}
+
+func exprNextBusinessSlot(args []interface{}) (interface{}, error) {
+	t, calendar, err := calendarArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := calendar.nextSlot(t)
+	if err != nil {
+		return nil, err
+	}
+	return slot.Format(time.RFC3339), nil
+}
@@ -0,0 +1,358 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"s4s-backend/internal/pkg/dom"
+)
+
+// HTMLExtractExecutor pulls fields out of an HTML page or XML feed, by
+// default the body of a preceding http_request node. Each field is a CSS
+// selector or an XPath expression and yields the first match or, with
+// multiple, every match. With item_selector (or item_xpath) the fields are
+// extracted relative to each matching element and returned as items, e.g.
+// one item per lead card on a listing page.
+type HTMLExtractExecutor struct{}
+
+type extractField struct {
+	name      string
+	selector  dom.Selector
+	attribute string
+	output    string
+	multiple  bool
+}
+
+func (h *HTMLExtractExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid html extract configuration")
+	}
+
+	source, err := markupSource(config, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc *html.Node
+	switch format := stringValue(config["format"]); format {
+	case "", "html":
+		doc, err = dom.ParseHTML(strings.NewReader(source))
+	case "xml":
+		doc, err = dom.ParseXML(strings.NewReader(source))
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fieldConfig, _ := config["fields"].(map[string]interface{})
+	if len(fieldConfig) == 0 {
+		return nil, errors.New("fields are required")
+	}
+	fields := make([]extractField, 0, len(fieldConfig))
+	for name, spec := range fieldConfig {
+		field, err := compileExtractField(name, spec)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+
+	var base *url.URL
+	if raw := replaceVariables(stringValue(config["base_url"]), input); raw != "" {
+		if base, err = url.Parse(raw); err != nil {
+			return nil, fmt.Errorf("invalid base_url: %w", err)
+		}
+	}
+
+	itemSelector, err := compileSelector(stringValue(config["item_selector"]), stringValue(config["item_xpath"]))
+	if err != nil {
+		return nil, err
+	}
+	if itemSelector == nil {
+		output := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			output[field.name] = field.extract(doc, base)
+		}
+		return output, nil
+	}
+
+	containers := itemSelector.Select(doc)
+	maxItems := defaultSpreadsheetRows
+	if n, ok := config["max_items"].(float64); ok && n > 0 && int(n) < maxItems {
+		maxItems = int(n)
+	}
+	if len(containers) > maxItems {
+		containers = containers[:maxItems]
+	}
+
+	items := make([]map[string]interface{}, 0, len(containers))
+	for _, container := range containers {
+		item := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			item[field.name] = field.extract(container, base)
+		}
+		items = append(items, item)
+	}
+	Logf(ctx, "extracted %d items", len(items))
+	return map[string]interface{}{"items": items, "item_count": len(items)}, nil
+}
+
+// compileExtractField accepts a CSS selector string or an object with
+// selector or xpath plus attribute, output (text, html or outer_html) and multiple
+func compileExtractField(name string, spec interface{}) (extractField, error) {
+	field := extractField{name: name, output: "text"}
+	var css, xpath string
+	switch v := spec.(type) {
+	case string:
+		css = v
+	case map[string]interface{}:
+		css = stringValue(v["selector"])
+		xpath = stringValue(v["xpath"])
+		field.attribute = stringValue(v["attribute"])
+		field.multiple, _ = v["multiple"].(bool)
+		if output := stringValue(v["output"]); output != "" {
+			field.output = output
+		}
+	default:
+		return field, fmt.Errorf("field %s: expected a selector or an object", name)
+	}
+
+	switch field.output {
+	case "text", "html", "outer_html":
+	default:
+		return field, fmt.Errorf("field %s: unsupported output %q", name, field.output)
+	}
+
+	selector, err := compileSelector(css, xpath)
+	if err != nil {
+		return field, fmt.Errorf("field %s: %w", name, err)
+	}
+	if selector == nil {
+		return field, fmt.Errorf("field %s: selector or xpath is required", name)
+	}
+	field.selector = selector
+	return field, nil
+}
+
+func compileSelector(css, xpath string) (dom.Selector, error) {
+	switch {
+	case css != "" && xpath != "":
+		return nil, errors.New("use either a css selector or xpath, not both")
+	case css != "":
+		return dom.CompileCSS(css)
+	case xpath != "":
+		return dom.CompileXPath(xpath)
+	}
+	return nil, nil
+}
+
+// extract returns the first match as a string, or every match when the field
+// is multiple. Missing matches give "" or an empty list.
+func (f extractField) extract(context *html.Node, base *url.URL) interface{} {
+	matches := f.selector.Select(context)
+	values := []string{}
+	for _, n := range matches {
+		value, ok := f.value(n, base)
+		if !ok {
+			continue
+		}
+		values = append(values, value)
+		if !f.multiple {
+			break
+		}
+	}
+	if f.multiple {
+		return values
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (f extractField) value(n *html.Node, base *url.URL) (string, bool) {
+	if f.attribute != "" {
+		value, ok := dom.Attr(n, f.attribute)
+		if !ok {
+			return "", false
+		}
+		value = strings.TrimSpace(value)
+		if base != nil && isURLAttribute(f.attribute) {
+			if ref, err := url.Parse(value); err == nil {
+				value = base.ResolveReference(ref).String()
+			}
+		}
+		return value, true
+	}
+	switch f.output {
+	case "html":
+		return dom.InnerHTML(n), true
+	case "outer_html":
+		return dom.OuterHTML(n), true
+	default:
+		return dom.Text(n), true
+	}
+}
+
+func isURLAttribute(name string) bool {
+	switch strings.ToLower(name) {
+	case "href", "src", "action", "data-src", "data-href", "poster":
+		return true
+	}
+	return false
+}
+
+// XMLConvertExecutor converts an XML document to JSON data (to_json) or JSON
+// data to an XML document (to_xml). See dom.XMLToMap for the mapping.
+type XMLConvertExecutor struct{}
+
+func (x *XMLConvertExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid xml convert configuration")
+	}
+
+	operation := stringValue(config["operation"])
+	outputKey := stringValue(config["output_key"])
+
+	switch operation {
+	case "", "to_json":
+		source, err := markupSource(config, input)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := dom.ParseXML(strings.NewReader(source))
+		if err != nil {
+			return nil, err
+		}
+		data, err := dom.XMLToMap(doc, stringList(config["force_list"]))
+		if err != nil {
+			return nil, err
+		}
+		if outputKey == "" {
+			outputKey = "json"
+		}
+		return map[string]interface{}{outputKey: data}, nil
+
+	case "to_xml":
+		var value interface{}
+		if path := stringValue(config["source"]); path != "" {
+			found, ok := lookupPath(input, path)
+			if !ok {
+				return nil, fmt.Errorf("%s not found in execution data", path)
+			}
+			value = found
+		} else if v, ok := config["value"]; ok {
+			value = replaceVariablesDeep(v, input)
+		} else {
+			return nil, errors.New("source or value is required")
+		}
+		// normalize node output such as []map[string]interface{} to plain JSON types
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("value is not serializable: %w", err)
+		}
+		value = nil
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+
+		indent := true
+		if v, ok := config["indent"].(bool); ok {
+			indent = v
+		}
+		declaration := true
+		if v, ok := config["declaration"].(bool); ok {
+			declaration = v
+		}
+		document, err := dom.MapToXML(value, stringValue(config["root"]), indent, declaration)
+		if err != nil {
+			return nil, err
+		}
+		if outputKey == "" {
+			outputKey = "xml"
+		}
+		return map[string]interface{}{outputKey: string(document)}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown xml convert operation: %s", operation)
+	}
+}
+
+// markupSource returns the document to parse: the execution data value at
+// config "source" (a dotted path such as "http_response.body"), or else the
+// body returned by an http_request node
+func markupSource(config, input map[string]interface{}) (string, error) {
+	path := stringValue(config["source"])
+	candidates := []string{path}
+	if path == "" {
+		candidates = []string{"http_response.body", "body"}
+	}
+
+	for _, candidate := range candidates {
+		value, ok := lookupPath(input, candidate)
+		if !ok {
+			continue
+		}
+		text, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("%s is not text", candidate)
+		}
+		if len(text) > maxResponseSize {
+			return "", fmt.Errorf("%s is larger than %d MB", candidate, maxResponseSize>>20)
+		}
+		return text, nil
+	}
+	if path != "" {
+		return "", fmt.Errorf("%s not found in execution data", path)
+	}
+	return "", errors.New("no document to parse; set source or run after an http_request node")
+}
+
+// lookupPath reads a dotted path such as "http_response.body" or "items.0.email"
+func lookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, ok := listIndex(part, len(v))
+			if !ok {
+				return nil, false
+			}
+			current = v[index]
+		case []map[string]interface{}:
+			index, ok := listIndex(part, len(v))
+			if !ok {
+				return nil, false
+			}
+			current = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func listIndex(part string, length int) (int, bool) {
+	index, err := strconv.Atoi(part)
+	if err != nil || index < 0 || index >= length {
+		return 0, false
+	}
+	return index, true
+}
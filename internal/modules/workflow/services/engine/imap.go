@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+)
+
+// Limits applied when parsing incoming mail
+const (
+	maxMailTextBytes       = 1 << 20
+	maxMailAttachmentBytes = 10 << 20
+)
+
+// IMAPTriggerExecutor is the imap_trigger node. The poller places the parsed
+// message in the execution data before the graph starts, so the node passes
+// it through like a webhook trigger.
+type IMAPTriggerExecutor struct{}
+
+func (i *IMAPTriggerExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	return input, nil
+}
+
+// IMAPMessageData parses a raw RFC 5322 message into execution data: sender
+// and recipients, subject, threading headers (in_reply_to, references), text
+// and html bodies, all headers, and attachments as binary data.
+func IMAPMessageData(raw io.Reader, uid uint32, folder string) (map[string]interface{}, error) {
+	reader, err := mail.CreateReader(raw)
+	if reader == nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	defer reader.Close()
+
+	header := reader.Header
+	data := map[string]interface{}{
+		"uid":    uid,
+		"folder": folder,
+	}
+
+	if from, err := header.AddressList("From"); err == nil && len(from) > 0 {
+		data["from"] = strings.ToLower(from[0].Address)
+		data["from_name"] = from[0].Name
+	}
+	for _, key := range []string{"To", "Cc", "Reply-To"} {
+		addresses, _ := header.AddressList(key)
+		list := make([]string, 0, len(addresses))
+		for _, address := range addresses {
+			list = append(list, strings.ToLower(address.Address))
+		}
+		data[strings.ToLower(strings.ReplaceAll(key, "-", "_"))] = list
+	}
+	data["subject"], _ = header.Subject()
+	if date, err := header.Date(); err == nil && !date.IsZero() {
+		data["date"] = date.UTC().Format(time.RFC3339)
+	}
+	// ids keep their angle brackets to match the email node's message_id output
+	if messageID, _ := header.MessageID(); messageID != "" {
+		data["message_id"] = "<" + messageID + ">"
+	} else {
+		data["message_id"] = ""
+	}
+	inReplyTo, _ := header.MsgIDList("In-Reply-To")
+	data["in_reply_to"] = strings.Join(bracketIDs(inReplyTo), " ")
+	references, _ := header.MsgIDList("References")
+	data["references"] = bracketIDs(references)
+
+	headers := make(map[string]interface{})
+	fields := header.Fields()
+	for fields.Next() {
+		key := strings.ToLower(fields.Key())
+		value, err := fields.Text()
+		if err != nil {
+			value = fields.Value()
+		}
+		switch existing := headers[key].(type) {
+		case nil:
+			headers[key] = value
+		case string:
+			headers[key] = []string{existing, value}
+		case []string:
+			headers[key] = append(existing, value)
+		}
+	}
+	data["headers"] = headers
+
+	var text, html strings.Builder
+	attachments := []map[string]interface{}{}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// keep what was parsed; a broken trailing part should not lose the reply
+			break
+		}
+
+		switch h := part.Header.(type) {
+		case *mail.InlineHeader:
+			contentType, _, _ := h.ContentType()
+			body, _ := io.ReadAll(io.LimitReader(part.Body, maxMailTextBytes))
+			switch contentType {
+			case "text/plain":
+				text.Write(body)
+			case "text/html":
+				html.Write(body)
+			}
+
+		case *mail.AttachmentHeader:
+			fileName, _ := h.Filename()
+			contentType, _, _ := h.ContentType()
+			body, err := io.ReadAll(io.LimitReader(part.Body, maxMailAttachmentBytes+1))
+			if err != nil || len(body) > maxMailAttachmentBytes {
+				continue
+			}
+			if fileName == "" {
+				fileName = fmt.Sprintf("attachment-%d", len(attachments)+1)
+				if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+					fileName += exts[0]
+				}
+			}
+			name := fmt.Sprintf("attachment_%d", len(attachments))
+			data[BinaryKey] = WithBinary(data, name, &BinaryData{FileName: fileName, MimeType: contentType, Data: body})
+			attachments = append(attachments, map[string]interface{}{
+				"binary":    name,
+				"file_name": fileName,
+				"mime_type": contentType,
+				"size":      len(body),
+			})
+		}
+	}
+
+	data["text"] = text.String()
+	data["html"] = html.String()
+	if text.Len() == 0 && html.Len() > 0 {
+		data["text"] = htmlToText(html.String())
+	}
+	data["attachments"] = attachments
+	return data, nil
+}
+
+func bracketIDs(ids []string) []string {
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = "<" + id + ">"
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const replyMessage = "From: \"Anna Petrova\" <Anna@Example.com>\r\n" +
+	"To: sales@example.com, Boss <boss@example.com>\r\n" +
+	"Cc: audit@example.com\r\n" +
+	"Reply-To: anna.private@example.com\r\n" +
+	"Subject: =?UTF-8?B?UmU6INCf0YDQuNCy0LXRgg==?=\r\n" +
+	"Date: Thu, 05 Mar 2026 14:07:09 +0300\r\n" +
+	"Message-ID: <reply-1@example.com>\r\n" +
+	"In-Reply-To: <outreach-1@s4s.example>\r\n" +
+	"References: <outreach-0@s4s.example> <outreach-1@s4s.example>\r\n" +
+	"X-Mailer: test\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Sounds good, let's talk.\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Sounds good, let's talk.</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/csv\r\n" +
+	"Content-Disposition: attachment; filename=\"leads.csv\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"bmFtZSxlbWFpbAo=\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment\r\n" +
+	"\r\n" +
+	"%PDF-1.4\r\n" +
+	"--outer--\r\n"
+
+func TestIMAPMessageData(t *testing.T) {
+	data, err := IMAPMessageData(strings.NewReader(replyMessage), 42, "INBOX")
+	if err != nil {
+		t.Fatalf("IMAPMessageData: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"uid":         uint32(42),
+		"folder":      "INBOX",
+		"from":        "anna@example.com",
+		"from_name":   "Anna Petrova",
+		"to":          []string{"sales@example.com", "boss@example.com"},
+		"cc":          []string{"audit@example.com"},
+		"reply_to":    []string{"anna.private@example.com"},
+		"subject":     "Re: Привет",
+		"date":        "2026-03-05T11:07:09Z",
+		"message_id":  "<reply-1@example.com>",
+		"in_reply_to": "<outreach-1@s4s.example>",
+		"references":  []string{"<outreach-0@s4s.example>", "<outreach-1@s4s.example>"},
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(data[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, data[key], value)
+		}
+	}
+	if text := data["text"].(string); strings.TrimSpace(text) != "Sounds good, let's talk." {
+		t.Errorf("text = %q", text)
+	}
+	if html := data["html"].(string); !strings.Contains(html, "<p>Sounds good") {
+		t.Errorf("html = %q", html)
+	}
+
+	headers := data["headers"].(map[string]interface{})
+	if headers["x-mailer"] != "test" || headers["subject"] != "Re: Привет" {
+		t.Errorf("headers = %v", headers)
+	}
+
+	attachments := data["attachments"].([]map[string]interface{})
+	if len(attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2", len(attachments))
+	}
+	csv, err := GetBinary(data, "attachment_0")
+	if err != nil || csv.FileName != "leads.csv" || csv.MimeType != "text/csv" || string(csv.Data) != "name,email\n" {
+		t.Errorf("attachment_0 = %+v, %v", csv, err)
+	}
+	if attachments[0]["size"] != len("name,email\n") {
+		t.Errorf("attachment size = %v", attachments[0]["size"])
+	}
+	// unnamed attachments get a name from their type
+	if name := attachments[1]["file_name"].(string); !strings.HasPrefix(name, "attachment-2.") {
+		t.Errorf("unnamed attachment called %q", name)
+	}
+}
+
+func TestIMAPMessageDataHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		check   func(t *testing.T, data map[string]interface{})
+	}{
+		{
+			name:    "html only fills text",
+			message: "From: a@example.com\r\nContent-Type: text/html\r\n\r\n<p>Hello <b>there</b></p>",
+			check: func(t *testing.T, data map[string]interface{}) {
+				if text := strings.TrimSpace(data["text"].(string)); text != "Hello there" {
+					t.Errorf("text = %q", text)
+				}
+			},
+		},
+		{
+			name:    "repeated headers become lists",
+			message: "From: a@example.com\r\nReceived: one\r\nReceived: two\r\nReceived: three\r\n\r\nbody",
+			check: func(t *testing.T, data map[string]interface{}) {
+				received := data["headers"].(map[string]interface{})["received"]
+				if !reflect.DeepEqual(received, []string{"one", "two", "three"}) {
+					t.Errorf("received = %#v", received)
+				}
+			},
+		},
+		{
+			name:    "missing threading headers",
+			message: "From: a@example.com\r\nSubject: new thread\r\n\r\nbody",
+			check: func(t *testing.T, data map[string]interface{}) {
+				if data["message_id"] != "" || data["in_reply_to"] != "" || len(data["references"].([]string)) != 0 {
+					t.Errorf("message_id %q in_reply_to %q references %v", data["message_id"], data["in_reply_to"], data["references"])
+				}
+				if len(data["to"].([]string)) != 0 || len(data["attachments"].([]map[string]interface{})) != 0 {
+					t.Errorf("to %v attachments %v", data["to"], data["attachments"])
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := IMAPMessageData(strings.NewReader(tt.message), 1, "INBOX")
+			if err != nil {
+				t.Fatalf("IMAPMessageData: %v", err)
+			}
+			tt.check(t, data)
+		})
+	}
+}
+
+func TestIMAPMessageDataRejectsGarbage(t *testing.T) {
+	if _, err := IMAPMessageData(strings.NewReader("no header block"), 1, "INBOX"); err == nil {
+		t.Error("expected an error for a message without headers")
+	}
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"s4s-backend/internal/pkg/scoring"
+)
+
+// ScoringRules is a stored lead scoring rule set
+type ScoringRules struct {
+	ID     string
+	Name   string
+	Rules  []scoring.Rule
+	Grades []scoring.Grade
+}
+
+// RuleSetResolver loads lead scoring rule sets owned by the user running the workflow
+type RuleSetResolver interface {
+	Resolve(ctx context.Context, userID, ruleSetID string) (*ScoringRules, error)
+}
+
+// LeadScoreExecutor scores leads with weighted rules and grades them. Rules
+// come from a stored rule set (rule_set_id) or inline rules and grades. When
+// the input has a list under items_key (default "items") every item gets
+// score, grade and score_details fields; otherwise the input itself is the
+// lead and the same fields are returned at the top level.
+type LeadScoreExecutor struct {
+	RuleSets RuleSetResolver
+}
+
+func (l *LeadScoreExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid lead score configuration")
+	}
+
+	ruleSet, err := l.ruleSet(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	itemsKey := stringValue(config["items_key"])
+	if itemsKey == "" {
+		itemsKey = "items"
+	}
+	raw, hasItems := input[itemsKey]
+	if !hasItems {
+		result := ruleSet.Score(input)
+		Logf(ctx, "lead scored %g (%s), %d rules matched", result.Score, result.Grade, len(result.Matched))
+		return map[string]interface{}{
+			"score":         result.Score,
+			"grade":         result.Grade,
+			"score_details": scoreDetails(result.Matched),
+		}, nil
+	}
+
+	items, err := itemList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", itemsKey, err)
+	}
+	scored := make([]map[string]interface{}, 0, len(items))
+	grades := make(map[string]int)
+	for _, item := range items {
+		result := ruleSet.Score(item)
+		copied := make(map[string]interface{}, len(item)+3)
+		for k, v := range item {
+			copied[k] = v
+		}
+		copied["score"] = result.Score
+		copied["grade"] = result.Grade
+		copied["score_details"] = scoreDetails(result.Matched)
+		scored = append(scored, copied)
+		grades[result.Grade]++
+	}
+	Logf(ctx, "scored %d leads", len(scored))
+	return map[string]interface{}{
+		itemsKey:       scored,
+		"item_count":   len(scored),
+		"grade_counts": grades,
+	}, nil
+}
+
+func (l *LeadScoreExecutor) ruleSet(ctx context.Context, config map[string]interface{}) (*scoring.RuleSet, error) {
+	if id := stringValue(config["rule_set_id"]); id != "" {
+		if l.RuleSets == nil {
+			return nil, errors.New("rule sets are not available")
+		}
+		stored, err := l.RuleSets.Resolve(ctx, RunInfoFromContext(ctx).UserID, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rule set: %w", err)
+		}
+		ruleSet, err := scoring.Compile(stored.Rules, stored.Grades)
+		if err != nil {
+			return nil, fmt.Errorf("rule set %s: %w", stored.Name, err)
+		}
+		return ruleSet, nil
+	}
+
+	// inline rules and grades share the rule set JSON shape
+	var inline struct {
+		Rules  []scoring.Rule  `json:"rules"`
+		Grades []scoring.Grade `json:"grades"`
+	}
+	raw, err := json.Marshal(map[string]interface{}{"rules": config["rules"], "grades": config["grades"]})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &inline); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	if len(inline.Rules) == 0 {
+		return nil, errors.New("rule_set_id or rules are required")
+	}
+	return scoring.Compile(inline.Rules, inline.Grades)
+}
+
+// scoreDetails lists the fired rules as plain maps so later nodes can read
+// them like any other execution data
+func scoreDetails(matched []scoring.Match) []interface{} {
+	details := make([]interface{}, 0, len(matched))
+	for _, m := range matched {
+		details = append(details, map[string]interface{}{
+			"rule":   m.Rule,
+			"field":  m.Field,
+			"value":  m.Value,
+			"points": m.Points,
+		})
+	}
+	return details
+}
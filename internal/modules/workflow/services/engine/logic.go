@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SwitchExecutor routes a run to one output by value. value is a template
+// (e.g. "{{stage}}") compared with each case's equals in order; a case may
+// bring its own value. The first match picks the case's output, otherwise
+// default_output is used; with neither, no routed edge runs. The chosen
+// output is written to output_key (default "switch_output").
+type SwitchExecutor struct{}
+
+func (s *SwitchExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid switch configuration")
+	}
+	cases, _ := config["cases"].([]interface{})
+	if len(cases) == 0 {
+		return nil, errors.New("at least one case is required")
+	}
+	outputKey := stringValue(config["output_key"])
+	if outputKey == "" {
+		outputKey = "switch_output"
+	}
+
+	value := stringValue(config["value"])
+	output := ""
+	for i, entry := range cases {
+		spec, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("case %d: expected an object", i+1)
+		}
+		name := stringValue(spec["output"])
+		if name == "" {
+			return nil, fmt.Errorf("case %d: output is required", i+1)
+		}
+		caseValue := value
+		if v, ok := spec["value"]; ok {
+			caseValue = stringValue(v)
+		}
+		if replaceVariables(caseValue, input) == replaceVariables(stringValue(spec["equals"]), input) {
+			output = name
+			break
+		}
+	}
+	if output == "" {
+		output = stringValue(config["default_output"])
+	}
+
+	branches := map[string]map[string]interface{}{}
+	if output != "" {
+		branches[output] = map[string]interface{}{}
+		Logf(ctx, "switch: routed to %s", output)
+	} else {
+		Logf(ctx, "switch: no case matched")
+	}
+	return map[string]interface{}{
+		outputKey:   output,
+		BranchesKey: branches,
+	}, nil
+}
+
+// SetExecutor writes fields into the run data. values maps field names to
+// values; strings are templates and nested objects and lists are filled in
+// too. A dotted name such as "lead.status" sets a nested field, keeping the
+// object's other fields.
+type SetExecutor struct{}
+
+func (s *SetExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid set configuration")
+	}
+	values, ok := config["values"].(map[string]interface{})
+	if !ok || len(values) == 0 {
+		return nil, errors.New("values is required")
+	}
+
+	output := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		value = replaceVariablesDeep(value, input)
+		parts := strings.Split(name, ".")
+		if len(parts) == 1 {
+			output[name] = value
+			continue
+		}
+		root, ok := output[parts[0]].(map[string]interface{})
+		if !ok {
+			root = copyObject(input[parts[0]])
+			output[parts[0]] = root
+		}
+		current := root
+		for _, part := range parts[1 : len(parts)-1] {
+			next := copyObject(current[part])
+			current[part] = next
+			current = next
+		}
+		current[parts[len(parts)-1]] = value
+	}
+	return output, nil
+}
+
+// copyObject returns a shallow copy of value if it is an object, or an empty
+// object, so setting fields never changes data other branches share
+func copyObject(value interface{}) map[string]interface{} {
+	object, _ := value.(map[string]interface{})
+	copied := make(map[string]interface{}, len(object)+1)
+	for k, v := range object {
+		copied[k] = v
+	}
+	return copied
+}
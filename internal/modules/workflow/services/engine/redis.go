@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"s4s-backend/internal/pkg/redisconn"
+)
+
+// Limits for keys stored in the platform Redis on behalf of users
+const (
+	platformRedisDefaultTTL = 30 * 24 * time.Hour
+	platformRedisMaxTTL     = 90 * 24 * time.Hour
+	platformRedisMaxValue   = 64 * 1024
+	platformRedisMaxList    = 10000
+	maxRedisKeyLength       = 512
+)
+
+// RedisClients caches one client per redis connection. A client is replaced
+// when the connection's credentials change and closed once the executions
+// still using it are done.
+type RedisClients struct {
+	clients *sharedClients[*redis.Client]
+}
+
+// NewRedisClients creates an empty client cache
+func NewRedisClients() *RedisClients {
+	return &RedisClients{clients: newSharedClients[*redis.Client]()}
+}
+
+// get returns the client of conn; release must be called once the caller's
+// commands are done
+func (r *RedisClients) get(conn *Connection, cfg *redisconn.Config, dial redisconn.DialFunc) (*redis.Client, func()) {
+	client, release, _ := r.clients.get(conn, func() (*redis.Client, error) {
+		return redisconn.NewClient(cfg, dial), nil
+	})
+	return client, release
+}
+
+// Close closes every client once it is no longer in use
+func (r *RedisClients) Close() {
+	r.clients.Close()
+}
+
+// RedisExecutor reads and writes small pieces of shared state. Without a
+// connection_id it uses the platform Redis, where every key is namespaced by
+// the workflow owner's user ID; with one it uses the user's own server.
+type RedisExecutor struct {
+	Platform    *redis.Client
+	Connections ConnectionResolver
+	Egress      *EgressPolicy
+	Clients     *RedisClients
+}
+
+func (r *RedisExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid redis configuration")
+	}
+
+	client, prefix, platform, release, err := r.client(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	key := replaceVariables(stringValue(config["key"]), input)
+	if key == "" {
+		return nil, errors.New("key is required")
+	}
+	if len(key) > maxRedisKeyLength {
+		return nil, fmt.Errorf("key is longer than %d bytes", maxRedisKeyLength)
+	}
+	key = prefix + key
+
+	var ttl time.Duration
+	if seconds, ok := config["ttl_seconds"].(float64); ok && seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if platform {
+		if ttl == 0 {
+			ttl = platformRedisDefaultTTL
+		}
+		if ttl > platformRedisMaxTTL {
+			ttl = platformRedisMaxTTL
+		}
+	}
+
+	value := func() (string, error) {
+		var s string
+		switch v := replaceVariablesDeep(config["value"], input).(type) {
+		case nil:
+			return "", errors.New("value is required")
+		case string:
+			s = v
+		default:
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return "", fmt.Errorf("invalid value: %w", err)
+			}
+			s = string(raw)
+		}
+		if platform && len(s) > platformRedisMaxValue {
+			return "", fmt.Errorf("value is larger than %d bytes", platformRedisMaxValue)
+		}
+		return s, nil
+	}
+
+	by := int64(1)
+	if n, ok := config["by"].(float64); ok && n != 0 {
+		by = int64(n)
+	}
+	left := stringValue(config["direction"]) == "left"
+
+	operation, _ := config["operation"].(string)
+	switch operation {
+	case "get":
+		result, err := client.Get(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			return map[string]interface{}{"redis_found": false, "redis_value": nil}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return map[string]interface{}{"redis_found": true, "redis_value": result}, nil
+
+	case "set":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		// only_if_absent turns set into a "first time?" check, e.g. one email per lead per day
+		if onlyIfAbsent, _ := config["only_if_absent"].(bool); onlyIfAbsent {
+			stored, err := client.SetNX(ctx, key, v, ttl).Result()
+			if err != nil {
+				return nil, fmt.Errorf("redis: %w", err)
+			}
+			return map[string]interface{}{"redis_stored": stored}, nil
+		}
+		if err := client.Set(ctx, key, v, ttl).Err(); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return map[string]interface{}{"redis_stored": true}, nil
+
+	case "delete":
+		deleted, err := client.Del(ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return map[string]interface{}{"redis_deleted": deleted > 0}, nil
+
+	case "incr", "decr":
+		if operation == "decr" {
+			by = -by
+		}
+		count, err := client.IncrBy(ctx, key, by).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		if err := ensureTTL(ctx, client, key, ttl); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"redis_count": count}, nil
+
+	case "push":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		var length int64
+		if left {
+			length, err = client.LPush(ctx, key, v).Result()
+		} else {
+			length, err = client.RPush(ctx, key, v).Result()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		if platform && length > platformRedisMaxList {
+			// keep the newest entries
+			if left {
+				err = client.LTrim(ctx, key, 0, platformRedisMaxList-1).Err()
+			} else {
+				err = client.LTrim(ctx, key, -platformRedisMaxList, -1).Err()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("redis: %w", err)
+			}
+			length = platformRedisMaxList
+		}
+		if err := ensureTTL(ctx, client, key, ttl); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"redis_length": length}, nil
+
+	case "pop":
+		var result string
+		if left {
+			result, err = client.LPop(ctx, key).Result()
+		} else {
+			result, err = client.RPop(ctx, key).Result()
+		}
+		if errors.Is(err, redis.Nil) {
+			return map[string]interface{}{"redis_found": false, "redis_value": nil}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return map[string]interface{}{"redis_found": true, "redis_value": result}, nil
+
+	case "set_add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		added, err := client.SAdd(ctx, key, v).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		if err := ensureTTL(ctx, client, key, ttl); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"redis_added": added > 0}, nil
+
+	case "set_contains":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		contains, err := client.SIsMember(ctx, key, v).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return map[string]interface{}{"redis_contains": contains}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown redis operation: %s", operation)
+	}
+}
+
+// client picks the platform Redis or the user's connection and returns the
+// key prefix for it. release must be called once the client is no longer used.
+func (r *RedisExecutor) client(ctx context.Context, config map[string]interface{}) (*redis.Client, string, bool, func(), error) {
+	noop := func() {}
+
+	if connectionID, _ := config["connection_id"].(string); connectionID == "" {
+		if r.Platform == nil {
+			return nil, "", false, nil, errors.New("platform redis is not configured; select a redis connection")
+		}
+		userID := RunInfoFromContext(ctx).UserID
+		if userID == "" {
+			return nil, "", false, nil, errors.New("platform redis requires a workflow owner")
+		}
+		return r.Platform, "s4s:" + userID + ":", true, noop, nil
+	}
+
+	conn, err := resolveConnection(ctx, r.Connections, config, "redis")
+	if err != nil {
+		return nil, "", false, nil, err
+	}
+	cfg, err := redisconn.ConfigFromCredentials(conn.Credentials)
+	if err != nil {
+		return nil, "", false, nil, fmt.Errorf("invalid redis connection: %w", err)
+	}
+
+	egress := r.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+	if r.Clients == nil {
+		client := redisconn.NewClient(cfg, egress.DialContext)
+		return client, cfg.KeyPrefix, false, func() { client.Close() }, nil
+	}
+	client, release := r.Clients.get(conn, cfg, egress.DialContext)
+	return client, cfg.KeyPrefix, false, release, nil
+}
+
+// ensureTTL sets ttl on a key that has no expiry yet, so counters and
+// collections expire a fixed time after they were created
+func ensureTTL(ctx context.Context, client *redis.Client, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	current, err := client.TTL(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	if current >= 0 {
+		return nil
+	}
+	if err := client.Expire(ctx, key, ttl).Err(); err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// commandRecorder answers commands without a server and records them as
+// "name arg arg". Integer replies are 0 unless set in replies.
+type commandRecorder struct {
+	commands []string
+	// replies sets the integer reply of a command by name, e.g. "rpush"
+	replies map[string]int64
+}
+
+func (c *commandRecorder) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("dialled %s", addr)
+	}
+}
+
+func (c *commandRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		args := make([]string, len(cmd.Args()))
+		for i, arg := range cmd.Args() {
+			args[i] = fmt.Sprint(arg)
+		}
+		c.commands = append(c.commands, strings.Join(args, " "))
+
+		switch cmd := cmd.(type) {
+		case *redis.IntCmd:
+			cmd.SetVal(c.replies[cmd.Name()])
+		case *redis.DurationCmd:
+			// no expiry yet
+			cmd.SetVal(-1)
+		case *redis.StatusCmd:
+			cmd.SetVal("OK")
+		case *redis.BoolCmd:
+			cmd.SetVal(true)
+		case *redis.StringCmd:
+			cmd.SetVal("stored")
+		}
+		return nil
+	}
+}
+
+func (c *commandRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return fmt.Errorf("unexpected pipeline of %d commands", len(cmds))
+	}
+}
+
+func TestRedisExecutorPlatformLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		replies map[string]int64
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "keys are namespaced by the owner and get the default TTL",
+			config: map[string]interface{}{"operation": "set", "key": "lead:{{email}}", "value": "{{stage}}"},
+			want:   []string{"set s4s:user-1:lead:anna@example.com won ex 2592000"},
+		},
+		{
+			name:   "TTL is capped",
+			config: map[string]interface{}{"operation": "set", "key": "lead", "value": "x", "ttl_seconds": 1e9},
+			want:   []string{"set s4s:user-1:lead x ex 7776000"},
+		},
+		{
+			name:   "shorter TTL is kept",
+			config: map[string]interface{}{"operation": "set", "key": "lead", "value": map[string]interface{}{"stage": "{{stage}}"}, "ttl_seconds": 60.0, "only_if_absent": true},
+			want:   []string{`set s4s:user-1:lead {"stage":"won"} ex 60 nx`},
+		},
+		{
+			name:   "reads are namespaced",
+			config: map[string]interface{}{"operation": "get", "key": "lead"},
+			want:   []string{"get s4s:user-1:lead"},
+		},
+		{
+			name:   "counters expire",
+			config: map[string]interface{}{"operation": "decr", "key": "sent", "by": 2.0},
+			want:   []string{"incrby s4s:user-1:sent -2", "ttl s4s:user-1:sent", "expire s4s:user-1:sent 2592000"},
+		},
+		{
+			name:    "lists keep the newest entries",
+			config:  map[string]interface{}{"operation": "push", "key": "log", "value": "x"},
+			replies: map[string]int64{"rpush": platformRedisMaxList + 1},
+			want:    []string{"rpush s4s:user-1:log x", "ltrim s4s:user-1:log -10000 -1", "ttl s4s:user-1:log", "expire s4s:user-1:log 2592000"},
+		},
+		{
+			name:    "left pushes trim the tail",
+			config:  map[string]interface{}{"operation": "push", "key": "log", "value": "x", "direction": "left"},
+			replies: map[string]int64{"lpush": platformRedisMaxList + 1},
+			want:    []string{"lpush s4s:user-1:log x", "ltrim s4s:user-1:log 0 9999", "ttl s4s:user-1:log", "expire s4s:user-1:log 2592000"},
+		},
+		{
+			name:    "values are capped",
+			config:  map[string]interface{}{"operation": "set", "key": "lead", "value": strings.Repeat("x", platformRedisMaxValue+1)},
+			wantErr: "value is larger than 65536 bytes",
+		},
+		{
+			name:    "keys are capped",
+			config:  map[string]interface{}{"operation": "get", "key": strings.Repeat("k", maxRedisKeyLength+1)},
+			wantErr: "key is longer than 512 bytes",
+		},
+		{
+			name:    "empty key",
+			config:  map[string]interface{}{"operation": "get", "key": ""},
+			wantErr: "key is required",
+		},
+	}
+
+	input := map[string]interface{}{"email": "anna@example.com", "stage": "won"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &commandRecorder{replies: tt.replies}
+			platform := redis.NewClient(&redis.Options{Addr: "platform-redis:6379"})
+			platform.AddHook(recorder)
+			defer platform.Close()
+
+			r := &RedisExecutor{Platform: platform}
+			ctx := WithRunInfo(context.Background(), &RunInfo{UserID: "user-1"})
+			_, err := r.Execute(ctx, &Node{Data: map[string]interface{}{"config": tt.config}}, input)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Execute() = %v, want %q", err, tt.wantErr)
+				}
+				if len(recorder.commands) > 0 {
+					t.Errorf("sent %q after the error", recorder.commands)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(recorder.commands, tt.want) {
+				t.Errorf("commands:\n%s\nwant:\n%s", strings.Join(recorder.commands, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestRedisExecutorPlatformRequiresOwner(t *testing.T) {
+	platform := redis.NewClient(&redis.Options{Addr: "platform-redis:6379"})
+	recorder := &commandRecorder{}
+	platform.AddHook(recorder)
+	defer platform.Close()
+
+	node := &Node{Data: map[string]interface{}{"config": map[string]interface{}{"operation": "get", "key": "lead"}}}
+	if _, err := (&RedisExecutor{}).Execute(WithRunInfo(context.Background(), &RunInfo{UserID: "user-1"}), node, nil); err == nil {
+		t.Error("Execute() without a platform redis succeeded")
+	}
+	if _, err := (&RedisExecutor{Platform: platform}).Execute(WithRunInfo(context.Background(), &RunInfo{}), node, nil); err == nil {
+		t.Error("Execute() without an owner succeeded")
+	}
+	if len(recorder.commands) > 0 {
+		t.Errorf("sent %q without an owner", recorder.commands)
+	}
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"github.com/redis/go-redis/v9"
+
+	"s4s-backend/internal/pkg/dbconn"
+	"s4s-backend/internal/pkg/mailer"
+)
+
+// Options holds the shared dependencies executors are built with
+type Options struct {
+	Egress      *EgressPolicy
+	Connections ConnectionResolver
+	RuleSets    RuleSetResolver
+	State       StateStore
+	Experiments ExperimentRecorder
+
+	// EmailTracker adds open and click tracking to email nodes; nil disables it
+	EmailTracker EmailTracker
+	// Suppressions are checked before sending messages; nil sends to everyone
+	Suppressions SuppressionChecker
+	// Contacts backs contact_upsert and contact_find; nil disables them
+	Contacts ContactStore
+
+	// API base URLs, overridable for tests against local stand-ins
+	SlackAPIURL    string
+	TelegramAPIURL string
+	CRMBaseURLs    map[string]string
+
+	// PlatformSMTP is the relay used by email nodes without a connection; nil disables it
+	PlatformSMTP *mailer.Config
+
+	// PlatformRedis backs redis nodes without a connection; nil disables it
+	PlatformRedis *redis.Client
+
+	// MXResolver answers normalize_contact MX checks; nil uses the system resolver
+	MXResolver MXResolver
+}
+
+// NewExecutors returns the executors keyed by node type
+func NewExecutors(opts Options) map[string]NodeExecutor {
+	if opts.Egress == nil {
+		opts.Egress = DefaultEgressPolicy()
+	}
+
+	// postgres_query and mysql_query share one pool cache keyed by connection
+	pools := NewDatabasePools()
+
+	return map[string]NodeExecutor{
+		"http_request":      &HTTPRequestExecutor{Egress: opts.Egress},
+		"email":             &EmailExecutor{Connections: opts.Connections, PlatformRelay: opts.PlatformSMTP, Egress: opts.Egress, Tracker: opts.EmailTracker, Suppressions: opts.Suppressions},
+		"slack_message":     &SlackMessageExecutor{Connections: opts.Connections, Egress: opts.Egress, APIURL: opts.SlackAPIURL, Suppressions: opts.Suppressions},
+		"telegram_message":  &TelegramMessageExecutor{Connections: opts.Connections, Egress: opts.Egress, APIURL: opts.TelegramAPIURL, Suppressions: opts.Suppressions},
+		"crm":               &CRMExecutor{Connections: opts.Connections, Egress: opts.Egress, BaseURLs: opts.CRMBaseURLs},
+		"postgres_query":    &DatabaseQueryExecutor{Driver: dbconn.EnginePostgres, Connections: opts.Connections, Egress: opts.Egress, Pools: pools},
+		"mysql_query":       &DatabaseQueryExecutor{Driver: dbconn.EngineMySQL, Connections: opts.Connections, Egress: opts.Egress, Pools: pools},
+		"redis":             &RedisExecutor{Platform: opts.PlatformRedis, Connections: opts.Connections, Egress: opts.Egress, Clients: NewRedisClients()},
+		"amqp_publish":      &AMQPPublishExecutor{Connections: opts.Connections, Egress: opts.Egress},
+		"amqp_consume":      &AMQPConsumeExecutor{},
+		"imap_trigger":      &IMAPTriggerExecutor{},
+		"email_event":       &EmailEventTriggerExecutor{},
+		"contact_event":     &ContactEventTriggerExecutor{},
+		"schedule":          &ScheduleTriggerExecutor{},
+		"spreadsheet_read":  &SpreadsheetReadExecutor{Egress: opts.Egress},
+		"spreadsheet_write": &SpreadsheetWriteExecutor{},
+		"html_extract":      &HTMLExtractExecutor{},
+		"xml_convert":       &XMLConvertExecutor{},
+		"datetime":          &DateTimeExecutor{},
+		"crypto":            &CryptoExecutor{Connections: opts.Connections},
+		"lead_score":        &LeadScoreExecutor{RuleSets: opts.RuleSets},
+		"normalize_contact": &NormalizeContactExecutor{Resolver: opts.MXResolver},
+		"assign":            &AssignExecutor{State: opts.State},
+		"contact_upsert":    &ContactUpsertExecutor{Contacts: opts.Contacts},
+		"contact_find":      &ContactFindExecutor{Contacts: opts.Contacts},
+		"split":             &SplitExecutor{Experiments: opts.Experiments},
+		"split_outcome":     &SplitOutcomeExecutor{Experiments: opts.Experiments},
+		"webhook":           &WebhookExecutor{},
+		"delay":             &DelayExecutor{},
+		"if":                &IfExecutor{},
+		"switch":            &SwitchExecutor{},
+		"set":               &SetExecutor{},
+	}
+}
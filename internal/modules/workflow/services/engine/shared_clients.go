@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// sharedClients caches one long-lived client per connection, e.g. a database
+// pool. A client is replaced when the connection's credentials change; the
+// old one is closed once the executions still using it have released it.
+type sharedClients[T io.Closer] struct {
+	mu      sync.Mutex
+	clients map[string]*sharedClient[T]
+}
+
+type sharedClient[T io.Closer] struct {
+	fingerprint string
+	client      T
+	users       int
+	// retired clients are no longer handed out and close with their last user
+	retired bool
+}
+
+func newSharedClients[T io.Closer]() *sharedClients[T] {
+	return &sharedClients[T]{clients: make(map[string]*sharedClient[T])}
+}
+
+// get returns the client of conn, opening one with open when there is none
+// yet or the credentials changed. release must be called once the caller no
+// longer uses the client; calling it again has no effect.
+func (s *sharedClients[T]) get(conn *Connection, open func() (T, error)) (T, func(), error) {
+	raw, _ := json.Marshal(conn.Credentials)
+	sum := sha256.Sum256(raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cached, ok := s.clients[conn.ID]
+	if ok && cached.fingerprint != fingerprint {
+		s.retire(cached)
+		delete(s.clients, conn.ID)
+		ok = false
+	}
+	if !ok {
+		client, err := open()
+		if err != nil {
+			var zero T
+			return zero, nil, err
+		}
+		cached = &sharedClient[T]{fingerprint: fingerprint, client: client}
+		s.clients[conn.ID] = cached
+	}
+	cached.users++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if cached.users--; cached.retired && cached.users == 0 {
+				cached.client.Close()
+			}
+		})
+	}
+	return cached.client, release, nil
+}
+
+// retire stops handing out c and closes it unless it is in use; s.mu must be held
+func (s *sharedClients[T]) retire(c *sharedClient[T]) {
+	c.retired = true
+	if c.users == 0 {
+		c.client.Close()
+	}
+}
+
+// Close retires every client; clients in use close when they are released
+func (s *sharedClients[T]) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, c := range s.clients {
+		s.retire(c)
+		delete(s.clients, id)
+	}
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultSlackAPIURL is the Slack Web API base URL
+const DefaultSlackAPIURL = "https://slack.com/api"
+
+// SlackMessageExecutor posts messages to Slack via a bot token or an incoming webhook.
+// The slack connection holds either "botToken" or "webhookUrl".
+type SlackMessageExecutor struct {
+	Connections  ConnectionResolver
+	Egress       *EgressPolicy
+	APIURL       string
+	Suppressions SuppressionChecker
+}
+
+type slackResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+func (s *SlackMessageExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid slack configuration")
+	}
+
+	conn, err := resolveConnection(ctx, s.Connections, config, "slack")
+	if err != nil {
+		return nil, err
+	}
+	botToken, _ := conn.Credentials["botToken"].(string)
+	webhookURL, _ := conn.Credentials["webhookUrl"].(string)
+
+	text, _ := config["text"].(string)
+	channel, _ := config["channel"].(string)
+	threadTS, _ := config["thread_ts"].(string)
+	text = replaceVariables(text, input)
+	channel = replaceVariables(channel, input)
+	threadTS = replaceVariables(threadTS, input)
+
+	// incoming webhooks post to the channel they were created for
+	if channel != "" {
+		suppressed, err := suppressedSet(ctx, s.Suppressions, "slack", channel)
+		if err != nil {
+			return nil, err
+		}
+		if suppressed[strings.ToLower(channel)] {
+			return suppressedOutput(ctx, "slack_sent", []string{channel}), nil
+		}
+	}
+
+	payload := map[string]interface{}{}
+	if text != "" {
+		payload["text"] = text
+	}
+	if blocks, ok := config["blocks"].([]interface{}); ok && len(blocks) > 0 {
+		payload["blocks"] = replaceVariablesDeep(blocks, input)
+	}
+	if len(payload) == 0 {
+		return nil, errors.New("text or blocks are required")
+	}
+	if markdown, ok := config["markdown"].(bool); ok {
+		payload["mrkdwn"] = markdown
+	}
+	if threadTS != "" {
+		payload["thread_ts"] = threadTS
+		if broadcast, _ := config["reply_broadcast"].(bool); broadcast {
+			payload["reply_broadcast"] = true
+		}
+	}
+
+	egress := s.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+	client := egress.HTTPClient(30 * time.Second)
+
+	if botToken == "" {
+		if webhookURL == "" {
+			return nil, errors.New("slack connection has neither botToken nor webhookUrl")
+		}
+		if _, err := doJSON(ctx, client, http.MethodPost, webhookURL, nil, payload, nil); err != nil {
+			return nil, fmt.Errorf("slack webhook: %w", err)
+		}
+		return map[string]interface{}{
+			"slack_sent": true,
+			"channel":    channel,
+		}, nil
+	}
+
+	if channel == "" {
+		return nil, errors.New("channel is required")
+	}
+	payload["channel"] = channel
+
+	apiURL := s.APIURL
+	if apiURL == "" {
+		apiURL = DefaultSlackAPIURL
+	}
+
+	var resp slackResponse
+	headers := map[string]string{"Authorization": "Bearer " + botToken}
+	if _, err := doJSON(ctx, client, http.MethodPost, strings.TrimRight(apiURL, "/")+"/chat.postMessage", headers, payload, &resp); err != nil {
+		return nil, fmt.Errorf("slack: %w", err)
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("slack: %s", resp.Error)
+	}
+
+	return map[string]interface{}{
+		"slack_sent": true,
+		"channel":    resp.Channel,
+		"ts":         resp.TS,
+		"thread_ts":  threadTS,
+	}, nil
+}
@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// apiRequest is a request received by an apiServer
+type apiRequest struct {
+	Method        string
+	Path          string
+	Query         string
+	Authorization string
+	// Body is the decoded JSON object body and List the decoded JSON array
+	// body; both are nil without one
+	Body map[string]interface{}
+	List []interface{}
+}
+
+// apiServer stands in for a JSON API. respond answers each request with a
+// status and a value encoded as JSON.
+type apiServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []apiRequest
+}
+
+func newAPIServer(t *testing.T, respond func(r apiRequest) (int, interface{})) *apiServer {
+	t.Helper()
+	s := &apiServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := apiRequest{
+			Method:        r.Method,
+			Path:          r.URL.EscapedPath(),
+			Query:         r.URL.RawQuery,
+			Authorization: r.Header.Get("Authorization"),
+		}
+		if raw, _ := io.ReadAll(r.Body); len(raw) > 0 {
+			var err error
+			if raw[0] == '[' {
+				err = json.Unmarshal(raw, &req.List)
+			} else {
+				err = json.Unmarshal(raw, &req.Body)
+			}
+			if err != nil {
+				t.Errorf("%s %s: body is not JSON: %s", r.Method, r.URL, raw)
+			}
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+
+		status, out := respond(req)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the requests received so far
+func (s *apiServer) Requests() []apiRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]apiRequest(nil), s.requests...)
+}
+
+func slackConnection(credentials map[string]interface{}) staticConnections {
+	return staticConnections{conn: &Connection{ID: "conn-1", Service: "slack", Credentials: credentials}}
+}
+
+func slackNode(config map[string]interface{}) *Node {
+	config["connection_id"] = "conn-1"
+	return &Node{ID: "slack-1", Data: map[string]interface{}{"type": "slack_message", "config": config}}
+}
+
+func TestSlackExecutorBotToken(t *testing.T) {
+	server := newAPIServer(t, func(r apiRequest) (int, interface{}) {
+		if r.Body["channel"] == "#nowhere" {
+			return http.StatusOK, map[string]interface{}{"ok": false, "error": "channel_not_found"}
+		}
+		return http.StatusOK, map[string]interface{}{"ok": true, "channel": "C024BE91L", "ts": "1712345678.000200"}
+	})
+	executor := &SlackMessageExecutor{
+		Connections: slackConnection(map[string]interface{}{"botToken": "xoxb-secret", "webhookUrl": "http://127.0.0.1:1/unused"}),
+		Egress:      localEgress(t),
+		APIURL:      server.URL + "/",
+	}
+	input := map[string]interface{}{"name": "Anna", "thread": "1712345678.000100"}
+
+	output, err := executor.Execute(context.Background(), slackNode(map[string]interface{}{
+		"channel":         "#sales",
+		"text":            "New lead: {{name}}",
+		"thread_ts":       "{{thread}}",
+		"reply_broadcast": true,
+		"markdown":        false,
+		"blocks":          []interface{}{map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": "*{{name}}*"}}},
+	}), input)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	want := map[string]interface{}{"slack_sent": true, "channel": "C024BE91L", "ts": "1712345678.000200", "thread_ts": "1712345678.000100"}
+	if !reflect.DeepEqual(output, want) {
+		t.Errorf("output = %v, want %v", output, want)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.Method != http.MethodPost || req.Path != "/chat.postMessage" || req.Authorization != "Bearer xoxb-secret" {
+		t.Errorf("request = %s %s with %q", req.Method, req.Path, req.Authorization)
+	}
+	wantBody := map[string]interface{}{
+		"channel":         "#sales",
+		"text":            "New lead: Anna",
+		"thread_ts":       "1712345678.000100",
+		"reply_broadcast": true,
+		"mrkdwn":          false,
+		"blocks":          []interface{}{map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": "*Anna*"}}},
+	}
+	if !reflect.DeepEqual(req.Body, wantBody) {
+		t.Errorf("body = %v, want %v", req.Body, wantBody)
+	}
+
+	// Slack reports errors with status 200 and ok false
+	_, err = executor.Execute(context.Background(), slackNode(map[string]interface{}{"channel": "#nowhere", "text": "hi"}), input)
+	if err == nil || err.Error() != "slack: channel_not_found" {
+		t.Errorf("Execute() = %v, want the Slack error", err)
+	}
+
+	if _, err := executor.Execute(context.Background(), slackNode(map[string]interface{}{"text": "hi"}), input); err == nil || !strings.Contains(err.Error(), "channel is required") {
+		t.Errorf("Execute() without a channel = %v", err)
+	}
+}
+
+func TestSlackExecutorWebhook(t *testing.T) {
+	server := newAPIServer(t, func(r apiRequest) (int, interface{}) {
+		if r.Path == "/services/T000/B000/broken" {
+			return http.StatusNotFound, "no_service"
+		}
+		return http.StatusOK, "ok"
+	})
+	executor := &SlackMessageExecutor{
+		Connections: slackConnection(map[string]interface{}{"webhookUrl": server.URL + "/services/T000/B000/XXXX"}),
+		Egress:      localEgress(t),
+		APIURL:      "http://127.0.0.1:1",
+	}
+
+	// the webhook posts to its own channel, so none is required
+	output, err := executor.Execute(context.Background(), slackNode(map[string]interface{}{"text": "Deal won", "thread_ts": "1712345678.000100"}), nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if output["slack_sent"] != true {
+		t.Errorf("output = %v", output)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if req := requests[0]; req.Path != "/services/T000/B000/XXXX" || req.Authorization != "" {
+		t.Errorf("request to %s with %q", req.Path, req.Authorization)
+	}
+	wantBody := map[string]interface{}{"text": "Deal won", "thread_ts": "1712345678.000100"}
+	if !reflect.DeepEqual(requests[0].Body, wantBody) {
+		t.Errorf("body = %v, want %v", requests[0].Body, wantBody)
+	}
+
+	executor.Connections = slackConnection(map[string]interface{}{"webhookUrl": server.URL + "/services/T000/B000/broken"})
+	if _, err := executor.Execute(context.Background(), slackNode(map[string]interface{}{"text": "hi"}), nil); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("Execute() = %v, want the webhook status", err)
+	}
+
+	executor.Connections = slackConnection(map[string]interface{}{})
+	if _, err := executor.Execute(context.Background(), slackNode(map[string]interface{}{"text": "hi"}), nil); err == nil || !strings.Contains(err.Error(), "neither botToken nor webhookUrl") {
+		t.Errorf("Execute() without credentials = %v", err)
+	}
+}
+
+func TestSlackExecutorSuppression(t *testing.T) {
+	server := newAPIServer(t, func(r apiRequest) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"ok": true, "channel": "C024BE91L", "ts": "1"}
+	})
+	suppressions := &staticSuppressions{channel: "slack", addresses: []string{"c024be91l"}}
+	executor := &SlackMessageExecutor{
+		Connections:  slackConnection(map[string]interface{}{"botToken": "xoxb-secret"}),
+		Egress:       localEgress(t),
+		APIURL:       server.URL,
+		Suppressions: suppressions,
+	}
+	var logged []string
+	ctx := WithRunInfo(context.Background(), &RunInfo{UserID: "user-1", Log: func(line string) { logged = append(logged, line) }})
+
+	output, err := executor.Execute(ctx, slackNode(map[string]interface{}{"channel": "{{channel}}", "text": "hi"}), map[string]interface{}{"channel": "C024BE91L"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if output["slack_sent"] != false || output["skip_reason"] != "suppressed" {
+		t.Errorf("output = %v, want a suppressed skip", output)
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("sent %d requests to a suppressed channel", n)
+	}
+	if suppressions.userID != "user-1" || len(logged) != 1 {
+		t.Errorf("checked user %q, logged %q", suppressions.userID, logged)
+	}
+
+	if _, err := executor.Execute(ctx, slackNode(map[string]interface{}{"channel": "C0OTHER", "text": "hi"}), nil); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("sent %d requests to another channel, want 1", n)
+	}
+}
@@ -0,0 +1,266 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// BranchesKey is the output key a routing node uses to choose which of its
+// outgoing edges run. The value maps an edge's sourceHandle to data merged
+// into that branch only; when it is set, edges with a handle that is not
+// listed are skipped. Edges without a handle always run.
+const BranchesKey = "_branches"
+
+// ExperimentRecorder stores experiment assignments and outcomes. Leads are
+// identified by a hash of their key so no contact data is stored.
+type ExperimentRecorder interface {
+	// Assign returns the variant already stored for the lead, or stores and
+	// returns the one pick chooses
+	Assign(ctx context.Context, workflowID, nodeID, keyHash, executionID string, pick func() string) (string, error)
+	// RecordOutcome records goal for the lead and returns its variant; found
+	// is false when the lead was never assigned by the split node
+	RecordOutcome(ctx context.Context, workflowID, nodeID, keyHash, goal, executionID string) (variant string, found bool, err error)
+}
+
+type splitVariant struct {
+	name string
+	// upper bound of the variant's bucket range, in basis points
+	upper int
+}
+
+// SplitExecutor is an A/B split: it routes each lead to one of the variants
+// by percentage. Assignment is sticky per key (key_field, default "email",
+// or a key template), so a lead always lands in the same variant, and is
+// recorded for the experiment report. With a list under items_key (default
+// "items") each branch runs once with its share of the items.
+type SplitExecutor struct {
+	Experiments ExperimentRecorder
+}
+
+func (s *SplitExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid split configuration")
+	}
+
+	variants, err := splitVariants(config["variants"])
+	if err != nil {
+		return nil, err
+	}
+	outputKey := stringValue(config["output_key"])
+	if outputKey == "" {
+		outputKey = "variant"
+	}
+
+	info := RunInfoFromContext(ctx)
+	assign := func(lead map[string]interface{}) (string, error) {
+		key := experimentKey(config, lead, input)
+		if key == "" {
+			// without a key the lead cannot be sticky; count it once under this run
+			key = uuid.NewString()
+			Logf(ctx, "split: lead has no key, assigned at random")
+		}
+		pick := func() string { return bucketVariant(variants, node.ID, key) }
+		if info.IsTest || info.WorkflowID == "" || s.Experiments == nil {
+			return pick(), nil
+		}
+		return s.Experiments.Assign(ctx, info.WorkflowID, node.ID, key, info.ExecutionID, pick)
+	}
+
+	itemsKey := stringValue(config["items_key"])
+	if itemsKey == "" {
+		itemsKey = "items"
+	}
+	raw, hasItems := input[itemsKey]
+	if !hasItems {
+		variant, err := assign(input)
+		if err != nil {
+			return nil, err
+		}
+		Logf(ctx, "split: lead assigned to %s", variant)
+		return map[string]interface{}{
+			outputKey:   variant,
+			BranchesKey: map[string]map[string]interface{}{variant: {}},
+		}, nil
+	}
+
+	items, err := itemList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", itemsKey, err)
+	}
+	groups := make(map[string][]map[string]interface{})
+	counts := make(map[string]int, len(variants))
+	for _, v := range variants {
+		counts[v.name] = 0
+	}
+	for _, item := range items {
+		variant, err := assign(item)
+		if err != nil {
+			return nil, err
+		}
+		copied := make(map[string]interface{}, len(item)+1)
+		for k, v := range item {
+			copied[k] = v
+		}
+		copied[outputKey] = variant
+		groups[variant] = append(groups[variant], copied)
+		counts[variant]++
+	}
+
+	branches := make(map[string]map[string]interface{}, len(groups))
+	for variant, group := range groups {
+		branches[variant] = map[string]interface{}{itemsKey: group, "item_count": len(group)}
+	}
+	Logf(ctx, "split: %d items assigned %v", len(items), counts)
+	return map[string]interface{}{
+		outputKey + "_counts": counts,
+		BranchesKey:           branches,
+	}, nil
+}
+
+// splitVariants reads [{"name": "A", "percent": 50}, ...]; percentages must
+// add up to 100
+func splitVariants(value interface{}) ([]splitVariant, error) {
+	list, _ := value.([]interface{})
+	if len(list) < 2 {
+		return nil, errors.New("at least two variants are required")
+	}
+
+	variants := make([]splitVariant, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	total := 0
+	for i, entry := range list {
+		spec, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("variant %d: expected an object", i+1)
+		}
+		name := strings.TrimSpace(stringValue(spec["name"]))
+		if name == "" {
+			return nil, fmt.Errorf("variant %d: name is required", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("variant %s is listed twice", name)
+		}
+		seen[name] = true
+		percent, ok := spec["percent"].(float64)
+		if !ok || percent < 0 {
+			return nil, fmt.Errorf("variant %s: percent is required", name)
+		}
+		total += int(math.Round(percent * 100))
+		variants = append(variants, splitVariant{name: name, upper: total})
+	}
+	if total != 10000 {
+		return nil, fmt.Errorf("variant percentages add up to %g, expected 100", float64(total)/100)
+	}
+	return variants, nil
+}
+
+// experimentKey returns the normalized key identifying a lead: the key
+// template, else the value at key_field (default "email") in the lead
+func experimentKey(config, lead, input map[string]interface{}) string {
+	var key string
+	if template := stringValue(config["key"]); template != "" {
+		key = replaceVariables(template, lead)
+		if strings.Contains(key, "{{") {
+			key = replaceVariables(key, input)
+		}
+		if strings.Contains(key, "{{") {
+			key = ""
+		}
+	} else {
+		field := stringValue(config["key_field"])
+		if field == "" {
+			field = "email"
+		}
+		value, _ := lookupPath(lead, field)
+		key = stringValue(value)
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// bucketVariant maps the key to a stable bucket in 0..9999; the node ID is
+// mixed in so separate experiments split the same leads independently
+func bucketVariant(variants []splitVariant, nodeID, keyHash string) string {
+	sum := sha256.Sum256([]byte(nodeID + ":" + keyHash))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % 10000)
+	for _, v := range variants {
+		if bucket < v.upper {
+			return v.name
+		}
+	}
+	return variants[len(variants)-1].name
+}
+
+// SplitOutcomeExecutor records an outcome (goal, default "conversion") for
+// leads previously routed by the split node with ID split_node. It uses the
+// same key settings as the split node. Each lead counts once per goal.
+type SplitOutcomeExecutor struct {
+	Experiments ExperimentRecorder
+}
+
+func (s *SplitOutcomeExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid split outcome configuration")
+	}
+	splitNode := stringValue(config["split_node"])
+	if splitNode == "" {
+		return nil, errors.New("split_node is required")
+	}
+	goal := stringValue(config["goal"])
+	if goal == "" {
+		goal = "conversion"
+	}
+
+	info := RunInfoFromContext(ctx)
+	record := func(lead map[string]interface{}) (string, bool, error) {
+		key := experimentKey(config, lead, input)
+		if key == "" || info.IsTest || info.WorkflowID == "" || s.Experiments == nil {
+			return "", false, nil
+		}
+		return s.Experiments.RecordOutcome(ctx, info.WorkflowID, splitNode, key, goal, info.ExecutionID)
+	}
+
+	itemsKey := stringValue(config["items_key"])
+	if itemsKey == "" {
+		itemsKey = "items"
+	}
+	raw, hasItems := input[itemsKey]
+	if !hasItems {
+		variant, found, err := record(input)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"outcome_variant": variant, "outcome_recorded": found}, nil
+	}
+
+	items, err := itemList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", itemsKey, err)
+	}
+	recorded := 0
+	for _, item := range items {
+		_, found, err := record(item)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			recorded++
+		}
+	}
+	Logf(ctx, "split outcome %s recorded for %d of %d items", goal, recorded, len(items))
+	return map[string]interface{}{"outcome_recorded_count": recorded}, nil
+}
@@ -0,0 +1,809 @@
+package engine
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Limits applied to spreadsheets read or written by nodes
+const (
+	maxSpreadsheetBytes         = 20 << 20
+	maxSpreadsheetUnpackedBytes = 200 << 20
+	defaultSpreadsheetRows      = 10000
+	maxSpreadsheetRows          = 100000
+	maxSheetNameLength          = 31
+)
+
+const (
+	spreadsheetFormatCSV  = "csv"
+	spreadsheetFormatXLSX = "xlsx"
+
+	mimeCSV  = "text/csv"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// csvDelimiters are the candidates tried when a CSV file's delimiter is not configured
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// SpreadsheetReadExecutor parses a CSV or XLSX file into items. The file comes
+// from execution binary data (e.g. an email attachment) or is downloaded from
+// url. Each row becomes an object keyed by the header row, optionally renamed
+// through header_map.
+type SpreadsheetReadExecutor struct {
+	Egress *EgressPolicy
+}
+
+func (s *SpreadsheetReadExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid spreadsheet read configuration")
+	}
+
+	file, err := s.source(ctx, config, input)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := spreadsheetFormat(stringValue(config["format"]), file)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	var sheet string
+	switch format {
+	case spreadsheetFormatCSV:
+		rows, err = readCSV(file.Data, stringValue(config["delimiter"]), stringValue(config["encoding"]))
+	case spreadsheetFormatXLSX:
+		rows, sheet, err = readXLSX(file.Data, config["sheet"])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if n, ok := config["skip_rows"].(float64); ok && n > 0 {
+		if int(n) >= len(rows) {
+			rows = nil
+		} else {
+			rows = rows[int(n):]
+		}
+	}
+
+	hasHeader := true
+	if v, ok := config["has_header"].(bool); ok {
+		hasHeader = v
+	}
+	headerMap, _ := config["header_map"].(map[string]interface{})
+	onlyMapped, _ := config["only_mapped"].(bool)
+
+	maxRows := defaultSpreadsheetRows
+	if n, ok := config["max_rows"].(float64); ok && n > 0 {
+		maxRows = int(n)
+	}
+	if maxRows > maxSpreadsheetRows {
+		maxRows = maxSpreadsheetRows
+	}
+
+	var header []string
+	if hasHeader {
+		for len(rows) > 0 && emptyRow(rows[0]) {
+			rows = rows[1:]
+		}
+		if len(rows) > 0 {
+			header = rows[0]
+			rows = rows[1:]
+		}
+	}
+	columns := spreadsheetColumns(header, rows, headerMap)
+
+	items := []map[string]interface{}{}
+	truncated := false
+	for _, row := range rows {
+		if emptyRow(row) {
+			continue
+		}
+		if len(items) == maxRows {
+			truncated = true
+			break
+		}
+		item := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if column.key == "" || (onlyMapped && !column.mapped) {
+				continue
+			}
+			value := ""
+			if i < len(row) {
+				value = strings.TrimSpace(row[i])
+			}
+			item[column.key] = value
+		}
+		items = append(items, item)
+	}
+
+	keys := []string{}
+	for _, column := range columns {
+		if column.key != "" && (!onlyMapped || column.mapped) {
+			keys = append(keys, column.key)
+		}
+	}
+
+	output := map[string]interface{}{
+		"items":     items,
+		"row_count": len(items),
+		"columns":   keys,
+	}
+	if sheet != "" {
+		output["sheet"] = sheet
+	}
+	if truncated {
+		output["truncated"] = true
+		Logf(ctx, "spreadsheet truncated to %d rows", maxRows)
+	}
+	return output, nil
+}
+
+// source loads the file named by binary, or downloads url
+func (s *SpreadsheetReadExecutor) source(ctx context.Context, config, input map[string]interface{}) (*BinaryData, error) {
+	if name := stringValue(config["binary"]); name != "" {
+		file, err := GetBinary(input, replaceVariables(name, input))
+		if err != nil {
+			return nil, err
+		}
+		if len(file.Data) > maxSpreadsheetBytes {
+			return nil, fmt.Errorf("spreadsheet is larger than %d MB", maxSpreadsheetBytes>>20)
+		}
+		return file, nil
+	}
+
+	rawURL := replaceVariables(stringValue(config["url"]), input)
+	if rawURL == "" {
+		return nil, errors.New("binary or url is required")
+	}
+
+	egress := s.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := egress.CheckURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	resp, err := egress.HTTPClient(60 * time.Second).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSpreadsheetBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read download: %w", err)
+	}
+	if len(body) > maxSpreadsheetBytes {
+		return nil, fmt.Errorf("spreadsheet is larger than %d MB", maxSpreadsheetBytes>>20)
+	}
+	return &BinaryData{
+		FileName: path.Base(req.URL.Path),
+		MimeType: resp.Header.Get("Content-Type"),
+		Data:     body,
+	}, nil
+}
+
+// spreadsheetFormat returns the configured format, or detects it from the
+// file name, the mime type and finally the content
+func spreadsheetFormat(format string, file *BinaryData) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case spreadsheetFormatCSV, spreadsheetFormatXLSX:
+		return format, nil
+	case "", "auto":
+	default:
+		return "", fmt.Errorf("unsupported spreadsheet format %q", format)
+	}
+
+	switch strings.ToLower(path.Ext(file.FileName)) {
+	case ".xlsx", ".xlsm":
+		return spreadsheetFormatXLSX, nil
+	case ".csv", ".tsv", ".txt":
+		return spreadsheetFormatCSV, nil
+	case ".xls":
+		return "", errors.New("legacy .xls files are not supported; save the file as .xlsx or .csv")
+	}
+
+	switch {
+	case bytes.HasPrefix(file.Data, []byte("PK\x03\x04")):
+		return spreadsheetFormatXLSX, nil
+	case bytes.HasPrefix(file.Data, []byte("\xD0\xCF\x11\xE0")):
+		return "", errors.New("legacy .xls files are not supported; save the file as .xlsx or .csv")
+	case strings.Contains(file.MimeType, "spreadsheetml"):
+		return spreadsheetFormatXLSX, nil
+	}
+	return spreadsheetFormatCSV, nil
+}
+
+// readCSV decodes data and splits it into rows. An empty delimiter is
+// detected from the first line; an empty or "auto" encoding is detected from
+// the byte order mark, falling back to Windows-1251 for text that is not
+// valid UTF-8 (the default of Excel on Russian-locale Windows).
+func readCSV(data []byte, delimiter, encodingName string) ([][]string, error) {
+	text, err := decodeText(data, encodingName)
+	if err != nil {
+		return nil, err
+	}
+
+	var comma rune
+	switch delimiter {
+	case "", "auto":
+		comma = detectDelimiter(text)
+	case "\\t", "tab":
+		comma = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || r == '"' || r == '\n' || r == '\r' {
+			return nil, fmt.Errorf("invalid delimiter %q", delimiter)
+		}
+		comma = r
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+		rows = append(rows, record)
+		if len(rows) > maxSpreadsheetRows+1 {
+			break
+		}
+	}
+	return rows, nil
+}
+
+// decodeText converts data in the named encoding to UTF-8 and strips any byte order mark
+func decodeText(data []byte, name string) (string, error) {
+	var enc encoding.Encoding
+	switch strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", "-")) {
+	case "", "auto":
+		switch {
+		case bytes.HasPrefix(data, []byte("\xEF\xBB\xBF")):
+			return string(data[3:]), nil
+		case bytes.HasPrefix(data, []byte("\xFF\xFE")), bytes.HasPrefix(data, []byte("\xFE\xFF")):
+			enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+		case utf8.Valid(data):
+			return string(data), nil
+		default:
+			enc = charmap.Windows1251
+		}
+	case "utf-8", "utf8":
+		return string(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))), nil
+	case "utf-16", "utf16":
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case "windows-1251", "cp1251":
+		enc = charmap.Windows1251
+	case "windows-1252", "cp1252":
+		enc = charmap.Windows1252
+	case "koi8-r", "koi8r":
+		enc = charmap.KOI8R
+	case "iso-8859-1", "latin1":
+		enc = charmap.ISO8859_1
+	default:
+		return "", fmt.Errorf("unsupported encoding %q", name)
+	}
+
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s text: %w", name, err)
+	}
+	return string(bytes.TrimPrefix(decoded, []byte("\xEF\xBB\xBF"))), nil
+}
+
+// detectDelimiter picks the candidate that occurs most often outside quotes
+// in the first non-empty line, defaulting to a comma
+func detectDelimiter(text string) rune {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var line string
+	for scanner.Scan() {
+		if line = scanner.Text(); strings.TrimSpace(line) != "" {
+			break
+		}
+	}
+
+	counts := make(map[rune]int)
+	quoted := false
+	for _, r := range line {
+		if r == '"' {
+			quoted = !quoted
+			continue
+		}
+		if !quoted {
+			counts[r]++
+		}
+	}
+
+	best := ','
+	for _, candidate := range csvDelimiters {
+		if counts[candidate] > counts[best] {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// readXLSX returns the rows of the selected sheet: a name, a 1-based
+// position, or the first sheet when unset
+func readXLSX(data []byte, selector interface{}) (rows [][]string, sheet string, err error) {
+	if err := checkXLSXArchive(data); err != nil {
+		return nil, "", err
+	}
+
+	// excelize panics on some malformed documents; report them as invalid files
+	defer func() {
+		if r := recover(); r != nil {
+			rows, sheet, err = nil, "", fmt.Errorf("invalid xlsx file: %v", r)
+		}
+	}()
+
+	file, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid xlsx file: %w", err)
+	}
+
+	sheets := file.GetSheetMap()
+	indexes := make([]int, 0, len(sheets))
+	for index := range sheets {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	if len(indexes) == 0 {
+		return nil, "", errors.New("xlsx file has no sheets")
+	}
+
+	switch v := selector.(type) {
+	case nil:
+		sheet = sheets[indexes[0]]
+	case float64:
+		position := int(v)
+		if position < 1 || position > len(indexes) {
+			return nil, "", fmt.Errorf("sheet %d not found; the file has %d sheets", position, len(indexes))
+		}
+		sheet = sheets[indexes[position-1]]
+	default:
+		name := strings.TrimSpace(stringValue(v))
+		if name == "" {
+			sheet = sheets[indexes[0]]
+			break
+		}
+		for _, index := range indexes {
+			if strings.EqualFold(sheets[index], name) {
+				sheet = sheets[index]
+				break
+			}
+		}
+		if sheet == "" {
+			if position, err := strconv.Atoi(name); err == nil && position >= 1 && position <= len(indexes) {
+				sheet = sheets[indexes[position-1]]
+			} else {
+				return nil, "", fmt.Errorf("sheet %q not found", name)
+			}
+		}
+	}
+
+	rows = file.GetRows(sheet)
+	if len(rows) > maxSpreadsheetRows+1 {
+		rows = rows[:maxSpreadsheetRows+1]
+	}
+	return rows, sheet, nil
+}
+
+// checkXLSXArchive rejects archives excelize cannot read safely: it unpacks
+// every entry into memory and exits the process on entries it cannot open
+func checkXLSXArchive(data []byte) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid xlsx file: %w", err)
+	}
+	var unpacked uint64
+	for _, entry := range archive.File {
+		if entry.Method != zip.Store && entry.Method != zip.Deflate {
+			return fmt.Errorf("invalid xlsx file: unsupported compression in %s", entry.Name)
+		}
+		unpacked += entry.UncompressedSize64
+		if unpacked > maxSpreadsheetUnpackedBytes {
+			return errors.New("invalid xlsx file: unpacked size is too large")
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("invalid xlsx file: %w", err)
+		}
+		rc.Close()
+	}
+	return nil
+}
+
+type spreadsheetColumn struct {
+	key    string
+	mapped bool
+}
+
+// spreadsheetColumns names each column from the header row and header_map.
+// Blank headers, or all headers when there is no header row, become
+// column_1, column_2, ...; repeated names get a _2, _3 suffix.
+func spreadsheetColumns(header []string, rows [][]string, headerMap map[string]interface{}) []spreadsheetColumn {
+	width := len(header)
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	mapping := make(map[string]string, len(headerMap))
+	for from, to := range headerMap {
+		mapping[strings.ToLower(strings.TrimSpace(from))] = strings.TrimSpace(stringValue(to))
+	}
+
+	columns := make([]spreadsheetColumn, width)
+	seen := make(map[string]int)
+	for i := range columns {
+		name := ""
+		if i < len(header) {
+			name = strings.TrimSpace(header[i])
+		}
+		fallback := fmt.Sprintf("column_%d", i+1)
+		if name == "" {
+			name = fallback
+		}
+
+		key, mapped := mapping[strings.ToLower(name)]
+		if !mapped {
+			key, mapped = mapping[fallback]
+		}
+		if !mapped {
+			key = name
+		}
+		if key == "" {
+			// mapped to "" drops the column
+			continue
+		}
+
+		seen[key]++
+		if seen[key] > 1 {
+			key = fmt.Sprintf("%s_%d", key, seen[key])
+		}
+		columns[i] = spreadsheetColumn{key: key, mapped: mapped}
+	}
+	return columns
+}
+
+func emptyRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// SpreadsheetWriteExecutor turns items into a CSV or XLSX file stored as
+// execution binary data, so a later email node can attach it or an upload
+// node can send it
+type SpreadsheetWriteExecutor struct{}
+
+func (s *SpreadsheetWriteExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid spreadsheet write configuration")
+	}
+
+	itemsKey := stringValue(config["items_key"])
+	if itemsKey == "" {
+		itemsKey = "items"
+	}
+	items, err := itemList(input[itemsKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", itemsKey, err)
+	}
+	if len(items) > maxSpreadsheetRows {
+		return nil, fmt.Errorf("%s has more than %d rows", itemsKey, maxSpreadsheetRows)
+	}
+
+	fields, headers := spreadsheetWriteColumns(config["columns"], items)
+	if len(fields) == 0 {
+		return nil, errors.New("columns are required when there are no items")
+	}
+
+	format := strings.ToLower(stringValue(config["format"]))
+	if format == "" {
+		format = spreadsheetFormatCSV
+	}
+	fileName := replaceVariables(stringValue(config["file_name"]), input)
+	if fileName == "" {
+		fileName = "export"
+	}
+	if !strings.EqualFold(path.Ext(fileName), "."+format) {
+		fileName += "." + format
+	}
+
+	var data []byte
+	var mimeType string
+	switch format {
+	case spreadsheetFormatCSV:
+		data, err = writeCSV(fields, headers, items, config)
+		mimeType = mimeCSV
+	case spreadsheetFormatXLSX:
+		data, err = writeXLSX(fields, headers, items, stringValue(config["sheet_name"]))
+		mimeType = mimeXLSX
+	default:
+		return nil, fmt.Errorf("unsupported spreadsheet format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSpreadsheetBytes {
+		return nil, fmt.Errorf("spreadsheet is larger than %d MB", maxSpreadsheetBytes>>20)
+	}
+
+	name := stringValue(config["binary_name"])
+	if name == "" {
+		name = "spreadsheet"
+	}
+	Logf(ctx, "wrote %d rows to %s", len(items), fileName)
+
+	return map[string]interface{}{
+		BinaryKey:   WithBinary(input, name, &BinaryData{FileName: fileName, MimeType: mimeType, Data: data}),
+		"file_name": fileName,
+		"row_count": len(items),
+	}, nil
+}
+
+// itemList reads a list of objects from execution data, whether produced by
+// a node as []map[string]interface{} or decoded from JSON as []interface{}
+func itemList(value interface{}) ([]map[string]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		items := make([]map[string]interface{}, 0, len(v))
+		for i, entry := range v {
+			item, ok := entry.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("item %d is not an object", i)
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, errors.New("not a list of items")
+	}
+}
+
+// spreadsheetWriteColumns returns the item fields to write and their header
+// titles. columns is a list of field names or {"field", "header"} objects;
+// without it every field is written, in first-seen order with the fields of
+// each item sorted.
+func spreadsheetWriteColumns(columns interface{}, items []map[string]interface{}) ([]string, []string) {
+	var fields, headers []string
+	if list, ok := columns.([]interface{}); ok && len(list) > 0 {
+		for _, entry := range list {
+			switch v := entry.(type) {
+			case map[string]interface{}:
+				field := stringValue(v["field"])
+				if field == "" {
+					continue
+				}
+				header := stringValue(v["header"])
+				if header == "" {
+					header = field
+				}
+				fields = append(fields, field)
+				headers = append(headers, header)
+			default:
+				if field := stringValue(v); field != "" {
+					fields = append(fields, field)
+					headers = append(headers, field)
+				}
+			}
+		}
+		return fields, headers
+	}
+	if list := stringList(columns); len(list) > 0 {
+		return list, list
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range items {
+		keys := make([]string, 0, len(item))
+		for key := range item {
+			if !seen[key] && key != BinaryKey {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			seen[key] = true
+			fields = append(fields, key)
+		}
+	}
+	return fields, fields
+}
+
+// cellText formats a value for a CSV cell; objects and lists are written as JSON
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}, []map[string]interface{}, []string:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(raw)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return stringValue(v)
+	}
+}
+
+// writeCSV writes a header row and one row per item. Text that a spreadsheet
+// would evaluate as a formula is prefixed with a quote unless
+// escape_formulas is false; phone numbers like +7 999 ... are left as they are.
+func writeCSV(fields, headers []string, items []map[string]interface{}, config map[string]interface{}) ([]byte, error) {
+	comma := ','
+	switch delimiter := stringValue(config["delimiter"]); delimiter {
+	case "":
+	case "\\t", "tab":
+		comma = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || r == '"' || r == '\n' || r == '\r' {
+			return nil, fmt.Errorf("invalid delimiter %q", delimiter)
+		}
+		comma = r
+	}
+	escape := true
+	if v, ok := config["escape_formulas"].(bool); ok {
+		escape = v
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Comma = comma
+	if err := writer.Write(headers); err != nil {
+		return nil, err
+	}
+	row := make([]string, len(fields))
+	for _, item := range items {
+		for i, field := range fields {
+			row[i] = cellText(item[field])
+			if escape && formulaLike(row[i]) {
+				row[i] = "'" + row[i]
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	// Excel only reads UTF-8 CSV correctly with a byte order mark
+	switch enc := strings.ToLower(strings.ReplaceAll(stringValue(config["encoding"]), "_", "-")); enc {
+	case "", "utf-8", "utf8":
+		bom := true
+		if v, ok := config["bom"].(bool); ok {
+			bom = v
+		}
+		if bom {
+			return append([]byte("\xEF\xBB\xBF"), buf.Bytes()...), nil
+		}
+		return buf.Bytes(), nil
+	case "windows-1251", "cp1251":
+		encoded, _, err := transform.Bytes(encoding.ReplaceUnsupported(charmap.Windows1251.NewEncoder()), buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode csv: %w", err)
+		}
+		return encoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", enc)
+	}
+}
+
+// formulaLike reports whether a spreadsheet would treat text as a formula
+func formulaLike(text string) bool {
+	if text == "" {
+		return false
+	}
+	switch text[0] {
+	case '=', '@', '\t', '\r':
+		return true
+	case '+', '-':
+		for _, r := range text[1:] {
+			if !strings.ContainsRune("0123456789 ()-.,", r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeXLSX writes a single-sheet workbook with a header row. Numbers and
+// booleans keep their cell type; text is always stored as text.
+func writeXLSX(fields, headers []string, items []map[string]interface{}, sheetName string) ([]byte, error) {
+	sheetName = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(sheetName))
+	if sheetName == "" {
+		sheetName = "Sheet1"
+	}
+	if utf8.RuneCountInString(sheetName) > maxSheetNameLength {
+		sheetName = string([]rune(sheetName)[:maxSheetNameLength])
+	}
+
+	file := excelize.NewFile()
+	if sheetName != "Sheet1" {
+		file.SetSheetName("Sheet1", sheetName)
+	}
+
+	for i, header := range headers {
+		file.SetCellStr(sheetName, excelize.ToAlphaString(i)+"1", header)
+	}
+	for r, item := range items {
+		row := strconv.Itoa(r + 2)
+		for i, field := range fields {
+			axis := excelize.ToAlphaString(i) + row
+			switch v := item[field].(type) {
+			case nil:
+			case float64, int, int64, bool:
+				file.SetCellValue(sheetName, axis, v)
+			default:
+				file.SetCellStr(sheetName, axis, cellText(v))
+			}
+		}
+	}
+
+	buf, err := file.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write xlsx: %w", err)
+	}
+	return buf.Bytes(), nil
+}
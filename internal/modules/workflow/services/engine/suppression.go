@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SuppressionChecker reports which recipients a user's workflows must not message
+type SuppressionChecker interface {
+	// Suppressed returns the addresses among addresses that are on the user's
+	// list for channel (email, telegram or slack)
+	Suppressed(ctx context.Context, userID, channel string, addresses []string) ([]string, error)
+}
+
+// suppressedSet returns the suppressed addresses among addresses, lowercased.
+// It is empty when no checker is configured or the run has no owner.
+func suppressedSet(ctx context.Context, checker SuppressionChecker, channel string, addresses ...string) (map[string]bool, error) {
+	userID := RunInfoFromContext(ctx).UserID
+	if checker == nil || userID == "" || len(addresses) == 0 {
+		return nil, nil
+	}
+	found, err := checker.Suppressed(ctx, userID, channel, addresses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check suppression list: %w", err)
+	}
+	set := make(map[string]bool, len(found))
+	for _, address := range found {
+		set[strings.ToLower(address)] = true
+	}
+	return set, nil
+}
+
+// suppressedOutput is the output of a messaging node that skipped its
+// message because every recipient is suppressed; the run carries on
+func suppressedOutput(ctx context.Context, sentKey string, suppressed []string) map[string]interface{} {
+	Logf(ctx, "message not sent: %s is on the suppression list", strings.Join(suppressed, ", "))
+	return map[string]interface{}{
+		sentKey:       false,
+		"skipped":     true,
+		"skip_reason": "suppressed",
+		"suppressed":  suppressed,
+	}
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTelegramAPIURL is the Telegram Bot API base URL
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
+// TelegramMessageExecutor sends messages through a bot; the telegram connection holds "botToken"
+type TelegramMessageExecutor struct {
+	Connections  ConnectionResolver
+	Egress       *EgressPolicy
+	APIURL       string
+	Suppressions SuppressionChecker
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Result      struct {
+		MessageID int64 `json:"message_id"`
+		Chat      struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"result"`
+}
+
+func (t *TelegramMessageExecutor) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := node.Data["config"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid telegram configuration")
+	}
+
+	conn, err := resolveConnection(ctx, t.Connections, config, "telegram")
+	if err != nil {
+		return nil, err
+	}
+	botToken, _ := conn.Credentials["botToken"].(string)
+	if botToken == "" {
+		return nil, errors.New("telegram connection has no botToken")
+	}
+
+	chatID := replaceVariables(stringValue(config["chat_id"]), input)
+	text, _ := config["text"].(string)
+	text = replaceVariables(text, input)
+	if chatID == "" || text == "" {
+		return nil, errors.New("chat_id and text are required")
+	}
+	suppressed, err := suppressedSet(ctx, t.Suppressions, "telegram", chatID)
+	if err != nil {
+		return nil, err
+	}
+	if suppressed[strings.ToLower(chatID)] {
+		return suppressedOutput(ctx, "telegram_sent", []string{chatID}), nil
+	}
+
+	payload := map[string]interface{}{
+		"chat_id": numericID(chatID),
+		"text":    text,
+	}
+
+	// "markdown" and "html" map onto Telegram parse modes
+	switch format, _ := config["format"].(string); strings.ToLower(format) {
+	case "markdown":
+		payload["parse_mode"] = "MarkdownV2"
+	case "html":
+		payload["parse_mode"] = "HTML"
+	}
+	if disable, _ := config["disable_preview"].(bool); disable {
+		payload["link_preview_options"] = map[string]interface{}{"is_disabled": true}
+	}
+	if replyTo := replaceVariables(stringValue(config["reply_to_message_id"]), input); replyTo != "" {
+		payload["reply_parameters"] = map[string]interface{}{"message_id": numericID(replyTo)}
+	}
+	if threadID := replaceVariables(stringValue(config["message_thread_id"]), input); threadID != "" {
+		payload["message_thread_id"] = numericID(threadID)
+	}
+
+	apiURL := t.APIURL
+	if apiURL == "" {
+		apiURL = DefaultTelegramAPIURL
+	}
+	egress := t.Egress
+	if egress == nil {
+		egress = DefaultEgressPolicy()
+	}
+
+	var resp telegramResponse
+	url := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimRight(apiURL, "/"), botToken)
+	if _, err := doJSON(ctx, egress.HTTPClient(30*time.Second), http.MethodPost, url, nil, payload, &resp); err != nil {
+		// the bot token is part of the URL; keep it out of execution logs
+		return nil, fmt.Errorf("telegram: %s", strings.ReplaceAll(err.Error(), botToken, "***"))
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("telegram: %s", resp.Description)
+	}
+
+	return map[string]interface{}{
+		"telegram_sent": true,
+		"chat_id":       resp.Result.Chat.ID,
+		"message_id":    resp.Result.MessageID,
+	}, nil
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func telegramConnection(token string) staticConnections {
+	return staticConnections{conn: &Connection{ID: "conn-1", Service: "telegram", Credentials: map[string]interface{}{"botToken": token}}}
+}
+
+func telegramNode(config map[string]interface{}) *Node {
+	config["connection_id"] = "conn-1"
+	return &Node{ID: "telegram-1", Data: map[string]interface{}{"type": "telegram_message", "config": config}}
+}
+
+func TestTelegramExecutorSends(t *testing.T) {
+	server := newAPIServer(t, func(r apiRequest) (int, interface{}) {
+		if r.Body["chat_id"] == float64(-1) {
+			return http.StatusOK, map[string]interface{}{"ok": false, "description": "Bad Request: chat not found"}
+		}
+		return http.StatusOK, map[string]interface{}{"ok": true, "result": map[string]interface{}{"message_id": 321, "chat": map[string]interface{}{"id": -100123}}}
+	})
+	executor := &TelegramMessageExecutor{Connections: telegramConnection("123:secret"), Egress: localEgress(t), APIURL: server.URL + "/"}
+	input := map[string]interface{}{"chat": "-100123", "name": "Anna", "message": 320.0, "topic": "7"}
+
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		wantBody map[string]interface{}
+	}{
+		{
+			name:     "plain text",
+			config:   map[string]interface{}{"chat_id": "{{chat}}", "text": "Hi {{name}}"},
+			wantBody: map[string]interface{}{"chat_id": float64(-100123), "text": "Hi Anna"},
+		},
+		{
+			name: "reply in a topic",
+			config: map[string]interface{}{
+				"chat_id": "@sales_team", "text": "<b>Done</b>", "format": "HTML", "disable_preview": true,
+				"reply_to_message_id": "{{message}}", "message_thread_id": "{{topic}}",
+			},
+			wantBody: map[string]interface{}{
+				"chat_id": "@sales_team", "text": "<b>Done</b>", "parse_mode": "HTML",
+				"link_preview_options": map[string]interface{}{"is_disabled": true},
+				"reply_parameters":     map[string]interface{}{"message_id": float64(320)},
+				"message_thread_id":    float64(7),
+			},
+		},
+		{
+			name:     "markdown",
+			config:   map[string]interface{}{"chat_id": 42.0, "text": "*hi*", "format": "markdown", "reply_to_message_id": 5.0},
+			wantBody: map[string]interface{}{"chat_id": float64(42), "text": "*hi*", "parse_mode": "MarkdownV2", "reply_parameters": map[string]interface{}{"message_id": float64(5)}},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := executor.Execute(context.Background(), telegramNode(tt.config), input)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			want := map[string]interface{}{"telegram_sent": true, "chat_id": int64(-100123), "message_id": int64(321)}
+			if !reflect.DeepEqual(output, want) {
+				t.Errorf("output = %v, want %v", output, want)
+			}
+			req := server.Requests()[i]
+			if req.Method != http.MethodPost || req.Path != "/bot123:secret/sendMessage" {
+				t.Errorf("request = %s %s", req.Method, req.Path)
+			}
+			if !reflect.DeepEqual(req.Body, tt.wantBody) {
+				t.Errorf("body = %v, want %v", req.Body, tt.wantBody)
+			}
+		})
+	}
+
+	_, err := executor.Execute(context.Background(), telegramNode(map[string]interface{}{"chat_id": "-1", "text": "hi"}), input)
+	if err == nil || err.Error() != "telegram: Bad Request: chat not found" {
+		t.Errorf("Execute() = %v, want the Telegram error", err)
+	}
+	if _, err := executor.Execute(context.Background(), telegramNode(map[string]interface{}{"chat_id": "{{missing}}", "text": ""}), input); err == nil {
+		t.Error("Execute() without text succeeded")
+	}
+}
+
+func TestTelegramExecutorRedactsToken(t *testing.T) {
+	const token = "123456:AAF-super-secret"
+
+	failing := newAPIServer(t, func(r apiRequest) (int, interface{}) {
+		return http.StatusUnauthorized, map[string]interface{}{"ok": false, "description": "Unauthorized"}
+	})
+	// a server that is gone fails in the client, whose error names the URL
+	gone := newAPIServer(t, func(r apiRequest) (int, interface{}) { return http.StatusOK, nil })
+	gone.Close()
+
+	for name, apiURL := range map[string]string{"error status": failing.URL, "connection error": gone.URL} {
+		t.Run(name, func(t *testing.T) {
+			executor := &TelegramMessageExecutor{Connections: telegramConnection(token), Egress: localEgress(t), APIURL: apiURL}
+			_, err := executor.Execute(context.Background(), telegramNode(map[string]interface{}{"chat_id": "42", "text": "hi"}), nil)
+			if err == nil {
+				t.Fatal("Execute() succeeded")
+			}
+			if strings.Contains(err.Error(), "secret") {
+				t.Errorf("error reveals the bot token: %v", err)
+			}
+			if name == "connection error" && !strings.Contains(err.Error(), "/bot***/sendMessage") {
+				t.Errorf("error = %v, want the redacted URL", err)
+			}
+		})
+	}
+}
+
+func TestTelegramExecutorSuppression(t *testing.T) {
+	server := newAPIServer(t, func(r apiRequest) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"ok": true}
+	})
+	suppressions := &staticSuppressions{channel: "telegram", addresses: []string{"-100123", "@Sales_Team"}}
+	executor := &TelegramMessageExecutor{Connections: telegramConnection("123:secret"), Egress: localEgress(t), APIURL: server.URL, Suppressions: suppressions}
+	ctx := WithRunInfo(context.Background(), &RunInfo{UserID: "user-1"})
+
+	for _, chatID := range []interface{}{"{{chat}}", -100123.0, "@sales_team"} {
+		output, err := executor.Execute(ctx, telegramNode(map[string]interface{}{"chat_id": chatID, "text": "hi"}), map[string]interface{}{"chat": "-100123"})
+		if err != nil {
+			t.Fatalf("Execute(%v): %v", chatID, err)
+		}
+		if output["telegram_sent"] != false || output["skip_reason"] != "suppressed" {
+			t.Errorf("Execute(%v) = %v, want a suppressed skip", chatID, output)
+		}
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("sent %d requests to suppressed chats", n)
+	}
+
+	if _, err := executor.Execute(ctx, telegramNode(map[string]interface{}{"chat_id": "42", "text": "hi"}), nil); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("sent %d requests to another chat, want 1", n)
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/repository"
+)
+
+// ErrWorkflowNotFound is returned for workflows that do not exist or belong
+// to another user
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// ExperimentService records split node assignments and outcomes for workflow
+// executors and reports per-variant results
+type ExperimentService struct {
+	experimentRepo *repository.ExperimentRepository
+	workflowRepo   *repository.WorkflowRepository
+}
+
+func NewExperimentService(experimentRepo *repository.ExperimentRepository, workflowRepo *repository.WorkflowRepository) *ExperimentService {
+	return &ExperimentService{experimentRepo: experimentRepo, workflowRepo: workflowRepo}
+}
+
+func (s *ExperimentService) Assign(ctx context.Context, workflowID, nodeID, keyHash, executionID string, pick func() string) (string, error) {
+	if existing, err := s.experimentRepo.FindAssignment(workflowID, nodeID, keyHash); err == nil {
+		return existing.Variant, nil
+	}
+	assignment, err := s.experimentRepo.Assign(&models.ExperimentAssignment{
+		WorkflowID:  workflowID,
+		NodeID:      nodeID,
+		KeyHash:     keyHash,
+		Variant:     pick(),
+		ExecutionID: executionID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return assignment.Variant, nil
+}
+
+func (s *ExperimentService) RecordOutcome(ctx context.Context, workflowID, nodeID, keyHash, goal, executionID string) (string, bool, error) {
+	assignment, err := s.experimentRepo.FindAssignment(workflowID, nodeID, keyHash)
+	if err != nil {
+		// leads that never went through the split do not count
+		return "", false, nil
+	}
+	err = s.experimentRepo.RecordOutcome(&models.ExperimentOutcome{
+		WorkflowID:  workflowID,
+		NodeID:      nodeID,
+		KeyHash:     keyHash,
+		Goal:        goal,
+		Variant:     assignment.Variant,
+		ExecutionID: executionID,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return assignment.Variant, true, nil
+}
+
+// VariantReport is the result of one variant of a split node
+type VariantReport struct {
+	Name     string  `json:"name"`
+	Percent  float64 `json:"percent"`
+	Assigned int64   `json:"assigned"`
+	// Outcomes and Rates are keyed by goal; a rate is outcomes per assigned lead
+	Outcomes map[string]int64   `json:"outcomes"`
+	Rates    map[string]float64 `json:"rates"`
+}
+
+// ExperimentReport is the result of one split node
+type ExperimentReport struct {
+	NodeID   string           `json:"nodeId"`
+	Label    string           `json:"label,omitempty"`
+	Variants []*VariantReport `json:"variants"`
+}
+
+// Report returns the results of every split node of the workflow, including
+// variants no lead has reached yet and nodes since removed from the workflow
+func (s *ExperimentService) Report(workflowID, userID string) ([]*ExperimentReport, error) {
+	workflow, err := s.workflowRepo.FindByID(workflowID)
+	if err != nil || workflow.UserID != userID {
+		return nil, ErrWorkflowNotFound
+	}
+
+	reports := []*ExperimentReport{}
+	byNode := make(map[string]*ExperimentReport)
+	variant := func(nodeID, name string) *VariantReport {
+		report, ok := byNode[nodeID]
+		if !ok {
+			report = &ExperimentReport{NodeID: nodeID, Variants: []*VariantReport{}}
+			byNode[nodeID] = report
+			reports = append(reports, report)
+		}
+		for _, v := range report.Variants {
+			if v.Name == name {
+				return v
+			}
+		}
+		v := &VariantReport{Name: name, Outcomes: map[string]int64{}, Rates: map[string]float64{}}
+		report.Variants = append(report.Variants, v)
+		return v
+	}
+
+	// variants are reported as configured in production
+	graph := workflow.PublishedJSON
+	if !workflow.IsPublished() {
+		graph = workflow.JSON
+	}
+	var definition WorkflowDefinition
+	if err := json.Unmarshal([]byte(graph), &definition); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow: %w", err)
+	}
+	for _, node := range definition.Nodes {
+		nodeType := node.Type
+		if typeStr, ok := node.Data["type"].(string); ok {
+			nodeType = typeStr
+		}
+		if nodeType != "split" {
+			continue
+		}
+		config, _ := node.Data["config"].(map[string]interface{})
+		specs, _ := config["variants"].([]interface{})
+		for _, entry := range specs {
+			spec, _ := entry.(map[string]interface{})
+			name, _ := spec["name"].(string)
+			if name == "" {
+				continue
+			}
+			v := variant(node.ID, name)
+			v.Percent, _ = spec["percent"].(float64)
+		}
+		if report, ok := byNode[node.ID]; ok {
+			report.Label, _ = node.Data["label"].(string)
+		}
+	}
+
+	assigned, err := s.experimentRepo.CountAssignments(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range assigned {
+		variant(count.NodeID, count.Variant).Assigned = count.Count
+	}
+
+	outcomes, err := s.experimentRepo.CountOutcomes(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range outcomes {
+		v := variant(count.NodeID, count.Variant)
+		v.Outcomes[count.Goal] = count.Count
+		if v.Assigned > 0 {
+			v.Rates[count.Goal] = float64(count.Count) / float64(v.Assigned)
+		}
+	}
+	return reports, nil
+}
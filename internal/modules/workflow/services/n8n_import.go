@@ -0,0 +1,966 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"s4s-backend/internal/modules/workflow/dto"
+	"s4s-backend/internal/modules/workflow/services/engine"
+)
+
+// ErrNotN8nWorkflow is returned for n8n imports that are not n8n workflow exports
+var ErrNotN8nWorkflow = errors.New("not an n8n workflow export")
+
+// n8nLongWait is the default workflow timeout; longer waits need a higher one
+const n8nLongWait = 300 * time.Second
+
+// n8nWorkflow is the part of an n8n workflow export the importer reads
+type n8nWorkflow struct {
+	Name  string    `json:"name"`
+	Nodes []n8nNode `json:"nodes"`
+	// Connections maps a source node name to its outputs by connection type;
+	// each output lists the nodes it feeds
+	Connections map[string]map[string][][]n8nConnection `json:"connections"`
+	Tags        []json.RawMessage                       `json:"tags"`
+}
+
+type n8nNode struct {
+	ID          string                     `json:"id"`
+	Name        string                     `json:"name"`
+	Type        string                     `json:"type"`
+	TypeVersion float64                    `json:"typeVersion"`
+	Position    []float64                  `json:"position"`
+	Parameters  map[string]interface{}     `json:"parameters"`
+	Credentials map[string]json.RawMessage `json:"credentials"`
+	Disabled    bool                       `json:"disabled"`
+}
+
+type n8nConnection struct {
+	Node  string `json:"node"`
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+}
+
+// n8nMapped is an n8n node converted to one of ours
+type n8nMapped struct {
+	kind    string
+	trigger bool
+	config  map[string]interface{}
+	// handle names the edge handle of an n8n output; nil for nodes with a
+	// single output
+	handle func(output int) string
+}
+
+type n8nNodeConverter func(c *n8nConverter, node *n8nNode) (*n8nMapped, error)
+
+// n8nConverters maps n8n node types to their converters. Older trigger nodes
+// (cron, interval) become schedule triggers like scheduleTrigger.
+var n8nConverters = map[string]n8nNodeConverter{
+	"n8n-nodes-base.webhook":         convertN8nWebhook,
+	"n8n-nodes-base.manualTrigger":   convertN8nManualTrigger,
+	"n8n-nodes-base.scheduleTrigger": convertN8nSchedule,
+	"n8n-nodes-base.cron":            convertN8nCron,
+	"n8n-nodes-base.interval":        convertN8nInterval,
+	"n8n-nodes-base.httpRequest":     convertN8nHTTPRequest,
+	"n8n-nodes-base.if":              convertN8nIf,
+	"n8n-nodes-base.switch":          convertN8nSwitch,
+	"n8n-nodes-base.set":             convertN8nSet,
+	"n8n-nodes-base.wait":            convertN8nWait,
+	"n8n-nodes-base.emailSend":       convertN8nEmail,
+}
+
+// n8nIgnoredNodes are editor-only n8n nodes that are dropped without a warning
+var n8nIgnoredNodes = map[string]bool{
+	"n8n-nodes-base.stickyNote": true,
+}
+
+var (
+	n8nExpression = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+	n8nTemplate   = regexp.MustCompile(`\{\{.*?\}\}`)
+	// n8nDataRef matches references to the current item's fields: $json.x,
+	// $node["Name"].json.x and $('Name').item.json.x
+	n8nDataRef  = regexp.MustCompile(`^(?:\$json|\$node\[\s*(?:"[^"]*"|'[^']*')\s*\]\.json|\$\(\s*(?:"[^"]*"|'[^']*')\s*\)\.item\.json)((?:\.[A-Za-z_$][\w$]*|\[\s*(?:"[^"]*"|'[^']*'|\d+)\s*\])+)$`)
+	n8nPathPart = regexp.MustCompile(`\.([A-Za-z_$][\w$]*)|\[\s*(?:"([^"]*)"|'([^']*)'|(\d+))\s*\]`)
+)
+
+// ConvertN8n converts an n8n workflow export, or a list of them, into a
+// bundle. Supported nodes are mapped onto ours; anything that cannot be
+// carried over (unsupported nodes, credentials, expressions beyond field
+// references) is reported as a warning.
+func ConvertN8n(raw json.RawMessage) (*dto.Bundle, []string, error) {
+	var workflows []n8nWorkflow
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(raw, &workflows); err != nil {
+			return nil, nil, ErrNotN8nWorkflow
+		}
+	} else {
+		var workflow n8nWorkflow
+		if err := json.Unmarshal(raw, &workflow); err != nil {
+			return nil, nil, ErrNotN8nWorkflow
+		}
+		workflows = []n8nWorkflow{workflow}
+	}
+	if len(workflows) == 0 {
+		return nil, nil, ErrNotN8nWorkflow
+	}
+	if len(workflows) > maxBundleWorkflows {
+		return nil, nil, &ValidationError{Problems: []string{fmt.Sprintf("a bundle may have at most %d workflows", maxBundleWorkflows)}}
+	}
+
+	bundle := &dto.Bundle{
+		Format:       dto.BundleFormat,
+		Version:      dto.BundleVersion,
+		ExportedAt:   time.Now(),
+		Placeholders: []dto.BundlePlaceholder{},
+	}
+	var warnings []string
+	for i := range workflows {
+		workflow := &workflows[i]
+		if workflow.Nodes == nil {
+			return nil, nil, ErrNotN8nWorkflow
+		}
+		c := &n8nConverter{}
+		if len(workflows) > 1 {
+			c.prefix = fmt.Sprintf("workflow %q: ", workflow.Name)
+		}
+		graph, err := c.convert(workflow)
+		if err != nil {
+			return nil, nil, err
+		}
+		bundle.Workflows = append(bundle.Workflows, dto.BundleWorkflow{
+			Ref:        fmt.Sprintf("workflow-%d", i+1),
+			Name:       workflow.Name,
+			Graph:      graph,
+			Settings:   dto.BundleSettings{MaxTimeout: 300, RetryCount: 3, RetryDelay: 60},
+			Tags:       n8nTags(workflow.Tags),
+			References: []dto.BundleReference{},
+		})
+		warnings = append(warnings, c.warnings...)
+	}
+	return bundle, warnings, nil
+}
+
+// n8nTags reads tag names, which n8n exports as objects or plain strings
+func n8nTags(raw []json.RawMessage) []string {
+	tags := []string{}
+	for _, entry := range raw {
+		var tag struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(entry, &tag); err != nil {
+			json.Unmarshal(entry, &tag.Name)
+		}
+		if name := strings.TrimSpace(tag.Name); name != "" {
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+type n8nConverter struct {
+	prefix   string
+	warnings []string
+}
+
+func (c *n8nConverter) warn(node *n8nNode, format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf("%snode %q: %s", c.prefix, node.Name, fmt.Sprintf(format, args...)))
+}
+
+func (c *n8nConverter) convert(workflow *n8nWorkflow) (json.RawMessage, error) {
+	def := WorkflowDefinition{Nodes: []engine.Node{}, Edges: []Edge{}}
+	mapped := make(map[string]*n8nMapped, len(workflow.Nodes))
+	ids := make(map[string]string, len(workflow.Nodes))
+	hasTrigger := false
+
+	for i := range workflow.Nodes {
+		node := &workflow.Nodes[i]
+		if n8nIgnoredNodes[node.Type] {
+			continue
+		}
+		if node.Disabled {
+			c.warn(node, "is disabled in n8n and was left out")
+			continue
+		}
+		convert, ok := n8nConverters[node.Type]
+		if !ok {
+			c.warn(node, "%s nodes are not supported; the node was left out and the nodes after it are not connected", node.Type)
+			continue
+		}
+		if node.Parameters == nil {
+			node.Parameters = map[string]interface{}{}
+		}
+		result, err := convert(c, node)
+		if err != nil {
+			c.warn(node, "%v; the node was left out", err)
+			continue
+		}
+		if result.trigger && hasTrigger {
+			c.warn(node, "a workflow has a single trigger; this one was left out")
+			continue
+		}
+		hasTrigger = hasTrigger || result.trigger
+		for _, name := range sortedKeys(node.Credentials) {
+			c.warn(node, "%s credential was not imported", name)
+		}
+
+		id := node.ID
+		if id == "" {
+			id = fmt.Sprintf("node-%d", i+1)
+		}
+		nodeType := "action"
+		if result.trigger {
+			nodeType = "trigger"
+		}
+		position := engine.Position{}
+		if len(node.Position) == 2 {
+			position = engine.Position{X: node.Position[0], Y: node.Position[1]}
+		}
+		def.Nodes = append(def.Nodes, engine.Node{
+			ID:   id,
+			Type: nodeType,
+			Data: map[string]interface{}{
+				"type":   result.kind,
+				"label":  node.Name,
+				"config": result.config,
+			},
+			Position: position,
+		})
+		mapped[node.Name] = result
+		ids[node.Name] = id
+	}
+
+	// edges follow the order of the nodes, so the same export always converts the same way
+	for i := range workflow.Nodes {
+		node := &workflow.Nodes[i]
+		source, ok := mapped[node.Name]
+		if !ok {
+			continue
+		}
+		connections := workflow.Connections[node.Name]
+		for _, connectionType := range sortedKeys(connections) {
+			if connectionType != "main" {
+				c.warn(node, "%s connections are not supported", connectionType)
+			}
+		}
+		for output, targets := range connections["main"] {
+			handle := ""
+			if source.handle != nil {
+				handle = source.handle(output)
+			} else if output > 0 && len(targets) > 0 {
+				c.warn(node, "output %d is not supported; its connections were left out", output+1)
+				continue
+			}
+			for _, target := range targets {
+				targetID, ok := ids[target.Node]
+				if !ok {
+					continue
+				}
+				def.Edges = append(def.Edges, Edge{
+					ID:           fmt.Sprintf("edge-%d", len(def.Edges)+1),
+					Source:       ids[node.Name],
+					Target:       targetID,
+					SourceHandle: handle,
+				})
+			}
+		}
+	}
+	if !hasTrigger {
+		c.warnings = append(c.warnings, c.prefix+"no supported trigger; add one before publishing")
+	}
+
+	return json.Marshal(def)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// text converts an n8n parameter value to one of our templates. Plain values
+// are kept; expressions ("=...") have their field references rewritten to
+// {{field}}, and anything else is kept as written with a warning.
+func (c *n8nConverter) text(node *n8nNode, value interface{}) string {
+	s, ok := value.(string)
+	if !ok {
+		if value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	}
+	if !strings.HasPrefix(s, "=") {
+		return s
+	}
+	converted, ok := convertN8nExpression(s[1:])
+	if !ok {
+		c.warn(node, "expression %q was kept as written; only field references such as {{ $json.email }} are converted", s[1:])
+	}
+	return converted
+}
+
+// value converts a parameter that may also be a number, boolean or object
+func (c *n8nConverter) value(node *n8nNode, value interface{}) interface{} {
+	if _, ok := value.(string); ok {
+		return c.text(node, value)
+	}
+	return value
+}
+
+// convertN8nExpression rewrites {{ $json.a.b }} references to {{a.b}} and
+// reports whether every expression could be rewritten
+func convertN8nExpression(expression string) (string, bool) {
+	ok := true
+	converted := n8nExpression.ReplaceAllStringFunc(expression, func(match string) string {
+		path, found := n8nFieldPath(n8nExpression.FindStringSubmatch(match)[1])
+		if !found {
+			ok = false
+			return match
+		}
+		return "{{" + path + "}}"
+	})
+	return converted, ok
+}
+
+// n8nFieldPath returns the dotted path of a field reference such as
+// $json["lead"].email
+func n8nFieldPath(reference string) (string, bool) {
+	match := n8nDataRef.FindStringSubmatch(strings.TrimSpace(reference))
+	if match == nil {
+		return "", false
+	}
+	var parts []string
+	for _, part := range n8nPathPart.FindAllStringSubmatch(match[1], -1) {
+		for _, name := range part[1:] {
+			if name != "" {
+				parts = append(parts, name)
+				break
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	return strings.Join(parts, "."), true
+}
+
+// n8nFieldTemplate returns the path of a value that is exactly one field
+// reference, e.g. "={{ $json.status }}"
+func n8nFieldTemplate(value interface{}) (string, bool) {
+	s, _ := value.(string)
+	if !strings.HasPrefix(s, "=") {
+		return "", false
+	}
+	match := n8nExpression.FindStringSubmatch(strings.TrimSpace(s[1:]))
+	if match == nil || match[0] != strings.TrimSpace(s[1:]) {
+		return "", false
+	}
+	return n8nFieldPath(match[1])
+}
+
+func n8nParam(params map[string]interface{}, key string) map[string]interface{} {
+	value, _ := params[key].(map[string]interface{})
+	return value
+}
+
+func n8nList(params map[string]interface{}, key string) []map[string]interface{} {
+	list, _ := params[key].([]interface{})
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, entry := range list {
+		if item, ok := entry.(map[string]interface{}); ok {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func n8nString(params map[string]interface{}, key, fallback string) string {
+	if value, ok := params[key].(string); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func n8nNumber(params map[string]interface{}, key string, fallback float64) float64 {
+	if value, ok := params[key].(float64); ok {
+		return value
+	}
+	return fallback
+}
+
+func convertN8nWebhook(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	if mode := n8nString(node.Parameters, "responseMode", "onReceived"); mode != "onReceived" {
+		c.warn(node, "responds immediately; response mode %q is not supported", mode)
+	}
+	return &n8nMapped{
+		kind:    "webhook",
+		trigger: true,
+		config: map[string]interface{}{
+			"method": n8nString(node.Parameters, "httpMethod", "GET"),
+			"path":   n8nString(node.Parameters, "path", ""),
+		},
+	}, nil
+}
+
+func convertN8nManualTrigger(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	c.warn(node, "manual triggers become webhook triggers; use test runs to start the workflow by hand")
+	return &n8nMapped{kind: "webhook", trigger: true, config: map[string]interface{}{}}, nil
+}
+
+// n8nIntervalUnits are the interval units of n8n schedule nodes, in seconds
+var n8nIntervalUnits = map[string]float64{
+	"seconds": 1,
+	"minutes": 60,
+	"hours":   3600,
+	"days":    86400,
+	"weeks":   7 * 86400,
+	"months":  30 * 86400,
+}
+
+func (c *n8nConverter) schedule(node *n8nNode, seconds float64) *n8nMapped {
+	if seconds < minScheduleInterval.Seconds() {
+		c.warn(node, "runs every %gs in n8n; schedules run at most once a minute", seconds)
+		seconds = minScheduleInterval.Seconds()
+	}
+	return &n8nMapped{
+		kind:    "schedule",
+		trigger: true,
+		config:  map[string]interface{}{"interval_seconds": seconds},
+	}
+}
+
+func convertN8nSchedule(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	rules := n8nList(n8nParam(node.Parameters, "rule"), "interval")
+	if len(rules) == 0 {
+		rules = []map[string]interface{}{{}}
+	}
+	if len(rules) > 1 {
+		c.warn(node, "has %d schedule rules; only the first was kept", len(rules))
+	}
+	rule := rules[0]
+
+	field := n8nString(rule, "field", "days")
+	for key := range rule {
+		if strings.HasPrefix(key, "triggerAt") {
+			c.warn(node, "runs at a set time in n8n; the schedule counts its interval from activation instead")
+			break
+		}
+	}
+	switch field {
+	case "cronExpression":
+		c.warn(node, "cron expression %q cannot be converted; the schedule runs daily, adjust interval_seconds", n8nString(rule, "expression", ""))
+		return c.schedule(node, n8nIntervalUnits["days"]), nil
+	case "months":
+		c.warn(node, "monthly schedules run every 30 days")
+	}
+	unit, ok := n8nIntervalUnits[field]
+	if !ok {
+		return nil, fmt.Errorf("schedule interval %q is not supported", field)
+	}
+	defaults := map[string]float64{"seconds": 30, "minutes": 5}
+	fallback, ok := defaults[field]
+	if !ok {
+		fallback = 1
+	}
+	amount := n8nNumber(rule, strings.TrimSuffix(field, "s")+"sInterval", fallback)
+	return c.schedule(node, amount*unit), nil
+}
+
+func convertN8nCron(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	items := n8nList(n8nParam(node.Parameters, "triggerTimes"), "item")
+	if len(items) == 0 {
+		return nil, errors.New("has no trigger times")
+	}
+	if len(items) > 1 {
+		c.warn(node, "has %d trigger times; only the first was kept", len(items))
+	}
+	item := items[0]
+	if _, ok := item["hour"]; ok {
+		c.warn(node, "runs at a set time in n8n; the schedule counts its interval from activation instead")
+	}
+
+	modes := map[string]float64{
+		"everyMinute": 60,
+		"everyHour":   3600,
+		"everyDay":    86400,
+		"everyWeek":   7 * 86400,
+		"everyMonth":  30 * 86400,
+	}
+	switch mode := n8nString(item, "mode", "everyDay"); mode {
+	case "everyX":
+		unit, ok := n8nIntervalUnits[n8nString(item, "unit", "hours")]
+		if !ok {
+			return nil, fmt.Errorf("interval unit %q is not supported", item["unit"])
+		}
+		return c.schedule(node, n8nNumber(item, "value", 2)*unit), nil
+	case "custom":
+		c.warn(node, "cron expression %q cannot be converted; the schedule runs daily, adjust interval_seconds", n8nString(item, "cronExpression", ""))
+		return c.schedule(node, modes["everyDay"]), nil
+	default:
+		seconds, ok := modes[mode]
+		if !ok {
+			return nil, fmt.Errorf("trigger mode %q is not supported", mode)
+		}
+		if mode == "everyMonth" {
+			c.warn(node, "monthly schedules run every 30 days")
+		}
+		return c.schedule(node, seconds), nil
+	}
+}
+
+func convertN8nInterval(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	unit, ok := n8nIntervalUnits[n8nString(node.Parameters, "unit", "seconds")]
+	if !ok {
+		return nil, fmt.Errorf("interval unit %q is not supported", node.Parameters["unit"])
+	}
+	return c.schedule(node, n8nNumber(node.Parameters, "interval", 1)*unit), nil
+}
+
+func convertN8nHTTPRequest(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	params := node.Parameters
+	config := map[string]interface{}{}
+	headers := map[string]interface{}{}
+	query := url.Values{}
+	var body interface{}
+
+	pairs := func(list []map[string]interface{}, into func(name string, value interface{})) {
+		for _, pair := range list {
+			if name := n8nString(pair, "name", ""); name != "" {
+				into(name, c.value(node, pair["value"]))
+			}
+		}
+	}
+	jsonParam := func(key string) interface{} {
+		raw := c.text(node, params[key])
+		if strings.TrimSpace(raw) == "" {
+			return nil
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+			c.warn(node, "%s is not valid JSON and was sent as a string", key)
+			return raw
+		}
+		return parsed
+	}
+	setHeaders := func(value interface{}) {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			c.warn(node, "headers could not be converted")
+			return
+		}
+		for name, v := range object {
+			headers[name] = fmt.Sprint(v)
+		}
+	}
+
+	if node.TypeVersion < 3 {
+		config["method"] = n8nString(params, "requestMethod", "GET")
+		if jsonParameters, _ := params["jsonParameters"].(bool); jsonParameters {
+			if value := jsonParam("headerParametersJson"); value != nil {
+				setHeaders(value)
+			}
+			body = jsonParam("bodyParametersJson")
+			if value := jsonParam("queryParametersJson"); value != nil {
+				if object, ok := value.(map[string]interface{}); ok {
+					for name, v := range object {
+						query.Set(name, fmt.Sprint(v))
+					}
+				}
+			}
+		} else {
+			pairs(n8nList(n8nParam(params, "headerParametersUi"), "parameter"), func(name string, value interface{}) { headers[name] = fmt.Sprint(value) })
+			pairs(n8nList(n8nParam(params, "queryParametersUi"), "parameter"), func(name string, value interface{}) { query.Set(name, fmt.Sprint(value)) })
+			fields := map[string]interface{}{}
+			pairs(n8nList(n8nParam(params, "bodyParametersUi"), "parameter"), func(name string, value interface{}) { fields[name] = value })
+			if len(fields) > 0 {
+				body = fields
+			}
+		}
+	} else {
+		config["method"] = n8nString(params, "method", "GET")
+		if send, _ := params["sendHeaders"].(bool); send {
+			if n8nString(params, "specifyHeaders", "keypair") == "json" {
+				if value := jsonParam("jsonHeaders"); value != nil {
+					setHeaders(value)
+				}
+			} else {
+				pairs(n8nList(n8nParam(params, "headerParameters"), "parameters"), func(name string, value interface{}) { headers[name] = fmt.Sprint(value) })
+			}
+		}
+		if send, _ := params["sendQuery"].(bool); send {
+			pairs(n8nList(n8nParam(params, "queryParameters"), "parameters"), func(name string, value interface{}) { query.Set(name, fmt.Sprint(value)) })
+		}
+		if send, _ := params["sendBody"].(bool); send {
+			if contentType := n8nString(params, "contentType", "json"); contentType != "json" {
+				c.warn(node, "%s bodies are not supported; the body is sent as JSON", contentType)
+			}
+			if n8nString(params, "specifyBody", "keypair") == "json" {
+				body = jsonParam("jsonBody")
+			} else {
+				fields := map[string]interface{}{}
+				pairs(n8nList(n8nParam(params, "bodyParameters"), "parameters"), func(name string, value interface{}) { fields[name] = value })
+				body = fields
+			}
+		}
+	}
+
+	if auth := n8nString(params, "authentication", "none"); auth != "none" {
+		c.warn(node, "authentication is not imported; add the credentials as a header")
+	}
+
+	target := c.text(node, params["url"])
+	if target == "" {
+		return nil, errors.New("has no url")
+	}
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(target, "?") {
+			separator = "&"
+		}
+		target += separator + n8nQuery(query)
+	}
+	config["url"] = target
+	if len(headers) > 0 {
+		config["headers"] = headers
+	}
+	if body != nil {
+		config["body"] = body
+	}
+	return &n8nMapped{kind: "http_request", config: config}, nil
+}
+
+// n8nQuery encodes query parameters sorted by name like url.Values.Encode,
+// but keeps templates as written so that they stay readable and expressions
+// that were not converted are not mangled
+func n8nQuery(query url.Values) string {
+	escape := func(text string) string {
+		var b strings.Builder
+		last := 0
+		for _, match := range n8nTemplate.FindAllStringIndex(text, -1) {
+			b.WriteString(url.QueryEscape(text[last:match[0]]))
+			b.WriteString(text[match[0]:match[1]])
+			last = match[1]
+		}
+		b.WriteString(url.QueryEscape(text[last:]))
+		return b.String()
+	}
+	var parts []string
+	for _, name := range sortedKeys(query) {
+		for _, value := range query[name] {
+			parts = append(parts, escape(name)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// n8nCondition is one comparison of an IF or Switch node
+type n8nCondition struct {
+	left, operation, right interface{}
+}
+
+// n8nConditions reads the conditions of IF nodes and Switch rules; version 1
+// groups them by data type, version 2 lists them with an operator
+func n8nConditions(params map[string]interface{}) ([]n8nCondition, string) {
+	conditions := n8nParam(params, "conditions")
+	var result []n8nCondition
+	if list := n8nList(conditions, "conditions"); len(list) > 0 {
+		for _, entry := range list {
+			operator := n8nParam(entry, "operator")
+			result = append(result, n8nCondition{left: entry["leftValue"], operation: operator["operation"], right: entry["rightValue"]})
+		}
+		return result, n8nString(conditions, "combinator", "and")
+	}
+	for _, dataType := range []string{"boolean", "dateTime", "number", "string"} {
+		for _, entry := range n8nList(conditions, dataType) {
+			operation := entry["operation"]
+			if operation == nil {
+				operation = "equal"
+			}
+			result = append(result, n8nCondition{left: entry["value1"], operation: operation, right: entry["value2"]})
+		}
+	}
+	combinator := "and"
+	if n8nString(params, "combineOperation", "all") == "any" {
+		combinator = "or"
+	}
+	return result, combinator
+}
+
+// n8nOperators maps n8n comparison operations, of version 1 and 2 nodes, to
+// the operators of our if conditions
+var n8nOperators = map[string]string{
+	"equal":          "==",
+	"equals":         "==",
+	"notEqual":       "!=",
+	"notEquals":      "!=",
+	"larger":         ">",
+	"gt":             ">",
+	"after":          ">",
+	"largerEqual":    ">=",
+	"gte":            ">=",
+	"afterOrEquals":  ">=",
+	"smaller":        "<",
+	"lt":             "<",
+	"before":         "<",
+	"smallerEqual":   "<=",
+	"lte":            "<=",
+	"beforeOrEquals": "<=",
+}
+
+// comparison returns the operator and right-hand side of a condition,
+// including the unary "is true" and "is false" checks
+func (cond n8nCondition) comparison() (string, interface{}, bool) {
+	switch cond.operation {
+	case "true":
+		return "==", true, true
+	case "false":
+		return "==", false, true
+	}
+	operation, _ := cond.operation.(string)
+	operator, ok := n8nOperators[operation]
+	return operator, cond.right, ok
+}
+
+// equality returns the right-hand side of an equality condition
+func (cond n8nCondition) equality() (interface{}, bool) {
+	operator, right, ok := cond.comparison()
+	return right, ok && operator == "=="
+}
+
+func convertN8nIf(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	conditions, combinator := n8nConditions(node.Parameters)
+	condition := ""
+	var unsupported []string
+	for _, cond := range conditions {
+		operator, right, ok := cond.comparison()
+		if !ok {
+			unsupported = append(unsupported, fmt.Sprint(cond.operation))
+			continue
+		}
+		left, isField := n8nFieldTemplate(cond.left)
+		literal := c.text(node, right)
+		if !isField || strings.Contains(literal, "{{") {
+			continue
+		}
+		condition = left + " " + operator + " " + literal
+		break
+	}
+
+	switch {
+	case condition == "" && len(unsupported) > 0:
+		c.warn(node, "operations %s cannot be converted; if nodes compare one field with a value using ==, !=, >, >=, < or <=; the node takes the false branch until you set a condition", strings.Join(unsupported, ", "))
+	case condition == "":
+		c.warn(node, "conditions could not be converted; if nodes compare one field with a value, e.g. status == won; the node takes the false branch until you set one")
+	case len(conditions) > 1:
+		c.warn(node, "has %d conditions combined with %s; only %q was kept", len(conditions), combinator, condition)
+	}
+	return &n8nMapped{
+		kind:   "if",
+		config: map[string]interface{}{"condition": condition},
+		handle: func(output int) string {
+			if output == 0 {
+				return "true"
+			}
+			return "false"
+		},
+	}, nil
+}
+
+func convertN8nSwitch(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	params := node.Parameters
+	if mode := n8nString(params, "mode", "rules"); mode != "rules" {
+		return nil, fmt.Errorf("%s mode is not supported, only rules", mode)
+	}
+
+	config := map[string]interface{}{}
+	cases := []interface{}{}
+	addCase := func(output int, cond n8nCondition) {
+		right, ok := cond.equality()
+		if !ok {
+			c.warn(node, "rule for output %d uses %v, which is not supported; only equality is", output+1, cond.operation)
+			return
+		}
+		spec := map[string]interface{}{"equals": c.text(node, right), "output": strconv.Itoa(output)}
+		if cond.left != nil {
+			spec["value"] = c.text(node, cond.left)
+		}
+		cases = append(cases, spec)
+	}
+
+	if node.TypeVersion < 3 {
+		config["value"] = c.text(node, params["value1"])
+		for _, rule := range n8nList(n8nParam(params, "rules"), "rules") {
+			operation := rule["operation"]
+			if operation == nil {
+				operation = "equal"
+			}
+			addCase(int(n8nNumber(rule, "output", 0)), n8nCondition{operation: operation, right: rule["value2"]})
+		}
+		if fallback := n8nNumber(params, "fallbackOutput", -1); fallback >= 0 {
+			config["default_output"] = strconv.Itoa(int(fallback))
+		}
+	} else {
+		rules := n8nList(n8nParam(params, "rules"), "values")
+		for i, rule := range rules {
+			conditions, _ := n8nConditions(rule)
+			if len(conditions) == 0 {
+				continue
+			}
+			if len(conditions) > 1 {
+				c.warn(node, "rule for output %d has %d conditions; only the first was kept", i+1, len(conditions))
+			}
+			addCase(i, conditions[0])
+		}
+		switch fallback := n8nParam(params, "options")["fallbackOutput"].(type) {
+		case string:
+			if fallback == "extra" {
+				config["default_output"] = strconv.Itoa(len(rules))
+			}
+		case float64:
+			config["default_output"] = strconv.Itoa(int(fallback))
+		}
+	}
+	if len(cases) == 0 {
+		return nil, errors.New("has no rules that could be converted")
+	}
+	config["cases"] = cases
+	return &n8nMapped{kind: "switch", config: config, handle: strconv.Itoa}, nil
+}
+
+func convertN8nSet(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	params := node.Parameters
+	values := map[string]interface{}{}
+
+	switch {
+	case n8nString(params, "mode", "manual") == "raw":
+		raw := c.text(node, params["jsonOutput"])
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &object); err != nil {
+			return nil, errors.New("JSON output is not a JSON object")
+		}
+		for name, value := range object {
+			values[name] = value
+		}
+	case node.TypeVersion >= 3.3:
+		for _, assignment := range n8nList(n8nParam(params, "assignments"), "assignments") {
+			if name := n8nString(assignment, "name", ""); name != "" {
+				values[name] = c.value(node, assignment["value"])
+			}
+		}
+	case node.TypeVersion >= 3:
+		for _, field := range n8nList(n8nParam(params, "fields"), "values") {
+			name := n8nString(field, "name", "")
+			if name == "" {
+				continue
+			}
+			// types are written "stringValue" or, in some versions, "string"
+			valueKey := n8nString(field, "type", "stringValue")
+			if !strings.HasSuffix(valueKey, "Value") {
+				valueKey += "Value"
+			}
+			values[name] = c.value(node, field[valueKey])
+		}
+	default:
+		if keep, _ := params["keepOnlySet"].(bool); keep {
+			c.warn(node, "keeps only the set fields in n8n; here the fields are added to the run data")
+		}
+		groups := n8nParam(params, "values")
+		for _, dataType := range []string{"boolean", "number", "string"} {
+			for _, field := range n8nList(groups, dataType) {
+				if name := n8nString(field, "name", ""); name != "" {
+					values[name] = c.value(node, field["value"])
+				}
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil, errors.New("sets no fields")
+	}
+	return &n8nMapped{kind: "set", config: map[string]interface{}{"values": values}}, nil
+}
+
+func convertN8nWait(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	params := node.Parameters
+	if resume := n8nString(params, "resume", "timeInterval"); resume != "timeInterval" {
+		return nil, fmt.Errorf("resuming on %s is not supported, only waiting for a time interval", resume)
+	}
+	// version 1 waited in hours by default
+	defaultUnit := "seconds"
+	if node.TypeVersion < 1.1 {
+		defaultUnit = "hours"
+	}
+	unit, ok := n8nIntervalUnits[n8nString(params, "unit", defaultUnit)]
+	if !ok {
+		return nil, fmt.Errorf("wait unit %q is not supported", params["unit"])
+	}
+	seconds := math.Round(n8nNumber(params, "amount", 1) * unit)
+	if time.Duration(seconds)*time.Second > n8nLongWait {
+		c.warn(node, "waits %s, longer than the default workflow timeout of %s; raise the timeout", time.Duration(seconds)*time.Second, n8nLongWait)
+	}
+	return &n8nMapped{kind: "delay", config: map[string]interface{}{"seconds": seconds}}, nil
+}
+
+func convertN8nEmail(c *n8nConverter, node *n8nNode) (*n8nMapped, error) {
+	params := node.Parameters
+	config := map[string]interface{}{}
+	fields := []struct{ from, to string }{
+		{"fromEmail", "from"},
+		{"toEmail", "to"},
+		{"ccEmail", "cc"},
+		{"bccEmail", "bcc"},
+		{"subject", "subject"},
+	}
+	for _, field := range fields {
+		if value := c.text(node, params[field.from]); value != "" {
+			config[field.to] = value
+		}
+	}
+	if config["to"] == nil {
+		return nil, errors.New("has no recipients")
+	}
+
+	format := n8nString(params, "emailFormat", "")
+	if format == "" && node.TypeVersion >= 2 {
+		format = "html"
+	}
+	// text emails have only a text body, html emails only an html one, and
+	// version 1 nodes without a format send both
+	if format != "html" {
+		if text := c.text(node, params["text"]); text != "" {
+			config["body"] = text
+		}
+	}
+	if format != "text" {
+		if html := c.text(node, params["html"]); html != "" {
+			config["html"] = html
+		} else if text := c.text(node, params["text"]); format == "html" && text != "" {
+			c.warn(node, "is an HTML email without an HTML body; its text was sent as the plain text body")
+			config["body"] = text
+		}
+	}
+	if config["body"] == nil && config["html"] == nil {
+		c.warn(node, "has no body; the node fails until you add one")
+	}
+
+	options := n8nParam(params, "options")
+	if replyTo := c.text(node, options["replyTo"]); replyTo != "" {
+		config["reply_to"] = replyTo
+	}
+	if _, ok := options["attachments"]; ok {
+		c.warn(node, "attachments are not imported")
+	}
+	if len(node.Credentials) > 0 {
+		c.warn(node, "sends through the platform relay until you choose an SMTP connection")
+	}
+	return &n8nMapped{kind: "email", config: config}, nil
+}
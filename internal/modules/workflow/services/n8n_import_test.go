@@ -0,0 +1,96 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"s4s-backend/internal/modules/workflow/dto"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the n8n import tests")
+
+// n8nGolden formats what a golden file holds: the converted bundle without
+// its export time, and the warnings. Keys are sorted and HTML characters are
+// not escaped, so conditions such as "score > 50" stay readable.
+func n8nGolden(t *testing.T, bundle *dto.Bundle, warnings []string) []byte {
+	t.Helper()
+	raw, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var converted map[string]interface{}
+	if err := json.Unmarshal(raw, &converted); err != nil {
+		t.Fatal(err)
+	}
+	delete(converted, "exportedAt")
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]interface{}{"bundle": converted, "warnings": warnings}); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestConvertN8n(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes string
+	}{
+		{"webhook_http_request", "Webhook and HTTP Request, versions 1 and 4"},
+		{"if_switch", "IF and Switch, versions 1 and 2 or 3"},
+		{"set_wait_schedule", "Schedule trigger, Set and Wait"},
+		{"email", "Cron trigger and Send Email"},
+		{"unsupported_disabled", "unsupported, disabled and editor-only nodes in a list of workflows"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export, err := os.ReadFile(filepath.Join("testdata", "n8n", tt.name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bundle, warnings, err := ConvertN8n(export)
+			if err != nil {
+				t.Fatalf("ConvertN8n(%s): %v", tt.nodes, err)
+			}
+			if bundle.ExportedAt.IsZero() {
+				t.Error("ExportedAt is not set")
+			}
+			got := n8nGolden(t, bundle, warnings)
+
+			golden := filepath.Join("testdata", "n8n", tt.name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run go test -run TestConvertN8n -update to create it", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s does not match; run go test -run TestConvertN8n -update and review the diff\ngot:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestConvertN8nRejectsOtherFiles(t *testing.T) {
+	for _, raw := range []string{`not json`, `[]`, `{"name": "x"}`, `[{"nodes": []}, {"name": "y"}]`, `"workflow"`} {
+		if _, _, err := ConvertN8n(json.RawMessage(raw)); !errors.Is(err, ErrNotN8nWorkflow) {
+			t.Errorf("ConvertN8n(%s) = %v, want ErrNotN8nWorkflow", raw, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+
+	scoringRepo "s4s-backend/internal/modules/scoring/repository"
+	"s4s-backend/internal/modules/workflow/services/engine"
+)
+
+// RuleSetResolver exposes the lead scoring rule sets to workflow executors
+type RuleSetResolver struct {
+	repo scoringRepo.RuleSetRepository
+}
+
+func NewRuleSetResolver(repo scoringRepo.RuleSetRepository) *RuleSetResolver {
+	return &RuleSetResolver{repo: repo}
+}
+
+func (r *RuleSetResolver) Resolve(ctx context.Context, userID, ruleSetID string) (*engine.ScoringRules, error) {
+	ruleSet, err := r.repo.GetByID(ruleSetID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &engine.ScoringRules{
+		ID:     ruleSet.ID,
+		Name:   ruleSet.Name,
+		Rules:  ruleSet.Rules,
+		Grades: ruleSet.Grades,
+	}, nil
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"context"
+
+	"s4s-backend/internal/modules/workflow/repository"
+)
+
+// StateStore exposes durable workflow state to workflow executors
+type StateStore struct {
+	repo *repository.WorkflowStateRepository
+}
+
+func NewStateStore(repo *repository.WorkflowStateRepository) *StateStore {
+	return &StateStore{repo: repo}
+}
+
+func (s *StateStore) Update(ctx context.Context, workflowID, key string, fn func(current []byte) (interface{}, error)) error {
+	return s.repo.Update(workflowID, key, fn)
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	connectionRepo "s4s-backend/internal/modules/connection/repository"
+	scoringRepo "s4s-backend/internal/modules/scoring/repository"
+	"s4s-backend/internal/modules/workflow/dto"
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/repository"
+)
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+// templateParam matches a parameter reference in a template graph, {{params.key}}
+var templateParam = regexp.MustCompile(`\{\{\s*params\.([A-Za-z0-9_]+)\s*\}\}`)
+
+// TemplateService lists workflow templates and creates workflows from them
+type TemplateService struct {
+	templateRepo    *repository.TemplateRepository
+	workflowService *WorkflowService
+	connectionRepo  connectionRepo.ConnectionRepository
+	ruleSetRepo     scoringRepo.RuleSetRepository
+}
+
+func NewTemplateService(
+	templateRepo *repository.TemplateRepository,
+	workflowService *WorkflowService,
+	connectionRepo connectionRepo.ConnectionRepository,
+	ruleSetRepo scoringRepo.RuleSetRepository,
+) *TemplateService {
+	return &TemplateService{
+		templateRepo:    templateRepo,
+		workflowService: workflowService,
+		connectionRepo:  connectionRepo,
+		ruleSetRepo:     ruleSetRepo,
+	}
+}
+
+// ListTemplates returns the templates of category carrying all of tags;
+// empty filters match every template
+func (s *TemplateService) ListTemplates(category string, tags []string) ([]models.Template, error) {
+	return s.templateRepo.FindByCategory(category, tags)
+}
+
+func (s *TemplateService) GetTemplate(templateID string) (*models.Template, error) {
+	template, err := s.templateRepo.FindByID(templateID)
+	if err != nil || !template.IsActive {
+		// templates switched off by an admin can't be viewed or used
+		return nil, ErrTemplateNotFound
+	}
+	return template, nil
+}
+
+// UseTemplate creates a workflow for the user from the template, with the
+// supplied parameter values filled into its graph. Invalid values are
+// reported together as a ValidationError.
+func (s *TemplateService) UseTemplate(templateID, userID string, req *dto.UseTemplateRequest) (*models.Workflow, error) {
+	template, err := s.GetTemplate(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	values, problems := s.resolveParameters(userID, template.Parameters, req.Parameters)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	graph, problems := fillParameters(template.JSON, values)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = template.Name
+	}
+	return s.workflowService.CreateWorkflow(userID, &dto.CreateWorkflowRequest{
+		Name:    name,
+		JSON:    graph,
+		Tags:    template.Tags,
+		Message: fmt.Sprintf("Created from template %q", template.Name),
+	})
+}
+
+// resolveParameters checks the supplied values against the template's
+// parameters and returns the value of every parameter by key; parameters
+// that are not supplied take their default
+func (s *TemplateService) resolveParameters(userID string, params models.TemplateParameters, supplied map[string]interface{}) (map[string]interface{}, []string) {
+	var problems []string
+	declared := make(map[string]bool, len(params))
+	for _, param := range params {
+		declared[param.Key] = true
+	}
+	for key := range supplied {
+		if !declared[key] {
+			problems = append(problems, fmt.Sprintf("unknown parameter %s", key))
+		}
+	}
+
+	values := make(map[string]interface{}, len(params))
+	for _, param := range params {
+		value, ok := supplied[param.Key]
+		if !ok || value == nil {
+			value = param.Default
+		}
+		if value == nil || value == "" {
+			if param.Required {
+				problems = append(problems, fmt.Sprintf("%s is required", parameterName(param)))
+				continue
+			}
+			values[param.Key] = zeroParameter(param.Type)
+			continue
+		}
+		if problem := s.checkParameter(userID, param, value); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s %s", parameterName(param), problem))
+			continue
+		}
+		values[param.Key] = value
+	}
+	return values, problems
+}
+
+// checkParameter returns what is wrong with value, or "" when it fits the parameter
+func (s *TemplateService) checkParameter(userID string, param models.TemplateParameter, value interface{}) string {
+	switch param.Type {
+	case models.ParameterString:
+		text, ok := value.(string)
+		if !ok {
+			return "must be text"
+		}
+		if len(param.Options) > 0 && !containsString(param.Options, text) {
+			return "must be one of " + strings.Join(param.Options, ", ")
+		}
+	case models.ParameterNumber:
+		number, ok := value.(float64)
+		if !ok {
+			return "must be a number"
+		}
+		if param.Min != nil && number < *param.Min {
+			return fmt.Sprintf("must be at least %g", *param.Min)
+		}
+		if param.Max != nil && number > *param.Max {
+			return fmt.Sprintf("must be at most %g", *param.Max)
+		}
+	case models.ParameterBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+	case models.ParameterConnection:
+		id, _ := value.(string)
+		conn, err := s.connectionRepo.GetByID(id, userID)
+		if err != nil {
+			return "must be one of your connections"
+		}
+		if param.Service != "" && conn.ServiceName != param.Service {
+			return fmt.Sprintf("must be a %s connection", param.Service)
+		}
+	case models.ParameterRuleSet:
+		id, _ := value.(string)
+		if _, err := s.ruleSetRepo.GetByID(id, userID); err != nil {
+			return "must be one of your rule sets"
+		}
+	default:
+		return fmt.Sprintf("has unknown type %q", param.Type)
+	}
+	return ""
+}
+
+func parameterName(param models.TemplateParameter) string {
+	if param.Label != "" {
+		return param.Label
+	}
+	return param.Key
+}
+
+func zeroParameter(paramType string) interface{} {
+	switch paramType {
+	case models.ParameterNumber:
+		return float64(0)
+	case models.ParameterBoolean:
+		return false
+	default:
+		return ""
+	}
+}
+
+// fillParameters replaces the parameter references in a template graph. A
+// string that is a single reference takes the value with its type, so
+// numbers and booleans stay numbers and booleans; references inside longer
+// strings are replaced with the value as text.
+func fillParameters(raw string, values map[string]interface{}) (string, []string) {
+	var graph interface{}
+	if err := json.Unmarshal([]byte(raw), &graph); err != nil {
+		return "", []string{"template has invalid json"}
+	}
+
+	undeclared := map[string]bool{}
+	var fill func(value interface{}) interface{}
+	fill = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			if match := templateParam.FindStringSubmatch(v); match != nil && match[0] == v {
+				if filled, ok := values[match[1]]; ok {
+					return filled
+				}
+				undeclared[match[1]] = true
+				return v
+			}
+			return templateParam.ReplaceAllStringFunc(v, func(ref string) string {
+				key := templateParam.FindStringSubmatch(ref)[1]
+				filled, ok := values[key]
+				if !ok {
+					undeclared[key] = true
+					return ref
+				}
+				return formatParameter(filled)
+			})
+		case map[string]interface{}:
+			for key, item := range v {
+				v[key] = fill(item)
+			}
+			return v
+		case []interface{}:
+			for i, item := range v {
+				v[i] = fill(item)
+			}
+			return v
+		default:
+			return value
+		}
+	}
+	graph = fill(graph)
+
+	if len(undeclared) > 0 {
+		keys := make([]string, 0, len(undeclared))
+		for key := range undeclared {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return "", []string{"template uses undeclared parameters: " + strings.Join(keys, ", ")}
+	}
+	filled, err := json.Marshal(graph)
+	if err != nil {
+		return "", []string{err.Error()}
+	}
+	return string(filled), nil
+}
+
+func formatParameter(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
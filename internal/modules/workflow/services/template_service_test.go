@@ -0,0 +1,261 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	connectionModels "s4s-backend/internal/modules/connection/models"
+	scoringModels "s4s-backend/internal/modules/scoring/models"
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+// userConnections is a connection repository holding one user's connections
+type userConnections struct {
+	userID      string
+	connections map[string]*connectionModels.Connection
+}
+
+func (r userConnections) GetByID(id, userID string) (*connectionModels.Connection, error) {
+	if conn, ok := r.connections[id]; ok && userID == r.userID {
+		return conn, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+func (userConnections) Create(conn *connectionModels.Connection) error {
+	return errors.New("not implemented")
+}
+
+func (userConnections) FindByUserID(userID string) ([]*connectionModels.Connection, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (userConnections) Update(conn *connectionModels.Connection) error {
+	return errors.New("not implemented")
+}
+
+func (userConnections) Delete(id, userID string) error {
+	return errors.New("not implemented")
+}
+
+// userRuleSets is a rule set repository holding one user's rule sets
+type userRuleSets struct {
+	userID string
+	ids    []string
+}
+
+func (r userRuleSets) GetByID(id, userID string) (*scoringModels.RuleSet, error) {
+	if userID == r.userID && slices.Contains(r.ids, id) {
+		return &scoringModels.RuleSet{ID: id}, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+func (userRuleSets) Create(ruleSet *scoringModels.RuleSet) error {
+	return errors.New("not implemented")
+}
+
+func (userRuleSets) FindByUserID(userID string) ([]*scoringModels.RuleSet, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (userRuleSets) Update(ruleSet *scoringModels.RuleSet) error {
+	return errors.New("not implemented")
+}
+
+func (userRuleSets) Delete(id, userID string) error {
+	return errors.New("not implemented")
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestResolveParameters(t *testing.T) {
+	s := &TemplateService{
+		connectionRepo: userConnections{userID: "user-1", connections: map[string]*connectionModels.Connection{
+			"conn-slack":   {ID: "conn-slack", ServiceName: "slack"},
+			"conn-hubspot": {ID: "conn-hubspot", ServiceName: "hubspot"},
+		}},
+		ruleSetRepo: userRuleSets{userID: "user-1", ids: []string{"rules-1"}},
+	}
+	params := models.TemplateParameters{
+		{Key: "channel", Label: "Channel", Type: models.ParameterString, Default: "#sales"},
+		{Key: "priority", Type: models.ParameterString, Options: []string{"low", "high"}},
+		{Key: "threshold", Label: "Score threshold", Type: models.ParameterNumber, Required: true, Min: floatPtr(0), Max: floatPtr(100)},
+		{Key: "notify", Type: models.ParameterBoolean, Default: true},
+		{Key: "slack", Label: "Slack", Type: models.ParameterConnection, Service: "slack"},
+		{Key: "rules", Type: models.ParameterRuleSet},
+	}
+
+	tests := []struct {
+		name         string
+		userID       string
+		supplied     map[string]interface{}
+		want         map[string]interface{}
+		wantProblems []string
+	}{
+		{
+			name:     "defaults and zero values",
+			supplied: map[string]interface{}{"threshold": 70.0},
+			want:     map[string]interface{}{"channel": "#sales", "priority": "", "threshold": 70.0, "notify": true, "slack": "", "rules": ""},
+		},
+		{
+			name: "supplied values replace defaults",
+			supplied: map[string]interface{}{
+				"channel": "#leads", "priority": "high", "threshold": 0.0, "notify": false, "slack": "conn-slack", "rules": "rules-1",
+			},
+			want: map[string]interface{}{"channel": "#leads", "priority": "high", "threshold": 0.0, "notify": false, "slack": "conn-slack", "rules": "rules-1"},
+		},
+		{
+			name:     "null takes the default",
+			supplied: map[string]interface{}{"channel": nil, "threshold": 5.0},
+			want:     map[string]interface{}{"channel": "#sales", "priority": "", "threshold": 5.0, "notify": true, "slack": "", "rules": ""},
+		},
+		{
+			name:         "required",
+			supplied:     map[string]interface{}{"threshold": ""},
+			wantProblems: []string{"Score threshold is required"},
+		},
+		{
+			name:     "wrong types",
+			supplied: map[string]interface{}{"channel": 5.0, "threshold": "70", "notify": "yes"},
+			wantProblems: []string{
+				"Channel must be text", "Score threshold must be a number", "notify must be true or false",
+			},
+		},
+		{
+			name:         "options and bounds",
+			supplied:     map[string]interface{}{"priority": "urgent", "threshold": 101.0},
+			wantProblems: []string{"priority must be one of low, high", "Score threshold must be at most 100"},
+		},
+		{
+			name:         "below the minimum",
+			supplied:     map[string]interface{}{"threshold": -1.0},
+			wantProblems: []string{"Score threshold must be at least 0"},
+		},
+		{
+			name:         "connection of another service",
+			supplied:     map[string]interface{}{"threshold": 1.0, "slack": "conn-hubspot"},
+			wantProblems: []string{"Slack must be a slack connection"},
+		},
+		{
+			name:         "another user's connection and rule set",
+			userID:       "user-2",
+			supplied:     map[string]interface{}{"threshold": 1.0, "slack": "conn-slack", "rules": "rules-1"},
+			wantProblems: []string{"Slack must be one of your connections", "rules must be one of your rule sets"},
+		},
+		{
+			name:         "unknown parameter",
+			supplied:     map[string]interface{}{"threshold": 1.0, "webhook": "https://example.com"},
+			wantProblems: []string{"unknown parameter webhook"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := tt.userID
+			if userID == "" {
+				userID = "user-1"
+			}
+			values, problems := s.resolveParameters(userID, params, tt.supplied)
+			if !slices.Equal(problems, tt.wantProblems) {
+				t.Fatalf("problems = %q, want %q", problems, tt.wantProblems)
+			}
+			if tt.want != nil && !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("values = %v, want %v", values, tt.want)
+			}
+		})
+	}
+
+	if _, problems := s.resolveParameters("user-1", models.TemplateParameters{{Key: "x", Type: "date"}}, map[string]interface{}{"x": "2026-10-19"}); !slices.Equal(problems, []string{`x has unknown type "date"`}) {
+		t.Errorf("problems = %q, want the unknown type", problems)
+	}
+}
+
+func TestFillParameters(t *testing.T) {
+	const hostile = `"}], "edges": [{"source": "x"}], "a": "\` + "\n\t<script>{{params.channel}}"
+
+	tests := []struct {
+		name         string
+		graph        string
+		values       map[string]interface{}
+		want         string
+		wantProblems []string
+	}{
+		{
+			name:   "whole strings keep the value's type",
+			graph:  `{"nodes": [{"id": "n", "data": {"config": {"threshold": "{{params.threshold}}", "notify": "{{ params.notify }}", "channel": "{{params.channel}}"}}}]}`,
+			values: map[string]interface{}{"threshold": 70.0, "notify": false, "channel": "#sales"},
+			want:   `{"nodes": [{"id": "n", "data": {"config": {"threshold": 70, "notify": false, "channel": "#sales"}}}]}`,
+		},
+		{
+			name:   "references inside text become text",
+			graph:  `{"nodes": [{"id": "n", "data": {"config": {"text": "Score over {{params.threshold}} ({{params.notify}}) in {{params.channel}}", "tags": ["{{params.channel}}-lead"]}}}]}`,
+			values: map[string]interface{}{"threshold": 70.5, "notify": true, "channel": "#sales"},
+			want:   `{"nodes": [{"id": "n", "data": {"config": {"text": "Score over 70.5 (true) in #sales", "tags": ["#sales-lead"]}}}]}`,
+		},
+		{
+			name:   "JSON-special characters stay inside their string",
+			graph:  `{"nodes": [{"id": "n", "data": {"config": {"text": "Hi {{params.channel}}!", "channel": "{{params.channel}}"}}}], "edges": []}`,
+			values: map[string]interface{}{"channel": hostile},
+			want: `{"nodes": [{"id": "n", "data": {"config": {"text": ` + quoteJSON(t, "Hi "+hostile+"!") +
+				`, "channel": ` + quoteJSON(t, hostile) + `}}}], "edges": []}`,
+		},
+		{
+			name:   "values are not filled again",
+			graph:  `{"text": "{{params.channel}} and {{params.other}}"}`,
+			values: map[string]interface{}{"channel": "{{params.other}}", "other": "x"},
+			want:   `{"text": "{{params.other}} and x"}`,
+		},
+		{
+			name:   "other templating is left alone",
+			graph:  `{"text": "{{contact.email}} {{ params }}", "count": 3}`,
+			values: map[string]interface{}{},
+			want:   `{"text": "{{contact.email}} {{ params }}", "count": 3}`,
+		},
+		{
+			name:         "undeclared parameters",
+			graph:        `{"a": "{{params.zeta}}", "b": "to {{params.alpha}}", "c": "{{params.channel}}"}`,
+			values:       map[string]interface{}{"channel": "#sales"},
+			wantProblems: []string{"template uses undeclared parameters: alpha, zeta"},
+		},
+		{
+			name:         "invalid template",
+			graph:        `{"nodes": [`,
+			wantProblems: []string{"template has invalid json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := fillParameters(tt.graph, tt.values)
+			if !slices.Equal(problems, tt.wantProblems) {
+				t.Fatalf("problems = %q, want %q", problems, tt.wantProblems)
+			}
+			if tt.want == "" {
+				return
+			}
+			var gotGraph, wantGraph interface{}
+			if err := json.Unmarshal([]byte(got), &gotGraph); err != nil {
+				t.Fatalf("filled graph is not JSON: %v\n%s", err, got)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantGraph); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotGraph, wantGraph) {
+				t.Errorf("graph = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func quoteJSON(t *testing.T, s string) string {
+	t.Helper()
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/services/engine"
+	"s4s-backend/internal/pkg/amqpconn"
+)
+
+const (
+	maxReconnectDelay = time.Minute
+	maxAMQPPrefetch   = 50
+	deadLetterTimeout = 30 * time.Second
+)
+
+// amqpTrigger is the config of an amqp_consume trigger node
+type amqpTrigger struct {
+	WorkflowID      string `json:"-"`
+	UserID          string `json:"-"`
+	ConnectionID    string `json:"connection_id"`
+	Queue           string `json:"queue"`
+	Prefetch        int    `json:"prefetch"`
+	OnFailure       string `json:"on_failure"`
+	DeadLetterQueue string `json:"dead_letter_queue"`
+}
+
+// amqpRunner runs the workflow with one delivery
+type amqpRunner func(ctx context.Context, data map[string]interface{}) (*models.Execution, error)
+
+// errWorkflowInactive stops a consumer whose workflow was deleted or
+// deactivated since the last sync
+var errWorkflowInactive = errors.New("workflow is no longer active")
+
+func (s *TriggerService) amqpSpec(workflow *models.Workflow, node *engine.Node) (*triggerSpec, error) {
+	trigger := amqpTrigger{}
+	fingerprint, err := triggerConfig(workflow, node, &trigger)
+	if err != nil || trigger.ConnectionID == "" || trigger.Queue == "" {
+		return nil, errors.New("amqp_consume trigger needs connection_id and queue")
+	}
+	trigger.WorkflowID = workflow.ID
+	trigger.UserID = workflow.UserID
+
+	return &triggerSpec{
+		fingerprint: fingerprint,
+		run:         func(ctx context.Context) { s.consume(ctx, trigger) },
+	}, nil
+}
+
+// consume keeps a consumer attached to the trigger's queue, reconnecting with
+// backoff when the broker goes away
+func (s *TriggerService) consume(ctx context.Context, trigger amqpTrigger) {
+	delay := time.Second
+	for {
+		connected, err := s.consumeOnce(ctx, trigger)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = time.Second
+		}
+		wait := delay
+		if errors.Is(err, errWorkflowInactive) {
+			// the next sync stops this trigger; consuming again before then
+			// would only take the same message back
+			wait = triggerSyncInterval
+		}
+		log.Printf("workflow %s: amqp consumer on %q stopped: %v; retrying in %s", trigger.WorkflowID, trigger.Queue, err, wait)
+
+		if !sleepContext(ctx, wait) {
+			return
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (s *TriggerService) consumeOnce(ctx context.Context, trigger amqpTrigger) (bool, error) {
+	// resolved on every reconnect so credential edits are picked up
+	conn, err := s.connections.Resolve(ctx, trigger.UserID, trigger.ConnectionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load connection: %w", err)
+	}
+	if conn.Service != "amqp" {
+		return false, fmt.Errorf("connection %s is a %s connection, expected amqp", conn.ID, conn.Service)
+	}
+	cfg, err := amqpconn.ConfigFromCredentials(conn.Credentials)
+	if err != nil {
+		return false, fmt.Errorf("invalid amqp connection: %w", err)
+	}
+
+	broker, err := amqpconn.Dial(ctx, cfg, s.egress.DialContext)
+	if err != nil {
+		return false, err
+	}
+	defer broker.Close()
+
+	ch, err := broker.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer ch.Close()
+
+	prefetch := trigger.Prefetch
+	if prefetch <= 0 {
+		prefetch = 1
+	}
+	if prefetch > maxAMQPPrefetch {
+		prefetch = maxAMQPPrefetch
+	}
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return false, err
+	}
+
+	var dlq *deadLetterer
+	if trigger.DeadLetterQueue != "" {
+		if dlq, err = openDeadLetterer(broker, trigger.DeadLetterQueue); err != nil {
+			return false, err
+		}
+		defer dlq.ch.Close()
+	}
+
+	deliveries, err := ch.Consume(trigger.Queue, "s4s-"+trigger.WorkflowID, false, false, false, false, nil)
+	if err != nil {
+		return false, err
+	}
+	closed := broker.NotifyClose(make(chan *amqp.Error, 1))
+	run := func(ctx context.Context, data map[string]interface{}) (*models.Execution, error) {
+		workflow, err := s.workflowRepo.FindByID(trigger.WorkflowID)
+		if err != nil || !workflow.Active {
+			return nil, errWorkflowInactive
+		}
+		return s.workflowService.RunTriggered(ctx, workflow, data)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case amqpErr := <-closed:
+			if amqpErr == nil {
+				return true, fmt.Errorf("connection closed")
+			}
+			return true, amqpErr
+		case d, ok := <-deliveries:
+			if !ok {
+				return true, fmt.Errorf("delivery channel closed")
+			}
+			if err := handleDelivery(ctx, dlq, trigger, d, run); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// deadLetterer publishes failed messages to the dead-letter queue on its own
+// channel in confirm mode, so the original is only acked once the broker has
+// taken responsibility for the copy
+type deadLetterer struct {
+	ch       deadLetterChannel
+	queue    string
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+	sent     uint64
+}
+
+// deadLetterChannel is the part of *amqp.Channel the dead-letterer publishes with
+type deadLetterChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// openDeadLetterer checks that queue exists, so a typo stops the consumer at
+// startup instead of dropping every failed message
+func openDeadLetterer(broker *amqp.Connection, queue string) (*deadLetterer, error) {
+	ch, err := broker.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ch.QueueDeclarePassive(queue, false, false, false, false, nil); err != nil {
+		// a failed passive declare closes the channel
+		return nil, fmt.Errorf("dead-letter queue %q: %w", queue, err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return &deadLetterer{
+		ch:       ch,
+		queue:    queue,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		returns:  ch.NotifyReturn(make(chan amqp.Return, 1)),
+	}, nil
+}
+
+// errDeadLetterUnknown means the broker's answer to a dead-letter publish
+// never arrived; the channel can't be trusted for further confirms
+var errDeadLetterUnknown = errors.New("no publisher confirm for dead-lettered message")
+
+// publish sends msg as mandatory and waits for the broker's confirm. A
+// returned message means the queue was deleted since startup.
+func (l *deadLetterer) publish(ctx context.Context, msg amqp.Publishing) error {
+	if err := l.ch.Publish("", l.queue, true, false, msg); err != nil {
+		return err
+	}
+	l.sent++
+
+	timer := time.NewTimer(deadLetterTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case confirm, ok := <-l.confirms:
+			if !ok {
+				return errDeadLetterUnknown
+			}
+			if confirm.DeliveryTag < l.sent {
+				continue
+			}
+			// a return is dispatched before the confirm of the same message
+			select {
+			case ret := <-l.returns:
+				return fmt.Errorf("message returned: %s", ret.ReplyText)
+			default:
+			}
+			if !confirm.Ack {
+				return errors.New("broker refused the message")
+			}
+			return nil
+		case <-timer.C:
+			return errDeadLetterUnknown
+		case <-ctx.Done():
+			return errDeadLetterUnknown
+		}
+	}
+}
+
+// handleDelivery runs one execution per message. The message is acked after
+// success; after a failure it is dead-lettered, requeued once, or nacked so
+// the broker applies the queue's own dead-letter policy. An error means the
+// consumer must stop: the workflow is gone, so the message is requeued for
+// others, or the dead-letter channel can no longer be trusted.
+func handleDelivery(ctx context.Context, dlq *deadLetterer, trigger amqpTrigger, d amqp.Delivery, run amqpRunner) error {
+	execution, err := run(ctx, engine.AMQPDeliveryData(d))
+	if errors.Is(err, errWorkflowInactive) {
+		d.Nack(false, true)
+		return err
+	}
+	if err == nil && execution.Status == "success" {
+		d.Ack(false)
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		// shutting down or the trigger changed; the message is not at fault
+		d.Nack(false, true)
+		return nil
+	}
+
+	reason := ""
+	executionID := ""
+	if err != nil {
+		reason = err.Error()
+	} else {
+		reason = execution.ErrorMessage
+		executionID = execution.ID
+	}
+
+	switch {
+	case dlq != nil:
+		headers := amqp.Table{}
+		for key, value := range d.Headers {
+			headers[key] = value
+		}
+		headers["x-s4s-workflow-id"] = trigger.WorkflowID
+		headers["x-s4s-execution-id"] = executionID
+		headers["x-s4s-error"] = reason
+		headers["x-s4s-original-queue"] = trigger.Queue
+
+		err := dlq.publish(ctx, amqp.Publishing{
+			Headers:       headers,
+			ContentType:   d.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			MessageId:     d.MessageId,
+			CorrelationId: d.CorrelationId,
+			Timestamp:     d.Timestamp,
+			Type:          d.Type,
+			Body:          d.Body,
+		})
+		if err != nil {
+			log.Printf("workflow %s: failed to dead-letter message to %q: %v", trigger.WorkflowID, trigger.DeadLetterQueue, err)
+			d.Nack(false, false)
+			if errors.Is(err, errDeadLetterUnknown) {
+				return err
+			}
+			return nil
+		}
+		d.Ack(false)
+
+	case trigger.OnFailure == "requeue":
+		// one more attempt; a message that fails again is not retried forever
+		d.Nack(false, !d.Redelivered)
+
+	default:
+		d.Nack(false, false)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/streadway/amqp"
+
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+// settlement records how a delivery was settled
+type settlement struct {
+	acks, nacks int
+	requeue     bool
+}
+
+func (s *settlement) Ack(tag uint64, multiple bool) error {
+	s.acks++
+	return nil
+}
+
+func (s *settlement) Nack(tag uint64, multiple, requeue bool) error {
+	s.nacks++
+	s.requeue = requeue
+	return nil
+}
+
+func (s *settlement) Reject(tag uint64, requeue bool) error {
+	return s.Nack(tag, false, requeue)
+}
+
+func (s *settlement) String() string {
+	switch {
+	case s.acks == 1 && s.nacks == 0:
+		return "ack"
+	case s.acks == 0 && s.nacks == 1 && s.requeue:
+		return "requeue"
+	case s.acks == 0 && s.nacks == 1:
+		return "drop"
+	}
+	return fmt.Sprintf("%d acks, %d nacks", s.acks, s.nacks)
+}
+
+// fakeDeadLetterChannel answers every publish at once, the way the broker
+// would: with a return when returned is set, then a confirm
+type fakeDeadLetterChannel struct {
+	dlq       *deadLetterer
+	published []amqp.Publishing
+	nack      bool
+	returned  bool
+	// silent closes the confirms instead of answering
+	silent bool
+}
+
+func (f *fakeDeadLetterChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if exchange != "" || key != f.dlq.queue || !mandatory {
+		return fmt.Errorf("published to %q/%q, mandatory %v", exchange, key, mandatory)
+	}
+	f.published = append(f.published, msg)
+	if f.silent {
+		close(f.dlq.confirms)
+		return nil
+	}
+	if f.returned {
+		f.dlq.returns <- amqp.Return{ReplyText: "NO_ROUTE"}
+	}
+	f.dlq.confirms <- amqp.Confirmation{DeliveryTag: uint64(len(f.published)), Ack: !f.nack}
+	return nil
+}
+
+func (f *fakeDeadLetterChannel) Close() error { return nil }
+
+func newFakeDeadLetterer(ch *fakeDeadLetterChannel) *deadLetterer {
+	ch.dlq = &deadLetterer{
+		ch:       ch,
+		queue:    "orders.failed",
+		confirms: make(chan amqp.Confirmation, 1),
+		returns:  make(chan amqp.Return, 1),
+	}
+	return ch.dlq
+}
+
+func TestHandleDelivery(t *testing.T) {
+	succeeded := func(ctx context.Context, data map[string]interface{}) (*models.Execution, error) {
+		return &models.Execution{ID: "exec-1", Status: "success"}, nil
+	}
+	failed := func(ctx context.Context, data map[string]interface{}) (*models.Execution, error) {
+		return &models.Execution{ID: "exec-2", Status: "failed", ErrorMessage: "node http failed"}, nil
+	}
+	notStarted := func(ctx context.Context, data map[string]interface{}) (*models.Execution, error) {
+		return nil, errors.New("workflow has no published version")
+	}
+	inactive := func(ctx context.Context, data map[string]interface{}) (*models.Execution, error) {
+		return nil, errWorkflowInactive
+	}
+
+	tests := []struct {
+		name        string
+		run         amqpRunner
+		onFailure   string
+		redelivered bool
+		cancelled   bool
+		dlq         *fakeDeadLetterChannel
+		want        string
+		wantErr     error
+		wantDLQ     bool
+	}{
+		{name: "success", run: succeeded, want: "ack"},
+		{name: "success with a dead-letter queue", run: succeeded, dlq: &fakeDeadLetterChannel{}, want: "ack"},
+		{name: "workflow gone stops the consumer", run: inactive, want: "requeue", wantErr: errWorkflowInactive},
+		{name: "failure is dropped", run: failed, want: "drop"},
+		{name: "run error is dropped", run: notStarted, want: "drop"},
+		{name: "failure is requeued once", run: failed, onFailure: "requeue", want: "requeue"},
+		{name: "redelivered failure is dropped", run: failed, onFailure: "requeue", redelivered: true, want: "drop"},
+		{name: "shutdown requeues", run: failed, cancelled: true, dlq: &fakeDeadLetterChannel{}, want: "requeue"},
+		{name: "failure is dead-lettered", run: failed, dlq: &fakeDeadLetterChannel{}, want: "ack", wantDLQ: true},
+		{name: "dead-letter refused", run: failed, dlq: &fakeDeadLetterChannel{nack: true}, want: "drop", wantDLQ: true},
+		{name: "dead-letter returned", run: failed, dlq: &fakeDeadLetterChannel{returned: true}, want: "drop", wantDLQ: true},
+		{name: "dead-letter unconfirmed stops the consumer", run: failed, dlq: &fakeDeadLetterChannel{silent: true}, want: "drop", wantErr: errDeadLetterUnknown, wantDLQ: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			var dlq *deadLetterer
+			if tt.dlq != nil {
+				dlq = newFakeDeadLetterer(tt.dlq)
+			}
+			settled := &settlement{}
+			d := amqp.Delivery{
+				Acknowledger: settled,
+				DeliveryTag:  7,
+				Redelivered:  tt.redelivered,
+				Headers:      amqp.Table{"x-tenant": "acme"},
+				ContentType:  "application/json",
+				MessageId:    "msg-1",
+				Body:         []byte(`{"order": 42}`),
+			}
+			trigger := amqpTrigger{WorkflowID: "wf-1", Queue: "orders", OnFailure: tt.onFailure}
+			if tt.dlq != nil {
+				trigger.DeadLetterQueue = "orders.failed"
+			}
+
+			err := handleDelivery(ctx, dlq, trigger, d, tt.run)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("handleDelivery() = %v, want %v", err, tt.wantErr)
+			}
+			if got := settled.String(); got != tt.want {
+				t.Errorf("delivery settled with %s, want %s", got, tt.want)
+			}
+
+			var published []amqp.Publishing
+			if tt.dlq != nil {
+				published = tt.dlq.published
+			}
+			if (len(published) == 1) != tt.wantDLQ || len(published) > 1 {
+				t.Fatalf("dead-lettered %d messages", len(published))
+			}
+			if tt.wantDLQ {
+				msg := published[0]
+				if string(msg.Body) != `{"order": 42}` || msg.MessageId != "msg-1" || msg.DeliveryMode != amqp.Persistent {
+					t.Errorf("dead-lettered %+v", msg)
+				}
+				for key, want := range map[string]interface{}{
+					"x-tenant":             "acme",
+					"x-s4s-workflow-id":    "wf-1",
+					"x-s4s-execution-id":   "exec-2",
+					"x-s4s-error":          "node http failed",
+					"x-s4s-original-queue": "orders",
+				} {
+					if msg.Headers[key] != want {
+						t.Errorf("header %s = %v, want %v", key, msg.Headers[key], want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+// contactEventTrigger is the config of a contact_event trigger node
+type contactEventTrigger struct {
+	// Events to react to: created, stage_changed; empty means all
+	Events []string `json:"events"`
+	// Stage limits the trigger to contacts in (or moved to) the stage
+	Stage string `json:"stage"`
+	// FromStage limits stage_changed events to contacts leaving the stage
+	FromStage string `json:"from_stage"`
+}
+
+// DispatchContactEvent runs the user's active workflows whose contact_event
+// trigger matches event. The workflow that made the change is skipped so a
+// workflow updating contacts cannot trigger itself, and events more than
+// maxEventDepth runs deep are dropped so workflows updating each other's
+// contacts stop. Each workflow runs in the background.
+func (s *TriggerService) DispatchContactEvent(ctx context.Context, userID string, event map[string]interface{}) {
+	depth, ok := nextEventDepth(event)
+	if !ok {
+		log.Printf("contact event of workflow %v dropped: more than %d events deep", event["source_workflow_id"], maxEventDepth)
+		return
+	}
+	workflows, err := s.workflowRepo.FindActiveByUserID(userID)
+	if err != nil {
+		log.Printf("contact event dispatch failed: %v", err)
+		return
+	}
+
+	ctx = withEventDepth(context.WithoutCancel(ctx), depth)
+	for _, workflow := range contactEventWorkflows(workflows, event) {
+		data := make(map[string]interface{}, len(event))
+		for k, v := range event {
+			data[k] = v
+		}
+		go func() {
+			if _, err := s.workflowService.RunTriggered(ctx, workflow, data); err != nil {
+				log.Printf("workflow %s: contact event run failed: %v", workflow.ID, err)
+			}
+		}()
+	}
+}
+
+// contactEventWorkflows returns the workflows whose contact_event trigger matches event
+func contactEventWorkflows(workflows []models.Workflow, event map[string]interface{}) []*models.Workflow {
+	eventType, _ := event["event"].(string)
+	sourceWorkflow, _ := event["source_workflow_id"].(string)
+	stage, _ := event["stage"].(string)
+	previousStage, _ := event["previous_stage"].(string)
+
+	var matched []*models.Workflow
+	for i := range workflows {
+		workflow := &workflows[i]
+		if workflow.ID == sourceWorkflow {
+			continue
+		}
+		node := publishedTrigger(workflow)
+		if node == nil {
+			continue
+		}
+		if nodeType, _ := node.Data["type"].(string); nodeType != "contact_event" {
+			continue
+		}
+
+		var trigger contactEventTrigger
+		if _, err := triggerConfig(workflow, node, &trigger); err != nil {
+			log.Printf("workflow %s: invalid contact_event trigger: %v", workflow.ID, err)
+			continue
+		}
+		if len(trigger.Events) > 0 && !containsString(trigger.Events, eventType) {
+			continue
+		}
+		if trigger.Stage != "" && !strings.EqualFold(trigger.Stage, stage) {
+			continue
+		}
+		if trigger.FromStage != "" && !strings.EqualFold(trigger.FromStage, previousStage) {
+			continue
+		}
+		matched = append(matched, workflow)
+	}
+	return matched
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+// triggeredWorkflow is a published workflow whose trigger node has the type and config
+func triggeredWorkflow(t *testing.T, id, triggerType string, config map[string]interface{}) models.Workflow {
+	t.Helper()
+	graph, err := json.Marshal(map[string]interface{}{
+		"nodes": []map[string]interface{}{{
+			"id":   "trigger",
+			"type": "trigger",
+			"data": map[string]interface{}{"type": triggerType, "config": config},
+		}},
+		"edges": []interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.Workflow{ID: id, UserID: "user-1", JSON: string(graph), PublishedJSON: string(graph), PublishedVersion: 1}
+}
+
+func workflowIDs(workflows []*models.Workflow) []string {
+	ids := []string{}
+	for _, workflow := range workflows {
+		ids = append(ids, workflow.ID)
+	}
+	return ids
+}
+
+func TestContactEventWorkflows(t *testing.T) {
+	unpublished := triggeredWorkflow(t, "unpublished", "contact_event", nil)
+	unpublished.PublishedJSON, unpublished.PublishedVersion = "", 0
+	workflows := []models.Workflow{
+		triggeredWorkflow(t, "any", "contact_event", nil),
+		triggeredWorkflow(t, "created", "contact_event", map[string]interface{}{"events": []string{"created"}}),
+		triggeredWorkflow(t, "won", "contact_event", map[string]interface{}{"events": []string{"stage_changed"}, "stage": "Won"}),
+		triggeredWorkflow(t, "lost-lead", "contact_event", map[string]interface{}{"stage": "lost", "from_stage": "lead"}),
+		triggeredWorkflow(t, "webhook", "webhook", nil),
+		triggeredWorkflow(t, "invalid", "contact_event", map[string]interface{}{"events": "created"}),
+		unpublished,
+	}
+
+	tests := []struct {
+		name  string
+		event map[string]interface{}
+		want  []string
+	}{
+		{
+			name:  "created",
+			event: map[string]interface{}{"event": "created", "stage": "lead"},
+			want:  []string{"any", "created"},
+		},
+		{
+			name:  "stage matches case-insensitively",
+			event: map[string]interface{}{"event": "stage_changed", "stage": "won", "previous_stage": "lead"},
+			want:  []string{"any", "won"},
+		},
+		{
+			name:  "from stage",
+			event: map[string]interface{}{"event": "stage_changed", "stage": "lost", "previous_stage": "lead"},
+			want:  []string{"any", "lost-lead"},
+		},
+		{
+			name:  "other from stage",
+			event: map[string]interface{}{"event": "stage_changed", "stage": "lost", "previous_stage": "won"},
+			want:  []string{"any"},
+		},
+		{
+			name:  "source workflow is skipped",
+			event: map[string]interface{}{"event": "created", "source_workflow_id": "created"},
+			want:  []string{"any"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workflowIDs(contactEventWorkflows(workflows, tt.event)); !slices.Equal(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventDepth(t *testing.T) {
+	// a run started by an event is one event deeper than the run that caused it
+	depth, ok := nextEventDepth(map[string]interface{}{"event": "created"})
+	if depth != 1 || !ok {
+		t.Errorf("event of a manual change: depth %d, dispatched %v", depth, ok)
+	}
+	ctx := withEventDepth(context.Background(), depth)
+	if got := eventDepthFromContext(ctx); got != 1 {
+		t.Errorf("eventDepthFromContext() = %d, want 1", got)
+	}
+	if got := eventDepthFromContext(context.Background()); got != 0 {
+		t.Errorf("eventDepthFromContext() without a depth = %d, want 0", got)
+	}
+
+	// workflows triggering each other stop after maxEventDepth runs
+	runs := 0
+	for event := (map[string]interface{}{"event_depth": 0}); ; runs++ {
+		depth, ok := nextEventDepth(event)
+		if !ok {
+			break
+		}
+		event = map[string]interface{}{"event_depth": depth}
+	}
+	if runs != maxEventDepth {
+		t.Errorf("a loop ran %d times, want %d", runs, maxEventDepth)
+	}
+}
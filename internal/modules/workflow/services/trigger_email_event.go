@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+// emailEventTrigger is the config of an email_event trigger node
+type emailEventTrigger struct {
+	// Events to react to: open, click, unsubscribe; empty means all
+	Events []string `json:"events"`
+	// WorkflowID limits the trigger to emails sent by one workflow
+	WorkflowID string `json:"workflow_id"`
+	// URLContains limits click events to links containing the text
+	URLContains string `json:"url_contains"`
+	// FirstOnly ignores repeated events of the same type for a message
+	FirstOnly bool `json:"first_only"`
+}
+
+// DispatchEmailEvent runs the user's active workflows whose email_event
+// trigger matches event. Events of emails sent more than maxEventDepth runs
+// deep are dropped, so workflows mailing in reaction to each other's emails
+// stop. Each workflow runs in the background.
+func (s *TriggerService) DispatchEmailEvent(ctx context.Context, userID string, event map[string]interface{}) {
+	depth, ok := nextEventDepth(event)
+	if !ok {
+		log.Printf("email event of workflow %v dropped: more than %d events deep", event["source_workflow_id"], maxEventDepth)
+		return
+	}
+	workflows, err := s.workflowRepo.FindActiveByUserID(userID)
+	if err != nil {
+		log.Printf("email event dispatch failed: %v", err)
+		return
+	}
+
+	ctx = withEventDepth(context.WithoutCancel(ctx), depth)
+	for _, workflow := range emailEventWorkflows(workflows, event) {
+		data := make(map[string]interface{}, len(event))
+		for k, v := range event {
+			data[k] = v
+		}
+		go func() {
+			if _, err := s.workflowService.RunTriggered(ctx, workflow, data); err != nil {
+				log.Printf("workflow %s: email event run failed: %v", workflow.ID, err)
+			}
+		}()
+	}
+}
+
+// emailEventWorkflows returns the workflows whose email_event trigger matches event
+func emailEventWorkflows(workflows []models.Workflow, event map[string]interface{}) []*models.Workflow {
+	eventType, _ := event["event"].(string)
+	sourceWorkflow, _ := event["source_workflow_id"].(string)
+	url, _ := event["url"].(string)
+	first, _ := event["first"].(bool)
+
+	var matched []*models.Workflow
+	for i := range workflows {
+		workflow := &workflows[i]
+		node := publishedTrigger(workflow)
+		if node == nil {
+			continue
+		}
+		if nodeType, _ := node.Data["type"].(string); nodeType != "email_event" {
+			continue
+		}
+
+		var trigger emailEventTrigger
+		if _, err := triggerConfig(workflow, node, &trigger); err != nil {
+			log.Printf("workflow %s: invalid email_event trigger: %v", workflow.ID, err)
+			continue
+		}
+		if len(trigger.Events) > 0 && !containsString(trigger.Events, eventType) {
+			continue
+		}
+		if trigger.WorkflowID != "" && trigger.WorkflowID != sourceWorkflow {
+			continue
+		}
+		if trigger.URLContains != "" && !strings.Contains(url, trigger.URLContains) {
+			continue
+		}
+		if trigger.FirstOnly && !first {
+			continue
+		}
+		matched = append(matched, workflow)
+	}
+	return matched
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
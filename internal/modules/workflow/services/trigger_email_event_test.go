@@ -0,0 +1,38 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+func TestEmailEventWorkflows(t *testing.T) {
+	workflows := []models.Workflow{
+		triggeredWorkflow(t, "any", "email_event", nil),
+		triggeredWorkflow(t, "first-open", "email_event", map[string]interface{}{"events": []string{"open"}, "first_only": true}),
+		triggeredWorkflow(t, "pricing", "email_event", map[string]interface{}{"events": []string{"click"}, "url_contains": "/pricing"}),
+		triggeredWorkflow(t, "newsletter", "email_event", map[string]interface{}{"workflow_id": "newsletter-sender"}),
+		triggeredWorkflow(t, "contacts", "contact_event", nil),
+	}
+
+	tests := []struct {
+		name  string
+		event map[string]interface{}
+		want  []string
+	}{
+		{"first open", map[string]interface{}{"event": "open", "first": true}, []string{"any", "first-open"}},
+		{"repeated open", map[string]interface{}{"event": "open", "first": false}, []string{"any"}},
+		{"click on the link", map[string]interface{}{"event": "click", "url": "https://acme.example.com/pricing?plan=pro"}, []string{"any", "pricing"}},
+		{"click elsewhere", map[string]interface{}{"event": "click", "url": "https://acme.example.com/"}, []string{"any"}},
+		{"email of a workflow", map[string]interface{}{"event": "unsubscribe", "source_workflow_id": "newsletter-sender"}, []string{"any", "newsletter"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workflowIDs(emailEventWorkflows(workflows, tt.event)); !slices.Equal(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
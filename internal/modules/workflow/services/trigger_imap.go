@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/services/engine"
+	"s4s-backend/internal/pkg/imapconn"
+)
+
+const (
+	defaultIMAPPollInterval = time.Minute
+	minIMAPPollInterval     = 30 * time.Second
+	defaultIMAPBatch        = 25
+	maxIMAPBatch            = 100
+	maxIMAPMessageSize      = 25 << 20
+)
+
+// imapTrigger is the config of an imap_trigger node
+type imapTrigger struct {
+	WorkflowID          string  `json:"-"`
+	UserID              string  `json:"-"`
+	NodeID              string  `json:"-"`
+	ConnectionID        string  `json:"connection_id"`
+	Folder              string  `json:"folder"`
+	From                string  `json:"from"`
+	Subject             string  `json:"subject"`
+	UnseenOnly          bool    `json:"unseen_only"`
+	MarkSeen            bool    `json:"mark_seen"`
+	MoveTo              string  `json:"move_to"`
+	PollIntervalSeconds float64 `json:"poll_interval_seconds"`
+	MaxMessages         int     `json:"max_messages"`
+}
+
+// imapState is the stored position of an imap_trigger in its folder. UIDs are
+// only comparable while the folder's UIDVALIDITY stays the same.
+type imapState struct {
+	UIDValidity uint32 `json:"uidValidity"`
+	LastUID     uint32 `json:"lastUid"`
+}
+
+// imapStateStore keeps imapState between polls
+type imapStateStore interface {
+	Get(workflowID, key string, out interface{}) (bool, error)
+	Put(workflowID, key string, value interface{}) error
+}
+
+// imapRunner runs the workflow with one parsed message
+type imapRunner func(ctx context.Context, data map[string]interface{}) error
+
+func (s *TriggerService) imapSpec(workflow *models.Workflow, node *engine.Node) (*triggerSpec, error) {
+	trigger := imapTrigger{}
+	fingerprint, err := triggerConfig(workflow, node, &trigger)
+	if err != nil || trigger.ConnectionID == "" {
+		return nil, errors.New("imap_trigger needs connection_id")
+	}
+	trigger.WorkflowID = workflow.ID
+	trigger.UserID = workflow.UserID
+	trigger.NodeID = node.ID
+	if trigger.Folder == "" {
+		trigger.Folder = "INBOX"
+	}
+	if trigger.MaxMessages <= 0 {
+		trigger.MaxMessages = defaultIMAPBatch
+	}
+	if trigger.MaxMessages > maxIMAPBatch {
+		trigger.MaxMessages = maxIMAPBatch
+	}
+
+	return &triggerSpec{
+		fingerprint: fingerprint,
+		run:         func(ctx context.Context) { s.pollIMAP(ctx, trigger) },
+	}, nil
+}
+
+func (t imapTrigger) stateKey() string {
+	return "imap:" + t.NodeID + ":" + t.Folder
+}
+
+func (t imapTrigger) interval() time.Duration {
+	interval := defaultIMAPPollInterval
+	if t.PollIntervalSeconds > 0 {
+		interval = time.Duration(t.PollIntervalSeconds * float64(time.Second))
+	}
+	if interval < minIMAPPollInterval {
+		interval = minIMAPPollInterval
+	}
+	return interval
+}
+
+// pollIMAP checks the mailbox every interval while this replica holds the
+// workflow's poller lease
+func (s *TriggerService) pollIMAP(ctx context.Context, trigger imapTrigger) {
+	interval := trigger.interval()
+	for {
+		held, err := s.stateRepo.TryLease(trigger.WorkflowID, trigger.stateKey()+":lease", s.instanceID, 2*interval+time.Minute)
+		if err != nil {
+			log.Printf("workflow %s: imap lease failed: %v", trigger.WorkflowID, err)
+		} else if held {
+			if err := s.pollIMAPOnce(ctx, trigger); err != nil && ctx.Err() == nil {
+				log.Printf("workflow %s: imap poll of %q failed: %v", trigger.WorkflowID, trigger.Folder, err)
+			}
+		}
+		if !sleepContext(ctx, interval) {
+			return
+		}
+	}
+}
+
+func (s *TriggerService) pollIMAPOnce(ctx context.Context, trigger imapTrigger) error {
+	conn, err := s.connections.Resolve(ctx, trigger.UserID, trigger.ConnectionID)
+	if err != nil {
+		return fmt.Errorf("failed to load connection: %w", err)
+	}
+	if conn.Service != "imap" {
+		return fmt.Errorf("connection %s is a %s connection, expected imap", conn.ID, conn.Service)
+	}
+	cfg, err := imapconn.ConfigFromCredentials(conn.Credentials)
+	if err != nil {
+		return fmt.Errorf("invalid imap connection: %w", err)
+	}
+
+	c, err := imapconn.Connect(ctx, cfg, s.egress.DialContext)
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	return pollMailbox(ctx, c, trigger, s.stateRepo, func(ctx context.Context, data map[string]interface{}) error {
+		workflow, err := s.workflowRepo.FindByID(trigger.WorkflowID)
+		if err != nil || !workflow.Active {
+			return errors.New("workflow is no longer active")
+		}
+		_, err = s.workflowService.RunTriggered(ctx, workflow, data)
+		return err
+	})
+}
+
+// pollMailbox runs the workflow for every message that arrived in the
+// trigger's folder since the stored UID
+func pollMailbox(ctx context.Context, c *client.Client, trigger imapTrigger, store imapStateStore, run imapRunner) error {
+	// seen flags and moves need a read-write selection
+	readOnly := !trigger.MarkSeen && trigger.MoveTo == ""
+	mailbox, err := c.Select(trigger.Folder, readOnly)
+	if err != nil {
+		return fmt.Errorf("select %q: %w", trigger.Folder, err)
+	}
+
+	var state imapState
+	found, err := store.Get(trigger.WorkflowID, trigger.stateKey(), &state)
+	if err != nil {
+		return err
+	}
+	if !found || state.UIDValidity != mailbox.UidValidity {
+		// start from "now": mail that was already there does not fire the workflow
+		last, err := lastUID(c, mailbox)
+		if err != nil {
+			return err
+		}
+		return store.Put(trigger.WorkflowID, trigger.stateKey(), imapState{UIDValidity: mailbox.UidValidity, LastUID: last})
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = new(imap.SeqSet)
+	criteria.Uid.AddRange(state.LastUID+1, 0)
+	criteria.Smaller = maxIMAPMessageSize
+	if trigger.From != "" {
+		criteria.Header.Add("From", trigger.From)
+	}
+	if trigger.Subject != "" {
+		criteria.Header.Add("Subject", trigger.Subject)
+	}
+	if trigger.UnseenOnly {
+		criteria.WithoutFlags = []string{imap.SeenFlag}
+	}
+
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	// "n:*" always matches the newest message, even when its UID is below n
+	matched := uids[:0]
+	for _, uid := range uids {
+		if uid > state.LastUID {
+			matched = append(matched, uid)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i] < matched[j] })
+
+	truncated := len(matched) > trigger.MaxMessages
+	if truncated {
+		matched = matched[:trigger.MaxMessages]
+	}
+
+	for _, uid := range matched {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := handleIMAPMessage(ctx, c, trigger, uid, run); err != nil {
+			return err
+		}
+		state.LastUID = uid
+		if err := store.Put(trigger.WorkflowID, trigger.stateKey(), state); err != nil {
+			return err
+		}
+	}
+
+	// skip past mail that did not match the filters
+	if !truncated && mailbox.UidNext > 0 && mailbox.UidNext-1 > state.LastUID {
+		state.LastUID = mailbox.UidNext - 1
+		return store.Put(trigger.WorkflowID, trigger.stateKey(), state)
+	}
+	return nil
+}
+
+// handleIMAPMessage fetches one message and runs the workflow with it. A
+// failed execution is recorded like any other run; the message is not retried.
+func handleIMAPMessage(ctx context.Context, c *client.Client, trigger imapTrigger, uid uint32, run imapRunner) error {
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uid)
+	section := &imap.BodySectionName{Peek: true}
+
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqSet, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages)
+	}()
+
+	var msg *imap.Message
+	for m := range messages {
+		msg = m
+	}
+	if err := <-done; err != nil {
+		return fmt.Errorf("fetch %d: %w", uid, err)
+	}
+	if msg == nil {
+		// expunged between search and fetch
+		return nil
+	}
+	body := msg.GetBody(section)
+	if body == nil {
+		return fmt.Errorf("fetch %d: server returned no body", uid)
+	}
+
+	data, err := engine.IMAPMessageData(body, uid, trigger.Folder)
+	if err != nil {
+		log.Printf("workflow %s: skipping unparsable message %d: %v", trigger.WorkflowID, uid, err)
+		return nil
+	}
+
+	if err := run(ctx, data); err != nil {
+		return err
+	}
+
+	if trigger.MarkSeen {
+		flags := []interface{}{imap.SeenFlag}
+		if err := c.UidStore(seqSet, imap.FormatFlagsOp(imap.AddFlags, true), flags, nil); err != nil {
+			return fmt.Errorf("mark %d seen: %w", uid, err)
+		}
+	}
+	if trigger.MoveTo != "" {
+		if err := c.UidMove(seqSet, trigger.MoveTo); err != nil {
+			return fmt.Errorf("move %d to %q: %w", uid, trigger.MoveTo, err)
+		}
+	}
+	return nil
+}
+
+// lastUID returns the highest UID currently in the selected mailbox
+func lastUID(c *client.Client, mailbox *imap.MailboxStatus) (uint32, error) {
+	if mailbox.UidNext > 0 {
+		return mailbox.UidNext - 1, nil
+	}
+	uids, err := c.UidSearch(imap.NewSearchCriteria())
+	if err != nil {
+		return 0, fmt.Errorf("search: %w", err)
+	}
+	var last uint32
+	for _, uid := range uids {
+		if uid > last {
+			last = uid
+		}
+	}
+	return last, nil
+}
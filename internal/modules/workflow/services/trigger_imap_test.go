@@ -0,0 +1,396 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/server"
+
+	"s4s-backend/internal/pkg/imapconn"
+)
+
+// imapStandIn is an IMAP server over go-imap's memory backend. Its login is
+// username/password, INBOX starts with one seen message (UID 6) and an
+// Archive folder is created for moves.
+type imapStandIn struct {
+	port     int
+	user     backend.User
+	validity uint32
+}
+
+// standInBackend reports the stand-in's UIDVALIDITY and supports MOVE
+type standInBackend struct {
+	*memory.Backend
+	standIn *imapStandIn
+}
+
+func (b *standInBackend) Login(info *imap.ConnInfo, username, password string) (backend.User, error) {
+	user, err := b.Backend.Login(info, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return &standInUser{User: user, standIn: b.standIn}, nil
+}
+
+type standInUser struct {
+	backend.User
+	standIn *imapStandIn
+}
+
+func (u *standInUser) GetMailbox(name string) (backend.Mailbox, error) {
+	mailbox, err := u.User.GetMailbox(name)
+	if err != nil {
+		return nil, err
+	}
+	return &standInMailbox{Mailbox: mailbox, standIn: u.standIn}, nil
+}
+
+type standInMailbox struct {
+	backend.Mailbox
+	standIn *imapStandIn
+}
+
+func (m *standInMailbox) Status(items []imap.StatusItem) (*imap.MailboxStatus, error) {
+	status, err := m.Mailbox.Status(items)
+	if err == nil && status.UidValidity != 0 {
+		status.UidValidity = m.standIn.validity
+	}
+	return status, err
+}
+
+func (m *standInMailbox) MoveMessages(uid bool, seqSet *imap.SeqSet, dest string) error {
+	if err := m.CopyMessages(uid, seqSet, dest); err != nil {
+		return err
+	}
+	if err := m.UpdateMessagesFlags(uid, seqSet, imap.AddFlags, []string{imap.DeletedFlag}); err != nil {
+		return err
+	}
+	return m.Expunge()
+}
+
+func newIMAPStandIn(t *testing.T) *imapStandIn {
+	t.Helper()
+	be := memory.New()
+	user, err := be.Login(nil, "username", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.CreateMailbox("Archive"); err != nil {
+		t.Fatal(err)
+	}
+
+	standIn := &imapStandIn{user: user, validity: 1}
+	srv := server.New(&standInBackend{Backend: be, standIn: standIn})
+	srv.AllowInsecureAuth = true
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standIn.port = listener.Addr().(*net.TCPAddr).Port
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+	return standIn
+}
+
+// deliver appends an unseen message to folder
+func (s *imapStandIn) deliver(t *testing.T, folder, from, subject string) {
+	t.Helper()
+	mailbox, err := s.user.GetMailbox(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := fmt.Sprintf("From: %s\r\nTo: sales@example.com\r\nSubject: %s\r\nMessage-ID: <%s@example.com>\r\n\r\nHello\r\n", from, subject, subject)
+	if err := mailbox.CreateMessage(nil, time.Now(), bytes.NewBufferString(body)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// messages returns what folder holds right now
+func (s *imapStandIn) messages(t *testing.T, folder string) []*memory.Message {
+	t.Helper()
+	mailbox, err := s.user.GetMailbox(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mailbox.(*memory.Mailbox).Messages
+}
+
+func (s *imapStandIn) connect(t *testing.T) *client.Client {
+	t.Helper()
+	cfg := &imapconn.Config{Host: "127.0.0.1", Port: s.port, TLSMode: imapconn.TLSModeNone, Username: "username", Password: "password"}
+	c, err := imapconn.Connect(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return c
+}
+
+// memoryState is an imapStateStore kept in memory
+type memoryState map[string][]byte
+
+func (m memoryState) Get(workflowID, key string, out interface{}) (bool, error) {
+	raw, ok := m[workflowID+"/"+key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, out)
+}
+
+func (m memoryState) Put(workflowID, key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m[workflowID+"/"+key] = raw
+	return nil
+}
+
+func (m memoryState) position(t *testing.T, trigger imapTrigger) imapState {
+	t.Helper()
+	var state imapState
+	if found, err := m.Get(trigger.WorkflowID, trigger.stateKey(), &state); err != nil || !found {
+		t.Fatalf("no state stored: %v", err)
+	}
+	return state
+}
+
+// recordingRunner collects the subjects of the messages it was run with
+type recordingRunner struct {
+	subjects []string
+	err      error
+}
+
+func (r *recordingRunner) run(ctx context.Context, data map[string]interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.subjects = append(r.subjects, data["subject"].(string))
+	return nil
+}
+
+func testIMAPTrigger() imapTrigger {
+	return imapTrigger{WorkflowID: "workflow-1", NodeID: "imap-1", Folder: "INBOX", MaxMessages: defaultIMAPBatch}
+}
+
+// poll runs one poll over a fresh connection and returns the subjects handled
+func poll(t *testing.T, standIn *imapStandIn, trigger imapTrigger, state memoryState) []string {
+	t.Helper()
+	c := standIn.connect(t)
+	defer c.Logout()
+	runner := &recordingRunner{}
+	if err := pollMailbox(context.Background(), c, trigger, state, runner.run); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	return runner.subjects
+}
+
+func hasFlag(message *memory.Message, flag string) bool {
+	return slices.Contains(message.Flags, flag)
+}
+
+func TestPollMailboxTracksLastUID(t *testing.T) {
+	standIn := newIMAPStandIn(t)
+	trigger := testIMAPTrigger()
+	state := memoryState{}
+
+	// the first poll only records where the folder stands
+	if got := poll(t, standIn, trigger, state); len(got) != 0 {
+		t.Fatalf("first poll ran for existing mail: %v", got)
+	}
+	if got := state.position(t, trigger); got != (imapState{UIDValidity: 1, LastUID: 6}) {
+		t.Fatalf("state after first poll = %+v", got)
+	}
+
+	standIn.deliver(t, "INBOX", "anna@example.com", "first")
+	standIn.deliver(t, "INBOX", "boris@example.com", "second")
+	if got := poll(t, standIn, trigger, state); !slices.Equal(got, []string{"first", "second"}) {
+		t.Fatalf("second poll handled %v", got)
+	}
+	if got := state.position(t, trigger); got.LastUID != 8 {
+		t.Fatalf("last UID = %d, want 8", got.LastUID)
+	}
+
+	if got := poll(t, standIn, trigger, state); len(got) != 0 {
+		t.Fatalf("mail was handled twice: %v", got)
+	}
+	standIn.deliver(t, "INBOX", "anna@example.com", "third")
+	if got := poll(t, standIn, trigger, state); !slices.Equal(got, []string{"third"}) {
+		t.Fatalf("third poll handled %v", got)
+	}
+}
+
+func TestPollMailboxFiltersAndBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(trigger *imapTrigger)
+		want      [][]string
+	}{
+		{
+			name:      "from filter",
+			configure: func(trigger *imapTrigger) { trigger.From = "anna@example.com" },
+			want:      [][]string{{"one", "three"}, nil},
+		},
+		{
+			name:      "subject filter",
+			configure: func(trigger *imapTrigger) { trigger.Subject = "two" },
+			want:      [][]string{{"two"}, nil},
+		},
+		{
+			name:      "batch limit leaves the rest for the next poll",
+			configure: func(trigger *imapTrigger) { trigger.MaxMessages = 2 },
+			want:      [][]string{{"one", "two"}, {"three"}, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn := newIMAPStandIn(t)
+			trigger := testIMAPTrigger()
+			tt.configure(&trigger)
+			state := memoryState{}
+			poll(t, standIn, trigger, state)
+
+			standIn.deliver(t, "INBOX", "anna@example.com", "one")
+			standIn.deliver(t, "INBOX", "boris@example.com", "two")
+			standIn.deliver(t, "INBOX", "anna@example.com", "three")
+			for i, want := range tt.want {
+				if got := poll(t, standIn, trigger, state); !slices.Equal(got, want) {
+					t.Fatalf("poll %d handled %v, want %v", i+1, got, want)
+				}
+			}
+			// skipped mail is passed over, not searched again
+			if got := state.position(t, trigger); got.LastUID != 9 {
+				t.Errorf("last UID = %d, want 9", got.LastUID)
+			}
+		})
+	}
+}
+
+func TestPollMailboxUnseenOnly(t *testing.T) {
+	standIn := newIMAPStandIn(t)
+	trigger := testIMAPTrigger()
+	trigger.UnseenOnly = true
+	state := memoryState{}
+	poll(t, standIn, trigger, state)
+
+	standIn.deliver(t, "INBOX", "anna@example.com", "read")
+	standIn.deliver(t, "INBOX", "anna@example.com", "unread")
+	standIn.messages(t, "INBOX")[1].Flags = []string{imap.SeenFlag}
+	if got := poll(t, standIn, trigger, state); !slices.Equal(got, []string{"unread"}) {
+		t.Fatalf("handled %v, want only the unseen message", got)
+	}
+}
+
+func TestPollMailboxResetsOnUIDValidityChange(t *testing.T) {
+	standIn := newIMAPStandIn(t)
+	trigger := testIMAPTrigger()
+	state := memoryState{}
+	poll(t, standIn, trigger, state)
+
+	// the server renumbered the folder: old UIDs mean nothing, so the
+	// trigger starts over from the newest message instead of replaying
+	standIn.deliver(t, "INBOX", "anna@example.com", "before reset")
+	standIn.validity = 2
+	if got := poll(t, standIn, trigger, state); len(got) != 0 {
+		t.Fatalf("poll after UIDVALIDITY change handled %v", got)
+	}
+	if got := state.position(t, trigger); got != (imapState{UIDValidity: 2, LastUID: 7}) {
+		t.Fatalf("state after reset = %+v", got)
+	}
+
+	standIn.deliver(t, "INBOX", "anna@example.com", "after reset")
+	if got := poll(t, standIn, trigger, state); !slices.Equal(got, []string{"after reset"}) {
+		t.Fatalf("handled %v after reset", got)
+	}
+}
+
+func TestPollMailboxMessageHandling(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(trigger *imapTrigger)
+		wantSeen  bool
+		wantMoved bool
+	}{
+		{name: "left untouched", configure: func(trigger *imapTrigger) {}},
+		{name: "marked seen", configure: func(trigger *imapTrigger) { trigger.MarkSeen = true }, wantSeen: true},
+		{name: "moved", configure: func(trigger *imapTrigger) { trigger.MoveTo = "Archive" }, wantMoved: true},
+		{
+			name: "marked seen and moved",
+			configure: func(trigger *imapTrigger) {
+				trigger.MarkSeen = true
+				trigger.MoveTo = "Archive"
+			},
+			wantSeen:  true,
+			wantMoved: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn := newIMAPStandIn(t)
+			trigger := testIMAPTrigger()
+			tt.configure(&trigger)
+			state := memoryState{}
+			poll(t, standIn, trigger, state)
+
+			standIn.deliver(t, "INBOX", "anna@example.com", "reply")
+			if got := poll(t, standIn, trigger, state); !slices.Equal(got, []string{"reply"}) {
+				t.Fatalf("handled %v", got)
+			}
+
+			inbox, archive := standIn.messages(t, "INBOX"), standIn.messages(t, "Archive")
+			var message *memory.Message
+			if tt.wantMoved {
+				if len(inbox) != 1 || len(archive) != 1 {
+					t.Fatalf("INBOX has %d and Archive %d messages after the move", len(inbox), len(archive))
+				}
+				message = archive[0]
+			} else {
+				if len(inbox) != 2 || len(archive) != 0 {
+					t.Fatalf("INBOX has %d and Archive %d messages", len(inbox), len(archive))
+				}
+				message = inbox[1]
+			}
+			// fetching peeks, so only mark_seen sets the flag
+			if seen := hasFlag(message, imap.SeenFlag); seen != tt.wantSeen {
+				t.Errorf("seen = %v, want %v", seen, tt.wantSeen)
+			}
+		})
+	}
+}
+
+func TestPollMailboxStopsOnFailedRun(t *testing.T) {
+	standIn := newIMAPStandIn(t)
+	trigger := testIMAPTrigger()
+	trigger.MarkSeen = true
+	trigger.MoveTo = "Archive"
+	state := memoryState{}
+	poll(t, standIn, trigger, state)
+
+	standIn.deliver(t, "INBOX", "anna@example.com", "reply")
+	c := standIn.connect(t)
+	defer c.Logout()
+	runner := &recordingRunner{err: errors.New("workflow is no longer active")}
+	if err := pollMailbox(context.Background(), c, trigger, state, runner.run); err == nil {
+		t.Fatal("expected the run error")
+	}
+
+	if got := state.position(t, trigger); got.LastUID != 6 {
+		t.Errorf("last UID advanced to %d past a failed run", got.LastUID)
+	}
+	inbox := standIn.messages(t, "INBOX")
+	if len(inbox) != 2 || hasFlag(inbox[1], imap.SeenFlag) {
+		t.Errorf("message was marked or moved after a failed run")
+	}
+}
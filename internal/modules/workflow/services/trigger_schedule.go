@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/services/engine"
+)
+
+const (
+	minScheduleInterval   = time.Minute
+	scheduleCheckInterval = 30 * time.Second
+)
+
+// scheduleTrigger is the config of a schedule trigger node
+type scheduleTrigger struct {
+	WorkflowID      string  `json:"-"`
+	NodeID          string  `json:"-"`
+	IntervalSeconds float64 `json:"interval_seconds"`
+}
+
+// scheduleState is when a schedule trigger last fired
+type scheduleState struct {
+	LastRun time.Time `json:"lastRun"`
+}
+
+func (s *TriggerService) scheduleSpec(workflow *models.Workflow, node *engine.Node) (*triggerSpec, error) {
+	trigger := scheduleTrigger{}
+	fingerprint, err := triggerConfig(workflow, node, &trigger)
+	if err != nil || trigger.IntervalSeconds <= 0 {
+		return nil, errors.New("schedule trigger needs interval_seconds")
+	}
+	trigger.WorkflowID = workflow.ID
+	trigger.NodeID = node.ID
+
+	return &triggerSpec{
+		fingerprint: fingerprint,
+		run:         func(ctx context.Context) { s.runSchedule(ctx, trigger) },
+	}, nil
+}
+
+func (t scheduleTrigger) stateKey() string {
+	return "schedule:" + t.NodeID
+}
+
+func (t scheduleTrigger) interval() time.Duration {
+	interval := time.Duration(t.IntervalSeconds * float64(time.Second))
+	if interval < minScheduleInterval {
+		interval = minScheduleInterval
+	}
+	return interval
+}
+
+// runSchedule fires the workflow once per interval while this replica holds
+// the workflow's scheduler lease. The last fire time is stored, so restarts
+// and lease handovers do not repeat a run; runs missed while no replica was
+// up are made up by a single run.
+func (s *TriggerService) runSchedule(ctx context.Context, trigger scheduleTrigger) {
+	for {
+		held, err := s.stateRepo.TryLease(trigger.WorkflowID, trigger.stateKey()+":lease", s.instanceID, 4*scheduleCheckInterval)
+		if err != nil {
+			log.Printf("workflow %s: schedule lease failed: %v", trigger.WorkflowID, err)
+		} else if held {
+			if err := s.fireSchedule(ctx, trigger); err != nil && ctx.Err() == nil {
+				log.Printf("workflow %s: scheduled run failed: %v", trigger.WorkflowID, err)
+			}
+		}
+		if !sleepContext(ctx, scheduleCheckInterval) {
+			return
+		}
+	}
+}
+
+func (s *TriggerService) fireSchedule(ctx context.Context, trigger scheduleTrigger) error {
+	now := time.Now()
+	var state scheduleState
+	found, err := s.stateRepo.Get(trigger.WorkflowID, trigger.stateKey(), &state)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// the first run is one interval after the trigger is activated
+		return s.stateRepo.Put(trigger.WorkflowID, trigger.stateKey(), scheduleState{LastRun: now})
+	}
+	if now.Sub(state.LastRun) < trigger.interval() {
+		return nil
+	}
+	// stored before the run, so a failing run is not retried every check
+	if err := s.stateRepo.Put(trigger.WorkflowID, trigger.stateKey(), scheduleState{LastRun: now}); err != nil {
+		return err
+	}
+
+	workflow, err := s.workflowRepo.FindByID(trigger.WorkflowID)
+	if err != nil || !workflow.Active {
+		return errors.New("workflow is no longer active")
+	}
+	_, err = s.workflowService.RunTriggered(ctx, workflow, map[string]interface{}{
+		"scheduled_at": now.UTC().Format(time.RFC3339),
+	})
+	return err
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/repository"
+	"s4s-backend/internal/modules/workflow/services/engine"
+)
+
+const triggerSyncInterval = 30 * time.Second
+
+// TriggerService runs long-lived triggers (amqp_consume, imap_trigger,
+// schedule) for active workflows. It periodically reconciles running
+// triggers with the database, so activating, editing or deactivating a
+// workflow takes effect without a restart.
+type TriggerService struct {
+	workflowRepo    *repository.WorkflowRepository
+	stateRepo       *repository.WorkflowStateRepository
+	workflowService *WorkflowService
+	connections     engine.ConnectionResolver
+	egress          *engine.EgressPolicy
+
+	// instanceID identifies this replica when holding poller leases
+	instanceID string
+
+	mu       sync.Mutex
+	triggers map[string]*runningTrigger
+}
+
+type runningTrigger struct {
+	fingerprint string
+	cancel      context.CancelFunc
+}
+
+// triggerSpec is a trigger that should be running for a workflow
+type triggerSpec struct {
+	fingerprint string
+	run         func(ctx context.Context)
+}
+
+func NewTriggerService(
+	workflowRepo *repository.WorkflowRepository,
+	stateRepo *repository.WorkflowStateRepository,
+	workflowService *WorkflowService,
+	connections engine.ConnectionResolver,
+	egress *engine.EgressPolicy,
+) *TriggerService {
+	return &TriggerService{
+		workflowRepo:    workflowRepo,
+		stateRepo:       stateRepo,
+		workflowService: workflowService,
+		connections:     connections,
+		egress:          egress,
+		instanceID:      uuid.New().String(),
+		triggers:        make(map[string]*runningTrigger),
+	}
+}
+
+// Start reconciles triggers until ctx is cancelled
+func (s *TriggerService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(triggerSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			s.sync(ctx)
+			select {
+			case <-ctx.Done():
+				s.stopAll()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *TriggerService) sync(ctx context.Context) {
+	workflows, err := s.workflowRepo.FindActive()
+	if err != nil {
+		log.Printf("trigger sync failed: %v", err)
+		return
+	}
+
+	desired := make(map[string]*triggerSpec)
+	for i := range workflows {
+		workflow := &workflows[i]
+		node := publishedTrigger(workflow)
+		if node == nil {
+			continue
+		}
+
+		var spec *triggerSpec
+		var err error
+		switch nodeType, _ := node.Data["type"].(string); nodeType {
+		case "amqp_consume":
+			spec, err = s.amqpSpec(workflow, node)
+		case "imap_trigger":
+			spec, err = s.imapSpec(workflow, node)
+		case "schedule":
+			spec, err = s.scheduleSpec(workflow, node)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("workflow %s: %v", workflow.ID, err)
+			continue
+		}
+		desired[workflow.ID] = spec
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, running := range s.triggers {
+		if spec, ok := desired[id]; !ok || spec.fingerprint != running.fingerprint {
+			running.cancel()
+			delete(s.triggers, id)
+		}
+	}
+	for id, spec := range desired {
+		if _, ok := s.triggers[id]; ok {
+			continue
+		}
+		triggerCtx, cancel := context.WithCancel(ctx)
+		s.triggers[id] = &runningTrigger{fingerprint: spec.fingerprint, cancel: cancel}
+		go spec.run(triggerCtx)
+	}
+}
+
+func (s *TriggerService) stopAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, running := range s.triggers {
+		running.cancel()
+		delete(s.triggers, id)
+	}
+}
+
+// triggerConfig decodes a trigger node's config into out and returns a
+// fingerprint that changes whenever the config or the owner changes
+func triggerConfig(workflow *models.Workflow, node *engine.Node, out interface{}) (string, error) {
+	raw, err := json.Marshal(node.Data["config"])
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return "", err
+	}
+	return workflow.UserID + ":" + string(raw), nil
+}
+
+// maxEventDepth is how many contact or email events may chain: a workflow
+// reacting to an event can cause another event, but past this depth events
+// are no longer dispatched, so workflows triggering each other stop
+const maxEventDepth = 5
+
+type eventDepthKey struct{}
+
+// withEventDepth marks the runs started with ctx as depth events deep
+func withEventDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, eventDepthKey{}, depth)
+}
+
+func eventDepthFromContext(ctx context.Context) int {
+	depth, _ := ctx.Value(eventDepthKey{}).(int)
+	return depth
+}
+
+// nextEventDepth returns the depth of the runs an event starts and whether
+// it may start any
+func nextEventDepth(event map[string]interface{}) (int, bool) {
+	depth, _ := event["event_depth"].(int)
+	return depth + 1, depth < maxEventDepth
+}
+
+// sleepContext waits for d or until ctx is cancelled and reports whether ctx is still live
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
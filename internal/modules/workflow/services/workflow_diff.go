@@ -0,0 +1,184 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+// layoutKeys are editor-only node properties; moving or selecting a node is
+// not a structural change
+var layoutKeys = map[string]bool{
+	"position": true, "positionAbsolute": true, "measured": true, "width": true,
+	"height": true, "selected": true, "dragging": true,
+}
+
+// WorkflowDiff is the structural difference between two workflow versions
+type WorkflowDiff struct {
+	From     int           `json:"from"`
+	To       int           `json:"to"`
+	Settings []FieldChange `json:"settings"`
+	Nodes    ElementDiff   `json:"nodes"`
+	Edges    ElementDiff   `json:"edges"`
+}
+
+// ElementDiff lists the nodes or edges added, removed and changed between
+// two versions, matched by id
+type ElementDiff struct {
+	Added   []map[string]interface{} `json:"added"`
+	Removed []map[string]interface{} `json:"removed"`
+	Changed []ElementChange          `json:"changed"`
+}
+
+type ElementChange struct {
+	ID     string        `json:"id"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is one changed value; Path is dotted, e.g. "data.config.url"
+type FieldChange struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// DiffWorkflowVersions compares the settings, nodes and edges of two versions
+func DiffWorkflowVersions(from, to *models.WorkflowVersion) (*WorkflowDiff, error) {
+	diff := &WorkflowDiff{From: from.Version, To: to.Version, Settings: []FieldChange{}}
+	settings := []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"name", from.Name, to.Name},
+		{"maxTimeout", from.MaxTimeout, to.MaxTimeout},
+		{"retryCount", from.RetryCount, to.RetryCount},
+		{"retryDelay", from.RetryDelay, to.RetryDelay},
+	}
+	for _, setting := range settings {
+		if setting.before != setting.after {
+			diff.Settings = append(diff.Settings, FieldChange{Path: setting.name, Before: setting.before, After: setting.after})
+		}
+	}
+
+	before, err := parseGraph(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parseGraph(to)
+	if err != nil {
+		return nil, err
+	}
+	diff.Nodes = diffElements(before.Nodes, after.Nodes, nodeKey, layoutKeys)
+	diff.Edges = diffElements(before.Edges, after.Edges, edgeKey, nil)
+	return diff, nil
+}
+
+type rawGraph struct {
+	Nodes []map[string]interface{} `json:"nodes"`
+	Edges []map[string]interface{} `json:"edges"`
+}
+
+func parseGraph(version *models.WorkflowVersion) (*rawGraph, error) {
+	var graph rawGraph
+	if err := json.Unmarshal([]byte(version.JSON), &graph); err != nil {
+		return nil, fmt.Errorf("version %d: invalid workflow json: %w", version.Version, err)
+	}
+	return &graph, nil
+}
+
+func nodeKey(node map[string]interface{}) string {
+	return stringField(node, "id")
+}
+
+// edgeKey matches edges by id, or by their endpoints when they have none
+func edgeKey(edge map[string]interface{}) string {
+	if id := stringField(edge, "id"); id != "" {
+		return id
+	}
+	return stringField(edge, "source") + ":" + stringField(edge, "sourceHandle") + "->" + stringField(edge, "target")
+}
+
+func stringField(element map[string]interface{}, key string) string {
+	value, _ := element[key].(string)
+	return value
+}
+
+func diffElements(before, after []map[string]interface{}, key func(map[string]interface{}) string, ignore map[string]bool) ElementDiff {
+	diff := ElementDiff{
+		Added:   []map[string]interface{}{},
+		Removed: []map[string]interface{}{},
+		Changed: []ElementChange{},
+	}
+	old := make(map[string]map[string]interface{}, len(before))
+	for _, element := range before {
+		old[key(element)] = element
+	}
+	seen := make(map[string]bool, len(after))
+	for _, element := range after {
+		id := key(element)
+		seen[id] = true
+		previous, ok := old[id]
+		if !ok {
+			diff.Added = append(diff.Added, element)
+			continue
+		}
+		var fields []FieldChange
+		diffValues("", strip(previous, ignore), strip(element, ignore), &fields)
+		if len(fields) > 0 {
+			diff.Changed = append(diff.Changed, ElementChange{ID: id, Fields: fields})
+		}
+	}
+	for _, element := range before {
+		if !seen[key(element)] {
+			diff.Removed = append(diff.Removed, element)
+		}
+	}
+	return diff
+}
+
+func strip(element map[string]interface{}, ignore map[string]bool) map[string]interface{} {
+	if len(ignore) == 0 {
+		return element
+	}
+	result := make(map[string]interface{}, len(element))
+	for k, v := range element {
+		if !ignore[k] {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// diffValues appends the leaf values that differ between a and b; objects
+// are compared key by key, anything else (including arrays) as a whole
+func diffValues(path string, a, b interface{}, changes *[]FieldChange) {
+	objA, okA := a.(map[string]interface{})
+	objB, okB := b.(map[string]interface{})
+	if !okA || !okB {
+		if !reflect.DeepEqual(a, b) {
+			*changes = append(*changes, FieldChange{Path: path, Before: a, After: b})
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(objA)+len(objB))
+	for k := range objA {
+		keys = append(keys, k)
+	}
+	for k := range objB {
+		if _, ok := objA[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+		diffValues(child, objA[k], objB[k], changes)
+	}
+}
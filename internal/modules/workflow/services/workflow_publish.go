@@ -0,0 +1,169 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/services/engine"
+)
+
+// ErrNotPublished is returned for production runs of a workflow that has
+// never been published
+var ErrNotPublished = errors.New("workflow has not been published")
+
+// ValidationError lists the problems that keep a draft from being published
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "workflow is invalid: " + strings.Join(e.Problems, "; ")
+}
+
+// PublishWorkflow validates the user's draft and makes it the graph that
+// triggers and production runs use
+func (s *WorkflowService) PublishWorkflow(workflowID, userID string) (*models.Workflow, error) {
+	workflow, err := s.ownedWorkflow(workflowID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if problems := s.ValidateDefinition(workflow.JSON); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	if err := s.ensureBaseline(workflow); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	workflow.PublishedJSON = workflow.JSON
+	workflow.PublishedVersion = workflow.Version
+	workflow.PublishedAt = &now
+	if err := s.workflowRepo.Update(workflow); err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// ValidateDefinition checks that a graph can run: it parses, has a single
+// trigger, uses known node types, its edges connect existing nodes and it
+// has no cycles
+func (s *WorkflowService) ValidateDefinition(raw string) []string {
+	var def WorkflowDefinition
+	if err := json.Unmarshal([]byte(raw), &def); err != nil {
+		return []string{fmt.Sprintf("invalid json: %v", err)}
+	}
+
+	var problems []string
+	nodes := make(map[string]*engine.Node, len(def.Nodes))
+	triggers := 0
+	for i := range def.Nodes {
+		node := &def.Nodes[i]
+		if node.ID == "" {
+			problems = append(problems, fmt.Sprintf("node %d has no id", i+1))
+			continue
+		}
+		if _, ok := nodes[node.ID]; ok {
+			problems = append(problems, fmt.Sprintf("node id %s is used twice", node.ID))
+			continue
+		}
+		nodes[node.ID] = node
+		if node.Type == "trigger" {
+			triggers++
+		}
+		nodeType := node.Type
+		if typeStr, ok := node.Data["type"].(string); ok {
+			nodeType = typeStr
+		}
+		if _, ok := s.executors[nodeType]; !ok {
+			problems = append(problems, fmt.Sprintf("node %s: unknown node type %q", node.ID, nodeType))
+		}
+	}
+	switch {
+	case triggers == 0:
+		problems = append(problems, "no trigger node")
+	case triggers > 1:
+		problems = append(problems, "more than one trigger node")
+	}
+
+	adjacency := make(map[string][]string)
+	for _, edge := range def.Edges {
+		if nodes[edge.Source] == nil || nodes[edge.Target] == nil {
+			problems = append(problems, fmt.Sprintf("edge %s: connects a node that does not exist", edgeName(edge)))
+			continue
+		}
+		adjacency[edge.Source] = append(adjacency[edge.Source], edge.Target)
+	}
+	if node := findCycle(def.Nodes, adjacency); node != "" {
+		problems = append(problems, fmt.Sprintf("node %s is part of a cycle", node))
+	}
+	return problems
+}
+
+func edgeName(edge Edge) string {
+	if edge.ID != "" {
+		return edge.ID
+	}
+	return edge.Source + "->" + edge.Target
+}
+
+// findCycle returns a node on a cycle, or "" when the graph is acyclic
+func findCycle(nodes []engine.Node, adjacency map[string][]string) string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(nodes))
+	var visit func(id string) string
+	visit = func(id string) string {
+		state[id] = visiting
+		for _, next := range adjacency[id] {
+			switch state[next] {
+			case visiting:
+				return next
+			case 0:
+				if found := visit(next); found != "" {
+					return found
+				}
+			}
+		}
+		state[id] = done
+		return ""
+	}
+	for _, node := range nodes {
+		if state[node.ID] == 0 {
+			if found := visit(node.ID); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+// runGraph returns the graph and version a run uses: the draft for test
+// runs, the published graph otherwise
+func runGraph(workflow *models.Workflow, isTest bool) (string, int, error) {
+	if isTest {
+		return workflow.JSON, workflow.Version, nil
+	}
+	if !workflow.IsPublished() {
+		return "", 0, ErrNotPublished
+	}
+	return workflow.PublishedJSON, workflow.PublishedVersion, nil
+}
+
+// publishedTrigger returns the trigger node of the workflow's published
+// graph, or nil when it is unpublished or has none; triggers never see drafts
+func publishedTrigger(workflow *models.Workflow) *engine.Node {
+	if !workflow.IsPublished() {
+		return nil
+	}
+	var def WorkflowDefinition
+	if err := json.Unmarshal([]byte(workflow.PublishedJSON), &def); err != nil {
+		return nil
+	}
+	return def.TriggerNode()
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"s4s-backend/internal/modules/workflow/models"
+	"s4s-backend/internal/modules/workflow/services/engine"
+)
+
+const (
+	draftGraph     = `{"nodes": [{"id": "trigger", "type": "trigger", "data": {"type": "webhook"}}, {"id": "draft", "type": "action"}], "edges": []}`
+	publishedGraph = `{"nodes": [{"id": "trigger", "type": "trigger", "data": {"type": "schedule"}}], "edges": []}`
+)
+
+func TestRunGraph(t *testing.T) {
+	published := &models.Workflow{JSON: draftGraph, Version: 5, PublishedJSON: publishedGraph, PublishedVersion: 3}
+	unpublished := &models.Workflow{JSON: draftGraph, Version: 2}
+
+	tests := []struct {
+		name        string
+		workflow    *models.Workflow
+		isTest      bool
+		wantGraph   string
+		wantVersion int
+		wantErr     error
+	}{
+		{"test run uses the draft", published, true, draftGraph, 5, nil},
+		{"triggered run uses the published graph", published, false, publishedGraph, 3, nil},
+		{"test run of an unpublished workflow", unpublished, true, draftGraph, 2, nil},
+		{"triggered run of an unpublished workflow", unpublished, false, "", 0, ErrNotPublished},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, version, err := runGraph(tt.workflow, tt.isTest)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("runGraph() = %v, want %v", err, tt.wantErr)
+			}
+			if graph != tt.wantGraph || version != tt.wantVersion {
+				t.Errorf("runGraph() = %s version %d, want %s version %d", graph, version, tt.wantGraph, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestPublishedTrigger(t *testing.T) {
+	published := &models.Workflow{JSON: draftGraph, PublishedJSON: publishedGraph, PublishedVersion: 3}
+	if node := publishedTrigger(published); node == nil || node.Data["type"] != "schedule" {
+		t.Errorf("publishedTrigger() = %+v, want the published schedule trigger", node)
+	}
+	if node := publishedTrigger(&models.Workflow{JSON: draftGraph}); node != nil {
+		t.Errorf("publishedTrigger() of a draft = %+v, want nil", node)
+	}
+	if node := publishedTrigger(&models.Workflow{PublishedJSON: "{", PublishedVersion: 1}); node != nil {
+		t.Errorf("publishedTrigger() of invalid json = %+v, want nil", node)
+	}
+}
+
+func TestValidateDefinition(t *testing.T) {
+	s := &WorkflowService{executors: map[string]engine.NodeExecutor{
+		"trigger":      nil,
+		"webhook":      nil,
+		"http_request": nil,
+		"condition":    nil,
+	}}
+
+	tests := []struct {
+		name  string
+		graph string
+		want  []string
+	}{
+		{
+			name: "valid",
+			graph: `{"nodes": [
+				{"id": "t", "type": "trigger", "data": {"type": "webhook"}},
+				{"id": "if", "type": "action", "data": {"type": "condition"}},
+				{"id": "a", "type": "action", "data": {"type": "http_request"}},
+				{"id": "b", "type": "action", "data": {"type": "http_request"}}
+			], "edges": [
+				{"source": "t", "target": "if"},
+				{"source": "if", "target": "a", "sourceHandle": "true"},
+				{"source": "if", "target": "b", "sourceHandle": "false"},
+				{"source": "a", "target": "b"}
+			]}`,
+		},
+		{
+			name:  "missing trigger",
+			graph: `{"nodes": [{"id": "a", "type": "action", "data": {"type": "http_request"}}], "edges": []}`,
+			want:  []string{"no trigger node"},
+		},
+		{
+			name:  "two triggers",
+			graph: `{"nodes": [{"id": "t1", "type": "trigger", "data": {"type": "webhook"}}, {"id": "t2", "type": "trigger"}], "edges": []}`,
+			want:  []string{"more than one trigger node"},
+		},
+		{
+			name: "unknown node type",
+			graph: `{"nodes": [
+				{"id": "t", "type": "trigger", "data": {"type": "webhook"}},
+				{"id": "x", "type": "action", "data": {"type": "fax_message"}},
+				{"id": "y", "type": "teleport"}
+			], "edges": []}`,
+			want: []string{`node x: unknown node type "fax_message"`, `node y: unknown node type "teleport"`},
+		},
+		{
+			name: "dangling edges",
+			graph: `{"nodes": [{"id": "t", "type": "trigger", "data": {"type": "webhook"}}], "edges": [
+				{"id": "e1", "source": "t", "target": "gone"},
+				{"source": "nowhere", "target": "t"}
+			]}`,
+			want: []string{"edge e1: connects a node that does not exist", "edge nowhere->t: connects a node that does not exist"},
+		},
+		{
+			name: "cycle",
+			graph: `{"nodes": [
+				{"id": "t", "type": "trigger", "data": {"type": "webhook"}},
+				{"id": "a", "type": "action", "data": {"type": "http_request"}},
+				{"id": "b", "type": "action", "data": {"type": "http_request"}}
+			], "edges": [{"source": "t", "target": "a"}, {"source": "a", "target": "b"}, {"source": "b", "target": "a"}]}`,
+			want: []string{"node a is part of a cycle"},
+		},
+		{
+			name:  "self loop",
+			graph: `{"nodes": [{"id": "t", "type": "trigger", "data": {"type": "webhook"}}], "edges": [{"source": "t", "target": "t"}]}`,
+			want:  []string{"node t is part of a cycle"},
+		},
+		{
+			name: "missing and duplicate ids",
+			graph: `{"nodes": [
+				{"id": "t", "type": "trigger", "data": {"type": "webhook"}},
+				{"type": "action", "data": {"type": "http_request"}},
+				{"id": "t", "type": "action", "data": {"type": "http_request"}}
+			], "edges": []}`,
+			want: []string{"node 2 has no id", "node id t is used twice"},
+		},
+		{
+			name:  "invalid json",
+			graph: `{"nodes": [`,
+			want:  []string{"invalid json: unexpected end of JSON input"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.ValidateDefinition(tt.graph); !slices.Equal(got, tt.want) {
+				t.Errorf("ValidateDefinition() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,18 +17,24 @@ import (
 type WorkflowService struct {
 	workflowRepo     *repository.WorkflowRepository
 	executionRepo    *repository.ExecutionRepository
+	versionRepo      *repository.WorkflowVersionRepository
 	subscriptionRepo *subscriptionRepo.SubscriptionRepository
+	executors        map[string]engine.NodeExecutor
 }
 
 func NewWorkflowService(
 	workflowRepo *repository.WorkflowRepository,
 	executionRepo *repository.ExecutionRepository,
+	versionRepo *repository.WorkflowVersionRepository,
 	subscriptionRepo *subscriptionRepo.SubscriptionRepository,
+	executors map[string]engine.NodeExecutor,
 ) *WorkflowService {
 	return &WorkflowService{
 		workflowRepo:     workflowRepo,
 		executionRepo:    executionRepo,
+		versionRepo:      versionRepo,
 		subscriptionRepo: subscriptionRepo,
+		executors:        executors,
 	}
 }
 
@@ -56,6 +62,7 @@ func (s *WorkflowService) CreateWorkflow(userID string, req *dto.CreateWorkflowR
 		MaxTimeout: req.MaxTimeout,
 		RetryCount: req.RetryCount,
 		RetryDelay: req.RetryDelay,
+		Tags:       models.Tags(req.Tags),
 	}
 
 	if workflow.MaxTimeout == 0 {
@@ -68,7 +75,9 @@ func (s *WorkflowService) CreateWorkflow(userID string, req *dto.CreateWorkflowR
 		workflow.RetryDelay = 60
 	}
 
-	if err := s.workflowRepo.Create(workflow); err != nil {
+	workflow.Version = 1
+	version := snapshot(workflow, userID, req.Message)
+	if err := s.workflowRepo.CreateWithVersion(workflow, version); err != nil {
 		return nil, err
 	}
 
@@ -90,11 +99,14 @@ func (s *WorkflowService) ListWorkflows(userID string, active *bool, page, limit
 	return s.workflowRepo.FindByUserID(userID, active, page, limit)
 }
 
-func (s *WorkflowService) UpdateWorkflow(workflowID string, req *dto.UpdateWorkflowRequest) (*models.Workflow, error) {
+// UpdateWorkflow saves the user's changes to a workflow. A save that changes
+// the definition or settings is recorded as a new version by userID.
+func (s *WorkflowService) UpdateWorkflow(workflowID, userID string, req *dto.UpdateWorkflowRequest) (*models.Workflow, error) {
 	workflow, err := s.workflowRepo.FindByID(workflowID)
-	if err != nil {
-		return nil, errors.New("workflow not found")
+	if err != nil || workflow.UserID != userID {
+		return nil, ErrWorkflowNotFound
 	}
+	before := snapshot(workflow, "", "")
 
 	if req.Name != "" {
 		workflow.Name = req.Name
@@ -114,8 +126,17 @@ func (s *WorkflowService) UpdateWorkflow(workflowID string, req *dto.UpdateWorkf
 	if req.RetryDelay > 0 {
 		workflow.RetryDelay = req.RetryDelay
 	}
+	if req.Tags != nil {
+		workflow.Tags = models.Tags(req.Tags)
+	}
 
-	if err := s.workflowRepo.Update(workflow); err != nil {
+	if sameSnapshot(before, snapshot(workflow, "", "")) {
+		if err := s.workflowRepo.Update(workflow); err != nil {
+			return nil, err
+		}
+		return workflow, nil
+	}
+	if err := s.saveVersion(workflow, before, snapshot(workflow, userID, req.Message)); err != nil {
 		return nil, err
 	}
 
@@ -131,11 +152,17 @@ func (s *WorkflowService) ExecuteWorkflow(ctx context.Context, workflowID string
 	if err != nil {
 		return "", errors.New("workflow not found")
 	}
+	// test runs try out the draft; other runs use the published graph
+	_, version, err := runGraph(workflow, isTest)
+	if err != nil {
+		return "", err
+	}
 
 	execution := &models.Execution{
-		WorkflowID: workflowID,
-		Status:     "pending",
-		IsTest:     isTest,
+		WorkflowID:      workflowID,
+		WorkflowVersion: version,
+		Status:          "pending",
+		IsTest:          isTest,
 	}
 
 	if err := s.executionRepo.Create(execution); err != nil {
@@ -148,6 +175,33 @@ func (s *WorkflowService) ExecuteWorkflow(ctx context.Context, workflowID string
 	return execution.ID, nil
 }
 
+// RunTriggered executes a workflow synchronously with the trigger's data and
+// returns the finished execution, so trigger services can acknowledge or
+// reject the event that started it
+func (s *WorkflowService) RunTriggered(ctx context.Context, workflow *models.Workflow, data map[string]interface{}) (*models.Execution, error) {
+	_, version, err := runGraph(workflow, false)
+	if err != nil {
+		return nil, err
+	}
+	execution := &models.Execution{
+		WorkflowID:      workflow.ID,
+		WorkflowVersion: version,
+		Status:          "pending",
+	}
+	if err := s.executionRepo.Create(execution); err != nil {
+		return nil, err
+	}
+
+	if workflow.MaxTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(workflow.MaxTimeout)*time.Second)
+		defer cancel()
+	}
+
+	s.runWorkflow(ctx, workflow, execution, data)
+	return execution, nil
+}
+
 func (s *WorkflowService) runWorkflow(ctx context.Context, workflow *models.Workflow, execution *models.Execution, testData map[string]interface{}) {
 	now := time.Now()
 	execution.StartedAt = &now
@@ -155,8 +209,13 @@ func (s *WorkflowService) runWorkflow(ctx context.Context, workflow *models.Work
 	s.executionRepo.Update(execution)
 
 	// Parse workflow JSON
+	graph, _, err := runGraph(workflow, execution.IsTest)
+	if err != nil {
+		s.failExecution(execution, err.Error())
+		return
+	}
 	var workflowDef WorkflowDefinition
-	if err := json.Unmarshal([]byte(workflow.JSON), &workflowDef); err != nil {
+	if err := json.Unmarshal([]byte(graph), &workflowDef); err != nil {
 		s.failExecution(execution, fmt.Sprintf("Failed to parse workflow: %v", err))
 		return
 	}
@@ -167,34 +226,18 @@ func (s *WorkflowService) runWorkflow(ctx context.Context, workflow *models.Work
 		nodeMap[workflowDef.Nodes[i].ID] = &workflowDef.Nodes[i]
 	}
 
-	adjacency := make(map[string][]string)
+	adjacency := make(map[string][]Edge)
 	for _, edge := range workflowDef.Edges {
-		adjacency[edge.Source] = append(adjacency[edge.Source], edge.Target)
+		adjacency[edge.Source] = append(adjacency[edge.Source], edge)
 	}
 
 	// Find trigger node
-	var startNode *engine.Node
-	for _, node := range workflowDef.Nodes {
-		if node.Type == "trigger" {
-			startNode = &node
-			break
-		}
-	}
-
+	startNode := workflowDef.TriggerNode()
 	if startNode == nil {
 		s.failExecution(execution, "No trigger node found")
 		return
 	}
 
-	// Initialize executors
-	executors := map[string]engine.NodeExecutor{
-		"http_request": &engine.HTTPRequestExecutor{},
-		"email":        &engine.EmailExecutor{},
-		"webhook":      &engine.WebhookExecutor{},
-		"delay":        &engine.DelayExecutor{},
-		"if":           &engine.IfExecutor{},
-	}
-
 	// Execute nodes
 	logEntries := []string{}
 	data := testData
@@ -202,7 +245,18 @@ func (s *WorkflowService) runWorkflow(ctx context.Context, workflow *models.Work
 		data = make(map[string]interface{})
 	}
 
-	if err := s.executeNode(ctx, startNode, nodeMap, adjacency, data, &logEntries, executors); err != nil {
+	ctx = engine.WithRunInfo(ctx, &engine.RunInfo{
+		ExecutionID: execution.ID,
+		WorkflowID:  workflow.ID,
+		UserID:      workflow.UserID,
+		IsTest:      execution.IsTest,
+		EventDepth:  eventDepthFromContext(ctx),
+		Log: func(line string) {
+			logEntries = append(logEntries, line)
+		},
+	})
+
+	if err := s.executeNode(ctx, startNode, nodeMap, adjacency, data, &logEntries, s.executors); err != nil {
 		s.failExecution(execution, fmt.Sprintf("Execution failed: %v", err))
 		execution.Log = s.formatLog(logEntries)
 		s.executionRepo.Update(execution)
@@ -224,7 +278,7 @@ func (s *WorkflowService) executeNode(
 	ctx context.Context,
 	node *engine.Node,
 	nodeMap map[string]*engine.Node,
-	adjacency map[string][]string,
+	adjacency map[string][]Edge,
 	data map[string]interface{},
 	logEntries *[]string,
 	executors map[string]engine.NodeExecutor,
@@ -249,14 +303,30 @@ func (s *WorkflowService) executeNode(
 
 	*logEntries = append(*logEntries, fmt.Sprintf("[%s] Node %s completed successfully", time.Now().Format("15:04:05"), node.ID))
 
+	branches, _ := output[engine.BranchesKey].(map[string]map[string]interface{})
+	delete(output, engine.BranchesKey)
 	for k, v := range output {
 		data[k] = v
 	}
 
-	nextNodeIDs := adjacency[node.ID]
-	for _, nextID := range nextNodeIDs {
-		nextNode := nodeMap[nextID]
-		if err := s.executeNode(ctx, nextNode, nodeMap, adjacency, data, logEntries, executors); err != nil {
+	for _, edge := range adjacency[node.ID] {
+		nextNode := nodeMap[edge.Target]
+		branchData := data
+		if edge.SourceHandle != "" && branches != nil {
+			// routing nodes pick the branches that run and may hand each its own data
+			overrides, ok := branches[edge.SourceHandle]
+			if !ok {
+				continue
+			}
+			branchData = make(map[string]interface{}, len(data)+len(overrides))
+			for k, v := range data {
+				branchData[k] = v
+			}
+			for k, v := range overrides {
+				branchData[k] = v
+			}
+		}
+		if err := s.executeNode(ctx, nextNode, nodeMap, adjacency, branchData, logEntries, executors); err != nil {
 			return err
 		}
 	}
@@ -288,8 +358,20 @@ type WorkflowDefinition struct {
 	Edges []Edge        `json:"edges"`
 }
 
+// TriggerNode returns the node a run starts from, or nil if there is none
+func (d *WorkflowDefinition) TriggerNode() *engine.Node {
+	for i := range d.Nodes {
+		if d.Nodes[i].Type == "trigger" {
+			return &d.Nodes[i]
+		}
+	}
+	return nil
+}
+
 type Edge struct {
 	ID     string `json:"id"`
 	Source string `json:"source"`
 	Target string `json:"target"`
+	// SourceHandle names the output of a routing node the edge leaves from
+	SourceHandle string `json:"sourceHandle,omitempty"`
 }
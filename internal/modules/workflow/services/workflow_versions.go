@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+// ErrVersionNotFound is returned for versions a workflow does not have
+var ErrVersionNotFound = errors.New("version not found")
+
+// ListVersions returns the user's workflow versions, newest first
+func (s *WorkflowService) ListVersions(workflowID, userID string, page, limit int) ([]models.WorkflowVersion, int64, error) {
+	workflow, err := s.ownedWorkflow(workflowID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	if err := s.ensureBaseline(workflow); err != nil {
+		return nil, 0, err
+	}
+	return s.versionRepo.FindByWorkflowID(workflow.ID, page, limit)
+}
+
+func (s *WorkflowService) GetVersion(workflowID, userID string, number int) (*models.WorkflowVersion, error) {
+	workflow, err := s.ownedWorkflow(workflowID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureBaseline(workflow); err != nil {
+		return nil, err
+	}
+	return s.findVersion(workflow.ID, number)
+}
+
+// DiffVersions compares version from with version to of the user's workflow
+func (s *WorkflowService) DiffVersions(workflowID, userID string, from, to int) (*WorkflowDiff, error) {
+	workflow, err := s.ownedWorkflow(workflowID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureBaseline(workflow); err != nil {
+		return nil, err
+	}
+	before, err := s.findVersion(workflow.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.findVersion(workflow.ID, to)
+	if err != nil {
+		return nil, err
+	}
+	return DiffWorkflowVersions(before, after)
+}
+
+// RestoreVersion makes an earlier version current again. The restore is
+// itself recorded as a new version, so history is never rewritten.
+func (s *WorkflowService) RestoreVersion(workflowID, userID string, number int, message string) (*models.Workflow, error) {
+	workflow, err := s.ownedWorkflow(workflowID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureBaseline(workflow); err != nil {
+		return nil, err
+	}
+	restored, err := s.findVersion(workflow.ID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	before := snapshot(workflow, "", "")
+	workflow.Name = restored.Name
+	workflow.JSON = restored.JSON
+	workflow.MaxTimeout = restored.MaxTimeout
+	workflow.RetryCount = restored.RetryCount
+	workflow.RetryDelay = restored.RetryDelay
+
+	if message == "" {
+		message = fmt.Sprintf("Restored version %d", number)
+	}
+	version := snapshot(workflow, userID, message)
+	version.RestoredFrom = &number
+	if err := s.saveVersion(workflow, before, version); err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+func (s *WorkflowService) ownedWorkflow(workflowID, userID string) (*models.Workflow, error) {
+	workflow, err := s.workflowRepo.FindByID(workflowID)
+	if err != nil || workflow.UserID != userID {
+		return nil, ErrWorkflowNotFound
+	}
+	return workflow, nil
+}
+
+func (s *WorkflowService) findVersion(workflowID string, number int) (*models.WorkflowVersion, error) {
+	version, err := s.versionRepo.FindByVersion(workflowID, number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrVersionNotFound
+	}
+	return version, err
+}
+
+// ensureBaseline records the current state of a workflow saved before
+// versioning existed as its first version
+func (s *WorkflowService) ensureBaseline(workflow *models.Workflow) error {
+	_, err := s.versionRepo.FindLatest(workflow.ID)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if workflow.Version < 1 {
+		workflow.Version = 1
+	}
+	baseline := snapshot(workflow, workflow.UserID, "Initial version")
+	baseline.CreatedAt = workflow.UpdatedAt
+	return s.versionRepo.Create(baseline)
+}
+
+// saveVersion saves workflow as the version after the latest one. before is
+// the state the workflow was loaded in, recorded first when the workflow has
+// no history yet.
+func (s *WorkflowService) saveVersion(workflow *models.Workflow, before, version *models.WorkflowVersion) error {
+	latest, err := s.versionRepo.FindLatest(workflow.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		before.Version = max(workflow.Version, 1)
+		before.AuthorID = workflow.UserID
+		before.Message = "Initial version"
+		if err := s.versionRepo.Create(before); err != nil {
+			return err
+		}
+		latest = before
+	} else if err != nil {
+		return err
+	}
+
+	workflow.Version = latest.Version + 1
+	version.Version = workflow.Version
+	return s.workflowRepo.UpdateWithVersion(workflow, version)
+}
+
+// snapshot copies the versioned fields of a workflow
+func snapshot(workflow *models.Workflow, authorID, message string) *models.WorkflowVersion {
+	return &models.WorkflowVersion{
+		WorkflowID: workflow.ID,
+		Version:    workflow.Version,
+		Name:       workflow.Name,
+		JSON:       workflow.JSON,
+		MaxTimeout: workflow.MaxTimeout,
+		RetryCount: workflow.RetryCount,
+		RetryDelay: workflow.RetryDelay,
+		AuthorID:   authorID,
+		Message:    message,
+	}
+}
+
+func sameSnapshot(a, b *models.WorkflowVersion) bool {
+	return a.Name == b.Name && a.JSON == b.JSON && a.MaxTimeout == b.MaxTimeout &&
+		a.RetryCount == b.RetryCount && a.RetryDelay == b.RetryDelay
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"s4s-backend/internal/modules/workflow/models"
+)
+
+const versionGraph = `{
+	"nodes": [
+		{"id": "trigger", "type": "trigger", "position": {"x": 0, "y": 0}, "data": {"type": "webhook", "config": {}}},
+		{"id": "http", "type": "action", "position": {"x": 200, "y": 0}, "data": {"type": "http_request", "config": {"url": "https://a.example.com", "method": "GET"}}}
+	],
+	"edges": [
+		{"id": "e1", "source": "trigger", "target": "http"}
+	]
+}`
+
+func workflowVersion(number int, graph string) *models.WorkflowVersion {
+	return &models.WorkflowVersion{Version: number, Name: "Leads", JSON: graph, MaxTimeout: 300, RetryCount: 3, RetryDelay: 60}
+}
+
+// diffJSON encodes a diff the way the API returns it
+func diffJSON(t *testing.T, diff interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestDiffWorkflowVersions(t *testing.T) {
+	empty := ElementDiff{Added: []map[string]interface{}{}, Removed: []map[string]interface{}{}, Changed: []ElementChange{}}
+
+	tests := []struct {
+		name      string
+		to        string
+		settings  func(v *models.WorkflowVersion)
+		wantNodes string
+		wantEdges string
+		// wantSettings lists the changed settings as JSON
+		wantSettings string
+	}{
+		{
+			name: "unchanged",
+			to:   versionGraph,
+		},
+		{
+			name: "layout changes are not structural",
+			to: `{"nodes": [
+				{"id": "http", "type": "action", "position": {"x": 500, "y": 90}, "selected": true, "width": 180, "data": {"type": "http_request", "config": {"method": "GET", "url": "https://a.example.com"}}},
+				{"id": "trigger", "type": "trigger", "position": {"x": 10, "y": 10}, "dragging": false, "data": {"type": "webhook", "config": {}}}
+			], "edges": [{"id": "e1", "source": "trigger", "target": "http"}]}`,
+		},
+		{
+			name: "node added, removed and changed",
+			to: `{"nodes": [
+				{"id": "trigger", "type": "trigger", "data": {"type": "schedule", "config": {"cron": "0 9 * * *"}}},
+				{"id": "slack", "type": "action", "data": {"type": "slack_message", "config": {"text": "hi"}}}
+			], "edges": []}`,
+			wantNodes: `{"added":[{"data":{"config":{"text":"hi"},"type":"slack_message"},"id":"slack","type":"action"}],` +
+				`"removed":[{"data":{"config":{"method":"GET","url":"https://a.example.com"},"type":"http_request"},"id":"http","position":{"x":200,"y":0},"type":"action"}],` +
+				`"changed":[{"id":"trigger","fields":[{"path":"data.config.cron","before":null,"after":"0 9 * * *"},{"path":"data.type","before":"webhook","after":"schedule"}]}]}`,
+			wantEdges: `{"added":[],"removed":[{"id":"e1","source":"trigger","target":"http"}],"changed":[]}`,
+		},
+		{
+			name: "config values and arrays",
+			to: `{"nodes": [
+				{"id": "trigger", "type": "trigger", "data": {"type": "webhook", "config": {}}},
+				{"id": "http", "type": "action", "data": {"type": "http_request", "config": {"url": "https://b.example.com", "method": "GET", "headers": ["X-A"]}}}
+			], "edges": [{"id": "e1", "source": "trigger", "target": "http"}]}`,
+			wantNodes: `{"added":[],"removed":[],"changed":[{"id":"http","fields":[` +
+				`{"path":"data.config.headers","before":null,"after":["X-A"]},` +
+				`{"path":"data.config.url","before":"https://a.example.com","after":"https://b.example.com"}]}]}`,
+		},
+		{
+			name: "edges are matched by id",
+			to: `{"nodes": [
+				{"id": "trigger", "type": "trigger", "data": {"type": "webhook", "config": {}}},
+				{"id": "http", "type": "action", "data": {"type": "http_request", "config": {"url": "https://a.example.com", "method": "GET"}}}
+			], "edges": [{"id": "e1", "source": "trigger", "target": "http", "sourceHandle": "true"}, {"source": "http", "target": "trigger"}]}`,
+			wantEdges: `{"added":[{"source":"http","target":"trigger"}],"removed":[],` +
+				`"changed":[{"id":"e1","fields":[{"path":"sourceHandle","before":null,"after":"true"}]}]}`,
+		},
+		{
+			name:         "settings",
+			to:           versionGraph,
+			settings:     func(v *models.WorkflowVersion) { v.Name, v.RetryCount = "Hot leads", 0 },
+			wantSettings: `[{"path":"name","before":"Leads","after":"Hot leads"},{"path":"retryCount","before":3,"after":0}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := workflowVersion(2, tt.to)
+			if tt.settings != nil {
+				tt.settings(to)
+			}
+			diff, err := DiffWorkflowVersions(workflowVersion(1, versionGraph), to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff.From != 1 || diff.To != 2 {
+				t.Errorf("diff of %d and %d, want 1 and 2", diff.From, diff.To)
+			}
+			for _, check := range []struct {
+				name      string
+				got       interface{}
+				want      string
+				unchanged interface{}
+			}{
+				{"nodes", diff.Nodes, tt.wantNodes, empty},
+				{"edges", diff.Edges, tt.wantEdges, empty},
+				{"settings", diff.Settings, tt.wantSettings, []FieldChange{}},
+			} {
+				want := check.want
+				if want == "" {
+					want = diffJSON(t, check.unchanged)
+				}
+				if got := diffJSON(t, check.got); got != want {
+					t.Errorf("%s:\n%s\nwant:\n%s", check.name, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := DiffWorkflowVersions(workflowVersion(1, versionGraph), workflowVersion(2, "{")); err == nil {
+		t.Error("DiffWorkflowVersions() with invalid json succeeded")
+	}
+}
+
+func TestSameSnapshot(t *testing.T) {
+	workflow := func() *models.Workflow {
+		return &models.Workflow{
+			ID: "wf-1", UserID: "user-1", Name: "Leads", JSON: versionGraph, Version: 4,
+			MaxTimeout: 300, RetryCount: 3, RetryDelay: 60, Active: false, Tags: models.Tags{"sales"},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		change      func(w *models.Workflow)
+		wantVersion bool
+	}{
+		{"nothing", func(w *models.Workflow) {}, false},
+		{"activation", func(w *models.Workflow) { w.Active = true }, false},
+		{"tags", func(w *models.Workflow) { w.Tags = models.Tags{"sales", "eu"} }, false},
+		{"execution counters", func(w *models.Workflow) { w.TotalExecutions, w.SuccessCount = 10, 9 }, false},
+		{"name", func(w *models.Workflow) { w.Name = "Hot leads" }, true},
+		{"graph", func(w *models.Workflow) { w.JSON = `{"nodes": [], "edges": []}` }, true},
+		{"timeout", func(w *models.Workflow) { w.MaxTimeout = 60 }, true},
+		{"retries turned off", func(w *models.Workflow) { w.RetryCount = 0 }, true},
+		{"retry delay", func(w *models.Workflow) { w.RetryDelay = 5 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := workflow()
+			before := snapshot(w, "", "")
+			tt.change(w)
+			// the author and message of the new version do not matter
+			if got := !sameSnapshot(before, snapshot(w, "user-2", "Saved")); got != tt.wantVersion {
+				t.Errorf("save creates a version: %v, want %v", got, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	w := &models.Workflow{ID: "wf-1", Name: "Leads", JSON: versionGraph, Version: 4, MaxTimeout: 300, RetryCount: 3, RetryDelay: 60}
+	want := &models.WorkflowVersion{
+		WorkflowID: "wf-1", Version: 4, Name: "Leads", JSON: versionGraph,
+		MaxTimeout: 300, RetryCount: 3, RetryDelay: 60, AuthorID: "user-1", Message: "Tuned retries",
+	}
+	if got := snapshot(w, "user-1", "Tuned retries"); !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package amqpconn
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	dialTimeout = 10 * time.Second
+	heartbeat   = 10 * time.Second
+)
+
+// DialFunc opens the TCP connection to the broker
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Config describes an "amqp" connection
+type Config struct {
+	URL string
+}
+
+// ConfigFromCredentials reads an amqp connection's credentials. Either "url"
+// (amqp:// or amqps://) or host, port, vhost, username, password and tls are accepted.
+func ConfigFromCredentials(creds map[string]interface{}) (*Config, error) {
+	if raw, _ := creds["url"].(string); raw != "" {
+		uri, err := amqp.ParseURI(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url: %w", err)
+		}
+		if uri.Host == "" {
+			return nil, errors.New("url must include a host")
+		}
+		return &Config{URL: raw}, nil
+	}
+
+	host, _ := creds["host"].(string)
+	if host == "" {
+		return nil, errors.New("url or host is required")
+	}
+	username, _ := creds["username"].(string)
+	password, _ := creds["password"].(string)
+	vhost, _ := creds["vhost"].(string)
+	useTLS, _ := creds["tls"].(bool)
+
+	scheme, port := "amqp", 5672
+	if useTLS {
+		scheme, port = "amqps", 5671
+	}
+	switch v := creds["port"].(type) {
+	case float64:
+		port = int(v)
+	case string:
+		if v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port %q", v)
+			}
+			port = n
+		}
+	}
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+	if vhost == "" {
+		vhost = "/"
+	}
+
+	u := url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+		// the vhost is a single path segment, so "/" must be sent as %2F
+		Path:    "/" + vhost,
+		RawPath: "/" + url.PathEscape(vhost),
+	}
+	if username != "" {
+		u.User = url.UserPassword(username, password)
+	}
+	return &Config{URL: u.String()}, nil
+}
+
+// Redacted returns the broker URL without the password, for logs
+func (c *Config) Redacted() string {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return "amqp"
+	}
+	return u.Redacted()
+}
+
+// Dial connects to the broker through dial so callers can enforce an egress policy
+func Dial(ctx context.Context, cfg *Config, dial DialFunc) (*amqp.Connection, error) {
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		dial = dialer.DialContext
+	}
+
+	conn, err := amqp.DialConfig(cfg.URL, amqp.Config{
+		Heartbeat: heartbeat,
+		Locale:    "en_US",
+		Dial: func(network, addr string) (net.Conn, error) {
+			dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+			defer cancel()
+			conn, err := dial(dialCtx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			// bounds the AMQP handshake; the library clears it once the connection is open
+			if err := conn.SetDeadline(time.Now().Add(dialTimeout)); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		},
+		TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	})
+	if err != nil {
+		return nil, redact(err, cfg)
+	}
+	return conn, nil
+}
+
+// Verify connects, opens a channel and disconnects
+func Verify(ctx context.Context, cfg *Config, dial DialFunc) error {
+	conn, err := Dial(ctx, cfg, dial)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	return ch.Close()
+}
+
+// redact keeps the broker password out of errors that quote the URL
+func redact(err error, cfg *Config) error {
+	u, parseErr := url.Parse(cfg.URL)
+	if parseErr != nil || u.User == nil {
+		return err
+	}
+	password, ok := u.User.Password()
+	if !ok || password == "" {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), password, "***"))
+}
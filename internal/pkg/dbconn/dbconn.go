@@ -0,0 +1,253 @@
+package dbconn
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+const (
+	EnginePostgres = "postgres"
+	EngineMySQL    = "mysql"
+
+	// Pool limits for user databases; customers' servers are not ours to exhaust
+	defaultMaxOpenConns = 5
+	maxOpenConnsLimit   = 10
+	maxIdleConns        = 2
+	connMaxLifetime     = 30 * time.Minute
+	connMaxIdleTime     = 5 * time.Minute
+	dialTimeout         = 10 * time.Second
+
+	// Server-side backstop; per-query timeouts come from the execution context
+	statementTimeout = 5 * time.Minute
+)
+
+// DialFunc opens the TCP connection to the database server
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Config describes a "database" connection
+type Config struct {
+	Engine       string
+	Host         string
+	Port         int
+	Database     string
+	Username     string
+	Password     string
+	SSLMode      string
+	MaxOpenConns int
+}
+
+// ConfigFromCredentials reads a database connection's credentials
+func ConfigFromCredentials(creds map[string]interface{}) (*Config, error) {
+	cfg := &Config{}
+	cfg.Engine, _ = creds["engine"].(string)
+	cfg.Host, _ = creds["host"].(string)
+	cfg.Database, _ = creds["database"].(string)
+	cfg.Username, _ = creds["username"].(string)
+	cfg.Password, _ = creds["password"].(string)
+	cfg.SSLMode, _ = creds["sslMode"].(string)
+	cfg.Port = intValue(creds["port"])
+	cfg.MaxOpenConns = intValue(creds["maxOpenConns"])
+
+	cfg.Engine = strings.ToLower(cfg.Engine)
+	switch cfg.Engine {
+	case EnginePostgres:
+		if cfg.Port == 0 {
+			cfg.Port = 5432
+		}
+		if cfg.SSLMode == "" {
+			cfg.SSLMode = "require"
+		}
+	case EngineMySQL:
+		if cfg.Port == 0 {
+			cfg.Port = 3306
+		}
+		if cfg.SSLMode == "" {
+			cfg.SSLMode = "preferred"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported database engine %q", cfg.Engine)
+	}
+
+	if cfg.Host == "" || cfg.Database == "" || cfg.Username == "" {
+		return nil, errors.New("host, database and username are required")
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", cfg.Port)
+	}
+	if cfg.MaxOpenConns <= 0 {
+		cfg.MaxOpenConns = defaultMaxOpenConns
+	}
+	if cfg.MaxOpenConns > maxOpenConnsLimit {
+		cfg.MaxOpenConns = maxOpenConnsLimit
+	}
+	return cfg, nil
+}
+
+func intValue(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+// Open creates a pooled handle with conservative limits. Connections are
+// opened through dial so callers can enforce an egress policy.
+func Open(cfg *Config, dial DialFunc) (*sql.DB, error) {
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		dial = dialer.DialContext
+	}
+
+	var db *sql.DB
+	switch cfg.Engine {
+	case EnginePostgres:
+		dsn := postgresDSN(cfg)
+		if _, err := pq.NewConnector(dsn); err != nil {
+			return nil, err
+		}
+		db = sql.OpenDB(pqConnector{dsn: dsn, dial: dial})
+
+	case EngineMySQL:
+		mysqlCfg := mysql.NewConfig()
+		mysqlCfg.User = cfg.Username
+		mysqlCfg.Passwd = cfg.Password
+		mysqlCfg.Net = mysqlNetwork
+		mysqlCfg.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+		mysqlCfg.DBName = cfg.Database
+		mysqlCfg.ParseTime = true
+		mysqlCfg.Timeout = dialTimeout
+		mysqlCfg.Params = map[string]string{
+			"max_execution_time": strconv.FormatInt(statementTimeout.Milliseconds(), 10),
+		}
+		switch cfg.SSLMode {
+		case "disable", "false":
+		case "skip-verify":
+			mysqlCfg.TLSConfig = "skip-verify"
+		case "preferred":
+			mysqlCfg.TLSConfig = "preferred"
+		default:
+			mysqlCfg.TLS = &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+		}
+		connector, err := mysql.NewConnector(mysqlCfg)
+		if err != nil {
+			return nil, err
+		}
+		db = sql.OpenDB(mysqlConnector{Connector: connector, dial: dial})
+
+	default:
+		return nil, fmt.Errorf("unsupported database engine %q", cfg.Engine)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(connMaxLifetime)
+	db.SetConnMaxIdleTime(connMaxIdleTime)
+	return db, nil
+}
+
+// Ping opens a single connection and checks the server answers
+func Ping(ctx context.Context, cfg *Config, dial DialFunc) error {
+	db, err := Open(cfg, dial)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.PingContext(ctx)
+}
+
+func postgresDSN(cfg *Config) string {
+	query := url.Values{}
+	query.Set("sslmode", cfg.SSLMode)
+	query.Set("connect_timeout", strconv.Itoa(int(dialTimeout.Seconds())))
+	query.Set("statement_timeout", strconv.FormatInt(statementTimeout.Milliseconds(), 10))
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.Username, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     "/" + cfg.Database,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// pqConnector opens lib/pq connections through the pool's DialFunc. The
+// driver only accepts a custom dialer via DialOpen, so the connect context is
+// carried by the dialer itself.
+type pqConnector struct {
+	dsn  string
+	dial DialFunc
+}
+
+func (c pqConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return pq.DialOpen(pqDialer{ctx: ctx, dial: c.dial}, c.dsn)
+}
+
+func (c pqConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// pqDialer adapts a DialFunc to lib/pq's Dialer and DialerContext interfaces
+type pqDialer struct {
+	ctx  context.Context
+	dial DialFunc
+}
+
+func (d pqDialer) Dial(network, address string) (net.Conn, error) {
+	return d.dial(d.ctx, network, address)
+}
+
+func (d pqDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, timeout)
+	defer cancel()
+	return d.dial(ctx, network, address)
+}
+
+func (d pqDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.dial(ctx, network, address)
+}
+
+// mysqlNetwork is the custom network registered with the mysql driver. The
+// driver only supports dialers globally, so the per-pool DialFunc travels in
+// the context passed to Connect.
+const mysqlNetwork = "dbconn"
+
+type mysqlDialKey struct{}
+
+func init() {
+	mysql.RegisterDialContext(mysqlNetwork, func(ctx context.Context, addr string) (net.Conn, error) {
+		dial, ok := ctx.Value(mysqlDialKey{}).(DialFunc)
+		if !ok {
+			return nil, errors.New("mysql dial attempted outside of dbconn")
+		}
+		return dial(ctx, "tcp", addr)
+	})
+}
+
+// mysqlConnector injects the pool's DialFunc into every connect
+type mysqlConnector struct {
+	driver.Connector
+	dial DialFunc
+}
+
+func (c mysqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.Connector.Connect(context.WithValue(ctx, mysqlDialKey{}, c.dial))
+}
@@ -0,0 +1,561 @@
+package dom
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// CSS is a compiled CSS selector group. Supported: type, universal, #id,
+// .class and attribute selectors ([a], =, ~=, |=, ^=, $=, *=, with an
+// optional i flag); descendant, >, + and ~ combinators; :first-child,
+// :last-child, :only-child, :first-of-type, :last-of-type, :nth-child(),
+// :nth-last-child(), :nth-of-type(), :empty, :not() and :contains().
+type CSS struct {
+	selectors []complexSelector
+}
+
+type complexSelector struct {
+	// compounds[i] is joined to compounds[i-1] by combinators[i-1]
+	compounds   []compoundSelector
+	combinators []byte
+}
+
+type compoundSelector struct {
+	tag     string
+	filters []func(n *html.Node) bool
+}
+
+// CompileCSS parses a selector group such as "div.card > a[href], h1"
+func CompileCSS(selector string) (*CSS, error) {
+	p := &cssParser{src: selector}
+	css := &CSS{}
+	for {
+		p.skipSpace()
+		complex, err := p.complex()
+		if err != nil {
+			return nil, err
+		}
+		css.selectors = append(css.selectors, complex)
+		p.skipSpace()
+		if p.eof() {
+			return css, nil
+		}
+		if p.peek() != ',' {
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+		p.pos++
+	}
+}
+
+// Select returns the elements below context matching any selector of the group
+func (c *CSS) Select(context *html.Node) []*html.Node {
+	var result []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			if c.Matches(child) {
+				result = append(result, child)
+			}
+			walk(child)
+		}
+	}
+	walk(context)
+	return result
+}
+
+// Matches reports whether n matches any selector of the group
+func (c *CSS) Matches(n *html.Node) bool {
+	for _, s := range c.selectors {
+		if s.matches(n, len(s.compounds)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s complexSelector) matches(n *html.Node, i int) bool {
+	if !s.compounds[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch s.combinators[i-1] {
+	case ' ':
+		for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+			if s.matches(p, i-1) {
+				return true
+			}
+		}
+	case '>':
+		if p := n.Parent; p != nil && p.Type == html.ElementNode {
+			return s.matches(p, i-1)
+		}
+	case '+':
+		if p := previousElement(n); p != nil {
+			return s.matches(p, i-1)
+		}
+	case '~':
+		for p := previousElement(n); p != nil; p = previousElement(p) {
+			if s.matches(p, i-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c compoundSelector) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if c.tag != "" && c.tag != "*" && !strings.EqualFold(c.tag, n.Data) {
+		return false
+	}
+	for _, filter := range c.filters {
+		if !filter(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func previousElement(n *html.Node) *html.Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func nextElement(n *html.Node) *html.Node {
+	for p := n.NextSibling; p != nil; p = p.NextSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+type cssParser struct {
+	src string
+	pos int
+}
+
+func (p *cssParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *cssParser) peek() byte { return p.src[p.pos] }
+
+func (p *cssParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid css selector %q at %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *cssParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\n\r\f", p.peek()) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *cssParser) complex() (complexSelector, error) {
+	var s complexSelector
+	compound, err := p.compound()
+	if err != nil {
+		return s, err
+	}
+	s.compounds = append(s.compounds, compound)
+
+	for {
+		spaced := p.skipSpace()
+		if p.eof() || p.peek() == ',' || p.peek() == ')' {
+			return s, nil
+		}
+		combinator := byte(' ')
+		if c := p.peek(); c == '>' || c == '+' || c == '~' {
+			combinator = c
+			p.pos++
+			p.skipSpace()
+		} else if !spaced {
+			return s, p.errorf("unexpected %q", c)
+		}
+		compound, err := p.compound()
+		if err != nil {
+			return s, err
+		}
+		s.compounds = append(s.compounds, compound)
+		s.combinators = append(s.combinators, combinator)
+	}
+}
+
+func (p *cssParser) compound() (compoundSelector, error) {
+	var c compoundSelector
+	if !p.eof() && p.peek() == '*' {
+		c.tag = "*"
+		p.pos++
+	} else if name := p.ident(); name != "" {
+		c.tag = name
+	}
+
+	for !p.eof() {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			id := p.ident()
+			if id == "" {
+				return c, p.errorf("expected id")
+			}
+			c.filters = append(c.filters, func(n *html.Node) bool {
+				v, _ := Attr(n, "id")
+				return v == id
+			})
+		case '.':
+			p.pos++
+			class := p.ident()
+			if class == "" {
+				return c, p.errorf("expected class name")
+			}
+			c.filters = append(c.filters, func(n *html.Node) bool {
+				v, _ := Attr(n, "class")
+				return containsWord(v, class)
+			})
+		case '[':
+			filter, err := p.attribute()
+			if err != nil {
+				return c, err
+			}
+			c.filters = append(c.filters, filter)
+		case ':':
+			filter, err := p.pseudo()
+			if err != nil {
+				return c, err
+			}
+			c.filters = append(c.filters, filter)
+		default:
+			if c.tag == "" && len(c.filters) == 0 {
+				return c, p.errorf("expected selector")
+			}
+			return c, nil
+		}
+	}
+	if c.tag == "" && len(c.filters) == 0 {
+		return c, p.errorf("expected selector")
+	}
+	return c, nil
+}
+
+// ident reads a CSS identifier, resolving backslash escapes of single characters
+func (p *cssParser) ident() string {
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '\\' && p.pos+1 < len(p.src):
+			r, size := utf8.DecodeRuneInString(p.src[p.pos+1:])
+			b.WriteRune(r)
+			p.pos += 1 + size
+		case c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+func (p *cssParser) stringOrIdent() (string, error) {
+	if p.eof() {
+		return "", p.errorf("unexpected end")
+	}
+	quote := p.peek()
+	if quote != '"' && quote != '\'' {
+		return p.ident(), nil
+	}
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			b.WriteByte(p.src[p.pos+1])
+			p.pos += 2
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *cssParser) attribute() (func(n *html.Node) bool, error) {
+	p.pos++ // [
+	p.skipSpace()
+	key := p.ident()
+	if key == "" {
+		return nil, p.errorf("expected attribute name")
+	}
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("unterminated attribute selector")
+	}
+	if p.peek() == ']' {
+		p.pos++
+		return func(n *html.Node) bool {
+			_, ok := Attr(n, key)
+			return ok
+		}, nil
+	}
+
+	op := ""
+	if c := p.peek(); strings.IndexByte("~|^$*", c) >= 0 {
+		op = string(c)
+		p.pos++
+	}
+	if p.eof() || p.peek() != '=' {
+		return nil, p.errorf("expected =")
+	}
+	p.pos++
+	p.skipSpace()
+	value, err := p.stringOrIdent()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	fold := false
+	if !p.eof() && (p.peek() == 'i' || p.peek() == 'I') {
+		fold = true
+		p.pos++
+		p.skipSpace()
+	}
+	if p.eof() || p.peek() != ']' {
+		return nil, p.errorf("expected ]")
+	}
+	p.pos++
+	if fold {
+		value = strings.ToLower(value)
+	}
+
+	return func(n *html.Node) bool {
+		v, ok := Attr(n, key)
+		if !ok {
+			return false
+		}
+		if fold {
+			v = strings.ToLower(v)
+		}
+		switch op {
+		case "~":
+			return containsWord(v, value)
+		case "|":
+			return v == value || strings.HasPrefix(v, value+"-")
+		case "^":
+			return value != "" && strings.HasPrefix(v, value)
+		case "$":
+			return value != "" && strings.HasSuffix(v, value)
+		case "*":
+			return value != "" && strings.Contains(v, value)
+		default:
+			return v == value
+		}
+	}, nil
+}
+
+func (p *cssParser) pseudo() (func(n *html.Node) bool, error) {
+	p.pos++ // :
+	if !p.eof() && p.peek() == ':' {
+		return nil, p.errorf("pseudo-elements are not supported")
+	}
+	name := strings.ToLower(p.ident())
+
+	switch name {
+	case "first-child":
+		return func(n *html.Node) bool { return previousElement(n) == nil }, nil
+	case "last-child":
+		return func(n *html.Node) bool { return nextElement(n) == nil }, nil
+	case "only-child":
+		return func(n *html.Node) bool { return previousElement(n) == nil && nextElement(n) == nil }, nil
+	case "first-of-type":
+		return func(n *html.Node) bool { return positionOfType(n, false) == 1 }, nil
+	case "last-of-type":
+		return func(n *html.Node) bool { return positionOfType(n, true) == 1 }, nil
+	case "empty":
+		return func(n *html.Node) bool {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode || c.Type == html.TextNode && c.Data != "" {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+
+	if p.eof() || p.peek() != '(' {
+		if name == "" {
+			return nil, p.errorf("expected pseudo-class")
+		}
+		return nil, p.errorf("unsupported pseudo-class :%s", name)
+	}
+	p.pos++
+	p.skipSpace()
+
+	var filter func(n *html.Node) bool
+	switch name {
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		end := strings.IndexByte(p.src[p.pos:], ')')
+		if end < 0 {
+			return nil, p.errorf("expected )")
+		}
+		a, b, err := parseNth(p.src[p.pos : p.pos+end])
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.pos += end
+		last := strings.Contains(name, "last")
+		ofType := strings.HasSuffix(name, "of-type")
+		filter = func(n *html.Node) bool {
+			var position int
+			if ofType {
+				position = positionOfType(n, last)
+			} else {
+				position = positionOfChild(n, last)
+			}
+			return nthMatches(a, b, position)
+		}
+	case "not":
+		inner := &CSS{}
+		for {
+			p.skipSpace()
+			compound, err := p.compound()
+			if err != nil {
+				return nil, err
+			}
+			inner.selectors = append(inner.selectors, complexSelector{compounds: []compoundSelector{compound}})
+			p.skipSpace()
+			if p.eof() || p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		filter = func(n *html.Node) bool { return !inner.Matches(n) }
+	case "contains":
+		text, err := p.stringOrIdent()
+		if err != nil {
+			return nil, err
+		}
+		filter = func(n *html.Node) bool { return strings.Contains(Text(n), text) }
+	default:
+		return nil, p.errorf("unsupported pseudo-class :%s()", name)
+	}
+
+	p.skipSpace()
+	if p.eof() || p.peek() != ')' {
+		return nil, p.errorf("expected )")
+	}
+	p.pos++
+	return filter, nil
+}
+
+// parseNth parses the an+b argument of :nth-child() and friends
+func parseNth(expr string) (int, int, error) {
+	expr = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(expr), " ", ""))
+	switch expr {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+
+	nIndex := strings.IndexByte(expr, 'n')
+	if nIndex < 0 {
+		b, err := strconv.Atoi(expr)
+		if err != nil {
+			return 0, 0, errors.New("invalid nth expression")
+		}
+		return 0, b, nil
+	}
+
+	a := 1
+	switch coefficient := expr[:nIndex]; coefficient {
+	case "", "+":
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(coefficient); err != nil {
+			return 0, 0, errors.New("invalid nth expression")
+		}
+	}
+	b := 0
+	if rest := expr[nIndex+1:]; rest != "" {
+		var err error
+		if b, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, errors.New("invalid nth expression")
+		}
+	}
+	return a, b, nil
+}
+
+func nthMatches(a, b, position int) bool {
+	if a == 0 {
+		return position == b
+	}
+	diff := position - b
+	return diff%a == 0 && diff/a >= 0
+}
+
+// positionOfChild is n's 1-based position among its element siblings,
+// counted from the end when fromEnd is set
+func positionOfChild(n *html.Node, fromEnd bool) int {
+	position := 1
+	for {
+		if fromEnd {
+			n = nextElement(n)
+		} else {
+			n = previousElement(n)
+		}
+		if n == nil {
+			return position
+		}
+		position++
+	}
+}
+
+func positionOfType(n *html.Node, fromEnd bool) int {
+	position := 1
+	for s := n; ; {
+		if fromEnd {
+			s = nextElement(s)
+		} else {
+			s = previousElement(s)
+		}
+		if s == nil {
+			return position
+		}
+		if strings.EqualFold(s.Data, n.Data) {
+			position++
+		}
+	}
+}
+
+func containsWord(list, word string) bool {
+	for _, item := range strings.Fields(list) {
+		if item == word {
+			return true
+		}
+	}
+	return false
+}
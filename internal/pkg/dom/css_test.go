@@ -0,0 +1,129 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCSSSelect(t *testing.T) {
+	doc := parseTestHTML(t)
+
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		// simple selectors
+		{"p", "#p1 #p2 #p3"},
+		{"P", "#p1 #p2 #p3"},
+		{"#main > *", "#title #p1 #p2 #s1 #p3 #list"},
+		{"#title", "#title"},
+		{".card", "#main #aside"},
+		{".card.featured", "#main"},
+		{"div.card#aside", "#aside"},
+		// attribute selectors
+		{"[title]", "#p3"},
+		{"[data-role=main-panel]", "#main"},
+		{`[title="Hello World"]`, "#p3"},
+		{"[title='hello world' i]", "#p3"},
+		{"[title='hello world']", ""},
+		{"[rel~=noopener]", "#link"},
+		{"[rel~=noop]", ""},
+		{"[lang|=en]", "#main"},
+		{"[lang|=en-US]", "#main"},
+		{"[href^=https]", "#link"},
+		{"[href$='.pdf']", "#link"},
+		{"[href*=example]", "#link"},
+		{"[href^='']", ""},
+		// combinators
+		{"div p", "#p1 #p2 #p3"},
+		{"div > b", ""},
+		{"p > b", "#b1"},
+		{"h1 + p", "#p1"},
+		{"h1 ~ p", "#p1 #p2 #p3"},
+		{"body > div a", "#link"},
+		{"h1, #link", "#title #link"},
+		// structural pseudo-classes
+		{"li:first-child", "#li1"},
+		{"li:last-child", "#li5"},
+		{"#aside :only-child", "#link"},
+		{"p:first-of-type", "#p1"},
+		{"p:last-of-type", "#p3"},
+		{"li:nth-child(2)", "#li2"},
+		{"li:nth-child(odd)", "#li1 #li3 #li5"},
+		{"li:nth-child(even)", "#li2 #li4"},
+		{"li:nth-child(2n+3)", "#li3 #li5"},
+		{"li:nth-child(-n+2)", "#li1 #li2"},
+		{"li:nth-last-child(1)", "#li5"},
+		{"p:nth-of-type(2)", "#p2"},
+		{"p:nth-last-of-type(1)", "#p3"},
+		{"#main :empty", "#s1"},
+		// :not() and :contains()
+		{"li:not(.odd):not(:first-child)", "#li3 #li4 #li5"},
+		{"p:not(#p1, #p3)", "#p2"},
+		{"p:contains(Second)", "#p2"},
+		{`li:contains("fi")`, "#li5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			css, err := CompileCSS(tt.selector)
+			if err != nil {
+				t.Fatalf("CompileCSS: %v", err)
+			}
+			if got := describe(css.Select(doc)); got != tt.want {
+				t.Errorf("Select = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSSSelectXML(t *testing.T) {
+	doc := parseTestXML(t)
+	for selector, want := range map[string]string{
+		"entry > title":    "title title",
+		"thumbnail[url]":   "thumbnail",
+		"summary":          "Summary",
+		"entry:last-child": "#e2",
+	} {
+		css, err := CompileCSS(selector)
+		if err != nil {
+			t.Fatalf("%s: %v", selector, err)
+		}
+		if got := describe(css.Select(doc)); got != want {
+			t.Errorf("%s: Select = %q, want %q", selector, got, want)
+		}
+	}
+}
+
+func TestCompileCSSErrors(t *testing.T) {
+	tests := []struct {
+		selector, wantErr string
+	}{
+		{"", "expected selector"},
+		{"div >", "expected selector"},
+		{"div,", "expected selector"},
+		{"#", "expected id"},
+		{".", "expected class name"},
+		{"[href", "unterminated attribute selector"},
+		{"[href=", "unexpected end"},
+		{"[href!=x]", "expected ="},
+		{"[=x]", "expected attribute name"},
+		{"[href='x]", "unterminated string"},
+		{"p:hover", "unsupported pseudo-class :hover"},
+		{"p:has(a)", "unsupported pseudo-class :has()"},
+		{"p::before", "pseudo-elements are not supported"},
+		{"li:nth-child(x)", "invalid nth expression"},
+		{"li:nth-child(2", "expected )"},
+		{"p:not(.a", "expected )"},
+		{"div$", "unexpected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			_, err := CompileCSS(tt.selector)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CompileCSS(%q) = %v, want an error containing %q", tt.selector, err, tt.wantErr)
+			}
+		})
+	}
+}
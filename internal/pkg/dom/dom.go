@@ -0,0 +1,279 @@
+// Package dom parses HTML and XML documents into one node tree and queries it
+// with CSS selectors or XPath. XML documents use the same html.Node type as
+// HTML, with element names kept as written, so both query languages work on
+// both kinds of documents.
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// MaxDepth limits element nesting in parsed XML documents
+const MaxDepth = 256
+
+const (
+	// xmlDocument marks the document node of a tree built by ParseXML
+	xmlDocument = "#xml"
+	// attrNamespace marks the detached nodes XPath returns for attributes
+	attrNamespace = "#attribute"
+)
+
+// Selector finds nodes below (or, for XPath, relative to) a context node.
+// Results are in document order without duplicates.
+type Selector interface {
+	Select(context *html.Node) []*html.Node
+}
+
+// ParseHTML parses an HTML document or fragment
+func ParseHTML(r io.Reader) (*html.Node, error) {
+	return html.Parse(r)
+}
+
+// ParseXML parses an XML document. The declared encoding (e.g. windows-1251)
+// is honoured; namespace prefixes are dropped from element and attribute names.
+func ParseXML(r io.Reader) (*html.Node, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	root := &html.Node{Type: html.DocumentNode, Data: xmlDocument}
+	current := root
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth > MaxDepth {
+				return nil, errors.New("invalid xml: document is nested too deeply")
+			}
+			node := &html.Node{Type: html.ElementNode, Data: t.Name.Local}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				node.Attr = append(node.Attr, html.Attribute{Key: attr.Name.Local, Val: attr.Value})
+			}
+			current.AppendChild(node)
+			current = node
+		case xml.EndElement:
+			depth--
+			if current.Parent != nil {
+				current = current.Parent
+			}
+		case xml.CharData:
+			if current == root {
+				continue
+			}
+			if last := current.LastChild; last != nil && last.Type == html.TextNode {
+				last.Data += string(t)
+			} else {
+				current.AppendChild(&html.Node{Type: html.TextNode, Data: string(t)})
+			}
+		case xml.Comment:
+			current.AppendChild(&html.Node{Type: html.CommentNode, Data: string(t)})
+		}
+	}
+	if root.FirstChild == nil {
+		return nil, errors.New("invalid xml: document has no root element")
+	}
+	return root, nil
+}
+
+// IsXML reports whether n belongs to a document built by ParseXML
+func IsXML(n *html.Node) bool {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n.Type == html.DocumentNode && n.Data == xmlDocument
+}
+
+// Text returns the text content of n with whitespace runs collapsed. In HTML
+// documents the content of script and style elements is skipped and block
+// elements are separated by a space.
+func Text(n *html.Node) string {
+	if isAttrNode(n) {
+		return strings.TrimSpace(n.Data)
+	}
+	var b strings.Builder
+	collectText(&b, n, !IsXML(n))
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func collectText(b *strings.Builder, n *html.Node, isHTML bool) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.CommentNode:
+		return
+	case html.ElementNode:
+		if isHTML {
+			switch n.Data {
+			case "script", "style", "noscript", "template":
+				return
+			case "br", "p", "div", "li", "tr", "td", "th", "h1", "h2", "h3", "h4", "h5", "h6":
+				defer b.WriteByte(' ')
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collectText(b, c, isHTML)
+	}
+}
+
+func newAttrNode(owner *html.Node, attr html.Attribute) *html.Node {
+	return &html.Node{Type: html.RawNode, Namespace: attrNamespace, Data: attr.Val, Attr: []html.Attribute{attr}, Parent: owner}
+}
+
+func isAttrNode(n *html.Node) bool {
+	return n.Type == html.RawNode && n.Namespace == attrNamespace
+}
+
+// stringValue is the XPath string-value of n: all descendant text, unmodified
+func stringValue(n *html.Node) string {
+	if n.Type == html.TextNode || n.Type == html.CommentNode || isAttrNode(n) {
+		return n.Data
+	}
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// Attr returns the value of n's attribute key and whether it is set
+func Attr(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// OuterHTML renders n including its own tag, as XML for XML documents
+func OuterHTML(n *html.Node) string {
+	if n.Type == html.TextNode || isAttrNode(n) {
+		return html.EscapeString(n.Data)
+	}
+	var buf bytes.Buffer
+	if IsXML(n) {
+		renderXML(&buf, n)
+	} else if err := html.Render(&buf, n); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// InnerHTML renders the children of n, as XML for XML documents
+func InnerHTML(n *html.Node) string {
+	var buf bytes.Buffer
+	isXML := IsXML(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isXML {
+			renderXML(&buf, c)
+		} else if err := html.Render(&buf, c); err != nil {
+			return ""
+		}
+	}
+	return buf.String()
+}
+
+// renderXML writes n as XML; attribute order and text are preserved
+func renderXML(buf *bytes.Buffer, n *html.Node) {
+	switch n.Type {
+	case html.DocumentNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			renderXML(buf, c)
+		}
+	case html.TextNode:
+		xml.EscapeText(buf, []byte(n.Data))
+	case html.CommentNode:
+		buf.WriteString("<!--" + n.Data + "-->")
+	case html.ElementNode:
+		buf.WriteString("<" + n.Data)
+		for _, attr := range n.Attr {
+			buf.WriteString(" " + attr.Key + `="`)
+			xml.EscapeText(buf, []byte(attr.Val))
+			buf.WriteByte('"')
+		}
+		if n.FirstChild == nil {
+			buf.WriteString("/>")
+			return
+		}
+		buf.WriteByte('>')
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			renderXML(buf, c)
+		}
+		buf.WriteString("</" + n.Data + ">")
+	}
+}
+
+// documentOrder sorts and de-duplicates nodes found below the same root
+func documentOrder(nodes []*html.Node) []*html.Node {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	root := nodes[0]
+	for root.Parent != nil {
+		root = root.Parent
+	}
+
+	wanted := make(map[*html.Node]bool, len(nodes))
+	for _, n := range nodes {
+		wanted[n] = true
+	}
+	// attribute nodes produced by XPath hang off their element without being
+	// one of its children; they follow the element itself
+	attrs := make(map[*html.Node][]*html.Node)
+	for _, n := range nodes {
+		if isAttrNode(n) {
+			attrs[n.Parent] = append(attrs[n.Parent], n)
+		}
+	}
+
+	result := make([]*html.Node, 0, len(wanted))
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if wanted[n] {
+			result = append(result, n)
+			delete(wanted, n)
+		}
+		for _, a := range attrs[n] {
+			if wanted[a] {
+				result = append(result, a)
+				delete(wanted, a)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return result
+}
@@ -0,0 +1,131 @@
+package dom
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const testHTML = `<html><head><title>Test page</title></head><body>
+<div id="main" class="card featured" data-role="main-panel" lang="en-US">
+	<h1 id="title">Title</h1>
+	<p id="p1" class="intro">First <b id="b1">bold</b></p>
+	<p id="p2">Second</p>
+	<span id="s1"></span>
+	<p id="p3" title="Hello World">Third</p>
+	<ul id="list"><li id="li1">one</li><li id="li2" class="odd">two</li><li id="li3">three</li><li id="li4">four</li><li id="li5">five</li></ul>
+</div>
+<div id="aside" class="card"><a id="link" href="https://example.com/page.pdf" rel="nofollow noopener">Link</a><!-- note --></div>
+</body></html>`
+
+const testXML = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+	<entry id="e1"><title>First</title><media:thumbnail url="https://example.com/1.jpg"/></entry>
+	<entry id="e2"><title>Second</title><Summary>Mixed case</Summary></entry>
+</feed>`
+
+func parseTestHTML(t *testing.T) *html.Node {
+	t.Helper()
+	doc, err := ParseHTML(strings.NewReader(testHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func parseTestXML(t *testing.T) *html.Node {
+	t.Helper()
+	doc, err := ParseXML(strings.NewReader(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// describe names nodes for comparison: "#id" for elements with an id, the
+// tag name for other elements, "@key" for attributes, text("...") for
+// non-blank text, "comment" and "/" for the document
+func describe(nodes []*html.Node) string {
+	var names []string
+	for _, n := range nodes {
+		switch {
+		case isAttrNode(n):
+			names = append(names, "@"+n.Attr[0].Key)
+		case n.Type == html.ElementNode:
+			if id, ok := Attr(n, "id"); ok {
+				names = append(names, "#"+id)
+			} else {
+				names = append(names, n.Data)
+			}
+		case n.Type == html.TextNode:
+			if text := strings.TrimSpace(n.Data); text != "" {
+				names = append(names, fmt.Sprintf("text(%q)", text))
+			}
+		case n.Type == html.CommentNode:
+			names = append(names, "comment")
+		case n.Type == html.DocumentNode:
+			names = append(names, "/")
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func TestParseXML(t *testing.T) {
+	doc := parseTestXML(t)
+	if !IsXML(doc) || IsXML(parseTestHTML(t)) {
+		t.Fatal("IsXML does not tell the documents apart")
+	}
+	feed := doc.FirstChild
+	if feed.Data != "feed" || len(feed.Attr) != 0 {
+		t.Errorf("root = %s %v, want feed without xmlns attributes", feed.Data, feed.Attr)
+	}
+	if got := OuterHTML(feed.FirstChild.NextSibling); !strings.HasPrefix(got, `<entry id="e1"><title>First</title><thumbnail url=`) {
+		t.Errorf("OuterHTML = %s", got)
+	}
+
+	tests := []struct {
+		name, source, wantErr string
+	}{
+		{name: "empty", source: "", wantErr: "no root element"},
+		{name: "too deep", source: strings.Repeat("<a>", MaxDepth+1), wantErr: "nested too deeply"},
+		{name: "unexpected end element", source: "<a></b>", wantErr: "unexpected end element"},
+		{name: "truncated", source: "<a><b", wantErr: "unexpected EOF"},
+		{name: "unknown charset", source: `<?xml version="1.0" encoding="nope"?><a/>`, wantErr: "unsupported charset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseXML(strings.NewReader(tt.source)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseXML() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	doc := parseTestHTML(t)
+	main := findByID(doc, "main")
+	want := "Title First bold Second Third one two three four five"
+	if got := Text(main); got != want {
+		t.Errorf("Text(main) = %q, want %q", got, want)
+	}
+	if got := Text(findByID(doc, "list")); got != "one two three four five" {
+		t.Errorf("block elements are not separated: %q", got)
+	}
+}
+
+func findByID(doc *html.Node, id string) *html.Node {
+	var found *html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if v, _ := Attr(n, "id"); v == id && found == nil {
+			found = n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return found
+}
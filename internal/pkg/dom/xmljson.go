@@ -0,0 +1,234 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Keys used for attributes and mixed text when mapping XML to JSON
+const (
+	AttrPrefix = "@"
+	TextKey    = "#text"
+)
+
+// XMLToMap converts a document from ParseXML to JSON-style data keyed by the
+// root element's name. Attributes become "@name" keys, repeated elements
+// become lists, and an element with only text becomes a string; text next to
+// attributes or child elements is kept under "#text". Elements named in
+// forceList are always lists.
+func XMLToMap(doc *html.Node, forceList []string) (map[string]interface{}, error) {
+	var root *html.Node
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			root = c
+			break
+		}
+	}
+	if root == nil {
+		return nil, errors.New("document has no root element")
+	}
+
+	lists := make(map[string]bool, len(forceList))
+	for _, name := range forceList {
+		lists[name] = true
+	}
+	value := elementValue(root, lists)
+	if lists[root.Data] {
+		value = []interface{}{value}
+	}
+	return map[string]interface{}{root.Data: value}, nil
+}
+
+func elementValue(n *html.Node, lists map[string]bool) interface{} {
+	result := make(map[string]interface{})
+	for _, attr := range n.Attr {
+		result[AttrPrefix+attr.Key] = attr.Val
+	}
+
+	var text strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			text.WriteString(c.Data)
+		case html.ElementNode:
+			value := elementValue(c, lists)
+			switch existing := result[c.Data].(type) {
+			case nil:
+				if lists[c.Data] {
+					result[c.Data] = []interface{}{value}
+				} else {
+					result[c.Data] = value
+				}
+			case []interface{}:
+				result[c.Data] = append(existing, value)
+			default:
+				result[c.Data] = []interface{}{existing, value}
+			}
+		}
+	}
+
+	trimmed := strings.TrimSpace(text.String())
+	if len(result) == 0 {
+		return trimmed
+	}
+	if trimmed != "" {
+		result[TextKey] = trimmed
+	}
+	return result
+}
+
+// MapToXML converts JSON-style data to an XML document, the reverse of
+// XMLToMap. A map with a single key names the root element unless rootName
+// is set; otherwise the root is rootName or "root". Keys are written in
+// sorted order so the output is stable.
+func MapToXML(value interface{}, rootName string, indent, declaration bool) ([]byte, error) {
+	if rootName == "" {
+		if m, ok := value.(map[string]interface{}); ok && len(m) == 1 {
+			for key, inner := range m {
+				if _, isList := inner.([]interface{}); !isList && !strings.HasPrefix(key, AttrPrefix) && key != TextKey {
+					rootName, value = key, inner
+				}
+			}
+		}
+	}
+	if rootName == "" {
+		rootName = "root"
+	}
+
+	var buf bytes.Buffer
+	if declaration {
+		buf.WriteString(xml.Header)
+	}
+	encoder := xml.NewEncoder(&buf)
+	if indent {
+		encoder.Indent("", "  ")
+	}
+	if err := encodeElement(encoder, xmlName(rootName), value, 0); err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeElement(encoder *xml.Encoder, name string, value interface{}, depth int) error {
+	if depth > MaxDepth {
+		return errors.New("value is nested too deeply")
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var children []string
+		for _, key := range keys {
+			if strings.HasPrefix(key, AttrPrefix) {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlName(key[len(AttrPrefix):])}, Value: scalarText(v[key])})
+			} else if key != TextKey {
+				children = append(children, key)
+			}
+		}
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		if text, ok := v[TextKey]; ok {
+			if err := encoder.EncodeToken(xml.CharData(scalarText(text))); err != nil {
+				return err
+			}
+		}
+		for _, key := range children {
+			child := xmlName(key)
+			items, isList := v[key].([]interface{})
+			if !isList {
+				items = []interface{}{v[key]}
+			}
+			for _, item := range items {
+				if err := encodeElement(encoder, child, item, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+
+	case []interface{}:
+		// a list without an element name of its own, e.g. the root value
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeElement(encoder, "item", item, depth+1); err != nil {
+				return err
+			}
+		}
+
+	default:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		if v != nil {
+			if err := encoder.EncodeToken(xml.CharData(scalarText(v))); err != nil {
+				return err
+			}
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+func scalarText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(raw)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// xmlName turns a JSON key into a valid element or attribute name
+func xmlName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		valid := r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7F
+		if i > 0 {
+			valid = valid || r == '-' || r == '.' || r >= '0' && r <= '9'
+		}
+		if valid {
+			b.WriteRune(r)
+		} else {
+			if i == 0 && (r == '-' || r == '.' || r >= '0' && r <= '9') {
+				b.WriteRune('_')
+				b.WriteRune(r)
+				continue
+			}
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
@@ -0,0 +1,971 @@
+package dom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// maxXPathLength bounds expressions so parsing depth stays small
+const maxXPathLength = 4096
+
+// XPath is a compiled XPath 1.0 expression. Supported are location paths
+// with the child, descendant, descendant-or-self, self, parent, ancestor,
+// ancestor-or-self, following-sibling, preceding-sibling and attribute axes
+// (and their abbreviations), predicates, unions, comparisons, arithmetic and
+// the functions last, position, count, name, local-name, string, concat,
+// contains, starts-with, ends-with, substring-before, substring-after,
+// normalize-space, string-length, lower-case, upper-case, not, true, false,
+// boolean and number. Namespace prefixes in name tests are ignored. Other
+// functions (e.g. sum() or substring()) and wrong argument counts are
+// rejected by CompileXPath.
+type XPath struct {
+	expr xpathExpr
+}
+
+// CompileXPath parses expr, e.g. "//div[@class='card']/a/@href"
+func CompileXPath(expr string) (*XPath, error) {
+	if len(expr) > maxXPathLength {
+		return nil, errors.New("xpath expression is too long")
+	}
+	tokens, err := lexXPath(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %q: %w", expr, err)
+	}
+	p := &xpathParser{tokens: tokens}
+	compiled, err := p.expr()
+	if err == nil && p.peek().kind != tokEnd {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %q: %w", expr, err)
+	}
+	return &XPath{expr: compiled}, nil
+}
+
+// Evaluate runs the expression with context as the context node. The result
+// is a node list, a string, a float64 or a bool.
+func (x *XPath) Evaluate(context *html.Node) interface{} {
+	ctx := &xpathContext{node: context, position: 1, size: 1, eval: &xpathEval{attrs: make(map[attrKey]*html.Node)}}
+	return x.expr.eval(ctx)
+}
+
+// Select returns the nodes the expression selects. Expressions that produce
+// a string, number or boolean yield a single text node holding the value.
+func (x *XPath) Select(context *html.Node) []*html.Node {
+	switch v := x.Evaluate(context).(type) {
+	case []*html.Node:
+		return v
+	default:
+		return []*html.Node{{Type: html.TextNode, Data: toString(v)}}
+	}
+}
+
+type xpathEval struct {
+	// attrs keeps attribute nodes stable so unions and predicates can compare them
+	attrs map[attrKey]*html.Node
+}
+
+type attrKey struct {
+	owner *html.Node
+	index int
+}
+
+func (e *xpathEval) attrNode(owner *html.Node, index int) *html.Node {
+	key := attrKey{owner, index}
+	if n, ok := e.attrs[key]; ok {
+		return n
+	}
+	n := newAttrNode(owner, owner.Attr[index])
+	e.attrs[key] = n
+	return n
+}
+
+type xpathContext struct {
+	node     *html.Node
+	position int
+	size     int
+	eval     *xpathEval
+}
+
+type xpathExpr interface {
+	eval(ctx *xpathContext) interface{}
+}
+
+// ---- lexer
+
+const (
+	tokEnd = iota
+	tokName
+	tokString
+	tokNumber
+	tokOp
+)
+
+type xpathToken struct {
+	kind int
+	text string
+}
+
+func lexXPath(src string) ([]xpathToken, error) {
+	var tokens []xpathToken
+	// an operator name or * is only an operator when it follows an operand
+	operand := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		last := tokens[len(tokens)-1]
+		if last.kind != tokOp {
+			return true
+		}
+		switch last.text {
+		case ")", "]", ".", "..":
+			return true
+		}
+		return false
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, xpathToken{tokString, src[i+1 : i+1+end]})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, xpathToken{tokNumber, src[start:i]})
+		case strings.HasPrefix(src[i:], "//"), strings.HasPrefix(src[i:], ".."), strings.HasPrefix(src[i:], "::"),
+			strings.HasPrefix(src[i:], "!="), strings.HasPrefix(src[i:], "<="), strings.HasPrefix(src[i:], ">="):
+			tokens = append(tokens, xpathToken{tokOp, src[i : i+2]})
+			i += 2
+		case c == '*':
+			if operand() {
+				tokens = append(tokens, xpathToken{tokOp, "*"})
+			} else {
+				tokens = append(tokens, xpathToken{tokName, "*"})
+			}
+			i++
+		case strings.IndexByte("/[]()@,|.=<>+-", c) >= 0:
+			tokens = append(tokens, xpathToken{tokOp, string(c)})
+			i++
+		case isNameStart(c):
+			start := i
+			for i < len(src) && isNameChar(src[i]) {
+				i++
+			}
+			// prefix:local or prefix:*, but not the axis separator
+			if i+1 < len(src) && src[i] == ':' && src[i+1] != ':' {
+				i++
+				if i < len(src) && src[i] == '*' {
+					i++
+				}
+				for i < len(src) && isNameChar(src[i]) {
+					i++
+				}
+			}
+			name := src[start:i]
+			if operand() && (name == "and" || name == "or" || name == "div" || name == "mod") {
+				tokens = append(tokens, xpathToken{tokOp, name})
+			} else {
+				tokens = append(tokens, xpathToken{tokName, name})
+			}
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return nil, fmt.Errorf("unexpected %q", r)
+		}
+	}
+	return append(tokens, xpathToken{kind: tokEnd}), nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || c == '-' || c == '.' || c >= '0' && c <= '9'
+}
+
+// ---- parser
+
+type xpathParser struct {
+	tokens []xpathToken
+	pos    int
+}
+
+func (p *xpathParser) peek() xpathToken { return p.tokens[p.pos] }
+
+func (p *xpathParser) peekAt(offset int) xpathToken {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return xpathToken{kind: tokEnd}
+}
+
+func (p *xpathParser) next() xpathToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *xpathParser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == text
+}
+
+func (p *xpathParser) expect(text string) error {
+	if !p.isOp(text) {
+		if p.peek().kind == tokEnd {
+			return fmt.Errorf("expected %q", text)
+		}
+		return fmt.Errorf("expected %q, found %q", text, p.peek().text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *xpathParser) expr() (xpathExpr, error) {
+	return p.binary(0)
+}
+
+// binaryLevels lists operators from the loosest to the tightest binding
+var binaryLevels = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+func (p *xpathParser) binary(level int) (xpathExpr, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		matched := false
+		if t.kind == tokOp {
+			for _, op := range binaryLevels[level] {
+				if t.text == op {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *xpathParser) unary() (xpathExpr, error) {
+	if p.isOp("-") {
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{operand}, nil
+	}
+	left, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("|") {
+		p.pos++
+		right, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		left = &unionExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *xpathParser) path() (xpathExpr, error) {
+	t := p.peek()
+	startsFilter := t.kind == tokString || t.kind == tokNumber || t.kind == tokOp && t.text == "(" ||
+		t.kind == tokName && p.peekAt(1).kind == tokOp && p.peekAt(1).text == "(" && !isNodeType(t.text)
+
+	path := &pathExpr{}
+	switch {
+	case startsFilter:
+		primary, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		for p.isOp("[") {
+			predicate, err := p.predicate()
+			if err != nil {
+				return nil, err
+			}
+			primary = &filterExpr{primary, predicate}
+		}
+		if !p.isOp("/") && !p.isOp("//") {
+			return primary, nil
+		}
+		path.filter = primary
+	case p.isOp("//"):
+		path.absolute = true
+	case p.isOp("/"):
+		p.pos++
+		path.absolute = true
+		if !p.startsStep() {
+			// "/" alone selects the document
+			return path, nil
+		}
+		fallthrough
+	default:
+		if !p.startsStep() {
+			if p.peek().kind == tokEnd {
+				return nil, errors.New("unexpected end of expression")
+			}
+			return nil, fmt.Errorf("unexpected %q", p.peek().text)
+		}
+		step, err := p.step()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+	}
+
+	for p.isOp("/") || p.isOp("//") {
+		if p.next().text == "//" {
+			path.steps = append(path.steps, &xpathStep{axis: "descendant-or-self", test: nodeTest{kind: "node"}})
+		}
+		step, err := p.step()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+	}
+	return path, nil
+}
+
+func (p *xpathParser) startsStep() bool {
+	t := p.peek()
+	return t.kind == tokName || t.kind == tokOp && (t.text == "." || t.text == ".." || t.text == "@")
+}
+
+func isNodeType(name string) bool {
+	return name == "text" || name == "node" || name == "comment"
+}
+
+var xpathAxes = map[string]bool{
+	"child": true, "descendant": true, "descendant-or-self": true, "self": true,
+	"parent": true, "ancestor": true, "ancestor-or-self": true,
+	"following-sibling": true, "preceding-sibling": true, "attribute": true,
+}
+
+func (p *xpathParser) step() (*xpathStep, error) {
+	switch {
+	case p.isOp("."):
+		p.pos++
+		return &xpathStep{axis: "self", test: nodeTest{kind: "node"}}, nil
+	case p.isOp(".."):
+		p.pos++
+		return &xpathStep{axis: "parent", test: nodeTest{kind: "node"}}, nil
+	}
+
+	step := &xpathStep{axis: "child"}
+	if p.isOp("@") {
+		p.pos++
+		step.axis = "attribute"
+	} else if t := p.peek(); t.kind == tokName && p.peekAt(1).kind == tokOp && p.peekAt(1).text == "::" {
+		if !xpathAxes[t.text] {
+			return nil, fmt.Errorf("unsupported axis %q", t.text)
+		}
+		step.axis = t.text
+		p.pos += 2
+	}
+
+	t := p.next()
+	if t.kind != tokName {
+		if t.kind == tokEnd {
+			return nil, errors.New("expected a node test")
+		}
+		return nil, fmt.Errorf("expected a node test, found %q", t.text)
+	}
+	switch {
+	case isNodeType(t.text) && p.isOp("("):
+		p.pos++
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		step.test = nodeTest{kind: t.text}
+	case p.isOp("("):
+		if _, ok := xpathFunctions[t.text]; !ok {
+			return nil, unsupportedFunction(t.text)
+		}
+		return nil, fmt.Errorf("%s() cannot be used as a location step", t.text)
+	default:
+		name := t.text
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name = name[i+1:]
+		}
+		step.test = nodeTest{kind: "name", name: name}
+	}
+
+	for p.isOp("[") {
+		predicate, err := p.predicate()
+		if err != nil {
+			return nil, err
+		}
+		step.predicates = append(step.predicates, predicate)
+	}
+	return step, nil
+}
+
+func (p *xpathParser) predicate() (xpathExpr, error) {
+	p.pos++ // [
+	predicate, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return predicate, nil
+}
+
+func (p *xpathParser) primary() (xpathExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literalExpr{t.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return literalExpr{n}, nil
+	case tokName:
+		fn := &functionExpr{name: t.text}
+		if _, ok := xpathFunctions[fn.name]; !ok {
+			return nil, unsupportedFunction(fn.name)
+		}
+		p.pos++ // (
+		if !p.isOp(")") {
+			for {
+				arg, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				fn.args = append(fn.args, arg)
+				if !p.isOp(",") {
+					break
+				}
+				p.pos++
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if arity := xpathArity[fn.name]; len(fn.args) < arity.min || arity.max >= 0 && len(fn.args) > arity.max {
+			return nil, fmt.Errorf("%s() takes %s, got %d", fn.name, arity, len(fn.args))
+		}
+		return fn, nil
+	default:
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+}
+
+func unsupportedFunction(name string) error {
+	if name == "processing-instruction" {
+		return errors.New("processing-instruction() node tests are not supported")
+	}
+	return fmt.Errorf("unsupported function %s()", name)
+}
+
+// ---- evaluation
+
+type literalExpr struct{ value interface{} }
+
+func (l literalExpr) eval(*xpathContext) interface{} { return l.value }
+
+type negateExpr struct{ operand xpathExpr }
+
+func (n *negateExpr) eval(ctx *xpathContext) interface{} {
+	return -toNumber(n.operand.eval(ctx))
+}
+
+type unionExpr struct{ left, right xpathExpr }
+
+func (u *unionExpr) eval(ctx *xpathContext) interface{} {
+	left, _ := u.left.eval(ctx).([]*html.Node)
+	right, _ := u.right.eval(ctx).([]*html.Node)
+	return documentOrder(append(append([]*html.Node{}, left...), right...))
+}
+
+type filterExpr struct {
+	primary   xpathExpr
+	predicate xpathExpr
+}
+
+func (f *filterExpr) eval(ctx *xpathContext) interface{} {
+	nodes, ok := f.primary.eval(ctx).([]*html.Node)
+	if !ok {
+		return []*html.Node{}
+	}
+	return applyPredicate(ctx.eval, nodes, f.predicate)
+}
+
+type pathExpr struct {
+	absolute bool
+	filter   xpathExpr
+	steps    []*xpathStep
+}
+
+func (p *pathExpr) eval(ctx *xpathContext) interface{} {
+	var nodes []*html.Node
+	switch {
+	case p.filter != nil:
+		nodes, _ = p.filter.eval(ctx).([]*html.Node)
+	case p.absolute:
+		root := ctx.node
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		nodes = []*html.Node{root}
+	default:
+		nodes = []*html.Node{ctx.node}
+	}
+
+	for _, step := range p.steps {
+		var next []*html.Node
+		for _, n := range nodes {
+			next = append(next, step.apply(ctx.eval, n)...)
+		}
+		nodes = documentOrder(next)
+	}
+	if nodes == nil {
+		nodes = []*html.Node{}
+	}
+	return nodes
+}
+
+type nodeTest struct {
+	kind string // name, node, text or comment
+	name string
+}
+
+type xpathStep struct {
+	axis       string
+	test       nodeTest
+	predicates []xpathExpr
+}
+
+func (s *xpathStep) apply(eval *xpathEval, n *html.Node) []*html.Node {
+	var candidates []*html.Node
+	add := func(c *html.Node) {
+		if s.matches(c) {
+			candidates = append(candidates, c)
+		}
+	}
+	var descend func(*html.Node)
+	descend = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			add(c)
+			descend(c)
+		}
+	}
+
+	switch s.axis {
+	case "child":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			add(c)
+		}
+	case "descendant":
+		descend(n)
+	case "descendant-or-self":
+		add(n)
+		descend(n)
+	case "self":
+		add(n)
+	case "parent":
+		if n.Parent != nil {
+			add(n.Parent)
+		}
+	case "ancestor", "ancestor-or-self":
+		if s.axis == "ancestor-or-self" {
+			add(n)
+		}
+		for a := n.Parent; a != nil; a = a.Parent {
+			add(a)
+		}
+	case "following-sibling":
+		if !isAttrNode(n) {
+			for c := n.NextSibling; c != nil; c = c.NextSibling {
+				add(c)
+			}
+		}
+	case "preceding-sibling":
+		if !isAttrNode(n) {
+			for c := n.PrevSibling; c != nil; c = c.PrevSibling {
+				add(c)
+			}
+		}
+	case "attribute":
+		if n.Type == html.ElementNode {
+			for i := range n.Attr {
+				add(eval.attrNode(n, i))
+			}
+		}
+	}
+
+	for _, predicate := range s.predicates {
+		candidates = applyPredicate(eval, candidates, predicate)
+	}
+	return candidates
+}
+
+func (s *xpathStep) matches(n *html.Node) bool {
+	switch s.test.kind {
+	case "node":
+		return true
+	case "text":
+		return n.Type == html.TextNode
+	case "comment":
+		return n.Type == html.CommentNode
+	}
+	// a name test selects the axis' principal node type
+	if s.axis == "attribute" {
+		return isAttrNode(n) && (s.test.name == "*" || strings.EqualFold(n.Attr[0].Key, s.test.name))
+	}
+	return n.Type == html.ElementNode && (s.test.name == "*" || strings.EqualFold(n.Data, s.test.name))
+}
+
+// applyPredicate keeps the nodes for which predicate holds; a number is
+// compared with the node's position in axis order
+func applyPredicate(eval *xpathEval, nodes []*html.Node, predicate xpathExpr) []*html.Node {
+	var result []*html.Node
+	for i, n := range nodes {
+		ctx := &xpathContext{node: n, position: i + 1, size: len(nodes), eval: eval}
+		switch v := predicate.eval(ctx).(type) {
+		case float64:
+			if v == float64(i+1) {
+				result = append(result, n)
+			}
+		default:
+			if toBool(v) {
+				result = append(result, n)
+			}
+		}
+	}
+	return result
+}
+
+type binaryExpr struct {
+	op          string
+	left, right xpathExpr
+}
+
+func (b *binaryExpr) eval(ctx *xpathContext) interface{} {
+	switch b.op {
+	case "or":
+		return toBool(b.left.eval(ctx)) || toBool(b.right.eval(ctx))
+	case "and":
+		return toBool(b.left.eval(ctx)) && toBool(b.right.eval(ctx))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compare(b.op, b.left.eval(ctx), b.right.eval(ctx))
+	}
+
+	left, right := toNumber(b.left.eval(ctx)), toNumber(b.right.eval(ctx))
+	switch b.op {
+	case "+":
+		return left + right
+	case "-":
+		return left - right
+	case "*":
+		return left * right
+	case "div":
+		return left / right
+	default: // mod
+		return math.Mod(left, right)
+	}
+}
+
+// compare implements XPath 1.0 comparisons, where a node list compares true
+// if any of its nodes does
+func compare(op string, left, right interface{}) bool {
+	if nodes, ok := left.([]*html.Node); ok {
+		if _, ok := right.(bool); ok {
+			return compareAtoms(op, len(nodes) > 0, right)
+		}
+		for _, n := range nodes {
+			if compare(op, stringValue(n), right) {
+				return true
+			}
+		}
+		return false
+	}
+	if nodes, ok := right.([]*html.Node); ok {
+		if _, ok := left.(bool); ok {
+			return compareAtoms(op, left, len(nodes) > 0)
+		}
+		for _, n := range nodes {
+			if compare(op, left, stringValue(n)) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, left, right)
+}
+
+func compareAtoms(op string, left, right interface{}) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, leftBool := left.(bool)
+		_, rightBool := right.(bool)
+		_, leftNumber := left.(float64)
+		_, rightNumber := right.(float64)
+		switch {
+		case leftBool || rightBool:
+			equal = toBool(left) == toBool(right)
+		case leftNumber || rightNumber:
+			equal = toNumber(left) == toNumber(right)
+		default:
+			equal = toString(left) == toString(right)
+		}
+		return equal == (op == "=")
+	}
+
+	l, r := toNumber(left), toNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		if math.IsNaN(v) {
+			return "NaN"
+		}
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []*html.Node:
+		if len(v) == 0 {
+			return ""
+		}
+		return stringValue(v[0])
+	}
+	return ""
+}
+
+func toNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	default:
+		n, err := strconv.ParseFloat(strings.TrimSpace(toString(v)), 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return n
+	}
+}
+
+func toBool(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case []*html.Node:
+		return len(v) > 0
+	}
+	return false
+}
+
+type functionExpr struct {
+	name string
+	args []xpathExpr
+}
+
+// xpathFunctions maps each function to its implementation; args are evaluated lazily
+var xpathFunctions map[string]func(ctx *xpathContext, args []xpathExpr) interface{}
+
+// functionArity is how many arguments a function takes; max -1 means any number
+type functionArity struct{ min, max int }
+
+func (a functionArity) String() string {
+	switch {
+	case a.max < 0:
+		return fmt.Sprintf("at least %d arguments", a.min)
+	case a.max == 0:
+		return "no arguments"
+	case a.min == a.max && a.min == 1:
+		return "1 argument"
+	case a.min == a.max:
+		return fmt.Sprintf("%d arguments", a.min)
+	default:
+		return fmt.Sprintf("%d to %d arguments", a.min, a.max)
+	}
+}
+
+// xpathArity holds the argument counts of xpathFunctions, checked when compiling
+var xpathArity = map[string]functionArity{
+	"last": {0, 0}, "position": {0, 0}, "count": {1, 1},
+	"name": {0, 1}, "local-name": {0, 1}, "string": {0, 1}, "concat": {2, -1},
+	"contains": {2, 2}, "starts-with": {2, 2}, "ends-with": {2, 2},
+	"substring-before": {2, 2}, "substring-after": {2, 2},
+	"normalize-space": {0, 1}, "string-length": {0, 1},
+	"lower-case": {1, 1}, "upper-case": {1, 1},
+	"not": {1, 1}, "true": {0, 0}, "false": {0, 0}, "boolean": {1, 1}, "number": {0, 1},
+}
+
+func init() {
+	// string arguments default to the context node, as in string() and normalize-space()
+	stringArg := func(ctx *xpathContext, args []xpathExpr, i int) string {
+		if i < len(args) {
+			return toString(args[i].eval(ctx))
+		}
+		if i == 0 {
+			return stringValue(ctx.node)
+		}
+		return ""
+	}
+	nodeName := func(ctx *xpathContext, args []xpathExpr) string {
+		n := ctx.node
+		if len(args) > 0 {
+			nodes, _ := args[0].eval(ctx).([]*html.Node)
+			if len(nodes) == 0 {
+				return ""
+			}
+			n = nodes[0]
+		}
+		if isAttrNode(n) {
+			return n.Attr[0].Key
+		}
+		if n.Type == html.ElementNode {
+			return n.Data
+		}
+		return ""
+	}
+
+	xpathFunctions = map[string]func(ctx *xpathContext, args []xpathExpr) interface{}{
+		"last":     func(ctx *xpathContext, _ []xpathExpr) interface{} { return float64(ctx.size) },
+		"position": func(ctx *xpathContext, _ []xpathExpr) interface{} { return float64(ctx.position) },
+		"count": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			nodes, _ := args[0].eval(ctx).([]*html.Node)
+			return float64(len(nodes))
+		},
+		"name":       func(ctx *xpathContext, args []xpathExpr) interface{} { return nodeName(ctx, args) },
+		"local-name": func(ctx *xpathContext, args []xpathExpr) interface{} { return nodeName(ctx, args) },
+		"string":     func(ctx *xpathContext, args []xpathExpr) interface{} { return stringArg(ctx, args, 0) },
+		"concat": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			var b strings.Builder
+			for _, arg := range args {
+				b.WriteString(toString(arg.eval(ctx)))
+			}
+			return b.String()
+		},
+		"contains": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return strings.Contains(stringArg(ctx, args, 0), stringArg(ctx, args, 1))
+		},
+		"starts-with": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return strings.HasPrefix(stringArg(ctx, args, 0), stringArg(ctx, args, 1))
+		},
+		"ends-with": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return strings.HasSuffix(stringArg(ctx, args, 0), stringArg(ctx, args, 1))
+		},
+		"substring-before": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			s, sep := stringArg(ctx, args, 0), stringArg(ctx, args, 1)
+			if i := strings.Index(s, sep); i >= 0 {
+				return s[:i]
+			}
+			return ""
+		},
+		"substring-after": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			s, sep := stringArg(ctx, args, 0), stringArg(ctx, args, 1)
+			if i := strings.Index(s, sep); i >= 0 {
+				return s[i+len(sep):]
+			}
+			return ""
+		},
+		"normalize-space": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return strings.Join(strings.Fields(stringArg(ctx, args, 0)), " ")
+		},
+		"string-length": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return float64(utf8.RuneCountInString(stringArg(ctx, args, 0)))
+		},
+		"lower-case": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return strings.ToLower(stringArg(ctx, args, 0))
+		},
+		"upper-case": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return strings.ToUpper(stringArg(ctx, args, 0))
+		},
+		"not": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return !toBool(args[0].eval(ctx))
+		},
+		"true":  func(*xpathContext, []xpathExpr) interface{} { return true },
+		"false": func(*xpathContext, []xpathExpr) interface{} { return false },
+		"boolean": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			return toBool(args[0].eval(ctx))
+		},
+		"number": func(ctx *xpathContext, args []xpathExpr) interface{} {
+			if len(args) == 0 {
+				return toNumber(stringValue(ctx.node))
+			}
+			return toNumber(args[0].eval(ctx))
+		},
+	}
+}
+
+func (f *functionExpr) eval(ctx *xpathContext) interface{} {
+	return xpathFunctions[f.name](ctx, f.args)
+}
@@ -0,0 +1,239 @@
+package dom
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestXPathSelect(t *testing.T) {
+	doc := parseTestHTML(t)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// abbreviated paths
+		{"/", "/"},
+		{"/html/body/div", "#main #aside"},
+		{"//li", "#li1 #li2 #li3 #li4 #li5"},
+		{"//div[@id='main']/p", "#p1 #p2 #p3"},
+		{"//b/..", "#p1"},
+		{"//li[.='two']", "#li2"},
+		{"//a/@href", "@href"},
+		{"//div[@id='main']/@*", "@id @class @data-role @lang"},
+		// axes
+		{"//ul/child::li[1]", "#li1"},
+		{"//div[@id='main']/descendant::b", "#b1"},
+		{"//p[@id='p1']/descendant-or-self::*", "#p1 #b1"},
+		{"//p/self::p[@id='p2']", "#p2"},
+		{"//b/parent::p", "#p1"},
+		{"//b/ancestor::div", "#main"},
+		{"//b/ancestor-or-self::*[@id]", "#main #p1 #b1"},
+		{"//li[@id='li3']/following-sibling::li", "#li4 #li5"},
+		{"//li[@id='li3']/following-sibling::li[1]", "#li4"},
+		{"//li[@id='li3']/preceding-sibling::li", "#li1 #li2"},
+		{"//li[@id='li3']/preceding-sibling::li[1]", "#li2"},
+		{"//a/attribute::rel", "@rel"},
+		{"//a/@href/following-sibling::*", ""},
+		// node type tests
+		{"//p[@id='p1']/text()", `text("First")`},
+		{"//p[@id='p1']/node()", `text("First") #b1`},
+		{"//comment()", "comment"},
+		// predicates
+		{"//li[last()]", "#li5"},
+		{"//li[position() > 3]", "#li4 #li5"},
+		{"//li[2]", "#li2"},
+		{"(//li)[2]", "#li2"},
+		{"(//p | //li)[last()]", "#li5"},
+		{"//p[b]", "#p1"},
+		{"//p[not(@class)]", "#p2 #p3"},
+		{"//*[@class='card' or @id='p2']", "#p2 #aside"},
+		{"//li[position() mod 2 = 0]", "#li2 #li4"},
+		{"//div[count(p) = 3]", "#main"},
+		{"//li[contains(., 'o')]", "#li1 #li2 #li4"},
+		{"//li[starts-with(., 't')]", "#li2 #li3"},
+		{"//li[ends-with(., 'e')]", "#li1 #li3 #li5"},
+		{"//p[string-length() = 6]", "#p2"},
+		{"//p[lower-case(@title) = 'hello world']", "#p3"},
+		{"//*[local-name() = 'h1']", "#title"},
+		{"//*[name() = 'span']", "#s1"},
+		// unions are in document order without duplicates
+		{"//a | //h1 | //h1", "#title #link"},
+		// namespace prefixes are ignored
+		{"//html:li[1]", "#li1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			xpath, err := CompileXPath(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileXPath: %v", err)
+			}
+			if got := describe(xpath.Select(doc)); got != tt.want {
+				t.Errorf("Select = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXPathSelectRelative(t *testing.T) {
+	doc := parseTestHTML(t)
+	list := findByID(doc, "list")
+	for expr, want := range map[string]string{
+		"li[3]":                 "#li3",
+		".":                     "#list",
+		"..":                    "#main",
+		"../p[2]":               "#p2",
+		"//h1":                  "#title",
+		"ancestor::*[1]":        "#main",
+		"ancestor::*[2]":        "body",
+		"descendant::*[last()]": "#li5",
+	} {
+		xpath, err := CompileXPath(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if got := describe(xpath.Select(list)); got != want {
+			t.Errorf("%s: Select = %q, want %q", expr, got, want)
+		}
+	}
+}
+
+func TestXPathSelectXML(t *testing.T) {
+	doc := parseTestXML(t)
+	for expr, want := range map[string]string{
+		"/feed/entry":                    "#e1 #e2",
+		"//media:thumbnail/@url":         "@url",
+		"//thumbnail/@url":               "@url",
+		"//entry[title='Second']/@id":    "@id",
+		"//summary":                      "Summary",
+		"//entry[media:thumbnail]/title": "title",
+	} {
+		xpath, err := CompileXPath(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if got := describe(xpath.Select(doc)); got != want {
+			t.Errorf("%s: Select = %q, want %q", expr, got, want)
+		}
+	}
+}
+
+func TestXPathEvaluate(t *testing.T) {
+	doc := parseTestHTML(t)
+
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"count(//li)", 5.0},
+		{"name(//a)", "a"},
+		{"local-name(//a/@href)", "href"},
+		{"string(//h1)", "Title"},
+		{"string(//a/@href)", "https://example.com/page.pdf"},
+		{"string(//nothing)", ""},
+		{"concat(//h1, ': ', //p[2])", "Title: Second"},
+		{"substring-before(//a/@href, '://')", "https"},
+		{"substring-after(//a/@href, 'example.com/')", "page.pdf"},
+		{"substring-after('abc', 'x')", ""},
+		{"normalize-space('  a \n b  ')", "a b"},
+		{"normalize-space(//p[@id='p1'])", "First bold"},
+		{"string-length('привет')", 6.0},
+		{"upper-case(//li[1])", "ONE"},
+		{"contains(//h1, 'itl')", true},
+		{"not(//blink)", true},
+		{"true()", true},
+		{"false()", false},
+		{"boolean(//li)", true},
+		{"boolean('')", false},
+		{"number('  42 ')", 42.0},
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"7 mod 3", 1.0},
+		{"6 div 4", 1.5},
+		{"-(2 - 5)", 3.0},
+		{"count(//li) > 4 and count(//p) = 3", true},
+		{"count(//li) <= 4 or false()", false},
+		{"//li = 'three'", true},
+		{"//li != 'three'", true},
+		{"//li = //p", false},
+		{"//li = true()", true},
+		{"1 = '1.0'", true},
+		{"'a' = 'A'", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			xpath, err := CompileXPath(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileXPath: %v", err)
+			}
+			if got := xpath.Evaluate(doc); got != tt.want {
+				t.Errorf("Evaluate = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	xpath, _ := CompileXPath("number('abc')")
+	if got := xpath.Evaluate(doc).(float64); !math.IsNaN(got) {
+		t.Errorf("number('abc') = %v, want NaN", got)
+	}
+	// non-node results are selected as a single text node
+	xpath, _ = CompileXPath("count(//p)")
+	if got := describe(xpath.Select(doc)); got != `text("3")` {
+		t.Errorf("Select(count) = %s", got)
+	}
+}
+
+func TestCompileXPathErrors(t *testing.T) {
+	tests := []struct {
+		expr, wantErr string
+	}{
+		{"", "unexpected end of expression"},
+		{"//", "expected a node test"},
+		{"//div[", "unexpected end of expression"},
+		{"//div[@id='x'", `expected "]"`},
+		{"//div[@id='x]", "unterminated string"},
+		{"//div)", `unexpected ")"`},
+		{"//div#main", `unexpected '#'`},
+		{"sum(//li)", "unsupported function sum()"},
+		{"//li[sum(b) > 1]", "unsupported function sum()"},
+		{"count(//li) + round(1.5)", "unsupported function round()"},
+		{"substring('abc', 2)", "unsupported function substring()"},
+		{"//a/sum(b)", "unsupported function sum()"},
+		{"//a/count(b)", "count() cannot be used as a location step"},
+		{"//processing-instruction()", "processing-instruction() node tests are not supported"},
+		{"contains(//a)", "contains() takes 2 arguments, got 1"},
+		{"count()", "count() takes 1 argument, got 0"},
+		{"concat('a')", "concat() takes at least 2 arguments, got 1"},
+		{"last(1)", "last() takes no arguments, got 1"},
+		{"string(1, 2)", "string() takes 0 to 1 arguments, got 2"},
+		{"namespace::x", `unsupported axis "namespace"`},
+		{"following::p", `unsupported axis "following"`},
+		{"preceding::*", `unsupported axis "preceding"`},
+		{strings.Repeat("a/", maxXPathLength), "too long"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := CompileXPath(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CompileXPath(%q) = %v, want an error containing %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestXPathArityCoversEveryFunction(t *testing.T) {
+	for name := range xpathFunctions {
+		if _, ok := xpathArity[name]; !ok {
+			t.Errorf("%s() has no arity", name)
+		}
+	}
+	for name := range xpathArity {
+		if _, ok := xpathFunctions[name]; !ok {
+			t.Errorf("arity of unknown function %s()", name)
+		}
+	}
+}
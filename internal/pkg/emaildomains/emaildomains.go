@@ -0,0 +1,151 @@
+package emaildomains
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed disposable.txt
+var builtinDisposable string
+
+var (
+	mu         sync.RWMutex
+	disposable = parseList(strings.NewReader(builtinDisposable))
+)
+
+// freeMail are consumer mailbox providers; an address there says nothing
+// about the sender's company
+var freeMail = setOf(
+	"gmail.com", "googlemail.com", "yahoo.com", "yahoo.co.uk", "yahoo.fr", "yahoo.de",
+	"ymail.com", "rocketmail.com", "hotmail.com", "hotmail.co.uk", "hotmail.fr", "hotmail.de",
+	"outlook.com", "outlook.de", "live.com", "live.ru", "msn.com", "aol.com", "icloud.com",
+	"me.com", "mac.com", "protonmail.com", "proton.me", "pm.me", "tutanota.com", "tuta.io",
+	"gmx.com", "gmx.de", "gmx.net", "web.de", "t-online.de", "mail.com", "zoho.com",
+	"zohomail.com", "fastmail.com", "hey.com", "yandex.ru", "yandex.com", "ya.ru",
+	"yandex.by", "yandex.kz", "yandex.ua", "mail.ru", "inbox.ru", "list.ru", "bk.ru",
+	"internet.ru", "rambler.ru", "lenta.ru", "autorambler.ru", "ro.ru", "qip.ru", "ukr.net",
+	"i.ua", "meta.ua", "tut.by", "qq.com", "163.com", "126.com", "sina.com", "naver.com",
+	"daum.net", "seznam.cz", "wp.pl", "o2.pl", "interia.pl", "onet.pl", "libero.it",
+	"virgilio.it", "orange.fr", "free.fr", "laposte.net", "sfr.fr", "wanadoo.fr",
+	"btinternet.com", "comcast.net", "verizon.net", "att.net", "sbcglobal.net",
+)
+
+func setOf(domains ...string) map[string]bool {
+	set := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		set[d] = true
+	}
+	return set
+}
+
+// Domain returns the lowercase domain of an email address, or the value
+// itself when it is already a bare domain
+func Domain(emailOrDomain string) string {
+	s := strings.ToLower(strings.TrimSpace(emailOrDomain))
+	if at := strings.LastIndexByte(s, '@'); at >= 0 {
+		s = s[at+1:]
+	}
+	return strings.TrimSuffix(s, ".")
+}
+
+// IsFreeMail reports whether the address or domain belongs to a free mailbox provider
+func IsFreeMail(emailOrDomain string) bool {
+	return freeMail[Domain(emailOrDomain)]
+}
+
+// IsDisposable reports whether the address or domain belongs to a throwaway
+// mailbox service. Subdomains of listed domains match too.
+func IsDisposable(emailOrDomain string) bool {
+	domain := Domain(emailOrDomain)
+	mu.RLock()
+	defer mu.RUnlock()
+	for domain != "" {
+		if disposable[domain] {
+			return true
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+// LoadDisposableFile replaces the disposable domain list with the built-in
+// list plus the domains in path (one per line, # comments allowed)
+func LoadDisposableFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	list := parseList(strings.NewReader(builtinDisposable))
+	for domain := range parseList(f) {
+		list[domain] = true
+	}
+	mu.Lock()
+	disposable = list
+	mu.Unlock()
+	return len(list), nil
+}
+
+// WatchDisposableFile loads path and reloads it whenever its modification
+// time changes, so the list can be updated without a restart
+func WatchDisposableFile(ctx context.Context, path string, interval time.Duration) {
+	var loaded time.Time
+	reload := func() {
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Printf("disposable domains: %v", err)
+			return
+		}
+		if info.ModTime().Equal(loaded) {
+			return
+		}
+		n, err := LoadDisposableFile(path)
+		if err != nil {
+			log.Printf("disposable domains: %v", err)
+			return
+		}
+		loaded = info.ModTime()
+		log.Printf("disposable domains: loaded %d domains from %s", n, path)
+	}
+
+	reload()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reload()
+			}
+		}
+	}()
+}
+
+func parseList(r io.Reader) map[string]bool {
+	set := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if domain := Domain(line); domain != "" {
+			set[domain] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,128 @@
+package imapconn
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+// TLS modes for IMAP connections
+const (
+	TLSModeImplicit = "tls"
+	TLSModeStartTLS = "starttls"
+	TLSModeNone     = "none"
+)
+
+const (
+	dialTimeout    = 10 * time.Second
+	commandTimeout = 2 * time.Minute
+)
+
+// DialFunc opens the TCP connection to the IMAP server
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Config describes an "imap" connection
+type Config struct {
+	Host     string
+	Port     int
+	TLSMode  string
+	Username string
+	Password string
+}
+
+// ConfigFromCredentials reads an imap connection's credentials
+func ConfigFromCredentials(creds map[string]interface{}) (*Config, error) {
+	cfg := &Config{}
+	cfg.Host, _ = creds["host"].(string)
+	cfg.TLSMode, _ = creds["tlsMode"].(string)
+	cfg.Username, _ = creds["username"].(string)
+	cfg.Password, _ = creds["password"].(string)
+	switch v := creds["port"].(type) {
+	case float64:
+		cfg.Port = int(v)
+	case string:
+		cfg.Port, _ = strconv.Atoi(v)
+	}
+
+	if cfg.Host == "" || cfg.Username == "" || cfg.Password == "" {
+		return nil, errors.New("host, username and password are required")
+	}
+	cfg.TLSMode = strings.ToLower(cfg.TLSMode)
+	switch cfg.TLSMode {
+	case "":
+		cfg.TLSMode = TLSModeImplicit
+	case TLSModeImplicit, TLSModeStartTLS, TLSModeNone:
+	default:
+		return nil, fmt.Errorf("unsupported tlsMode %q", cfg.TLSMode)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 993
+		if cfg.TLSMode != TLSModeImplicit {
+			cfg.Port = 143
+		}
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", cfg.Port)
+	}
+	return cfg, nil
+}
+
+// Connect dials the server through dial, negotiates TLS and logs in
+func Connect(ctx context.Context, cfg *Config, dial DialFunc) (*client.Client, error) {
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		dial = dialer.DialContext
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	conn, err := dial(dialCtx, "tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+	if cfg.TLSMode == TLSModeImplicit {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(dialCtx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	c, err := client.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.Timeout = commandTimeout
+
+	if cfg.TLSMode == TLSModeStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Logout()
+			return nil, fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if err := c.Login(cfg.Username, cfg.Password); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("imap authentication failed: %w", err)
+	}
+	return c, nil
+}
+
+// Verify logs in and out again
+func Verify(ctx context.Context, cfg *Config, dial DialFunc) error {
+	c, err := Connect(ctx, cfg, dial)
+	if err != nil {
+		return err
+	}
+	return c.Logout()
+}
@@ -0,0 +1,271 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TLSModeImplicit = "tls"
+	TLSModeStartTLS = "starttls"
+	TLSModeNone     = "none"
+
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCRAMMD5 = "cram-md5"
+	AuthNone    = "none"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// DialFunc opens the TCP connection to the SMTP server
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Config describes how to reach and authenticate against an SMTP server
+type Config struct {
+	Host          string
+	Port          int
+	TLSMode       string
+	AuthMechanism string
+	Username      string
+	Password      string
+	FromAddress   string
+	FromName      string
+
+	// Dial overrides the network dialer, e.g. to apply an egress policy
+	Dial DialFunc
+	// RootCAs replaces the system roots when verifying the server
+	// certificate, e.g. for a relay with a private CA
+	RootCAs *x509.CertPool
+}
+
+// ConfigFromCredentials reads an smtp connection's credentials
+func ConfigFromCredentials(creds map[string]interface{}) (*Config, error) {
+	cfg := &Config{}
+	cfg.Host, _ = creds["host"].(string)
+	cfg.TLSMode, _ = creds["tlsMode"].(string)
+	cfg.AuthMechanism, _ = creds["authMechanism"].(string)
+	cfg.Username, _ = creds["username"].(string)
+	cfg.Password, _ = creds["password"].(string)
+	cfg.FromAddress, _ = creds["fromAddress"].(string)
+	cfg.FromName, _ = creds["fromName"].(string)
+
+	switch port := creds["port"].(type) {
+	case float64:
+		cfg.Port = int(port)
+	case int:
+		cfg.Port = port
+	case string:
+		cfg.Port, _ = strconv.Atoi(port)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate fills defaults and checks the configuration is usable
+func (c *Config) Validate() error {
+	c.Host = strings.TrimSpace(c.Host)
+	if c.Host == "" {
+		return errors.New("smtp host is required")
+	}
+
+	c.TLSMode = strings.ToLower(c.TLSMode)
+	switch c.TLSMode {
+	case "":
+		c.TLSMode = TLSModeStartTLS
+	case TLSModeImplicit, TLSModeStartTLS, TLSModeNone:
+	default:
+		return fmt.Errorf("unsupported smtp tls mode %q", c.TLSMode)
+	}
+
+	if c.Port == 0 {
+		switch c.TLSMode {
+		case TLSModeImplicit:
+			c.Port = 465
+		case TLSModeStartTLS:
+			c.Port = 587
+		default:
+			c.Port = 25
+		}
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("invalid smtp port %d", c.Port)
+	}
+
+	c.AuthMechanism = strings.ToLower(c.AuthMechanism)
+	switch c.AuthMechanism {
+	case "":
+		c.AuthMechanism = AuthPlain
+		if c.Username == "" {
+			c.AuthMechanism = AuthNone
+		}
+	case AuthPlain, AuthLogin, AuthCRAMMD5, AuthNone:
+	default:
+		return fmt.Errorf("unsupported smtp auth mechanism %q", c.AuthMechanism)
+	}
+	if c.AuthMechanism != AuthNone && c.Username == "" {
+		return errors.New("smtp username is required for authentication")
+	}
+
+	return nil
+}
+
+func (c *Config) address() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+func (c *Config) auth() smtp.Auth {
+	switch c.AuthMechanism {
+	case AuthPlain:
+		return smtp.PlainAuth("", c.Username, c.Password, c.Host)
+	case AuthLogin:
+		return &loginAuth{username: c.Username, password: c.Password, host: c.Host}
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(c.Username, c.Password)
+	default:
+		return nil
+	}
+}
+
+// Connect dials the server, negotiates TLS and authenticates
+func Connect(ctx context.Context, cfg *Config) (*smtp.Client, error) {
+	dial := cfg.Dial
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: defaultTimeout}
+		dial = dialer.DialContext
+	}
+
+	conn, err := dial(ctx, "tcp", cfg.address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12, RootCAs: cfg.RootCAs}
+	if cfg.TLSMode == TLSModeImplicit {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("tls handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp handshake failed: %w", err)
+	}
+
+	if cfg.TLSMode == TLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("starttls failed: %w", err)
+		}
+	}
+
+	if auth := cfg.auth(); auth != nil {
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp authentication failed: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// Verify connects and authenticates without sending anything
+func Verify(ctx context.Context, cfg *Config) error {
+	client, err := Connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Send delivers a pre-built RFC 5322 message
+func Send(ctx context.Context, cfg *Config, from string, recipients []string, message []byte) error {
+	client, err := Connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM rejected: %w", err)
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp recipient %s rejected: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// loginAuth implements the non-standard but widespread LOGIN mechanism
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "user"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "pass"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
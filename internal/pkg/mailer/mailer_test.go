@@ -0,0 +1,278 @@
+package mailer_test
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"s4s-backend/internal/pkg/mailer"
+	"s4s-backend/internal/pkg/mailer/mailertest"
+)
+
+const testMessage = "From: sender@example.com\r\nTo: rcpt@example.com\r\nSubject: hi\r\n\r\nhello\r\n.leading dot\r\n"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      mailer.Config
+		wantMode string
+		wantPort int
+		wantAuth string
+		wantErr  string
+	}{
+		{name: "defaults to starttls", cfg: mailer.Config{Host: " smtp.example.com "}, wantMode: "starttls", wantPort: 587, wantAuth: "none"},
+		{name: "implicit tls port", cfg: mailer.Config{Host: "h", TLSMode: "TLS"}, wantMode: "tls", wantPort: 465, wantAuth: "none"},
+		{name: "plain text port", cfg: mailer.Config{Host: "h", TLSMode: "none"}, wantMode: "none", wantPort: 25, wantAuth: "none"},
+		{name: "explicit port kept", cfg: mailer.Config{Host: "h", Port: 2525}, wantMode: "starttls", wantPort: 2525, wantAuth: "none"},
+		{name: "username implies plain auth", cfg: mailer.Config{Host: "h", Username: "u"}, wantMode: "starttls", wantPort: 587, wantAuth: "plain"},
+		{name: "login auth", cfg: mailer.Config{Host: "h", Username: "u", AuthMechanism: "LOGIN"}, wantMode: "starttls", wantPort: 587, wantAuth: "login"},
+		{name: "missing host", cfg: mailer.Config{}, wantErr: "host is required"},
+		{name: "unknown tls mode", cfg: mailer.Config{Host: "h", TLSMode: "ssl3"}, wantErr: "unsupported smtp tls mode"},
+		{name: "port out of range", cfg: mailer.Config{Host: "h", Port: 70000}, wantErr: "invalid smtp port"},
+		{name: "unknown auth", cfg: mailer.Config{Host: "h", Username: "u", AuthMechanism: "xoauth2"}, wantErr: "unsupported smtp auth mechanism"},
+		{name: "auth without username", cfg: mailer.Config{Host: "h", AuthMechanism: "plain"}, wantErr: "username is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate(): %v", err)
+			}
+			if cfg.TLSMode != tt.wantMode || cfg.Port != tt.wantPort || cfg.AuthMechanism != tt.wantAuth {
+				t.Errorf("got mode %q port %d auth %q, want %q %d %q", cfg.TLSMode, cfg.Port, cfg.AuthMechanism, tt.wantMode, tt.wantPort, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestConfigFromCredentials(t *testing.T) {
+	for _, port := range []interface{}{float64(2525), 2525, "2525"} {
+		cfg, err := mailer.ConfigFromCredentials(map[string]interface{}{
+			"host":        "smtp.example.com",
+			"port":        port,
+			"tlsMode":     "tls",
+			"username":    "user",
+			"password":    "secret",
+			"fromAddress": "sender@example.com",
+		})
+		if err != nil {
+			t.Fatalf("port %#v: %v", port, err)
+		}
+		if cfg.Port != 2525 || cfg.TLSMode != "tls" || cfg.AuthMechanism != "plain" || cfg.FromAddress != "sender@example.com" {
+			t.Errorf("port %#v: unexpected config %+v", port, cfg)
+		}
+	}
+	if _, err := mailer.ConfigFromCredentials(map[string]interface{}{"port": 25}); err == nil {
+		t.Error("credentials without host: expected an error")
+	}
+}
+
+// testConfig points a config at server
+func testConfig(server *mailertest.Server, tlsMode, auth string) *mailer.Config {
+	cfg := &mailer.Config{
+		Host:          server.Host,
+		Port:          server.Port,
+		TLSMode:       tlsMode,
+		AuthMechanism: auth,
+		RootCAs:       server.RootCAs,
+	}
+	if auth != mailer.AuthNone {
+		cfg.Username, cfg.Password = "user", "secret"
+	}
+	return cfg
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverTLS string
+		tlsMode   string
+		auth      string
+	}{
+		{name: "plain text", serverTLS: mailertest.TLSNone, tlsMode: mailer.TLSModeNone, auth: mailer.AuthNone},
+		{name: "starttls", serverTLS: mailertest.TLSStartTLS, tlsMode: mailer.TLSModeStartTLS, auth: mailer.AuthNone},
+		{name: "implicit tls", serverTLS: mailertest.TLSImplicit, tlsMode: mailer.TLSModeImplicit, auth: mailer.AuthNone},
+		{name: "starttls with plain auth", serverTLS: mailertest.TLSStartTLS, tlsMode: mailer.TLSModeStartTLS, auth: mailer.AuthPlain},
+		{name: "implicit tls with login auth", serverTLS: mailertest.TLSImplicit, tlsMode: mailer.TLSModeImplicit, auth: mailer.AuthLogin},
+		{name: "cram-md5 auth", serverTLS: mailertest.TLSStartTLS, tlsMode: mailer.TLSModeStartTLS, auth: mailer.AuthCRAMMD5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := mailertest.Options{TLS: tt.serverTLS}
+			if tt.auth != mailer.AuthNone {
+				opts.Username, opts.Password = "user", "secret"
+			}
+			server := mailertest.NewServer(opts)
+			defer server.Close()
+
+			cfg := testConfig(server, tt.tlsMode, tt.auth)
+			recipients := []string{"a@example.com", "b@example.com"}
+			if err := mailer.Send(testContext(t), cfg, "sender@example.com", recipients, []byte(testMessage)); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			messages := server.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("server got %d messages, want 1", len(messages))
+			}
+			msg := messages[0]
+			if msg.From != "sender@example.com" || !slices.Equal(msg.To, recipients) {
+				t.Errorf("envelope %s -> %v", msg.From, msg.To)
+			}
+			if !strings.Contains(string(msg.Data), "\n.leading dot\n") {
+				t.Errorf("dot-stuffed line was not restored: %q", msg.Data)
+			}
+			if wantTLS := tt.tlsMode != mailer.TLSModeNone; msg.TLS != wantTLS {
+				t.Errorf("message TLS = %v, want %v", msg.TLS, wantTLS)
+			}
+			if sawStartTLS := slices.Contains(server.Commands(), "STARTTLS"); sawStartTLS != (tt.tlsMode == mailer.TLSModeStartTLS) {
+				t.Errorf("STARTTLS sent = %v for mode %s", sawStartTLS, tt.tlsMode)
+			}
+			if wantUser := opts.Username; msg.Username != wantUser {
+				t.Errorf("authenticated as %q, want %q", msg.Username, wantUser)
+			}
+		})
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    mailertest.Options
+		setup   func(cfg *mailer.Config)
+		tlsMode string
+		auth    string
+		wantErr string
+	}{
+		{
+			name:    "server without starttls",
+			opts:    mailertest.Options{TLS: mailertest.TLSNone},
+			tlsMode: mailer.TLSModeStartTLS,
+			auth:    mailer.AuthNone,
+			wantErr: "does not support STARTTLS",
+		},
+		{
+			name:    "implicit tls against plain text server",
+			opts:    mailertest.Options{TLS: mailertest.TLSNone},
+			tlsMode: mailer.TLSModeImplicit,
+			auth:    mailer.AuthNone,
+			wantErr: "tls handshake failed",
+		},
+		{
+			name:    "untrusted certificate on starttls",
+			opts:    mailertest.Options{TLS: mailertest.TLSStartTLS},
+			setup:   func(cfg *mailer.Config) { cfg.RootCAs = nil },
+			tlsMode: mailer.TLSModeStartTLS,
+			auth:    mailer.AuthNone,
+			wantErr: "starttls failed",
+		},
+		{
+			name:    "untrusted certificate on implicit tls",
+			opts:    mailertest.Options{TLS: mailertest.TLSImplicit},
+			setup:   func(cfg *mailer.Config) { cfg.RootCAs = nil },
+			tlsMode: mailer.TLSModeImplicit,
+			auth:    mailer.AuthNone,
+			wantErr: "certificate",
+		},
+		{
+			name: "certificate for another host",
+			opts: mailertest.Options{TLS: mailertest.TLSImplicit},
+			setup: func(cfg *mailer.Config) {
+				address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+				cfg.Host = "mail.example.com"
+				cfg.Dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, address)
+				}
+			},
+			tlsMode: mailer.TLSModeImplicit,
+			auth:    mailer.AuthNone,
+			wantErr: "tls handshake failed",
+		},
+		{
+			name:    "wrong password",
+			opts:    mailertest.Options{TLS: mailertest.TLSStartTLS, Username: "user", Password: "other"},
+			tlsMode: mailer.TLSModeStartTLS,
+			auth:    mailer.AuthPlain,
+			wantErr: "smtp authentication failed",
+		},
+		{
+			name:    "authentication required",
+			opts:    mailertest.Options{TLS: mailertest.TLSStartTLS, Username: "user", Password: "secret"},
+			tlsMode: mailer.TLSModeStartTLS,
+			auth:    mailer.AuthNone,
+			wantErr: "MAIL FROM rejected",
+		},
+		{
+			name:    "rejected recipient",
+			opts:    mailertest.Options{RejectRecipients: []string{"b@example.com"}},
+			tlsMode: mailer.TLSModeNone,
+			auth:    mailer.AuthNone,
+			wantErr: "recipient b@example.com rejected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := mailertest.NewServer(tt.opts)
+			defer server.Close()
+
+			cfg := testConfig(server, tt.tlsMode, tt.auth)
+			if tt.setup != nil {
+				tt.setup(cfg)
+			}
+			err := mailer.Send(testContext(t), cfg, "sender@example.com", []string{"a@example.com", "b@example.com"}, []byte(testMessage))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Send() = %v, want error containing %q", err, tt.wantErr)
+			}
+			if n := len(server.Messages()); n != 0 {
+				t.Errorf("server accepted %d messages after a failure", n)
+			}
+		})
+	}
+}
+
+func TestSendConnectionRefused(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{})
+	cfg := testConfig(server, mailer.TLSModeNone, mailer.AuthNone)
+	server.Close()
+
+	err := mailer.Send(testContext(t), cfg, "sender@example.com", []string{"a@example.com"}, []byte(testMessage))
+	if err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Fatalf("Send() = %v, want a connection error", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	server := mailertest.NewServer(mailertest.Options{TLS: mailertest.TLSStartTLS, Username: "user", Password: "secret"})
+	defer server.Close()
+
+	if err := mailer.Verify(testContext(t), testConfig(server, mailer.TLSModeStartTLS, mailer.AuthLogin)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if n := len(server.Messages()); n != 0 {
+		t.Errorf("Verify sent %d messages", n)
+	}
+	if !slices.Contains(server.Commands(), "QUIT") {
+		t.Error("Verify did not QUIT")
+	}
+}
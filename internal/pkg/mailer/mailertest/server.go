@@ -0,0 +1,344 @@
+// Package mailertest provides an in-process SMTP server for tests, in the
+// spirit of net/http/httptest.
+package mailertest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLS modes of the server
+const (
+	TLSNone     = ""
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+)
+
+// Options configure a Server
+type Options struct {
+	// TLS is TLSImplicit to speak TLS from the first byte, TLSStartTLS to
+	// offer STARTTLS, or TLSNone for plain text only
+	TLS string
+	// Username and Password turn on AUTH (PLAIN, LOGIN and CRAM-MD5); MAIL
+	// is then refused until the client authenticates
+	Username string
+	Password string
+	// RejectRecipients are refused at RCPT TO with 550
+	RejectRecipients []string
+}
+
+// Message is one message the server accepted
+type Message struct {
+	From string
+	To   []string
+	// Data is the message as sent, with line endings turned into \n
+	Data []byte
+	// TLS reports whether the message came over an encrypted connection
+	TLS bool
+	// Username is who authenticated, empty without AUTH
+	Username string
+}
+
+// Server is an SMTP server listening on 127.0.0.1. It speaks enough SMTP
+// for net/smtp clients: EHLO, STARTTLS, AUTH, MAIL, RCPT, DATA, RSET, NOOP
+// and QUIT.
+type Server struct {
+	Host string
+	Port int
+	// RootCAs trusts the server's self-signed certificate
+	RootCAs *x509.CertPool
+
+	opts      Options
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	messages []Message
+	commands []string
+}
+
+// NewServer starts a server; it panics if it cannot listen, like
+// httptest.NewServer. Close it when done.
+func NewServer(opts Options) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("mailertest: failed to listen: %v", err))
+	}
+	cert, pool, err := selfSigned()
+	if err != nil {
+		panic(fmt.Sprintf("mailertest: failed to create certificate: %v", err))
+	}
+
+	s := &Server{
+		Host:      "127.0.0.1",
+		Port:      listener.Addr().(*net.TCPAddr).Port,
+		RootCAs:   pool,
+		opts:      opts,
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	go s.serve()
+	return s
+}
+
+// Close stops accepting connections
+func (s *Server) Close() {
+	s.listener.Close()
+}
+
+// Messages returns the messages accepted so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Commands returns the verbs clients sent so far, e.g. EHLO, STARTTLS, AUTH
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// session is the state of one client connection
+type session struct {
+	conn     net.Conn
+	text     *textproto.Conn
+	tls      bool
+	username string
+	from     string
+	to       []string
+}
+
+func (s *Server) handle(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	c := &session{conn: conn}
+	if s.opts.TLS == TLSImplicit {
+		tlsConn := tls.Server(conn, s.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return
+		}
+		c.conn, c.tls = tlsConn, true
+	}
+	c.text = textproto.NewConn(c.conn)
+	defer func() { c.text.Close() }()
+
+	c.text.PrintfLine("220 mailertest ESMTP ready")
+	for {
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		s.mu.Lock()
+		s.commands = append(s.commands, verb)
+		s.mu.Unlock()
+
+		switch verb {
+		case "EHLO", "HELO":
+			s.hello(c)
+		case "STARTTLS":
+			if s.opts.TLS != TLSStartTLS || c.tls {
+				c.text.PrintfLine("502 STARTTLS not available")
+				continue
+			}
+			c.text.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(c.conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			c.conn, c.text, c.tls = tlsConn, textproto.NewConn(tlsConn), true
+			c.username, c.from, c.to = "", "", nil
+		case "AUTH":
+			if username, ok := s.auth(c, arg); ok {
+				c.username = username
+				c.text.PrintfLine("235 authenticated")
+			} else {
+				c.text.PrintfLine("535 authentication credentials invalid")
+			}
+		case "MAIL":
+			if s.opts.Username != "" && c.username == "" {
+				c.text.PrintfLine("530 authentication required")
+				continue
+			}
+			c.from, c.to = address(arg), nil
+			c.text.PrintfLine("250 ok")
+		case "RCPT":
+			rcpt := address(arg)
+			switch {
+			case c.from == "":
+				c.text.PrintfLine("503 MAIL first")
+			case s.rejected(rcpt):
+				c.text.PrintfLine("550 no such user %s", rcpt)
+			default:
+				c.to = append(c.to, rcpt)
+				c.text.PrintfLine("250 ok")
+			}
+		case "DATA":
+			if len(c.to) == 0 {
+				c.text.PrintfLine("503 RCPT first")
+				continue
+			}
+			c.text.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := c.text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, Message{From: c.from, To: c.to, Data: data, TLS: c.tls, Username: c.username})
+			s.mu.Unlock()
+			c.from, c.to = "", nil
+			c.text.PrintfLine("250 queued")
+		case "RSET":
+			c.from, c.to = "", nil
+			c.text.PrintfLine("250 ok")
+		case "NOOP":
+			c.text.PrintfLine("250 ok")
+		case "QUIT":
+			c.text.PrintfLine("221 bye")
+			return
+		default:
+			c.text.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+func (s *Server) hello(c *session) {
+	lines := []string{"mailertest", "8BITMIME"}
+	if s.opts.TLS == TLSStartTLS && !c.tls {
+		lines = append(lines, "STARTTLS")
+	}
+	if s.opts.Username != "" {
+		lines = append(lines, "AUTH PLAIN LOGIN CRAM-MD5")
+	}
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		c.text.PrintfLine("250%s%s", sep, line)
+	}
+}
+
+// auth runs an AUTH exchange and returns the user name on success
+func (s *Server) auth(c *session, arg string) (string, bool) {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		if initial == "" {
+			c.text.PrintfLine("334 ")
+			initial, _ = c.text.ReadLine()
+		}
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		if err != nil {
+			return "", false
+		}
+		parts := strings.Split(string(decoded), "\x00")
+		if len(parts) != 3 {
+			return "", false
+		}
+		return parts[1], parts[1] == s.opts.Username && parts[2] == s.opts.Password
+	case "LOGIN":
+		username := s.prompt(c, "Username:")
+		password := s.prompt(c, "Password:")
+		return username, username == s.opts.Username && password == s.opts.Password
+	case "CRAM-MD5":
+		challenge := fmt.Sprintf("<%d@mailertest>", time.Now().UnixNano())
+		response := s.prompt(c, challenge)
+		username, digest, _ := strings.Cut(response, " ")
+		mac := hmac.New(md5.New, []byte(s.opts.Password))
+		mac.Write([]byte(challenge))
+		return username, username == s.opts.Username && digest == hex.EncodeToString(mac.Sum(nil))
+	default:
+		return "", false
+	}
+}
+
+// prompt sends a base64 challenge and returns the decoded answer
+func (s *Server) prompt(c *session, challenge string) string {
+	c.text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return ""
+	}
+	decoded, _ := base64.StdEncoding.DecodeString(line)
+	return string(decoded)
+}
+
+func (s *Server) rejected(rcpt string) bool {
+	for _, rejected := range s.opts.RejectRecipients {
+		if strings.EqualFold(rejected, rcpt) {
+			return true
+		}
+	}
+	return false
+}
+
+// address extracts the path of "FROM:<a@b> BODY=8BITMIME" or "TO:<a@b>"
+func address(arg string) string {
+	start := strings.IndexByte(arg, '<')
+	end := strings.IndexByte(arg, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// selfSigned creates a certificate for 127.0.0.1 and localhost and a pool
+// trusting it
+func selfSigned() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mailertest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool, nil
+}
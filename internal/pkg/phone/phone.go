@@ -0,0 +1,223 @@
+package phone
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// region describes national numbering for one country: its calling code,
+// the trunk prefix dialled before national numbers, and the allowed lengths
+// of the national significant number
+type region struct {
+	code       string
+	trunk      string
+	minDigits  int
+	maxDigits  int
+	codePrefix string // numbers within a shared calling code, e.g. "7" Kazakhstan vs Russia
+}
+
+// regions is keyed by ISO 3166-1 alpha-2 code
+var regions = map[string]region{
+	"US": {code: "1", minDigits: 10, maxDigits: 10},
+	"CA": {code: "1", minDigits: 10, maxDigits: 10},
+	"RU": {code: "7", trunk: "8", minDigits: 10, maxDigits: 10},
+	"KZ": {code: "7", trunk: "8", minDigits: 10, maxDigits: 10, codePrefix: "7"},
+	"BY": {code: "375", trunk: "80", minDigits: 9, maxDigits: 9},
+	"UA": {code: "380", trunk: "0", minDigits: 9, maxDigits: 9},
+	"UZ": {code: "998", trunk: "8", minDigits: 9, maxDigits: 9},
+	"KG": {code: "996", trunk: "0", minDigits: 9, maxDigits: 9},
+	"AM": {code: "374", trunk: "0", minDigits: 8, maxDigits: 8},
+	"GE": {code: "995", trunk: "0", minDigits: 9, maxDigits: 9},
+	"AZ": {code: "994", trunk: "0", minDigits: 9, maxDigits: 9},
+	"MD": {code: "373", trunk: "0", minDigits: 8, maxDigits: 8},
+	"GB": {code: "44", trunk: "0", minDigits: 9, maxDigits: 10},
+	"IE": {code: "353", trunk: "0", minDigits: 7, maxDigits: 9},
+	"DE": {code: "49", trunk: "0", minDigits: 6, maxDigits: 13},
+	"AT": {code: "43", trunk: "0", minDigits: 4, maxDigits: 13},
+	"CH": {code: "41", trunk: "0", minDigits: 9, maxDigits: 9},
+	"FR": {code: "33", trunk: "0", minDigits: 9, maxDigits: 9},
+	"BE": {code: "32", trunk: "0", minDigits: 8, maxDigits: 9},
+	"NL": {code: "31", trunk: "0", minDigits: 9, maxDigits: 9},
+	"LU": {code: "352", minDigits: 4, maxDigits: 11},
+	"ES": {code: "34", minDigits: 9, maxDigits: 9},
+	"PT": {code: "351", minDigits: 9, maxDigits: 9},
+	"IT": {code: "39", minDigits: 6, maxDigits: 11},
+	"GR": {code: "30", minDigits: 10, maxDigits: 10},
+	"PL": {code: "48", minDigits: 9, maxDigits: 9},
+	"CZ": {code: "420", minDigits: 9, maxDigits: 9},
+	"SK": {code: "421", trunk: "0", minDigits: 9, maxDigits: 9},
+	"HU": {code: "36", trunk: "06", minDigits: 8, maxDigits: 9},
+	"RO": {code: "40", trunk: "0", minDigits: 9, maxDigits: 9},
+	"BG": {code: "359", trunk: "0", minDigits: 8, maxDigits: 9},
+	"RS": {code: "381", trunk: "0", minDigits: 8, maxDigits: 9},
+	"HR": {code: "385", trunk: "0", minDigits: 8, maxDigits: 9},
+	"SI": {code: "386", trunk: "0", minDigits: 8, maxDigits: 8},
+	"DK": {code: "45", minDigits: 8, maxDigits: 8},
+	"NO": {code: "47", minDigits: 8, maxDigits: 8},
+	"SE": {code: "46", trunk: "0", minDigits: 7, maxDigits: 9},
+	"FI": {code: "358", trunk: "0", minDigits: 5, maxDigits: 10},
+	"EE": {code: "372", minDigits: 7, maxDigits: 8},
+	"LV": {code: "371", minDigits: 8, maxDigits: 8},
+	"LT": {code: "370", trunk: "8", minDigits: 8, maxDigits: 8},
+	"TR": {code: "90", trunk: "0", minDigits: 10, maxDigits: 10},
+	"IL": {code: "972", trunk: "0", minDigits: 8, maxDigits: 9},
+	"AE": {code: "971", trunk: "0", minDigits: 8, maxDigits: 9},
+	"SA": {code: "966", trunk: "0", minDigits: 8, maxDigits: 9},
+	"EG": {code: "20", trunk: "0", minDigits: 9, maxDigits: 10},
+	"ZA": {code: "27", trunk: "0", minDigits: 9, maxDigits: 9},
+	"NG": {code: "234", trunk: "0", minDigits: 8, maxDigits: 10},
+	"KE": {code: "254", trunk: "0", minDigits: 9, maxDigits: 9},
+	"IN": {code: "91", trunk: "0", minDigits: 10, maxDigits: 10},
+	"PK": {code: "92", trunk: "0", minDigits: 9, maxDigits: 10},
+	"CN": {code: "86", trunk: "0", minDigits: 9, maxDigits: 11},
+	"HK": {code: "852", minDigits: 8, maxDigits: 8},
+	"JP": {code: "81", trunk: "0", minDigits: 9, maxDigits: 10},
+	"KR": {code: "82", trunk: "0", minDigits: 8, maxDigits: 10},
+	"SG": {code: "65", minDigits: 8, maxDigits: 8},
+	"MY": {code: "60", trunk: "0", minDigits: 8, maxDigits: 10},
+	"TH": {code: "66", trunk: "0", minDigits: 8, maxDigits: 9},
+	"VN": {code: "84", trunk: "0", minDigits: 9, maxDigits: 10},
+	"ID": {code: "62", trunk: "0", minDigits: 8, maxDigits: 12},
+	"PH": {code: "63", trunk: "0", minDigits: 8, maxDigits: 10},
+	"AU": {code: "61", trunk: "0", minDigits: 9, maxDigits: 9},
+	"NZ": {code: "64", trunk: "0", minDigits: 8, maxDigits: 10},
+	"BR": {code: "55", trunk: "0", minDigits: 10, maxDigits: 11},
+	"AR": {code: "54", trunk: "0", minDigits: 10, maxDigits: 11},
+	"MX": {code: "52", minDigits: 10, maxDigits: 10},
+	"CL": {code: "56", minDigits: 9, maxDigits: 9},
+	"CO": {code: "57", minDigits: 10, maxDigits: 10},
+	"PE": {code: "51", trunk: "0", minDigits: 8, maxDigits: 9},
+}
+
+// countryNames maps common spellings to region codes so a CRM "country"
+// field can be used as the hint directly
+var countryNames = map[string]string{
+	"usa": "US", "united states": "US", "canada": "CA", "russia": "RU", "россия": "RU",
+	"kazakhstan": "KZ", "казахстан": "KZ", "belarus": "BY", "беларусь": "BY",
+	"ukraine": "UA", "украина": "UA", "uk": "GB", "united kingdom": "GB",
+	"great britain": "GB", "germany": "DE", "deutschland": "DE", "france": "FR",
+	"spain": "ES", "italy": "IT", "netherlands": "NL", "poland": "PL", "turkey": "TR",
+	"india": "IN", "china": "CN", "japan": "JP", "australia": "AU", "brazil": "BR",
+}
+
+// ErrInvalid is returned for numbers that cannot be normalized
+var ErrInvalid = errors.New("invalid phone number")
+
+// Region returns the ISO code for a country hint such as "de", "DE" or "Germany"
+func Region(hint string) string {
+	hint = strings.TrimSpace(hint)
+	if code, ok := countryNames[strings.ToLower(hint)]; ok {
+		return code
+	}
+	code := strings.ToUpper(hint)
+	if _, ok := regions[code]; ok {
+		return code
+	}
+	return ""
+}
+
+// Normalize converts a phone number to E.164 (+4930123456) and returns the
+// region it belongs to, if known. Numbers written without an international
+// prefix are read as national numbers of the country hint.
+func Normalize(raw, countryHint string) (string, string, error) {
+	raw = strings.TrimSpace(raw)
+	// drop extensions such as "ext. 12" or "доб. 12"
+	for _, marker := range []string{"ext", "доб", "x", "#"} {
+		if i := strings.Index(strings.ToLower(raw), marker); i > 0 {
+			raw = raw[:i]
+		}
+	}
+
+	// "+49 (0)30 ..." marks the trunk prefix that is dropped when dialling from abroad
+	if strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "00") {
+		raw = strings.Replace(raw, "(0)", "", 1)
+	}
+
+	var digits strings.Builder
+	international := false
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && digits.Len() == 0 && !international:
+			international = true
+		case strings.ContainsRune(" -(). /", r):
+		default:
+			return "", "", ErrInvalid
+		}
+	}
+	number := digits.String()
+	if strings.HasPrefix(number, "00") && !international {
+		international = true
+		number = number[2:]
+	}
+
+	hint := Region(countryHint)
+	if !international {
+		if hint == "" {
+			return "", "", errors.New("phone number has no country code and no country hint")
+		}
+		r := regions[hint]
+		switch {
+		case len(number) == len(r.code)+r.maxDigits && strings.HasPrefix(number, r.code) && r.trunk != r.code:
+			// national number already carrying the calling code, e.g. 79161234567
+			number = number[len(r.code):]
+		case r.trunk != "" && strings.HasPrefix(number, r.trunk) && len(number)-len(r.trunk) >= r.minDigits:
+			number = number[len(r.trunk):]
+		}
+		number = r.code + number
+	}
+
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", "", ErrInvalid
+	}
+	region := regionOf(number, hint)
+	if region != "" {
+		r := regions[region]
+		national := len(number) - len(r.code)
+		if national < r.minDigits || national > r.maxDigits {
+			return "", "", ErrInvalid
+		}
+	}
+	return "+" + number, region, nil
+}
+
+// regionOf picks the region for an international number: the hint if its
+// calling code matches, else the region with the longest matching code
+func regionOf(number, hint string) string {
+	if r, ok := regions[hint]; ok && strings.HasPrefix(number, r.code) && matchesPrefix(number, r) {
+		return hint
+	}
+	codes := make([]string, 0, len(regions))
+	for code := range regions {
+		codes = append(codes, code)
+	}
+	// prefer longer calling codes and regions with a specific number prefix,
+	// then the first in alphabetical order for a stable answer
+	sort.Slice(codes, func(i, j int) bool {
+		a, b := regions[codes[i]], regions[codes[j]]
+		if len(a.code) != len(b.code) {
+			return len(a.code) > len(b.code)
+		}
+		if len(a.codePrefix) != len(b.codePrefix) {
+			return len(a.codePrefix) > len(b.codePrefix)
+		}
+		return codes[i] < codes[j]
+	})
+	for _, code := range codes {
+		r := regions[code]
+		if strings.HasPrefix(number, r.code) && matchesPrefix(number, r) {
+			if r.code == "1" {
+				// NANP numbers cannot be told apart by prefix alone
+				return "US"
+			}
+			return code
+		}
+	}
+	return ""
+}
+
+func matchesPrefix(number string, r region) bool {
+	return r.codePrefix == "" || strings.HasPrefix(number[len(r.code):], r.codePrefix)
+}
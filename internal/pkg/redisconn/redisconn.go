@@ -0,0 +1,124 @@
+package redisconn
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	dialTimeout = 10 * time.Second
+	poolSize    = 5
+)
+
+// DialFunc opens the TCP connection to the Redis server
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Config describes a "redis" connection
+type Config struct {
+	Options *redis.Options
+
+	// KeyPrefix is prepended to every key the workflow uses
+	KeyPrefix string
+}
+
+// ConfigFromCredentials reads a redis connection's credentials. Either "url"
+// (redis:// or rediss://) or host, port, username, password, db and tls are
+// accepted, plus an optional keyPrefix.
+func ConfigFromCredentials(creds map[string]interface{}) (*Config, error) {
+	cfg := &Config{}
+	cfg.KeyPrefix, _ = creds["keyPrefix"].(string)
+
+	if raw, _ := creds["url"].(string); raw != "" {
+		opts, err := redis.ParseURL(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url: %w", err)
+		}
+		if opts.Network != "tcp" {
+			return nil, errors.New("only redis:// and rediss:// urls are supported")
+		}
+		cfg.Options = opts
+	} else {
+		host, _ := creds["host"].(string)
+		if host == "" {
+			return nil, errors.New("url or host is required")
+		}
+		port := 6379
+		switch v := creds["port"].(type) {
+		case float64:
+			port = int(v)
+		case string:
+			if v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid port %q", v)
+				}
+				port = n
+			}
+		}
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+
+		opts := &redis.Options{Addr: net.JoinHostPort(host, strconv.Itoa(port))}
+		opts.Username, _ = creds["username"].(string)
+		opts.Password, _ = creds["password"].(string)
+		if db, ok := creds["db"].(float64); ok {
+			opts.DB = int(db)
+		}
+		if useTLS, _ := creds["tls"].(bool); useTLS {
+			opts.TLSConfig = &tls.Config{ServerName: host}
+		}
+		cfg.Options = opts
+	}
+
+	if cfg.Options.DB < 0 || cfg.Options.DB > 15 {
+		return nil, fmt.Errorf("invalid db %d", cfg.Options.DB)
+	}
+	return cfg, nil
+}
+
+// NewClient creates a client with a small pool. Connections are opened
+// through dial so callers can enforce an egress policy.
+func NewClient(cfg *Config, dial DialFunc) *redis.Client {
+	opts := *cfg.Options
+	opts.PoolSize = poolSize
+	opts.DialTimeout = dialTimeout
+	opts.ContextTimeoutEnabled = true
+	if opts.TLSConfig != nil && opts.TLSConfig.MinVersion == 0 {
+		tlsConfig := opts.TLSConfig.Clone()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		opts.TLSConfig = tlsConfig
+	}
+
+	if dial != nil {
+		tlsConfig := opts.TLSConfig
+		opts.Dialer = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil || tlsConfig == nil {
+				return conn, err
+			}
+			// a custom dialer replaces go-redis's TLS handling
+			tlsConn := tls.Client(conn, tlsConfig)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	}
+	return redis.NewClient(&opts)
+}
+
+// Ping connects and checks the server answers
+func Ping(ctx context.Context, cfg *Config, dial DialFunc) error {
+	client := NewClient(cfg, dial)
+	defer client.Close()
+	return client.Ping(ctx).Err()
+}
@@ -0,0 +1,326 @@
+package scoring
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"s4s-backend/internal/pkg/emaildomains"
+)
+
+// MaxRules bounds the number of rules in one rule set
+const MaxRules = 200
+
+// Rule adds Points to a lead's score when the value at Field (a dotted path
+// such as "company.size") satisfies Operator against Value
+type Rule struct {
+	Name     string      `json:"name,omitempty"`
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value,omitempty"`
+	Points   float64     `json:"points"`
+}
+
+// Grade is assigned to scores of at least Min
+type Grade struct {
+	Grade string  `json:"grade"`
+	Min   float64 `json:"min"`
+}
+
+// DefaultGrades are used when a rule set defines none
+var DefaultGrades = []Grade{
+	{Grade: "A", Min: 60},
+	{Grade: "B", Min: 30},
+	{Grade: "C", Min: 0},
+}
+
+// Operators lists the supported rule operators. Comparisons of text are
+// case-insensitive; a missing field is treated as empty.
+var Operators = map[string]bool{
+	"equals": true, "not_equals": true,
+	"contains": true, "not_contains": true,
+	"starts_with": true, "ends_with": true,
+	"in": true, "not_in": true,
+	"gt": true, "gte": true, "lt": true, "lte": true, "between": true,
+	"exists": true, "not_exists": true, "matches": true,
+	"free_email": true, "not_free_email": true,
+}
+
+// RuleSet is a compiled list of rules and grade thresholds
+type RuleSet struct {
+	rules    []Rule
+	patterns map[int]*regexp.Regexp
+	grades   []Grade
+}
+
+// Match is a rule that fired for a lead
+type Match struct {
+	Rule   string      `json:"rule"`
+	Field  string      `json:"field"`
+	Value  interface{} `json:"value"`
+	Points float64     `json:"points"`
+}
+
+// Result is a lead's score, its grade and the rules that contributed
+type Result struct {
+	Score   float64 `json:"score"`
+	Grade   string  `json:"grade"`
+	Matched []Match `json:"matched"`
+}
+
+// Compile validates rules and grades. Rules without a name are named after
+// their condition, e.g. "country not_in [RU BY KZ]".
+func Compile(rules []Rule, grades []Grade) (*RuleSet, error) {
+	if len(rules) == 0 {
+		return nil, errors.New("at least one rule is required")
+	}
+	if len(rules) > MaxRules {
+		return nil, fmt.Errorf("a rule set may have at most %d rules", MaxRules)
+	}
+
+	set := &RuleSet{rules: make([]Rule, len(rules)), patterns: make(map[int]*regexp.Regexp)}
+	for i, rule := range rules {
+		rule.Field = strings.TrimSpace(rule.Field)
+		rule.Operator = strings.ToLower(strings.TrimSpace(rule.Operator))
+		if rule.Field == "" {
+			return nil, fmt.Errorf("rule %d: field is required", i+1)
+		}
+		if !Operators[rule.Operator] {
+			return nil, fmt.Errorf("rule %d: unknown operator %q", i+1, rule.Operator)
+		}
+
+		switch rule.Operator {
+		case "in", "not_in":
+			if _, ok := rule.Value.([]interface{}); !ok {
+				return nil, fmt.Errorf("rule %d: %s needs a list value", i+1, rule.Operator)
+			}
+		case "gt", "gte", "lt", "lte":
+			if _, ok := number(rule.Value); !ok {
+				return nil, fmt.Errorf("rule %d: %s needs a numeric value", i+1, rule.Operator)
+			}
+		case "between":
+			bounds, ok := rule.Value.([]interface{})
+			if !ok || len(bounds) != 2 {
+				return nil, fmt.Errorf("rule %d: between needs a [min, max] value", i+1)
+			}
+			for _, bound := range bounds {
+				if _, ok := number(bound); !ok {
+					return nil, fmt.Errorf("rule %d: between needs a [min, max] value", i+1)
+				}
+			}
+		case "matches":
+			pattern, err := regexp.Compile("(?i)" + text(rule.Value))
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid pattern: %w", i+1, err)
+			}
+			set.patterns[i] = pattern
+		}
+
+		if rule.Name == "" {
+			rule.Name = rule.Field + " " + rule.Operator
+			if rule.Value != nil {
+				rule.Name += fmt.Sprintf(" %v", rule.Value)
+			}
+		}
+		set.rules[i] = rule
+	}
+
+	if len(grades) == 0 {
+		set.grades = DefaultGrades
+	} else {
+		set.grades = append([]Grade(nil), grades...)
+		for i, grade := range set.grades {
+			if strings.TrimSpace(grade.Grade) == "" {
+				return nil, fmt.Errorf("grade %d: name is required", i+1)
+			}
+		}
+		sort.SliceStable(set.grades, func(i, j int) bool { return set.grades[i].Min > set.grades[j].Min })
+	}
+	return set, nil
+}
+
+// Score evaluates every rule against lead. Scores below the lowest grade's
+// minimum get that lowest grade.
+func (s *RuleSet) Score(lead map[string]interface{}) Result {
+	result := Result{Matched: []Match{}}
+	for i, rule := range s.rules {
+		value, _ := lookup(lead, rule.Field)
+		if !s.matches(i, rule, value) {
+			continue
+		}
+		result.Score += rule.Points
+		result.Matched = append(result.Matched, Match{Rule: rule.Name, Field: rule.Field, Value: value, Points: rule.Points})
+	}
+
+	result.Grade = s.grades[len(s.grades)-1].Grade
+	for _, grade := range s.grades {
+		if result.Score >= grade.Min {
+			result.Grade = grade.Grade
+			break
+		}
+	}
+	return result
+}
+
+// FirstMatch returns the index of the first rule whose condition holds for
+// lead, or -1. It lets rule lists be used for routing as well as scoring.
+func (s *RuleSet) FirstMatch(lead map[string]interface{}) int {
+	for i, rule := range s.rules {
+		value, _ := lookup(lead, rule.Field)
+		if s.matches(i, rule, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *RuleSet) matches(i int, rule Rule, value interface{}) bool {
+	switch rule.Operator {
+	case "equals":
+		return equal(value, rule.Value)
+	case "not_equals":
+		return !equal(value, rule.Value)
+	case "contains":
+		return contains(value, rule.Value)
+	case "not_contains":
+		return !contains(value, rule.Value)
+	case "starts_with":
+		return strings.HasPrefix(lower(value), lower(rule.Value))
+	case "ends_with":
+		return strings.HasSuffix(lower(value), lower(rule.Value))
+	case "in", "not_in":
+		found := false
+		for _, candidate := range rule.Value.([]interface{}) {
+			if equal(value, candidate) {
+				found = true
+				break
+			}
+		}
+		return found == (rule.Operator == "in")
+	case "gt", "gte", "lt", "lte":
+		n, ok := number(value)
+		if !ok {
+			return false
+		}
+		limit, _ := number(rule.Value)
+		switch rule.Operator {
+		case "gt":
+			return n > limit
+		case "gte":
+			return n >= limit
+		case "lt":
+			return n < limit
+		default:
+			return n <= limit
+		}
+	case "between":
+		n, ok := number(value)
+		if !ok {
+			return false
+		}
+		bounds := rule.Value.([]interface{})
+		low, _ := number(bounds[0])
+		high, _ := number(bounds[1])
+		return n >= low && n <= high
+	case "exists":
+		return !empty(value)
+	case "not_exists":
+		return empty(value)
+	case "matches":
+		return s.patterns[i].MatchString(text(value))
+	case "free_email":
+		return !empty(value) && emaildomains.IsFreeMail(text(value))
+	case "not_free_email":
+		return !empty(value) && !emaildomains.IsFreeMail(text(value))
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return x == y
+		}
+	}
+	return lower(a) == lower(b)
+}
+
+// contains checks list membership for list fields and substrings otherwise
+func contains(value, needle interface{}) bool {
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if equal(item, needle) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(lower(value), lower(needle))
+}
+
+func empty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func lower(value interface{}) string {
+	return strings.ToLower(strings.TrimSpace(text(value)))
+}
+
+// lookup reads a dotted path such as "company.size"; a key containing dots
+// is matched as a whole first
+func lookup(data map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := data[path]; ok {
+		return v, true
+	}
+	var current interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,50 @@
+package tracking
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLinksVerify(t *testing.T) {
+	links := NewLinks("https://api.example.com/api/v1/", "0123456789abcdef0123456789abcdef")
+	id := "11111111-1111-1111-1111-111111111111"
+
+	unsubscribe, err := url.Parse(links.UnsubscribeURL(id))
+	if err != nil {
+		t.Fatalf("UnsubscribeURL: %v", err)
+	}
+	if unsubscribe.Path != "/api/v1/t/u/"+id {
+		t.Errorf("unsubscribe path = %s", unsubscribe.Path)
+	}
+	if !links.Verify(KindUnsubscribe, id, "", unsubscribe.Query().Get("s")) {
+		t.Error("unsubscribe link does not verify")
+	}
+
+	click, err := url.Parse(links.ClickURL(id, "https://shop.example.com/?a=1&b=2"))
+	if err != nil {
+		t.Fatalf("ClickURL: %v", err)
+	}
+	signature := click.Query().Get("s")
+	if !links.Verify(KindClick, id, click.Query().Get("u"), signature) {
+		t.Error("click link does not verify")
+	}
+
+	tests := []struct {
+		name                        string
+		links                       *Links
+		kind, id, target, signature string
+	}{
+		{name: "other target", links: links, kind: KindClick, id: id, target: "https://evil.example.com/", signature: signature},
+		{name: "other kind", links: links, kind: KindUnsubscribe, id: id, signature: signature},
+		{name: "other id", links: links, kind: KindUnsubscribe, id: strings.Replace(id, "1", "2", 1), signature: unsubscribe.Query().Get("s")},
+		{name: "other secret", links: NewLinks(links.BaseURL, "fedcba9876543210fedcba9876543210"), kind: KindUnsubscribe, id: id, signature: unsubscribe.Query().Get("s")},
+		{name: "empty signature", links: links, kind: KindUnsubscribe, id: id},
+		{name: "no secret", links: NewLinks(links.BaseURL, ""), kind: KindUnsubscribe, id: id, signature: NewLinks(links.BaseURL, "").Sign(KindUnsubscribe, id, "")},
+	}
+	for _, tt := range tests {
+		if tt.links.Verify(tt.kind, tt.id, tt.target, tt.signature) {
+			t.Errorf("%s: link verified", tt.name)
+		}
+	}
+}
@@ -3,10 +3,18 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -67,3 +75,46 @@ func Decrypt(encryptedText, key string) (string, error) {
 
 	return string(ciphertext), nil
 }
+
+// hashes are the digest algorithms accepted by Hash and HMAC
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+func hashFunc(algorithm string) (func() hash.Hash, error) {
+	name := strings.ReplaceAll(strings.ToLower(algorithm), "-", "")
+	if name == "" {
+		name = "sha256"
+	}
+	fn, ok := hashes[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", algorithm)
+	}
+	return fn, nil
+}
+
+// Hash returns the digest of data; algorithm is md5, sha1, sha256 (default), sha384 or sha512
+func Hash(algorithm string, data []byte) ([]byte, error) {
+	fn, err := hashFunc(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := fn()
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// HMAC returns the keyed digest of data using the algorithms accepted by Hash
+func HMAC(algorithm string, key, data []byte) ([]byte, error) {
+	fn, err := hashFunc(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(fn, key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
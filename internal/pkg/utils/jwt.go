@@ -2,6 +2,8 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -44,3 +46,24 @@ func ValidateJWT(tokenString, secret string) (*Claims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// SignJWT signs arbitrary claims. HS256, HS384 and HS512 take a []byte
+// secret; RS*, PS* and ES* take the matching private key.
+func SignJWT(claims map[string]interface{}, algorithm string, key interface{}, headers map[string]interface{}) (string, error) {
+	if algorithm == "" {
+		algorithm = "HS256"
+	}
+	method := jwt.GetSigningMethod(strings.ToUpper(algorithm))
+	if method == nil || method == jwt.SigningMethodNone {
+		return "", fmt.Errorf("unsupported jwt algorithm %q", algorithm)
+	}
+
+	token := jwt.NewWithClaims(method, jwt.MapClaims(claims))
+	for name, value := range headers {
+		if name == "alg" || name == "typ" {
+			continue
+		}
+		token.Header[name] = value
+	}
+	return token.SignedString(key)
+}
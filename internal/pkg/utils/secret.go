@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Secret is the key material of a "secret" connection
+type Secret struct {
+	// Key is the shared secret used for HMAC, HS* JWTs and AES encryption
+	Key []byte
+
+	// PrivateKey is an RSA, ECDSA or Ed25519 key used for RS*, PS*, ES* and EdDSA JWTs
+	PrivateKey crypto.Signer
+}
+
+// SecretFromCredentials reads a secret connection's credentials: "secret"
+// with its "encoding" (text, base64 or hex; default text) and/or a PEM
+// "privateKey". At least one of them is required.
+func SecretFromCredentials(creds map[string]interface{}) (*Secret, error) {
+	s := &Secret{}
+
+	if raw, _ := creds["secret"].(string); raw != "" {
+		encoding, _ := creds["encoding"].(string)
+		var err error
+		switch encoding {
+		case "", "text":
+			s.Key = []byte(raw)
+		case "base64":
+			if s.Key, err = base64.StdEncoding.DecodeString(raw); err != nil {
+				s.Key, err = base64.RawURLEncoding.DecodeString(raw)
+			}
+		case "hex":
+			s.Key, err = hex.DecodeString(raw)
+		default:
+			return nil, fmt.Errorf("unsupported encoding %q", encoding)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("secret is not valid %s", encoding)
+		}
+	}
+
+	if raw, _ := creds["privateKey"].(string); raw != "" {
+		key, err := parsePrivateKey([]byte(raw))
+		if err != nil {
+			return nil, err
+		}
+		s.PrivateKey = key
+	}
+
+	if s.Key == nil && s.PrivateKey == nil {
+		return nil, errors.New("secret or privateKey is required")
+	}
+	return s, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("privateKey must be PEM encoded")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("privateKey is not a supported RSA, ECDSA or Ed25519 key")
+}